@@ -21,15 +21,18 @@
 package application
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	golog "log"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/reinit/coward/common/config"
 	"github.com/reinit/coward/common/logger"
@@ -46,6 +49,7 @@ type Application interface {
 	ExecuteArgumentInput(parameters []string) error
 	ExecuteParameter(
 		execCfg ExecuteConfig, name string, parameter string) error
+	ExecuteParameterGroup(execCfg ExecuteConfig, parameter string) error
 	ExecuteConfiguration(
 		execCfg ExecuteConfig, name string, config interface{}) error
 }
@@ -219,21 +223,42 @@ func (c *application) Help() error {
 	if c.logger == nil {
 		printer.Writeln([]byte(fmt.Sprintf(
 			helpUsageSlient,
-			strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+11, 1)
+			strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
 		printer.Writeln([]byte(fmt.Sprintf(
 			helpUsageDebug,
-			strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+11, 1)
+			strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
 		printer.Writeln([]byte(fmt.Sprintf(
 			helpUsageLog,
-			strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+11, 1)
+			strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
+		printer.Writeln([]byte(fmt.Sprintf(
+			helpUsageLogFmt,
+			strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
+		printer.Writeln([]byte(fmt.Sprintf(
+			helpUsageLogSize,
+			strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
+		printer.Writeln([]byte(fmt.Sprintf(
+			helpUsageLogAge,
+			strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
+		printer.Writeln([]byte(fmt.Sprintf(
+			helpUsageLogKeep,
+			strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
 	}
 
+	printer.Writeln([]byte(fmt.Sprintf(
+		helpUsageLevel,
+		strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
 	printer.Writeln([]byte(fmt.Sprintf(
 		helpUsageDaemon,
-		strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+11, 1)
+		strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
+	printer.Writeln([]byte(fmt.Sprintf(
+		helpUsageDrain,
+		strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
 	printer.Writeln([]byte(fmt.Sprintf(
 		helpUsageParam,
-		strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+11, 1)
+		strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
+	printer.Writeln([]byte(fmt.Sprintf(
+		helpUsageRoles,
+		strings.Repeat(" ", helpItemSpaceLen))), 4, helpItemSpaceLen+12, 1)
 
 	printer.Write([]byte("\r\n\r\n"))
 
@@ -272,17 +297,41 @@ func (c *application) loadConfigurationFromFile(
 	return data, nil
 }
 
+// updateLogLevels updates the log level rules. When the rules are started
+// with "@", they will be loaded from the file specified after it
+func (c *application) updateLogLevels(
+	levels *logger.Levels, rules string) error {
+	if !strings.HasPrefix(rules, "@") {
+		return levels.Update(rules)
+	}
+
+	data, loadErr := c.loadConfigurationFromFile(rules[1:])
+
+	if loadErr != nil {
+		return loadErr
+	}
+
+	return levels.Update(strings.Replace(string(data), "\n", ",", -1))
+}
+
 func (c *application) buildRunConfigFromParam(
 	parameters []string) (ExecuteConfig, int, error) {
 	breakLoop := false
 	result := ExecuteConfig{
-		Daemom:    false,
-		Slient:    false,
-		Debug:     false,
-		LogFile:   "",
-		ParamFile: "",
-		Shutdown:  nil,
-		Booted:    nil,
+		Daemom:       false,
+		Slient:       false,
+		Debug:        false,
+		LogFile:      "",
+		LogFormat:    LogFormatText,
+		LogMaxSize:   0,
+		LogMaxAge:    0,
+		LogRetention: 0,
+		LogLevel:     "",
+		ParamFile:    "",
+		RolesFile:    "",
+		DrainTimeout: defaultDrainTimeout,
+		Shutdown:     nil,
+		Booted:       nil,
 	}
 	lastIdx := 0
 	paramLen := len(parameters)
@@ -290,6 +339,11 @@ func (c *application) buildRunConfigFromParam(
 
 	for {
 		if lastIdx > paramLastIdx {
+			// Role name is not needed when Roles are loaded from file
+			if result.RolesFile != "" {
+				break
+			}
+
 			return ExecuteConfig{}, 0, ErrExecuteOptionEndedBeforeRoleName
 		}
 
@@ -325,11 +379,120 @@ func (c *application) buildRunConfigFromParam(
 				return ExecuteConfig{}, 0, ErrLogFileMustBeSpecified
 			}
 
+		case c.logger == nil && trimedParam == "-logfmt":
+			if lastIdx+1 >= paramLen {
+				return ExecuteConfig{}, 0, ErrLogFormatInvalid
+			}
+
+			lastIdx++
+
+			result.LogFormat = strings.ToLower(
+				strings.TrimSpace(parameters[lastIdx]))
+
+			switch result.LogFormat {
+			case LogFormatText:
+			case LogFormatJSON:
+			case LogFormatSyslog:
+			case LogFormatJournald:
+			default:
+				return ExecuteConfig{}, 0, ErrLogFormatInvalid
+			}
+
+		case c.logger == nil && trimedParam == "-logsize":
+			if lastIdx+1 >= paramLen {
+				return ExecuteConfig{}, 0, ErrLogSizeMustBeSpecified
+			}
+
+			lastIdx++
+
+			logSize, logSizeErr := strconv.ParseUint(
+				strings.TrimSpace(parameters[lastIdx]), 10, 32)
+
+			if logSizeErr != nil {
+				return ExecuteConfig{}, 0, ErrLogSizeMustBeSpecified
+			}
+
+			result.LogMaxSize = int64(logSize) * 1024 * 1024
+
+		case c.logger == nil && trimedParam == "-logage":
+			if lastIdx+1 >= paramLen {
+				return ExecuteConfig{}, 0, ErrLogAgeMustBeSpecified
+			}
+
+			lastIdx++
+
+			logAge, logAgeErr := strconv.ParseUint(
+				strings.TrimSpace(parameters[lastIdx]), 10, 16)
+
+			if logAgeErr != nil {
+				return ExecuteConfig{}, 0, ErrLogAgeMustBeSpecified
+			}
+
+			result.LogMaxAge = time.Duration(logAge) * time.Hour
+
+		case c.logger == nil && trimedParam == "-logkeep":
+			if lastIdx+1 >= paramLen {
+				return ExecuteConfig{}, 0, ErrLogKeepMustBeSpecified
+			}
+
+			lastIdx++
+
+			logKeep, logKeepErr := strconv.ParseUint(
+				strings.TrimSpace(parameters[lastIdx]), 10, 16)
+
+			if logKeepErr != nil {
+				return ExecuteConfig{}, 0, ErrLogKeepMustBeSpecified
+			}
+
+			result.LogRetention = uint16(logKeep)
+
+		case trimedParam == "-level":
+			if lastIdx+1 >= paramLen {
+				return ExecuteConfig{}, 0, ErrLogLevelMustBeSpecified
+			}
+
+			lastIdx++
+
+			result.LogLevel = strings.TrimSpace(parameters[lastIdx])
+
+			if result.LogLevel == "" {
+				return ExecuteConfig{}, 0, ErrLogLevelMustBeSpecified
+			}
+
 		case c.logger == nil && trimedParam == "-daemon":
 			fallthrough
 		case c.logger == nil && trimedParam == "-d":
 			result.Daemom = true
 
+		case trimedParam == "-roles":
+			if lastIdx+1 >= paramLen {
+				return ExecuteConfig{}, 0, ErrRolesFileMustBeSpecified
+			}
+
+			lastIdx++
+
+			result.RolesFile = strings.TrimSpace(parameters[lastIdx])
+
+			if result.RolesFile == "" {
+				return ExecuteConfig{}, 0, ErrRolesFileMustBeSpecified
+			}
+
+		case trimedParam == "-drain":
+			if lastIdx+1 >= paramLen {
+				return ExecuteConfig{}, 0, ErrDrainTimeoutMustBeSpecified
+			}
+
+			lastIdx++
+
+			drainTimeout, drainTimeoutErr := strconv.ParseUint(
+				strings.TrimSpace(parameters[lastIdx]), 10, 16)
+
+			if drainTimeoutErr != nil {
+				return ExecuteConfig{}, 0, ErrDrainTimeoutMustBeSpecified
+			}
+
+			result.DrainTimeout = time.Duration(drainTimeout) * time.Second
+
 		case trimedParam == "-param":
 			fallthrough
 		case trimedParam == "-p":
@@ -401,10 +564,19 @@ func (c *application) escapeArgumentString(
 func (c *application) run(
 	init func() (ExecuteConfig, int, error),
 	exec func(cfg ExecuteConfig, roleParamStart int, p print.Printer) error,
+) error {
+	return c.runTo(os.Stdout, init, exec)
+}
+
+// runTo is run but the messages will be written to w
+func (c *application) runTo(
+	w io.Writer,
+	init func() (ExecuteConfig, int, error),
+	exec func(cfg ExecuteConfig, roleParamStart int, p print.Printer) error,
 ) error {
 	var err error
 
-	printer := c.printer.Printer(os.Stdout)
+	printer := c.printer.Printer(w)
 
 	defer func() {
 		if err != nil {
@@ -461,6 +633,22 @@ func (c *application) run(
 }
 
 func (c *application) ExecuteArgumentInput(parameters []string) error {
+	if len(parameters) > 0 {
+		switch strings.ToLower(strings.TrimSpace(parameters[0])) {
+		case CommandValidate:
+			return c.validate(parameters[1:])
+
+		case CommandDump:
+			return c.dump(parameters[1:])
+
+		case CommandDiff:
+			return c.diff(parameters[1:])
+
+		case CommandCompletion:
+			return c.completion(parameters[1:])
+		}
+	}
+
 	return c.run(func() (ExecuteConfig, int, error) {
 		exeCfg, roleParamStart, exeCfgErr := c.buildRunConfigFromParam(
 			parameters)
@@ -475,6 +663,37 @@ func (c *application) ExecuteArgumentInput(parameters []string) error {
 		roleParamStart int,
 		printer print.Printer,
 	) error {
+		if execCfg.RolesFile != "" {
+			if roleParamStart < len(parameters) || execCfg.ParamFile != "" {
+				return ErrRolesFileWithRole
+			}
+
+			return c.execute(
+				printer,
+				func(log logger.Logger) (role.Role, error) {
+					param, err := c.loadConfigurationFromFile(
+						execCfg.RolesFile)
+
+					if err != nil {
+						return nil, err
+					}
+
+					return c.roles.InitParameterGroup(printer, param, log)
+				},
+				execCfg,
+			)
+		}
+
+		if roleParamStart+1 < len(parameters) && strings.ToLower(
+			strings.TrimSpace(parameters[roleParamStart+1])) == CommandInit {
+			if execCfg.ParamFile != "" {
+				return ErrInitWithParameterFile
+			}
+
+			return c.init(printer, parameters[roleParamStart],
+				parameters[roleParamStart+2:])
+		}
+
 		cmdParam := c.escapeArgumentString(parameters, roleParamStart+1)
 
 		return c.execute(
@@ -483,6 +702,26 @@ func (c *application) ExecuteArgumentInput(parameters []string) error {
 				var param []byte
 				var err error
 
+				docFormat := config.DocumentFormat(execCfg.ParamFile)
+
+				if docFormat != "" {
+					param, err = c.loadConfigurationFromFile(
+						execCfg.ParamFile)
+
+					if err != nil {
+						return nil, err
+					}
+
+					return c.roles.InitDocument(
+						printer,
+						parameters[roleParamStart],
+						docFormat,
+						param,
+						cmdParam,
+						log,
+					)
+				}
+
 				if execCfg.ParamFile != "" {
 					param, err = c.loadConfigurationFromFile(
 						execCfg.ParamFile)
@@ -528,6 +767,26 @@ func (c *application) ExecuteParameter(
 	})
 }
 
+func (c *application) ExecuteParameterGroup(
+	exeCfg ExecuteConfig, parameter string) error {
+	return c.run(func() (ExecuteConfig, int, error) {
+		return exeCfg, 0, nil
+	}, func(
+		execCfg ExecuteConfig,
+		roleParamStart int,
+		printer print.Printer,
+	) error {
+		return c.execute(
+			printer,
+			func(log logger.Logger) (role.Role, error) {
+				return c.roles.InitParameterGroup(
+					printer, []byte(parameter), log)
+			},
+			execCfg,
+		)
+	})
+}
+
 func (c *application) ExecuteConfiguration(
 	exeCfg ExecuteConfig, name string, config interface{}) error {
 	return c.run(func() (ExecuteConfig, int, error) {
@@ -553,6 +812,8 @@ func (c *application) execute(
 	config ExecuteConfig,
 ) error {
 	var log logger.Logger
+	var logFile logger.Rotater
+	var levels *logger.Levels
 
 	// Buffer 1 for close notify because the shutdown function
 	// or `Unspawn` will try to write it. But since no one is
@@ -560,39 +821,110 @@ func (c *application) execute(
 	// So it's necessary to buffer it so the method will return and we
 	// can read it afterwards
 	closedNotify := make(role.UnspawnNotifier, 1)
-	signals := make(chan os.Signal)
+	signals := make(chan os.Signal, 1)
 	breakLoop := false
 
-	if c.logger == nil {
-		if config.LogFile == "" {
-			if config.Slient {
-				log = logger.NewDitch()
-			} else if config.Debug {
-				log = logger.NewScreen(printer)
-			} else {
-				log = logger.NewScreenNonDebug(printer)
-			}
-		} else {
-			file, fileErr := os.Create(config.LogFile)
+	if c.logger == nil && config.LogFile != "" &&
+		config.LogFormat != LogFormatSyslog &&
+		config.LogFormat != LogFormatJournald {
+		rotater, rotaterErr := logger.NewRotate(
+			config.LogFile, logger.RotateConfig{
+				MaxSize:       config.LogMaxSize,
+				MaxAge:        config.LogMaxAge,
+				Retention:     config.LogRetention,
+				FlushInterval: logFlushInterval,
+			})
+
+		if rotaterErr != nil {
+			return rotaterErr
+		}
 
-			if fileErr != nil {
-				return fileErr
-			}
+		defer rotater.Close()
+
+		logFile = rotater
+	}
 
-			defer file.Close()
+	// Loggers must write messages of all levels when they are filtered by
+	// the log level rules
+	debug := config.Debug || config.LogLevel != ""
 
-			bFile := bufio.NewWriter(file)
+	if c.logger != nil {
+		log = c.logger
+	} else if config.LogFormat == LogFormatSyslog ||
+		config.LogFormat == LogFormatJournald {
+		socketPath := config.LogFile
+
+		if socketPath == "" && config.LogFormat == LogFormatSyslog {
+			socketPath = logger.SyslogSocket
+		} else if socketPath == "" {
+			socketPath = logger.JournaldSocket
+		}
 
-			defer bFile.Flush()
+		socket, socketErr := logger.DialUnixgram(socketPath)
 
-			if config.Debug {
-				log = logger.NewWrite(rw.NewMutexedWriter(bFile))
-			} else {
-				log = logger.NewWriteNonDebug(rw.NewMutexedWriter(bFile))
-			}
+		if socketErr != nil {
+			return socketErr
+		}
+
+		defer socket.Close()
+
+		switch {
+		case config.LogFormat == LogFormatSyslog && debug:
+			log = logger.NewSyslog(socket, logIdentifier)
+
+		case config.LogFormat == LogFormatSyslog:
+			log = logger.NewSyslogNonDebug(socket, logIdentifier)
+
+		case debug:
+			log = logger.NewJournald(socket, logIdentifier)
+
+		default:
+			log = logger.NewJournaldNonDebug(socket, logIdentifier)
+		}
+	} else if config.LogFormat == LogFormatJSON &&
+		(config.LogFile != "" || !config.Slient) {
+		// Rotater is already safe for concurrent use
+		var logWriter io.Writer = logFile
+
+		if logFile == nil {
+			logWriter = rw.NewMutexedWriter(os.Stdout)
+		}
+
+		if debug {
+			log = logger.NewJSON(logWriter)
+		} else {
+			log = logger.NewJSONNonDebug(logWriter)
+		}
+	} else if config.LogFile == "" {
+		if config.Slient {
+			log = logger.NewDitch()
+		} else if debug {
+			log = logger.NewScreen(printer)
+		} else {
+			log = logger.NewScreenNonDebug(printer)
 		}
+	} else if debug {
+		log = logger.NewWrite(logFile)
 	} else {
-		log = c.logger
+		log = logger.NewWriteNonDebug(logFile)
+	}
+
+	if config.LogLevel != "" {
+		levelBase := logger.Info
+
+		if config.Debug {
+			levelBase = logger.Debug
+		}
+
+		levels = logger.NewLevels(levelBase)
+
+		levelErr := c.updateLogLevels(levels, config.LogLevel)
+
+		if levelErr != nil {
+			return levelErr
+		}
+
+		log = logger.NewLeveled(log, levels)
 	}
 
 	golog.SetOutput(log)
@@ -603,39 +935,47 @@ func (c *application) execute(
 	// channel, then there will be no need for monitering os signals as
 	// the Shutdown channel is designed for integration
 	if config.Shutdown == nil {
-		signal.Notify(
-			signals, syscall.SIGINT,
-			syscall.SIGTERM, syscall.SIGHUP)
+		signal.Notify(signals, append([]os.Signal{
+			syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP,
+		}, reopenSignals...)...)
 
 		defer signal.Stop(signals)
 	}
 
-	for {
-		if breakLoop {
-			break
-		}
+	// Roles which have been replaced by a reload will be drained and
+	// closed in background, wait for them before return
+	draining := sync.WaitGroup{}
 
-		r, rErr := roleGen(log)
+	defer draining.Wait()
 
-		if rErr != nil {
-			return rErr
-		}
+	r, rErr := roleGen(log)
 
-		spawnErr := r.Spawn(closedNotify)
+	if rErr != nil {
+		return rErr
+	}
 
-		if spawnErr != nil {
-			r.Unspawn()
+	spawnErr := r.Spawn(closedNotify)
 
-			<-closedNotify
+	if spawnErr != nil {
+		r.Unspawn()
 
-			return spawnErr
-		}
+		<-closedNotify
 
-		select {
-		case config.Booted <- true:
-		default:
+		return spawnErr
+	}
+
+	select {
+	case config.Booted <- true:
+	default:
+	}
+
+	for {
+		if breakLoop {
+			break
 		}
 
+		reload := false
+
 		select {
 		case sig := <-signals:
 			switch sig {
@@ -645,37 +985,48 @@ func (c *application) execute(
 
 			case syscall.SIGTERM:
 				breakLoop = true
-				fallthrough
 
 			case syscall.SIGHUP:
 				if !config.Daemom {
 					breakLoop = true
+				} else {
+					reload = true
 				}
 
-				unspawnErr := r.Unspawn()
+			default:
+				// Only reopen signals are left
+				if logFile != nil {
+					reopenErr := logFile.Reopen()
 
-				if unspawnErr != nil {
-					return unspawnErr
+					if reopenErr != nil {
+						log.Errorf("Failed to reopen log file due to "+
+							"error: %s", reopenErr)
+					} else {
+						log.Infof("Log file has been reopened")
+					}
 				}
 
-				<-closedNotify
-			}
-
-		case <-closedNotify:
-			breakLoop = true
+				// Files written by the Role, such as the access logs
+				reopener, isReopener := r.(role.Reopener)
 
-			unspawnErr := r.Unspawn()
+				if isReopener && reopener.Reopen() == nil {
+					log.Infof("Role files have been reopened")
+				}
 
-			if unspawnErr != nil {
-				return unspawnErr
+				continue
 			}
 
-			<-closedNotify
+		case <-closedNotify:
+			breakLoop = true
 
 		case breakLoop = <-config.Shutdown:
 			// When shutdown channel send true, we shutdown
 			// the application, otherwise the application will
 			// be just reloaded
+			reload = !breakLoop
+		}
+
+		if !reload {
 			unspawnErr := r.Unspawn()
 
 			if unspawnErr != nil {
@@ -683,8 +1034,120 @@ func (c *application) execute(
 			}
 
 			<-closedNotify
+
+			continue
+		}
+
+		// Log level rules loaded from a file will be reloaded as well
+		if levels != nil && strings.HasPrefix(config.LogLevel, "@") {
+			levelErr := c.updateLogLevels(levels, config.LogLevel)
+
+			if levelErr != nil {
+				log.Errorf("Failed to reload log levels due to error: %s",
+					levelErr)
+			}
+		}
+
+		newRole, newNotify, reloadErr := c.reload(
+			log, roleGen, r, closedNotify, config.DrainTimeout, &draining)
+
+		if reloadErr != nil {
+			return reloadErr
+		}
+
+		r = newRole
+		closedNotify = newNotify
+
+		select {
+		case config.Booted <- true:
+		default:
 		}
 	}
 
 	return nil
 }
+
+// reload replaces the running Role with a new one generated from current
+// configuration. The new configuration is verified before anything is
+// changed, and if it's invalid, the running Role will be kept.
+//
+// The new Role is spawned before the old one is closed so there is no gap
+// of service (Listeners are created with port reusing). The old Role will
+// then be drained and closed in background. If the new Role can't be spawned
+// while the old one is running (i.e. the Proxy datagram port which can't be
+// shared), the old Role will be closed first and then the new Role will be
+// spawned again
+func (c *application) reload(
+	log logger.Logger,
+	roleGen func(log logger.Logger) (role.Role, error),
+	current role.Role,
+	currentNotify role.UnspawnNotifier,
+	drainTimeout time.Duration,
+	draining *sync.WaitGroup,
+) (role.Role, role.UnspawnNotifier, error) {
+	newRole, newRoleErr := roleGen(log)
+
+	if newRoleErr != nil {
+		log.Errorf("Reload refused, new configuration is invalid: %s",
+			newRoleErr)
+
+		return current, currentNotify, nil
+	}
+
+	newNotify := make(role.UnspawnNotifier, 1)
+
+	spawnErr := newRole.Spawn(newNotify)
+
+	if spawnErr == nil {
+		draining.Add(1)
+
+		go func() {
+			defer draining.Done()
+
+			drainer, isDrainer := current.(role.Drainer)
+
+			if isDrainer {
+				drainer.Drain(drainTimeout)
+			}
+
+			current.Unspawn()
+
+			<-currentNotify
+		}()
+
+		return newRole, newNotify, nil
+	}
+
+	newRole.Unspawn()
+
+	<-newNotify
+
+	log.Warningf("Failed to spawn new Role alongside the running one due to "+
+		"error: %s. Closing the running Role before retry", spawnErr)
+
+	unspawnErr := current.Unspawn()
+
+	if unspawnErr != nil {
+		return nil, nil, unspawnErr
+	}
+
+	<-currentNotify
+
+	newRole, newRoleErr = roleGen(log)
+
+	if newRoleErr != nil {
+		return nil, nil, newRoleErr
+	}
+
+	spawnErr = newRole.Spawn(newNotify)
+
+	if spawnErr != nil {
+		newRole.Unspawn()
+
+		<-newNotify
+
+		return nil, nil, spawnErr
+	}
+
+	return newRole, newNotify, nil
+}
@@ -0,0 +1,302 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package application
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Shells which completion scripts can be generated for
+const (
+	CompletionBash = "bash"
+	CompletionZsh  = "zsh"
+	CompletionFish = "fish"
+)
+
+// completionComplete is the argument for the completion scripts to ask for
+// candidates
+const completionComplete = "-complete"
+
+// Completion errors
+var (
+	ErrCompletionUnknownShell = errors.New(
+		"Shell must be one of \"bash\", \"zsh\" or \"fish\"")
+)
+
+// Completion scripts. They call back to the application with the words
+// of the command line, and fall back to the file completion when there is
+// no candidate
+const (
+	completionScriptBash = `# bash completion for <Name>, load it with:
+#   source <(<Name> completion bash)
+_coward_complete() {
+    local IFS=$'\n'
+
+    COMPREPLY=($("${COMP_WORDS[0]}" completion -complete \
+        "${COMP_WORDS[@]:1:$COMP_CWORD}" 2>/dev/null))
+}
+
+complete -o default -F _coward_complete <Name>
+`
+
+	completionScriptZsh = `#compdef <Name>
+# zsh completion for <Name>, load it with:
+#   source <(<Name> completion zsh)
+_coward_complete() {
+    local -a candidates
+
+    candidates=("${(@f)$("${words[1]}" completion -complete \
+        "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+
+    if [[ -n "${candidates[1]}" ]]; then
+        compadd -S ' ' -a candidates
+    else
+        _files
+    fi
+}
+
+compdef _coward_complete <Name>
+`
+
+	completionScriptFish = `# fish completion for <Name>, load it with:
+#   <Name> completion fish | source
+function __coward_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    set -l candidates ($tokens[1] completion -complete $tokens[2..-1] \
+        2>/dev/null)
+
+    if test (count $candidates) -eq 0
+        __fish_complete_path (commandline -ct)
+
+        return
+    end
+
+    printf '%s\n' $candidates
+end
+
+complete -c <Name> -f -a '(__coward_complete)'
+`
+)
+
+// completion prints the completion script of a shell, or the candidates
+// of the last word when it's called by the script
+func (c *application) completion(parameters []string) error {
+	if len(parameters) > 0 && parameters[0] == completionComplete {
+		for _, candidate := range c.complete(parameters[1:]) {
+			os.Stdout.Write([]byte(candidate + "\n"))
+		}
+
+		return nil
+	}
+
+	if len(parameters) != 1 {
+		return c.completionError(ErrCompletionUnknownShell)
+	}
+
+	script := ""
+
+	switch strings.ToLower(strings.TrimSpace(parameters[0])) {
+	case CompletionBash:
+		script = completionScriptBash
+
+	case CompletionZsh:
+		script = completionScriptZsh
+
+	case CompletionFish:
+		script = completionScriptFish
+
+	default:
+		return c.completionError(ErrCompletionUnknownShell)
+	}
+
+	_, err := os.Stdout.Write(
+		[]byte(strings.Replace(script, "<Name>", c.exeName, -1)))
+
+	return err
+}
+
+// completionError prints the error to the stderr
+func (c *application) completionError(err error) error {
+	printer := c.printer.Printer(os.Stderr)
+
+	printer.Writeln([]byte("<ERR> Command has failed due to error: "+
+		err.Error()), 1, 7, 1)
+
+	return err
+}
+
+// executeOptions returns the Execute Options and whether they require a
+// value
+func (c *application) executeOptions() map[string]bool {
+	options := map[string]bool{
+		"-level": true,
+		"-roles": true,
+		"-drain": true,
+		"-param": true,
+		"-p":     true,
+	}
+
+	if c.logger != nil {
+		return options
+	}
+
+	options["-slient"] = false
+	options["-s"] = false
+	options["-debug"] = false
+	options["-daemon"] = false
+	options["-d"] = false
+	options["-log"] = true
+	options["-l"] = true
+	options["-logfmt"] = true
+	options["-logsize"] = true
+	options["-logage"] = true
+	options["-logkeep"] = true
+
+	return options
+}
+
+// complete returns the candidates of the last word of the arguments
+func (c *application) complete(words []string) []string {
+	if len(words) <= 0 {
+		return nil
+	}
+
+	last := len(words) - 1
+	start := 0
+
+	if last > 0 {
+		switch strings.ToLower(words[0]) {
+		case CommandValidate:
+			fallthrough
+		case CommandDump:
+			start = 1
+
+		case CommandDiff:
+			if last != 1 {
+				return nil
+			}
+
+			return completeFilter(
+				append(c.roles.Names(), "-roles"), words[last])
+
+		case CommandCompletion:
+			if last != 1 {
+				return nil
+			}
+
+			return completeFilter([]string{
+				CompletionBash, CompletionZsh, CompletionFish,
+			}, words[last])
+		}
+	}
+
+	options := c.executeOptions()
+
+	for wIdx := start; wIdx < last; wIdx++ {
+		option := strings.ToLower(words[wIdx])
+		requireValue, isOption := options[option]
+
+		if isOption && !requireValue {
+			continue
+		}
+
+		if isOption {
+			wIdx++
+
+			if wIdx < last {
+				continue
+			}
+
+			return c.completeOptionValue(option, words[last])
+		}
+
+		if strings.HasPrefix(option, "-") {
+			continue
+		}
+
+		// Found the Role, the rest of the words are Role Options
+		if start == 0 && wIdx+1 == last &&
+			!strings.HasPrefix(words[last], "-") {
+			return completeFilter([]string{CommandInit}, words[last])
+		}
+
+		return c.roles.Complete(words[wIdx], words[wIdx+1:])
+	}
+
+	if strings.HasPrefix(words[last], "-") {
+		candidates := make([]string, 0, len(options))
+
+		for option := range options {
+			candidates = append(candidates, option)
+		}
+
+		return completeFilter(candidates, words[last])
+	}
+
+	candidates := c.roles.Names()
+
+	if last == 0 {
+		candidates = append(candidates,
+			CommandValidate, CommandDump, CommandDiff, CommandCompletion)
+	}
+
+	return completeFilter(candidates, words[last])
+}
+
+// completeOptionValue returns the candidates of the value of an Execute
+// Option. Options which require a file will get no candidate so the shell
+// can complete the file path instead
+func (c *application) completeOptionValue(
+	option string, word string) []string {
+	switch option {
+	case "-logfmt":
+		return completeFilter([]string{
+			LogFormatText, LogFormatJSON, LogFormatSyslog, LogFormatJournald,
+		}, word)
+
+	case "-level":
+		return completeFilter([]string{
+			"debug", "info", "warning", "error",
+		}, word)
+	}
+
+	return nil
+}
+
+// completeFilter returns candidates which start with the prefix, sorted
+func completeFilter(candidates []string, prefix string) []string {
+	result := make([]string, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		if !strings.HasPrefix(candidate, prefix) {
+			continue
+		}
+
+		result = append(result, candidate)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
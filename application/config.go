@@ -20,6 +20,8 @@
 
 package application
 
+import "time"
+
 // SignalChan is something will be used for sending and
 // receiving signals
 type SignalChan chan bool
@@ -27,6 +29,24 @@ type SignalChan chan bool
 // SignalReceiveChan is read only SignalChan
 type SignalReceiveChan chan<- bool
 
+// Log formats
+const (
+	LogFormatText     = "text"
+	LogFormatJSON     = "json"
+	LogFormatSyslog   = "syslog"
+	LogFormatJournald = "journald"
+)
+
+// Commands which inspect or generate the Role configurations without
+// running them
+const (
+	CommandValidate   = "validate"
+	CommandDump       = "dump"
+	CommandDiff       = "diff"
+	CommandInit       = "init"
+	CommandCompletion = "completion"
+)
+
 // Config is the COWARD config
 type Config struct {
 	Banner     string
@@ -39,11 +59,18 @@ type Config struct {
 
 // ExecuteConfig is command config of current COWARD application instance
 type ExecuteConfig struct {
-	Daemom    bool
-	Slient    bool
-	Debug     bool
-	LogFile   string
-	ParamFile string
-	Shutdown  SignalChan
-	Booted    SignalReceiveChan
+	Daemom       bool
+	Slient       bool
+	Debug        bool
+	LogFile      string
+	LogFormat    string
+	LogMaxSize   int64
+	LogMaxAge    time.Duration
+	LogRetention uint16
+	LogLevel     string
+	ParamFile    string
+	RolesFile    string
+	DrainTimeout time.Duration
+	Shutdown     SignalChan
+	Booted       SignalReceiveChan
 }
@@ -20,7 +20,10 @@
 
 package application
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 const (
 	aboutBanner = `
@@ -31,13 +34,52 @@ const (
 	aboutPoweredByBanner = ` Powered by <COWARD:Name> v.<COWARD:Version>`
 
 	helpUsage = "Usage:\r\n\r\n" +
-		"%s [Execute Options ...] <Role> [Role Options ...]\r\n"
+		"%[1]s [Execute Options ...] <Role> [Role Options ...]\r\n" +
+		"%[1]s [Execute Options ...] -roles <File>\r\n\r\n" +
+		"Check the configuration without running it:\r\n\r\n" +
+		"%[1]s validate|dump [Execute Options ...] <Role> " +
+		"[Role Options ...]\r\n" +
+		"%[1]s validate|dump [Execute Options ...] -roles <File>\r\n" +
+		"%[1]s diff <Role> <File> <File>\r\n" +
+		"%[1]s diff -roles <File> <File>\r\n\r\n" +
+		"Generate a parameter file by answering questions:\r\n\r\n" +
+		"%[1]s <Role> init [File]\r\n\r\n" +
+		"Print the completion script of bash, zsh or fish:\r\n\r\n" +
+		"%[1]s completion bash|zsh|fish\r\n"
+
+	helpUsageSlient = `-slient %sDisable output`
+	helpUsageDebug  = `-debug  %sEnable debug output`
+	helpUsageDaemon = `-daemon %sRun as daemon`
+	helpUsageLog    = `-log    %sWrite log to a file. Send SIGUSR1 to ` +
+		`reopen the file after it has been moved away`
+	helpUsageLogFmt = `-logfmt %sFormat of the log, "text" (default), ` +
+		`"json" for one JSON object per line, "syslog" to send RFC 5424 ` +
+		`messages to the local syslog socket, or "journald" to send ` +
+		`entries to the local journald socket. The socket path can be ` +
+		`changed with -log`
+	helpUsageLogSize = `-logsize%sRotate the log file before it grows ` +
+		`larger than this many megabytes`
+	helpUsageLogAge = `-logage %sRotate the log file after it has been ` +
+		`written for this many hours`
+	helpUsageLogKeep = `-logkeep%sHow many rotated log files to keep, ` +
+		`0 (default) to keep all of them`
+	helpUsageLevel = `-level  %sMinimal log level ("debug", "info", ` +
+		`"warning" or "error") of given contexts, for example ` +
+		`"Proxy > Server=debug,Workers=warning". Rules can be loaded from ` +
+		`a file by specifying "@<File>", and the file will be loaded again ` +
+		`when the Roles are reloaded`
+	helpUsageParam = `-param  %sLoad Role Options from a file. Files ` +
+		`ending with .json, .yaml, .yml or .toml are loaded as documents ` +
+		`whose keys are the JSON names of the Role Options`
+	helpUsageRoles = `-roles  %sLoad and run multiple Roles from a file`
+	helpUsageDrain = `-drain  %sSeconds to wait for ongoing connections to ` +
+		`complete before the old Role is closed during a reload`
+
+	defaultDrainTimeout = 30 * time.Second
 
-	helpUsageSlient = `-slient%sDisable output`
-	helpUsageDebug  = `-debug %sEnable debug output`
-	helpUsageDaemon = `-daemon%sRun as daemon`
-	helpUsageLog    = `-log   %sWrite log to a file`
-	helpUsageParam  = `-param %sLoad Role Options from a file`
+	logFlushInterval = 1 * time.Second
+
+	logIdentifier = "coward"
 )
 
 // COWARD application errors
@@ -45,9 +87,35 @@ var (
 	ErrLogFileMustBeSpecified = errors.New(
 		"Log file must be specified")
 
+	ErrLogFormatInvalid = errors.New(
+		"Log format must be one of \"text\", \"json\", \"syslog\" or " +
+			"\"journald\"")
+
+	ErrLogSizeMustBeSpecified = errors.New(
+		"Log size must be specified as megabytes")
+
+	ErrLogAgeMustBeSpecified = errors.New(
+		"Log age must be specified as hours")
+
+	ErrLogLevelMustBeSpecified = errors.New(
+		"Log level rules must be specified")
+
+	ErrLogKeepMustBeSpecified = errors.New(
+		"Amount of rotated log files to keep must be specified")
+
 	ErrConfigFileMustBeSpecified = errors.New(
 		"Configuration file must be specified")
 
+	ErrRolesFileMustBeSpecified = errors.New(
+		"Roles file must be specified")
+
+	ErrRolesFileWithRole = errors.New(
+		"Role and Parameter File can't be specified when Roles are " +
+			"loaded from a file")
+
+	ErrDrainTimeoutMustBeSpecified = errors.New(
+		"Drain timeout must be specified as seconds")
+
 	ErrUnknownExecuteOption = errors.New(
 		"At least one of the Execute Option is unknown")
 
@@ -59,6 +127,21 @@ var (
 
 	ErrParameterFileEmpty = errors.New(
 		"Parameter file is empty")
+
+	ErrDiffFilesMustBeSpecified = errors.New(
+		"Two configuration files must be specified for comparison")
+
+	ErrConfigurationsAreDifferent = errors.New(
+		"Configurations are different")
+
+	ErrInitWithParameterFile = errors.New(
+		"Parameter File can't be specified when generating a new one")
+
+	ErrInitTooManyArguments = errors.New(
+		"Only the path of the Parameter File can be specified after init")
+
+	ErrParameterFileExisted = errors.New(
+		"Parameter File already existed")
 )
 
 // Changeable declaration data like version etc
@@ -0,0 +1,274 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/reinit/coward/common/config"
+	"github.com/reinit/coward/common/print"
+)
+
+// configure parses and verifies the Role configuration specified by the
+// arguments without initializing the Role
+func (c *application) configure(
+	printer print.Printer,
+	execCfg ExecuteConfig,
+	parameters []string,
+	roleParamStart int,
+) (config.Exported, error) {
+	if execCfg.RolesFile != "" {
+		if roleParamStart < len(parameters) || execCfg.ParamFile != "" {
+			return nil, ErrRolesFileWithRole
+		}
+
+		return c.configureFile(printer, "", execCfg.RolesFile)
+	}
+
+	var param []byte
+	var err error
+
+	name := parameters[roleParamStart]
+	cmdParam := c.escapeArgumentString(parameters, roleParamStart+1)
+	docFormat := config.DocumentFormat(execCfg.ParamFile)
+
+	if docFormat != "" {
+		param, err = c.loadConfigurationFromFile(execCfg.ParamFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return c.roles.ConfigureDocument(
+			printer, name, docFormat, param, cmdParam)
+	}
+
+	if execCfg.ParamFile != "" {
+		param, err = c.loadConfigurationFromFile(execCfg.ParamFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		param = append(param, []byte("\r\n")...)
+	}
+
+	return c.roles.Configure(printer, name, append(param, cmdParam...))
+}
+
+// configureFile parses and verifies the Role configuration in a file. The
+// file will be loaded as a Roles file when the name is empty
+func (c *application) configureFile(
+	printer print.Printer,
+	name string,
+	path string,
+) (config.Exported, error) {
+	param, err := c.loadConfigurationFromFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		return c.roles.ConfigureParameterGroup(printer, param)
+	}
+
+	docFormat := config.DocumentFormat(path)
+
+	if docFormat != "" {
+		return c.roles.ConfigureDocument(printer, name, docFormat, param, nil)
+	}
+
+	return c.roles.Configure(printer, name, param)
+}
+
+// validate parses and verifies the Role configuration without running it
+func (c *application) validate(parameters []string) error {
+	return c.run(func() (ExecuteConfig, int, error) {
+		return c.buildRunConfigFromParam(parameters)
+	}, func(
+		execCfg ExecuteConfig,
+		roleParamStart int,
+		printer print.Printer,
+	) error {
+		_, err := c.configure(printer, execCfg, parameters, roleParamStart)
+
+		return err
+	})
+}
+
+// dump prints the resolved Role configuration as JSON to the stdout. Other
+// messages are written to the stderr so the output can be piped
+func (c *application) dump(parameters []string) error {
+	return c.runTo(os.Stderr, func() (ExecuteConfig, int, error) {
+		return c.buildRunConfigFromParam(parameters)
+	}, func(
+		execCfg ExecuteConfig,
+		roleParamStart int,
+		printer print.Printer,
+	) error {
+		exported, err := c.configure(
+			printer, execCfg, parameters, roleParamStart)
+
+		if err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(exported, "", "  ")
+
+		if err != nil {
+			return err
+		}
+
+		_, err = os.Stdout.Write(append(data, '\n'))
+
+		return err
+	})
+}
+
+// diff compares the Role configurations of two files
+func (c *application) diff(parameters []string) error {
+	return c.run(func() (ExecuteConfig, int, error) {
+		if len(parameters) != 3 {
+			return ExecuteConfig{}, 0, ErrDiffFilesMustBeSpecified
+		}
+
+		return ExecuteConfig{}, 0, nil
+	}, func(
+		execCfg ExecuteConfig,
+		roleParamStart int,
+		printer print.Printer,
+	) error {
+		name := strings.TrimSpace(parameters[0])
+
+		if strings.ToLower(name) == "-roles" {
+			name = ""
+		}
+
+		configs := [2]config.Exported{}
+
+		for cIdx := range configs {
+			path := strings.TrimSpace(parameters[cIdx+1])
+			exported, err := c.configureFile(printer, name, path)
+
+			if err != nil {
+				printer.Writeln([]byte(fmt.Sprintf(
+					"Failed to load configuration from \"%s\"", path)),
+					1, 1, 1)
+
+				return err
+			}
+
+			configs[cIdx] = exported
+		}
+
+		differences := configs[0].Diff(configs[1])
+
+		if len(differences) <= 0 {
+			printer.Writeln([]byte("Configurations are identical"), 1, 1, 1)
+
+			return nil
+		}
+
+		printer.Writeln([]byte(fmt.Sprintf(
+			"Found %d difference(s):\r\n", len(differences))), 1, 1, 1)
+
+		for _, d := range differences {
+			printer.Writeln([]byte(d.String()), 3, 5, 1)
+		}
+
+		return ErrConfigurationsAreDifferent
+	})
+}
+
+// init asks for the Role Options of a Role and writes them into a new
+// Parameter File
+func (c *application) init(
+	printer print.Printer,
+	name string,
+	parameters []string,
+) error {
+	path := name + ".conf"
+
+	switch len(parameters) {
+	case 0:
+	case 1:
+		path = strings.TrimSpace(parameters[0])
+
+	default:
+		return ErrInitTooManyArguments
+	}
+
+	_, statErr := os.Stat(path)
+
+	if statErr == nil {
+		return ErrParameterFileExisted
+	}
+
+	printer.Writeln([]byte(fmt.Sprintf("Please configure \"%s\" by "+
+		"answering following questions. Leave the answer empty to skip "+
+		"an option.", name)), 1, 1, 1)
+
+	param, err := c.roles.Wizard(printer, name, os.Stdin)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = c.roles.Configure(printer, name, param)
+
+	if err != nil {
+		printer.Writeln([]byte(fmt.Sprintf("\r\nThe answers can't make "+
+			"a valid configuration, nothing has been written:\r\n\r\n%s",
+			param)), 1, 1, 1)
+
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(param)
+
+	if err != nil {
+		file.Close()
+
+		return err
+	}
+
+	err = file.Close()
+
+	if err != nil {
+		return err
+	}
+
+	printer.Writeln([]byte(fmt.Sprintf("\r\nParameters have been written "+
+		"to \"%s\", run the Role with:\r\n\r\n%s -param %s %s\r\n",
+		path, c.exeName, path, name)), 1, 1, 1)
+
+	return nil
+}
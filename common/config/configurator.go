@@ -21,6 +21,7 @@
 package config
 
 import (
+	"io"
 	"reflect"
 	"strings"
 
@@ -36,6 +37,10 @@ type carrier struct {
 // Configurator is configuration parser
 type Configurator interface {
 	Parse(parameters []byte) error
+	ParseDocument(format string, document []byte, parameters []byte) error
+	Export() Exported
+	Wizard(w print.Common, r io.Reader) ([]byte, error)
+	Complete(words []string) []string
 	Help(w print.Common)
 }
 
@@ -108,7 +113,9 @@ func Import(cfg interface{}) (Configurator, error) {
 					Path:        currentCarrier.Path,
 					Tag:         "[]",
 					Tags:        []string{},
+					JSON:        "",
 					Description: "A array slice of:",
+					Secret:      false,
 					Sub:         fields{},
 				}
 
@@ -193,6 +200,17 @@ func Import(cfg interface{}) (Configurator, error) {
 				fieldNameMutex[fTagName] = true
 			}
 
+			fieldJSONName := strings.TrimSpace(strings.SplitN(
+				fieldType.Tag.Get("json"), ",", 2)[0])
+
+			if fieldJSONName == "" {
+				fieldJSONName = fieldType.Name
+			}
+
+			// Secret fields will be masked when the configuration is
+			// exported
+			fieldSecret := fieldType.Tag.Get("secret") == "true"
+
 			fieldTypeType := fieldType.Type
 
 			for {
@@ -237,7 +255,9 @@ func Import(cfg interface{}) (Configurator, error) {
 						Path:        currentCarrier.Path + "/" + fieldType.Name,
 						Tag:         "-" + strings.Join(fieldTags, ", -"),
 						Tags:        fieldTags,
+						JSON:        fieldJSONName,
 						Description: fieldDescription,
+						Secret:      fieldSecret,
 						Sub:         fields{},
 					})
 
@@ -250,7 +270,9 @@ func Import(cfg interface{}) (Configurator, error) {
 					Tag: "-" + strings.Join(
 						fieldTags, " [], -") + " []",
 					Tags:        fieldTags,
+					JSON:        fieldJSONName,
 					Description: fieldDescription,
+					Secret:      fieldSecret,
 					Sub:         fields{},
 				}
 
@@ -270,7 +292,9 @@ func Import(cfg interface{}) (Configurator, error) {
 					Tag: "-" + strings.Join(
 						fieldTags, " {}, -") + " {}",
 					Tags:        fieldTags,
+					JSON:        fieldJSONName,
 					Description: fieldDescription,
+					Secret:      fieldSecret,
 					Sub:         fields{},
 				}
 
@@ -0,0 +1,133 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// completeBlock is a block of options during completion
+type completeBlock struct {
+	fields fields
+	label  *field
+}
+
+// Complete returns the completion candidates of the last word of the
+// given parameter words. The candidates of a field value is provided by
+// the optional GetCandidates method of the configuration
+func (c *configurator) Complete(words []string) []string {
+	if len(words) <= 0 {
+		return nil
+	}
+
+	current := completeBlock{
+		fields: c.fields,
+		label:  nil,
+	}
+	blocks := make([]completeBlock, 0, 8)
+
+	for _, word := range words[:len(words)-1] {
+		switch {
+		case word == "{":
+			blocks = append(blocks, current)
+
+			if current.label == nil || len(current.label.Sub) <= 0 {
+				current = completeBlock{
+					fields: fields{},
+					label:  nil,
+				}
+
+				continue
+			}
+
+			current = completeBlock{
+				fields: current.label.Sub,
+				label:  nil,
+			}
+
+		case word == "}":
+			if len(blocks) <= 0 {
+				continue
+			}
+
+			current = blocks[len(blocks)-1]
+			blocks = blocks[:len(blocks)-1]
+
+		case strings.HasPrefix(word, "-"):
+			label, labelErr := current.fields.GetByTag(word[1:])
+
+			if labelErr != nil {
+				label = nil
+			}
+
+			current.label = label
+		}
+	}
+
+	word := words[len(words)-1]
+
+	if strings.HasPrefix(word, "-") {
+		result := make([]string, 0, len(current.fields)*2)
+
+		for _, f := range current.fields {
+			for _, tag := range f.Tags {
+				result = append(result, "-"+tag)
+			}
+		}
+
+		return completeFilter(result, word)
+	}
+
+	if current.label == nil {
+		return nil
+	}
+
+	if len(current.label.Sub) > 0 {
+		return completeFilter([]string{"{"}, word)
+	}
+
+	candidater := c.config.MethodByName("GetCandidates")
+
+	if !candidater.IsValid() {
+		return nil
+	}
+
+	return completeFilter(candidater.Interface().(func(string) []string)(
+		current.label.Path), word)
+}
+
+// completeFilter returns candidates which start with the prefix, sorted
+func completeFilter(candidates []string, prefix string) []string {
+	result := make([]string, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		if !strings.HasPrefix(candidate, prefix) {
+			continue
+		}
+
+		result = append(result, candidate)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,82 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+type dummyCompleteConfig struct {
+	Port     uint16                 `json:"port" cfg:"p,-port:Port"`
+	Codec    string                 `json:"codec" cfg:"c,-codec:Codec"`
+	Mappings []dummyDocumentMapping `json:"mapping" cfg:"m,-mapping:Mappings"`
+}
+
+func (d dummyCompleteConfig) GetCandidates(fieldPath string) []string {
+	switch fieldPath {
+	case "/Codec":
+		return []string{"plain", "aes-cfb-128", "aes-gcm-128"}
+
+	case "/Mappings/Host":
+		return []string{"localhost"}
+	}
+
+	return nil
+}
+
+func TestComplete(t *testing.T) {
+	c, importErr := Import(&dummyCompleteConfig{})
+
+	if importErr != nil {
+		t.Error("Failed to import configuration due to error:", importErr)
+
+		return
+	}
+
+	tests := []struct {
+		words    []string
+		expected []string
+	}{
+		{[]string{"-"}, []string{
+			"--codec", "--mapping", "--port", "-c", "-m", "-p"}},
+		{[]string{"-c", "aes"}, []string{"aes-cfb-128", "aes-gcm-128"}},
+		{[]string{"-p", "80", "-c", ""},
+			[]string{"aes-cfb-128", "aes-gcm-128", "plain"}},
+		{[]string{"-c", "plain", "-p", ""}, []string{}},
+		{[]string{"-m", "{", "-h", ""}, []string{"localhost"}},
+		{[]string{"-m", "{", "-"}, []string{"--host", "--id", "-h", "-i"}},
+		{[]string{"-m", "{", "-h", "a", "}", "-c", "p"}, []string{"plain"}},
+	}
+
+	for _, test := range tests {
+		result := c.Complete(test.words)
+
+		if strings.Join(result, " ") == strings.Join(test.expected, " ") {
+			continue
+		}
+
+		t.Errorf("Expecting candidates %v for %v, got %v",
+			test.expected, test.words, result)
+
+		return
+	}
+}
@@ -0,0 +1,321 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// exportSecretMask replaces the value of secret fields when the
+// configuration is printed
+const exportSecretMask = "******"
+
+// Exported is a resolved configuration exported by Configurator.Export.
+// The order of the fields is the same as they're declared in the
+// configuration struct
+type Exported []ExportedField
+
+// ExportedField is a field of an Exported configuration. The Value is
+// either nil, a bare value, a []interface{} or an Exported
+type ExportedField struct {
+	Name   string
+	Value  interface{}
+	Secret bool
+}
+
+// Difference is a difference between two Exported configurations
+type Difference struct {
+	Path   string
+	Old    interface{}
+	New    interface{}
+	Secret bool
+}
+
+// Export exports current configuration values with the JSON name of the
+// fields
+func (c *configurator) Export() Exported {
+	return exportStruct(c.config.Elem(), c.fields)
+}
+
+func exportStruct(v reflect.Value, fs fields) Exported {
+	result := make(Exported, 0, len(fs))
+
+	for _, f := range fs {
+		fieldValue := v.FieldByName(f.Name)
+
+		if !fieldValue.IsValid() {
+			continue
+		}
+
+		result = append(result, ExportedField{
+			Name:   f.JSON,
+			Value:  exportValue(fieldValue, f),
+			Secret: f.Secret,
+		})
+	}
+
+	return result
+}
+
+func exportValue(v reflect.Value, f *field) interface{} {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return exportStruct(v, f.Sub)
+
+	case reflect.Array:
+		fallthrough
+	case reflect.Slice:
+		subField := f
+
+		// Items of a slice of slice are described by the "[]" sub field
+		if len(f.Sub) == 1 && f.Sub[0].Tag == "[]" {
+			subField = f.Sub[0]
+		}
+
+		items := make([]interface{}, 0, v.Len())
+
+		for itemIdx := 0; itemIdx < v.Len(); itemIdx++ {
+			items = append(items, exportValue(v.Index(itemIdx), subField))
+		}
+
+		return items
+
+	default:
+		return v.Interface()
+	}
+}
+
+// mask hides the value of a secret field. Empty values are kept so it can
+// still be told whether the secret has been set
+func mask(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil:
+		return nil
+
+	case string:
+		if value == "" {
+			return value
+		}
+
+		return exportSecretMask
+
+	case []interface{}:
+		result := make([]interface{}, len(value))
+
+		for vIdx := range value {
+			result[vIdx] = mask(value[vIdx])
+		}
+
+		return result
+
+	case Exported:
+		result := make(Exported, len(value))
+
+		for vIdx := range value {
+			result[vIdx] = ExportedField{
+				Name:   value[vIdx].Name,
+				Value:  value[vIdx].Value,
+				Secret: true,
+			}
+		}
+
+		return result
+
+	default:
+		return exportSecretMask
+	}
+}
+
+// MarshalJSON encodes the configuration as a JSON object with the value of
+// secret fields masked
+func (e Exported) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, 256))
+
+	buf.WriteByte('{')
+
+	for fIdx, f := range e {
+		if fIdx > 0 {
+			buf.WriteByte(',')
+		}
+
+		name, nameErr := json.Marshal(f.Name)
+
+		if nameErr != nil {
+			return nil, nameErr
+		}
+
+		value := f.Value
+
+		if f.Secret {
+			value = mask(value)
+		}
+
+		data, dataErr := json.Marshal(value)
+
+		if dataErr != nil {
+			return nil, dataErr
+		}
+
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(data)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// Diff compares current configuration with another one and returns the
+// differences between them
+func (e Exported) Diff(to Exported) []Difference {
+	result := make([]Difference, 0, 16)
+
+	diffValue("", e, to, false, &result)
+
+	return result
+}
+
+func (e Exported) get(name string) (ExportedField, bool) {
+	for _, f := range e {
+		if f.Name != name {
+			continue
+		}
+
+		return f, true
+	}
+
+	return ExportedField{}, false
+}
+
+func diffValue(
+	path string,
+	old interface{},
+	new interface{},
+	secret bool,
+	result *[]Difference,
+) {
+	oldExported, oldIsExported := old.(Exported)
+	newExported, newIsExported := new.(Exported)
+
+	if oldIsExported && newIsExported {
+		for _, f := range oldExported {
+			newField, _ := newExported.get(f.Name)
+
+			diffValue(joinDiffPath(path, f.Name), f.Value, newField.Value,
+				secret || f.Secret || newField.Secret, result)
+		}
+
+		for _, f := range newExported {
+			_, existed := oldExported.get(f.Name)
+
+			if existed {
+				continue
+			}
+
+			diffValue(joinDiffPath(path, f.Name), nil, f.Value,
+				secret || f.Secret, result)
+		}
+
+		return
+	}
+
+	oldItems, oldIsItems := old.([]interface{})
+	newItems, newIsItems := new.([]interface{})
+
+	if oldIsItems && newIsItems {
+		for itemIdx := 0; itemIdx < len(oldItems) ||
+			itemIdx < len(newItems); itemIdx++ {
+			var oldItem interface{}
+			var newItem interface{}
+
+			if itemIdx < len(oldItems) {
+				oldItem = oldItems[itemIdx]
+			}
+
+			if itemIdx < len(newItems) {
+				newItem = newItems[itemIdx]
+			}
+
+			diffValue(fmt.Sprintf("%s[%d]", path, itemIdx),
+				oldItem, newItem, secret, result)
+		}
+
+		return
+	}
+
+	if reflect.DeepEqual(old, new) {
+		return
+	}
+
+	*result = append(*result, Difference{
+		Path:   path,
+		Old:    old,
+		New:    new,
+		Secret: secret,
+	})
+}
+
+func joinDiffPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// String returns the difference as a printable string, the value of secret
+// fields will be masked
+func (d Difference) String() string {
+	old, new := d.Old, d.New
+
+	if d.Secret {
+		old, new = mask(old), mask(new)
+	}
+
+	return fmt.Sprintf("%s: %s -> %s", d.Path,
+		differenceValue(old), differenceValue(new))
+}
+
+func differenceValue(v interface{}) string {
+	if v == nil {
+		return "(undefined)"
+	}
+
+	data, dataErr := json.Marshal(v)
+
+	if dataErr != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(data)
+}
@@ -0,0 +1,126 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type dummyExportAccount struct {
+	User     string `json:"user" cfg:"u,-user:User"`
+	Password string `json:"password" secret:"true" cfg:"p,-pass:Password"`
+}
+
+type dummyExportConfig struct {
+	Host     string               `json:"host" cfg:"h,-host:Host"`
+	Port     uint16               `json:"port" cfg:"p,-port:Port"`
+	Keys     []string             `json:"keys" secret:"true" cfg:"k,-keys:Keys"`
+	Accounts []dummyExportAccount `json:"accounts" cfg:"a,-accounts:Accounts"`
+}
+
+func testExport(t *testing.T, parameters string) Exported {
+	c, importErr := Import(&dummyExportConfig{})
+
+	if importErr != nil {
+		t.Error("Failed to import configuration due to error:", importErr)
+
+		return nil
+	}
+
+	parseErr := c.Parse([]byte(parameters))
+
+	if parseErr != nil {
+		t.Error("Failed to parse due to error:", parseErr)
+
+		return nil
+	}
+
+	return c.Export()
+}
+
+func TestExportMarshalJSON(t *testing.T) {
+	exported := testExport(t, "-h localhost -p 80 -k a b "+
+		"-a { -u admin -p secret } { -u guest }")
+
+	if exported == nil {
+		return
+	}
+
+	data, marshalErr := json.Marshal(exported)
+
+	if marshalErr != nil {
+		t.Error("Failed to marshal due to error:", marshalErr)
+
+		return
+	}
+
+	expected := `{"host":"localhost","port":80,"keys":["******","******"],` +
+		`"accounts":[{"user":"admin","password":"******"},` +
+		`{"user":"guest","password":""}]}`
+
+	if string(data) != expected {
+		t.Errorf("Expecting %s, got %s", expected, data)
+
+		return
+	}
+}
+
+func TestExportDiff(t *testing.T) {
+	old := testExport(t, "-h localhost -p 80 -k a -a { -u admin -p secret }")
+	new := testExport(t, "-h localhost -p 81 -k b "+
+		"-a { -u admin -p secret } { -u guest -p guest }")
+
+	if old == nil || new == nil {
+		return
+	}
+
+	if len(old.Diff(old)) != 0 {
+		t.Errorf("Expecting no difference, got %v", old.Diff(old))
+
+		return
+	}
+
+	diffs := new.Diff(old)
+	expected := []string{
+		"port: 81 -> 80",
+		"keys[0]: \"******\" -> \"******\"",
+		"accounts[1]: {\"user\":\"guest\",\"password\":\"******\"} -> " +
+			"(undefined)",
+	}
+
+	if len(diffs) != len(expected) {
+		t.Errorf("Expecting %d differences, got %v", len(expected), diffs)
+
+		return
+	}
+
+	for dIdx := range diffs {
+		if diffs[dIdx].String() == expected[dIdx] {
+			continue
+		}
+
+		t.Errorf("Expecting difference %s, got %s",
+			expected[dIdx], diffs[dIdx].String())
+
+		return
+	}
+}
@@ -51,6 +51,25 @@ type parsedConfig struct {
 	End   int
 }
 
+// interpolateValues resolves interpolations such as `${ENV:NAME}` of the
+// values
+func interpolateValues(
+	values []*parameter.Value) ([]*parameter.Value, error) {
+	result := make([]*parameter.Value, len(values))
+
+	for vIdx := range values {
+		interpolated, interpolateErr := values[vIdx].Interpolate()
+
+		if interpolateErr != nil {
+			return nil, interpolateErr
+		}
+
+		result[vIdx] = interpolated
+	}
+
+	return result, nil
+}
+
 // Parse parameter string and fillin configuration
 func (c *configurator) Parse(parameters []byte) error {
 	param, paramErr := parameter.New(parameters, 3)
@@ -189,7 +208,16 @@ func (c *configurator) Parse(parameters []byte) error {
 					slices = append(slices, newSliceRefer)
 
 				default:
-					bareValueSetErr := fieldRefl.SetBareSlice(label.Values())
+					values, interpolateErr := interpolateValues(label.Values())
+
+					if interpolateErr != nil {
+						return newParseError(
+							interpolateErr, currentFieldTag,
+							currentLabel.Start(), currentLabel.End(),
+							parameters)
+					}
+
+					bareValueSetErr := fieldRefl.SetBareSlice(values)
 
 					if bareValueSetErr != nil {
 						return newParseError(
@@ -212,7 +240,16 @@ func (c *configurator) Parse(parameters []byte) error {
 				}
 
 			default:
-				bareValueSetErr := fieldRefl.SetBareValue(label.Values())
+				values, interpolateErr := interpolateValues(label.Values())
+
+				if interpolateErr != nil {
+					return newParseError(
+						interpolateErr, currentFieldTag,
+						currentLabel.Start(), currentLabel.End(),
+						parameters)
+				}
+
+				bareValueSetErr := fieldRefl.SetBareValue(values)
 
 				if bareValueSetErr != nil {
 					return newParseError(
@@ -0,0 +1,66 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/reinit/coward/common/parameter"
+)
+
+func TestParseInterpolation(t *testing.T) {
+	t.Setenv("COWARD_TEST_PORT", "8080")
+	t.Setenv("COWARD_TEST_NAME", "b c")
+
+	cfg := &dummyDocumentConfig{}
+
+	c, importErr := Import(cfg)
+
+	if importErr != nil {
+		t.Error("Failed to import configuration due to error:", importErr)
+
+		return
+	}
+
+	parseErr := c.Parse([]byte(
+		"-p ${ENV:COWARD_TEST_PORT} -n a \"${ENV:COWARD_TEST_NAME}\""))
+
+	if parseErr != nil {
+		t.Error("Failed to parse due to error:", parseErr)
+
+		return
+	}
+
+	if cfg.Port != 8080 || len(cfg.Names) != 2 || cfg.Names[1] != "b c" {
+		t.Errorf("Unexpected configuration: %+v", cfg)
+
+		return
+	}
+
+	parseErr = c.Parse([]byte("-p ${ENV:COWARD_TEST_UNDEFINED}"))
+
+	if !errors.Is(parseErr, parameter.ErrInterpolationEnvironmentUndefined) {
+		t.Errorf("Expecting an interpolation error, got %v", parseErr)
+
+		return
+	}
+}
@@ -0,0 +1,436 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/reinit/coward/common/parameter"
+	"github.com/reinit/coward/common/print"
+)
+
+// Wizard errors
+var (
+	ErrWizardInputEnded = errors.New(
+		"Input has ended before the configuration is completed")
+
+	ErrWizardInputMustBeSingleOption = errors.New(
+		"Input must be values of a single option")
+)
+
+const wizardIndent = "    "
+
+// wizard asks for the configuration field by field
+type wizard struct {
+	w      print.Common
+	r      *bufio.Reader
+	root   reflect.Value
+	output *bytes.Buffer
+}
+
+// Wizard walks through the fields of the configuration, asks for their
+// values from r and returns the answers as a parameter string. Every value
+// will be verified right after it has been entered, fields which are
+// left empty will not be included in the result
+func (c *configurator) Wizard(w print.Common, r io.Reader) ([]byte, error) {
+	wz := wizard{
+		w:      w,
+		r:      bufio.NewReader(r),
+		root:   c.config,
+		output: bytes.NewBuffer(make([]byte, 0, 1024)),
+	}
+
+	wizardErr := wz.walk(c.config, c.fields, 0)
+
+	if wizardErr != nil {
+		return nil, wizardErr
+	}
+
+	return wz.output.Bytes(), nil
+}
+
+// ask prints the question and reads an answer
+func (z *wizard) ask(question string, depth int) (string, error) {
+	z.w.Write([]byte(strings.Repeat(wizardIndent, depth) + question + "> "))
+
+	answer, readErr := z.r.ReadString('\n')
+
+	if readErr != nil && (readErr != io.EOF || answer == "") {
+		z.w.Write([]byte("\r\n"))
+
+		return "", ErrWizardInputEnded
+	}
+
+	return strings.TrimSpace(answer), nil
+}
+
+// confirm asks a yes or no question
+func (z *wizard) confirm(question string, depth int) (bool, error) {
+	for {
+		answer, askErr := z.ask(question+" (yes/no) ", depth)
+
+		if askErr != nil {
+			return false, askErr
+		}
+
+		switch strings.ToLower(answer) {
+		case "y":
+			fallthrough
+		case "yes":
+			return true, nil
+
+		case "":
+			fallthrough
+		case "n":
+			fallthrough
+		case "no":
+			return false, nil
+		}
+	}
+}
+
+// describe prints the tag and description of a field
+func (z *wizard) describe(f *field, depth int) {
+	indent := len(wizardIndent) * depth
+
+	z.w.Writeln([]byte("\r\n"+f.Tag), indent, indent, 1)
+
+	if f.Description == "" {
+		return
+	}
+
+	z.w.Writeln([]byte(f.Description+"\r\n"),
+		indent+len(wizardIndent), indent+len(wizardIndent), 1)
+}
+
+// writeOutput appends a line to the result parameter string
+func (z *wizard) writeOutput(line string, depth int) {
+	z.output.WriteString(strings.Repeat(wizardIndent, depth) + line + "\n")
+}
+
+// walk asks for every field of the struct which cfgPtr points to
+func (z *wizard) walk(cfgPtr reflect.Value, fs fields, depth int) error {
+	for _, f := range fs {
+		fieldValue := cfgPtr.Elem().FieldByName(f.Name)
+
+		if !fieldValue.IsValid() {
+			continue
+		}
+
+		var walkErr error
+
+		fieldType := newTypeReflect(fieldValue.Type()).DirectElem()
+
+		switch fieldType.Kind() {
+		case reflect.Array:
+			fallthrough
+		case reflect.Slice:
+			elemType := newTypeReflect(fieldType.Elem()).DirectElem()
+
+			if elemType.Kind() == reflect.Struct {
+				walkErr = z.walkStructs(cfgPtr, fieldValue, f, depth)
+			} else {
+				walkErr = z.walkValue(cfgPtr, fieldValue, f, depth)
+			}
+
+		case reflect.Struct:
+			walkErr = z.walkStruct(cfgPtr, fieldValue, f, depth)
+
+		default:
+			walkErr = z.walkValue(cfgPtr, fieldValue, f, depth)
+		}
+
+		if walkErr != nil {
+			return walkErr
+		}
+	}
+
+	return nil
+}
+
+// walkValue asks for the value of a field until it's verified or skipped
+func (z *wizard) walkValue(
+	cfgPtr reflect.Value,
+	fieldValue reflect.Value,
+	f *field,
+	depth int,
+) error {
+	label := "-" + f.Tags[0]
+
+	z.describe(f, depth)
+
+	for {
+		answer, askErr := z.ask("", depth+1)
+
+		if askErr != nil {
+			return askErr
+		}
+
+		if answer == "" {
+			return nil
+		}
+
+		fieldValue.Set(reflect.Zero(fieldValue.Type()))
+
+		setErr := z.setValue(cfgPtr, fieldValue, f, label+" "+answer)
+
+		if setErr == nil {
+			z.writeOutput(label+" "+answer, depth)
+
+			return nil
+		}
+
+		fieldValue.Set(reflect.Zero(fieldValue.Type()))
+
+		z.w.Writeln([]byte(fmt.Sprintf("Invalid value: %s", setErr)),
+			len(wizardIndent)*(depth+1), len(wizardIndent)*(depth+1), 1)
+	}
+}
+
+// setValue parses the answer into the field and verifies it
+func (z *wizard) setValue(
+	cfgPtr reflect.Value,
+	fieldValue reflect.Value,
+	f *field,
+	answer string,
+) error {
+	param, paramErr := parameter.New([]byte(answer), 3)
+
+	if paramErr != nil {
+		return paramErr
+	}
+
+	labels := param.Value().Labels()
+
+	if len(labels) != 1 {
+		return ErrWizardInputMustBeSingleOption
+	}
+
+	values, interpolateErr := interpolateValues(labels[0].Values())
+
+	if interpolateErr != nil {
+		return interpolateErr
+	}
+
+	fieldRefl := newValueReflect(fieldValue).DirectElem()
+
+	var setErr error
+
+	switch fieldRefl.Kind() {
+	case reflect.Array:
+		fallthrough
+	case reflect.Slice:
+		setErr = fieldRefl.SetBareSlice(values)
+
+	default:
+		setErr = fieldRefl.SetBareValue(values)
+	}
+
+	if setErr != nil {
+		return setErr
+	}
+
+	rootVerifier := z.root.MethodByName("CheckValue")
+
+	if rootVerifier.IsValid() {
+		verifyErr := rootVerifier.Interface().(func(
+			string, interface{}) error)(
+			fieldRefl.Type().Name(), fieldRefl.Interface())
+
+		if verifyErr != nil {
+			return verifyErr
+		}
+	}
+
+	return z.verify(cfgPtr, "Verify"+f.Name)
+}
+
+// verify calls the verifier method of cfgPtr if it exists
+func (z *wizard) verify(cfgPtr reflect.Value, method string) error {
+	verifier := cfgPtr.MethodByName(method)
+
+	if !verifier.IsValid() {
+		return nil
+	}
+
+	return verifier.Interface().(func() error)()
+}
+
+// walkItem asks for the fields of a struct item, and verifies the item
+// once all fields are given
+func (z *wizard) walkItem(
+	parentPtr reflect.Value,
+	itemPtr reflect.Value,
+	f *field,
+	depth int,
+) error {
+	initer := itemPtr.MethodByName("Init")
+
+	if initer.IsValid() {
+		initer.Call([]reflect.Value{parentPtr})
+	}
+
+	walkErr := z.walk(itemPtr, f.Sub, depth)
+
+	if walkErr != nil {
+		return walkErr
+	}
+
+	return z.verify(itemPtr, "Verify")
+}
+
+// walkStruct asks for the fields of a struct field
+func (z *wizard) walkStruct(
+	cfgPtr reflect.Value,
+	fieldValue reflect.Value,
+	f *field,
+	depth int,
+) error {
+	z.describe(f, depth)
+
+	for {
+		configure, confirmErr := z.confirm(
+			fmt.Sprintf("Configure -%s?", f.Tags[0]), depth+1)
+
+		if confirmErr != nil || !configure {
+			return confirmErr
+		}
+
+		outputLen := z.output.Len()
+		itemPtr := reflect.New(newTypeReflect(fieldValue.Type()).
+			DirectElem().Extract())
+
+		z.writeOutput("-"+f.Tags[0]+" {", depth)
+
+		itemErr := z.walkItem(cfgPtr, itemPtr, f, depth+1)
+
+		if itemErr == ErrWizardInputEnded {
+			return itemErr
+		}
+
+		if itemErr == nil {
+			z.writeOutput("}", depth)
+
+			newValueReflect(fieldValue).DirectElem().Set(itemPtr.Elem())
+
+			return nil
+		}
+
+		z.output.Truncate(outputLen)
+
+		z.w.Writeln([]byte(fmt.Sprintf(
+			"Invalid %s: %s, please try again", f.Tag, itemErr)),
+			len(wizardIndent)*depth, len(wizardIndent)*depth, 1)
+	}
+}
+
+// walkStructs asks for items of a slice of struct field
+func (z *wizard) walkStructs(
+	cfgPtr reflect.Value,
+	fieldValue reflect.Value,
+	f *field,
+	depth int,
+) error {
+	label := "-" + f.Tags[0]
+
+	z.describe(f, depth)
+
+	sliceValue := newValueReflect(fieldValue).DirectElem()
+	itemType := sliceValue.Type().Elem()
+	itemStructType := newTypeReflect(itemType).DirectElem().Extract()
+	items := 0
+
+	for {
+		if sliceValue.Kind() == reflect.Array && items >= sliceValue.Len() {
+			break
+		}
+
+		addItem, confirmErr := z.confirm(fmt.Sprintf(
+			"Add item #%d to %s?", items+1, label), depth+1)
+
+		if confirmErr != nil {
+			return confirmErr
+		}
+
+		if !addItem {
+			break
+		}
+
+		outputLen := z.output.Len()
+		itemPtr := reflect.New(itemStructType)
+
+		z.writeOutput(label+" {", depth)
+
+		itemErr := z.walkItem(cfgPtr, itemPtr, f, depth+1)
+
+		if itemErr == ErrWizardInputEnded {
+			return itemErr
+		}
+
+		if itemErr != nil {
+			z.output.Truncate(outputLen)
+
+			z.w.Writeln([]byte(fmt.Sprintf(
+				"Item #%d of %s is invalid and has been dropped: %s",
+				items+1, label, itemErr)),
+				len(wizardIndent)*(depth+1), len(wizardIndent)*(depth+1), 1)
+
+			continue
+		}
+
+		z.writeOutput("}", depth)
+
+		item := itemPtr
+
+		if itemType.Kind() != reflect.Ptr {
+			item = itemPtr.Elem()
+		}
+
+		if sliceValue.Kind() == reflect.Array {
+			sliceValue.Index(items).Set(item)
+		} else {
+			sliceValue.Set(reflect.Append(sliceValue.Value, item))
+		}
+
+		items++
+	}
+
+	if items <= 0 {
+		return nil
+	}
+
+	verifyErr := z.verify(cfgPtr, "Verify"+f.Name)
+
+	if verifyErr == nil {
+		return nil
+	}
+
+	z.w.Writeln([]byte(fmt.Sprintf("Invalid %s: %s", f.Tag, verifyErr)),
+		len(wizardIndent)*depth, len(wizardIndent)*depth, 1)
+
+	return verifyErr
+}
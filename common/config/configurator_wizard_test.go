@@ -0,0 +1,97 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reinit/coward/common/print"
+)
+
+func TestWizard(t *testing.T) {
+	cfg := &dummyDocumentConfig{}
+
+	c, importErr := Import(cfg)
+
+	if importErr != nil {
+		t.Error("Failed to import configuration due to error:", importErr)
+
+		return
+	}
+
+	screen := print.New(print.Config{
+		MaxLineWidth: func() int {
+			return 80
+		},
+	}).Buffer(nil, nil)
+
+	result, wizardErr := c.Wizard(screen, strings.NewReader(
+		"localhost\n0\n80\n\na \"b c\"\nyes\n1\nh\nno\n"))
+
+	if wizardErr != nil {
+		t.Error("Failed to run the wizard due to error:", wizardErr)
+
+		return
+	}
+
+	expected := "-h localhost\n-p 80\n-n a \"b c\"\n-m {\n    -i 1\n    -h h\n}\n"
+
+	if string(result) != expected {
+		t.Errorf("Expecting result %q, got %q", expected, result)
+
+		return
+	}
+
+	parsed := &dummyDocumentConfig{}
+
+	c, importErr = Import(parsed)
+
+	if importErr != nil {
+		t.Error("Failed to import configuration due to error:", importErr)
+
+		return
+	}
+
+	parseErr := c.Parse(result)
+
+	if parseErr != nil {
+		t.Error("Failed to parse the result due to error:", parseErr)
+
+		return
+	}
+
+	if parsed.Port != cfg.Port || len(parsed.Names) != 2 ||
+		len(cfg.Names) != 2 || len(cfg.Mappings) != 1 ||
+		cfg.Mappings[0].Host != "h" {
+		t.Errorf("Unexpected configuration: %+v, %+v", cfg, parsed)
+
+		return
+	}
+
+	_, wizardErr = c.Wizard(screen, strings.NewReader("localhost\n"))
+
+	if wizardErr != ErrWizardInputEnded {
+		t.Errorf("Expecting error %v, got %v", ErrWizardInputEnded, wizardErr)
+
+		return
+	}
+}
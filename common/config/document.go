@@ -0,0 +1,505 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Document formats
+const (
+	DocumentJSON = "json"
+	DocumentYAML = "yaml"
+	DocumentTOML = "toml"
+)
+
+// Document errors
+var (
+	ErrDocumentUnknownFormat = errors.New(
+		"Unknown document format")
+
+	ErrDocumentUnexpectedCharacter = errors.New(
+		"Unexpected character")
+
+	ErrDocumentUnexpectedEnd = errors.New(
+		"Unexpected end of document")
+
+	ErrDocumentInvalidString = errors.New(
+		"Invalid string")
+
+	ErrDocumentInvalidValue = errors.New(
+		"Invalid value")
+
+	ErrDocumentInvalidIndent = errors.New(
+		"Invalid indentation")
+
+	ErrDocumentDuplicatedKey = errors.New(
+		"Duplicated key")
+
+	ErrDocumentRootMustBeMap = errors.New(
+		"Document must contain a map of options")
+
+	ErrDocumentUnknownOption = errors.New(
+		"Unknown option")
+
+	ErrDocumentOptionMustBeMap = errors.New(
+		"Option must be a map")
+
+	ErrDocumentOptionMustNotBeMap = errors.New(
+		"Option must not be a map")
+
+	ErrDocumentNestedListUnsupported = errors.New(
+		"List inside a list is unsupported")
+)
+
+// DocumentError indicating an error at a position of a document
+type DocumentError struct {
+	err     error
+	subject string
+	line    int
+	column  int
+}
+
+// documentKind is the kind of a documentNode
+type documentKind byte
+
+// Document node kinds
+const (
+	documentNull documentKind = iota
+	documentScalar
+	documentList
+	documentMap
+)
+
+// documentNode is a decoded document node
+type documentNode struct {
+	kind    documentKind
+	line    int
+	column  int
+	scalar  string
+	items   []*documentNode
+	entries []documentEntry
+}
+
+// documentEntry is a key value pair of a documentNode map
+type documentEntry struct {
+	key    string
+	line   int
+	column int
+	value  *documentNode
+}
+
+// documentMark maps an offset of the translated parameters back to the
+// position of the document
+type documentMark struct {
+	offset int
+	line   int
+	column int
+}
+
+// documentTranslation is a document that been translated to parameters
+type documentTranslation struct {
+	output bytes.Buffer
+	marks  []documentMark
+}
+
+func newDocumentError(err error, subject string, line, column int) error {
+	return &DocumentError{
+		err:     err,
+		subject: subject,
+		line:    line,
+		column:  column,
+	}
+}
+
+// Is check if the input error is equals to underlying DocumentError
+func (d *DocumentError) Is(err error) bool {
+	return d.err == err
+}
+
+// Unwrap returns the underlying error
+func (d *DocumentError) Unwrap() error {
+	return d.err
+}
+
+// Position returns the line and column where the error happened
+func (d *DocumentError) Position() (int, int) {
+	return d.line, d.column
+}
+
+// Error returns a formated error string
+func (d *DocumentError) Error() string {
+	if d.subject == "" {
+		return fmt.Sprintf("%s at line %d, column %d",
+			d.err, d.line, d.column)
+	}
+
+	return fmt.Sprintf("%s \"%s\" at line %d, column %d",
+		d.err, d.subject, d.line, d.column)
+}
+
+// DocumentFormat returns the document format by file extension, or empty
+// string when the file is not a supported document
+func DocumentFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return DocumentJSON
+
+	case ".yaml", ".yml":
+		return DocumentYAML
+
+	case ".toml":
+		return DocumentTOML
+	}
+
+	return ""
+}
+
+// get returns the value of the key in a map node
+func (d *documentNode) get(key string) *documentNode {
+	for eIdx := range d.entries {
+		if d.entries[eIdx].key != key {
+			continue
+		}
+
+		return d.entries[eIdx].value
+	}
+
+	return nil
+}
+
+// set adds a new key value pair to a map node
+func (d *documentNode) set(
+	key string, line, column int, value *documentNode) error {
+	if d.get(key) != nil {
+		return newDocumentError(ErrDocumentDuplicatedKey, key, line, column)
+	}
+
+	d.entries = append(d.entries, documentEntry{
+		key:    key,
+		line:   line,
+		column: column,
+		value:  value,
+	})
+
+	return nil
+}
+
+// mark records current output offset as the given document position
+func (t *documentTranslation) mark(line, column int) {
+	t.marks = append(t.marks, documentMark{
+		offset: t.output.Len(),
+		line:   line,
+		column: column,
+	})
+}
+
+// position returns document position of the output offset
+func (t *documentTranslation) position(offset int) (int, int) {
+	mIdx := sort.Search(len(t.marks), func(i int) bool {
+		return t.marks[i].offset > offset
+	}) - 1
+
+	if mIdx < 0 {
+		return 1, 1
+	}
+
+	return t.marks[mIdx].line, t.marks[mIdx].column
+}
+
+// scalar writes a quoted scalar value
+func (t *documentTranslation) scalar(n *documentNode) {
+	t.mark(n.line, n.column)
+
+	t.output.WriteByte('"')
+	t.output.WriteString(strings.Replace(strings.Replace(
+		n.scalar, "\\", "\\\\", -1), "\"", "\\\"", -1))
+	t.output.WriteString("\" ")
+}
+
+// block writes a map node as a block of parameters
+func (t *documentTranslation) block(n *documentNode, fs fields) error {
+	t.mark(n.line, n.column)
+
+	t.output.WriteString("{ ")
+
+	translateErr := t.translate(n, fs)
+
+	if translateErr != nil {
+		return translateErr
+	}
+
+	t.output.WriteString("} ")
+
+	return nil
+}
+
+// translate translates a map node to parameters according to fields
+func (t *documentTranslation) translate(n *documentNode, fs fields) error {
+	for _, entry := range n.entries {
+		if entry.value.kind == documentNull {
+			continue
+		}
+
+		var f *field
+
+		for fIdx := range fs {
+			if len(fs[fIdx].Tags) <= 0 || fs[fIdx].JSON != entry.key {
+				continue
+			}
+
+			f = fs[fIdx]
+
+			break
+		}
+
+		if f == nil {
+			return newDocumentError(
+				ErrDocumentUnknownOption, entry.key, entry.line, entry.column)
+		}
+
+		t.mark(entry.line, entry.column)
+
+		t.output.WriteString("-" + f.Tags[0] + " ")
+
+		isBlock := len(f.Sub) > 0
+
+		items := []*documentNode{entry.value}
+
+		if entry.value.kind == documentList {
+			items = entry.value.items
+		}
+
+		for _, item := range items {
+			switch item.kind {
+			case documentMap:
+				if !isBlock {
+					return newDocumentError(
+						ErrDocumentOptionMustNotBeMap, entry.key,
+						item.line, item.column)
+				}
+
+				blockErr := t.block(item, f.Sub)
+
+				if blockErr != nil {
+					return blockErr
+				}
+
+			case documentList:
+				return newDocumentError(
+					ErrDocumentNestedListUnsupported, entry.key,
+					item.line, item.column)
+
+			case documentScalar:
+				if isBlock {
+					return newDocumentError(
+						ErrDocumentOptionMustBeMap, entry.key,
+						item.line, item.column)
+				}
+
+				t.scalar(item)
+			}
+		}
+
+		t.output.WriteString("\r\n")
+	}
+
+	return nil
+}
+
+// decodeDocument decodes the document according to it's format
+func decodeDocument(format string, document []byte) (*documentNode, error) {
+	switch format {
+	case DocumentJSON:
+		return decodeJSON(document)
+
+	case DocumentYAML:
+		return decodeYAML(document)
+
+	case DocumentTOML:
+		return decodeTOML(document)
+	}
+
+	return nil, ErrDocumentUnknownFormat
+}
+
+// ParseDocument decodes a JSON, YAML or TOML document and fillin
+// configuration in the same way as Parse does. Additional parameters will be
+// parsed after the document, so they can override the settings in the
+// document
+func (c *configurator) ParseDocument(
+	format string, document []byte, parameters []byte) error {
+	root, decodeErr := decodeDocument(format, document)
+
+	if decodeErr != nil {
+		return decodeErr
+	}
+
+	if root.kind == documentNull {
+		root = containerNode(documentMap, 1, 1)
+	}
+
+	if root.kind != documentMap {
+		return newDocumentError(
+			ErrDocumentRootMustBeMap, "", root.line, root.column)
+	}
+
+	translated := &documentTranslation{
+		output: bytes.Buffer{},
+		marks:  make([]documentMark, 0, 64),
+	}
+
+	translated.mark(1, 1)
+
+	translateErr := translated.translate(root, c.fields)
+
+	if translateErr != nil {
+		return translateErr
+	}
+
+	documentLen := translated.output.Len()
+
+	translated.output.Write(parameters)
+
+	parseErr := c.Parse(translated.output.Bytes())
+
+	if parseErr == nil {
+		return nil
+	}
+
+	pErr, isParseErr := parseErr.(*ParseError)
+
+	if !isParseErr || pErr.start >= documentLen {
+		return parseErr
+	}
+
+	line, column := translated.position(pErr.start)
+
+	return newDocumentError(pErr, "", line, column)
+}
+
+// documentScanner reads a document byte by byte with position tracking
+type documentScanner struct {
+	input  []byte
+	pos    int
+	line   int
+	column int
+}
+
+func newDocumentScanner(input []byte, line, column int) *documentScanner {
+	return &documentScanner{
+		input:  input,
+		pos:    0,
+		line:   line,
+		column: column,
+	}
+}
+
+// eof returns whether or not the scanner has reached the end
+func (d *documentScanner) eof() bool {
+	return d.pos >= len(d.input)
+}
+
+// peek returns current byte without consuming it
+func (d *documentScanner) peek() byte {
+	if d.eof() {
+		return 0
+	}
+
+	return d.input[d.pos]
+}
+
+// peekAt returns the byte at offset from current position
+func (d *documentScanner) peekAt(offset int) byte {
+	if d.pos+offset >= len(d.input) {
+		return 0
+	}
+
+	return d.input[d.pos+offset]
+}
+
+// next consumes and returns current byte
+func (d *documentScanner) next() byte {
+	if d.eof() {
+		return 0
+	}
+
+	b := d.input[d.pos]
+
+	d.pos++
+
+	if b == '\n' {
+		d.line++
+		d.column = 1
+	} else {
+		d.column++
+	}
+
+	return b
+}
+
+// error returns a DocumentError at current position
+func (d *documentScanner) error(err error, subject string) error {
+	return newDocumentError(err, subject, d.line, d.column)
+}
+
+// unexpected returns an unexpected character error at current position
+func (d *documentScanner) unexpected() error {
+	if d.eof() {
+		return d.error(ErrDocumentUnexpectedEnd, "")
+	}
+
+	return d.error(ErrDocumentUnexpectedCharacter, string(d.peek()))
+}
+
+// scalarNode creates a scalar node
+func scalarNode(value string, line, column int) *documentNode {
+	return &documentNode{
+		kind:    documentScalar,
+		line:    line,
+		column:  column,
+		scalar:  value,
+		items:   nil,
+		entries: nil,
+	}
+}
+
+// containerNode creates a list or a map node
+func containerNode(kind documentKind, line, column int) *documentNode {
+	return &documentNode{
+		kind:    kind,
+		line:    line,
+		column:  column,
+		scalar:  "",
+		items:   nil,
+		entries: nil,
+	}
+}
+
+// nullNode creates a node with no value
+func nullNode(line, column int) *documentNode {
+	return containerNode(documentNull, line, column)
+}
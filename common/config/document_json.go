@@ -0,0 +1,351 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// decodeJSON decodes a JSON document
+func decodeJSON(document []byte) (*documentNode, error) {
+	s := newDocumentScanner(document, 1, 1)
+
+	s.skipJSONSpaces()
+
+	if s.eof() {
+		return nullNode(1, 1), nil
+	}
+
+	root, rootErr := s.jsonValue()
+
+	if rootErr != nil {
+		return nil, rootErr
+	}
+
+	s.skipJSONSpaces()
+
+	if !s.eof() {
+		return nil, s.unexpected()
+	}
+
+	return root, nil
+}
+
+// skipJSONSpaces skips all JSON white spaces
+func (d *documentScanner) skipJSONSpaces() {
+	for !d.eof() {
+		switch d.peek() {
+		case ' ', '\t', '\r', '\n':
+			d.next()
+
+		default:
+			return
+		}
+	}
+}
+
+// jsonValue reads a JSON value
+func (d *documentScanner) jsonValue() (*documentNode, error) {
+	line, column := d.line, d.column
+
+	switch d.peek() {
+	case '{':
+		return d.jsonObject()
+
+	case '[':
+		return d.jsonArray()
+
+	case '"':
+		str, strErr := d.jsonString()
+
+		if strErr != nil {
+			return nil, strErr
+		}
+
+		return scalarNode(str, line, column), nil
+	}
+
+	start := d.pos
+
+	for !d.eof() {
+		c := d.peek()
+
+		if c == ',' || c == '}' || c == ']' ||
+			c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			break
+		}
+
+		d.next()
+	}
+
+	literal := string(d.input[start:d.pos])
+
+	switch literal {
+	case "":
+		return nil, d.unexpected()
+
+	case "null":
+		return nullNode(line, column), nil
+
+	case "true", "false":
+		return scalarNode(literal, line, column), nil
+	}
+
+	_, numErr := strconv.ParseFloat(literal, 64)
+
+	if numErr != nil || strings.HasPrefix(literal, "+") {
+		return nil, newDocumentError(
+			ErrDocumentInvalidValue, literal, line, column)
+	}
+
+	return scalarNode(literal, line, column), nil
+}
+
+// jsonObject reads a JSON object
+func (d *documentScanner) jsonObject() (*documentNode, error) {
+	node := containerNode(documentMap, d.line, d.column)
+
+	d.next()
+	d.skipJSONSpaces()
+
+	if d.peek() == '}' {
+		d.next()
+
+		return node, nil
+	}
+
+	for {
+		d.skipJSONSpaces()
+
+		if d.peek() != '"' {
+			return nil, d.unexpected()
+		}
+
+		keyLine, keyColumn := d.line, d.column
+
+		key, keyErr := d.jsonString()
+
+		if keyErr != nil {
+			return nil, keyErr
+		}
+
+		d.skipJSONSpaces()
+
+		if d.peek() != ':' {
+			return nil, d.unexpected()
+		}
+
+		d.next()
+		d.skipJSONSpaces()
+
+		value, valueErr := d.jsonValue()
+
+		if valueErr != nil {
+			return nil, valueErr
+		}
+
+		setErr := node.set(key, keyLine, keyColumn, value)
+
+		if setErr != nil {
+			return nil, setErr
+		}
+
+		d.skipJSONSpaces()
+
+		switch d.peek() {
+		case ',':
+			d.next()
+
+		case '}':
+			d.next()
+
+			return node, nil
+
+		default:
+			return nil, d.unexpected()
+		}
+	}
+}
+
+// jsonArray reads a JSON array
+func (d *documentScanner) jsonArray() (*documentNode, error) {
+	node := containerNode(documentList, d.line, d.column)
+
+	d.next()
+	d.skipJSONSpaces()
+
+	if d.peek() == ']' {
+		d.next()
+
+		return node, nil
+	}
+
+	for {
+		d.skipJSONSpaces()
+
+		value, valueErr := d.jsonValue()
+
+		if valueErr != nil {
+			return nil, valueErr
+		}
+
+		if value.kind != documentNull {
+			node.items = append(node.items, value)
+		}
+
+		d.skipJSONSpaces()
+
+		switch d.peek() {
+		case ',':
+			d.next()
+
+		case ']':
+			d.next()
+
+			return node, nil
+
+		default:
+			return nil, d.unexpected()
+		}
+	}
+}
+
+// jsonString reads a double quoted JSON string
+func (d *documentScanner) jsonString() (string, error) {
+	result := make([]byte, 0, 64)
+
+	d.next()
+
+	for {
+		if d.eof() {
+			return "", d.unexpected()
+		}
+
+		c := d.peek()
+
+		switch {
+		case c == '"':
+			d.next()
+
+			return string(result), nil
+
+		case c < 0x20:
+			return "", d.error(ErrDocumentInvalidString, "")
+
+		case c != '\\':
+			result = append(result, d.next())
+
+			continue
+		}
+
+		d.next()
+
+		escaped := d.peek()
+
+		switch escaped {
+		case '"', '\\', '/':
+			result = append(result, escaped)
+
+		case 'b':
+			result = append(result, '\b')
+
+		case 'f':
+			result = append(result, '\f')
+
+		case 'n':
+			result = append(result, '\n')
+
+		case 'r':
+			result = append(result, '\r')
+
+		case 't':
+			result = append(result, '\t')
+
+		case 'u':
+			r, rErr := d.jsonUnicode()
+
+			if rErr != nil {
+				return "", rErr
+			}
+
+			result = append(result, string(r)...)
+
+			continue
+
+		default:
+			return "", d.error(ErrDocumentInvalidString, "")
+		}
+
+		d.next()
+	}
+}
+
+// jsonUnicode reads an \uXXXX escape (with the leading u), including the
+// low part of a surrogate pair
+func (d *documentScanner) jsonUnicode() (rune, error) {
+	high, highErr := d.jsonHex4()
+
+	if highErr != nil {
+		return 0, highErr
+	}
+
+	if !utf16.IsSurrogate(high) {
+		return high, nil
+	}
+
+	if d.peek() != '\\' || d.peekAt(1) != 'u' {
+		return utf8.RuneError, nil
+	}
+
+	d.next()
+
+	low, lowErr := d.jsonHex4()
+
+	if lowErr != nil {
+		return 0, lowErr
+	}
+
+	return utf16.DecodeRune(high, low), nil
+}
+
+// jsonHex4 reads u followed by 4 hex digits
+func (d *documentScanner) jsonHex4() (rune, error) {
+	d.next()
+
+	if d.pos+4 > len(d.input) {
+		return 0, d.error(ErrDocumentInvalidString, "")
+	}
+
+	v, vErr := strconv.ParseUint(string(d.input[d.pos:d.pos+4]), 16, 16)
+
+	if vErr != nil {
+		return 0, d.error(ErrDocumentInvalidString, "")
+	}
+
+	for i := 0; i < 4; i++ {
+		d.next()
+	}
+
+	return rune(v), nil
+}
@@ -0,0 +1,65 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	testDocumentDecoding(t, DocumentJSON, []testDocumentDecode{
+		{"", "null"},
+		{"  \n ", "null"},
+		{"{}", "{}"},
+		{"[]", "[]"},
+		{"{\"a\": \"b\", \"c\": 1.5e3, \"d\": -2, \"e\": true}",
+			"{a:\"b\",c:\"1.5e3\",d:\"-2\",e:\"true\"}"},
+		{"{\"a\": {\"b\": {\"c\": [1, [2, 3], {\"d\": null}]}}}",
+			"{a:{b:{c:[\"1\",[\"2\",\"3\"],{d:null}]}}}"},
+		{"[1, null, 2]", "[\"1\",\"2\"]"},
+		{"{\n\t\"a\" :\r\n\"b\"\n}", "{a:\"b\"}"},
+		{"{\"q\": \"\\\"\\\\\\/\\b\\f\\n\\r\\t\"}",
+			"{q:\"\\\"\\\\/\\b\\f\\n\\r\\t\"}"},
+		{"{\"u\": \"\\u0041\\u00e9\\u4e2d\"}", "{u:\"Aé中\"}"},
+		{"{\"u\": \"\\ud83d\\ude00\"}", "{u:\"😀\"}"},
+		{"{\"u\": \"\\ud83dx\"}", "{u:\"\ufffdx\"}"},
+		{"{\"a b\": \"c d\"}", "{a b:\"c d\"}"},
+	})
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	testDocumentDecodingErrors(t, DocumentJSON, []testDocumentDecodeError{
+		{"{", ErrDocumentUnexpectedEnd, 1, 2},
+		{"{\"a\": 1", ErrDocumentUnexpectedEnd, 1, 8},
+		{"{\"a\" 1}", ErrDocumentUnexpectedCharacter, 1, 6},
+		{"{a: 1}", ErrDocumentUnexpectedCharacter, 1, 2},
+		{"{\n  \"a\": 1,\n  \"a\": 2\n}", ErrDocumentDuplicatedKey, 3, 3},
+		{"[1, 2,]", ErrDocumentUnexpectedCharacter, 1, 7},
+		{"[1 2]", ErrDocumentUnexpectedCharacter, 1, 4},
+		{"{\"a\": +1}", ErrDocumentInvalidValue, 1, 7},
+		{"{\"a\":\n  nope}", ErrDocumentInvalidValue, 2, 3},
+		{"{\"a\": \"b\n\"}", ErrDocumentInvalidString, 1, 9},
+		{"{\"a\": \"\\x\"}", ErrDocumentInvalidString, 1, 9},
+		{"{\"a\": \"\\u12\"}", ErrDocumentInvalidString, 1, 10},
+		{"{\"a\": \"b", ErrDocumentUnexpectedEnd, 1, 9},
+		{"{} {}", ErrDocumentUnexpectedCharacter, 1, 4},
+	})
+}
@@ -0,0 +1,315 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var (
+	errDummyDocumentPortZero = errors.New(
+		"Port must not be zero")
+)
+
+type dummyDocumentMapping struct {
+	ID   uint8  `json:"id" cfg:"i,-id:ID"`
+	Host string `json:"host" cfg:"h,-host:Host"`
+}
+
+type dummyDocumentConfig struct {
+	Host     string                 `json:"host" cfg:"h,-host:Host"`
+	Port     uint16                 `json:"port" cfg:"p,-port:Port"`
+	Enabled  bool                   `json:"enabled" cfg:"e,-enabled:Enabled"`
+	Names    []string               `json:"names" cfg:"n,-names:Names"`
+	Mappings []dummyDocumentMapping `json:"mapping" cfg:"m,-mapping:Mappings"`
+}
+
+func (d *dummyDocumentConfig) VerifyPort() error {
+	if d.Port == 0 {
+		return errDummyDocumentPortZero
+	}
+
+	return nil
+}
+
+type testDocumentDecode struct {
+	document string
+	expected string
+}
+
+type testDocumentDecodeError struct {
+	document string
+	err      error
+	line     int
+	column   int
+}
+
+// dumpDocumentNode writes the node in a compact form so the results can be
+// compared as strings
+func dumpDocumentNode(b *strings.Builder, n *documentNode) {
+	switch n.kind {
+	case documentNull:
+		b.WriteString("null")
+
+	case documentScalar:
+		b.WriteString(strconv.Quote(n.scalar))
+
+	case documentList:
+		b.WriteString("[")
+
+		for i, item := range n.items {
+			if i > 0 {
+				b.WriteString(",")
+			}
+
+			dumpDocumentNode(b, item)
+		}
+
+		b.WriteString("]")
+
+	case documentMap:
+		b.WriteString("{")
+
+		for i, entry := range n.entries {
+			if i > 0 {
+				b.WriteString(",")
+			}
+
+			b.WriteString(entry.key)
+			b.WriteString(":")
+
+			dumpDocumentNode(b, entry.value)
+		}
+
+		b.WriteString("}")
+	}
+}
+
+func testDocumentDecoding(
+	t *testing.T, format string, tests []testDocumentDecode) {
+	for tIdx, test := range tests {
+		root, rootErr := decodeDocument(format, []byte(test.document))
+
+		if rootErr != nil {
+			t.Errorf("Test %d failed to decode due to error: %s",
+				tIdx, rootErr)
+
+			return
+		}
+
+		b := strings.Builder{}
+
+		dumpDocumentNode(&b, root)
+
+		if b.String() != test.expected {
+			t.Errorf("Test %d expecting %s, got %s",
+				tIdx, test.expected, b.String())
+
+			return
+		}
+	}
+}
+
+func testDocumentDecodingErrors(
+	t *testing.T, format string, tests []testDocumentDecodeError) {
+	for tIdx, test := range tests {
+		_, rootErr := decodeDocument(format, []byte(test.document))
+
+		dErr, isDocumentErr := rootErr.(*DocumentError)
+
+		if !isDocumentErr {
+			t.Errorf("Test %d expecting a DocumentError, got %v",
+				tIdx, rootErr)
+
+			return
+		}
+
+		if !errors.Is(dErr, test.err) {
+			t.Errorf("Test %d expecting error %v, got %v",
+				tIdx, test.err, dErr)
+
+			return
+		}
+
+		line, column := dErr.Position()
+
+		if line != test.line || column != test.column {
+			t.Errorf("Test %d expecting error at %d:%d, got %d:%d",
+				tIdx, test.line, test.column, line, column)
+
+			return
+		}
+	}
+}
+
+func testDocument(
+	t *testing.T, format string, document string) *dummyDocumentConfig {
+	cfg := &dummyDocumentConfig{}
+
+	c, importErr := Import(cfg)
+
+	if importErr != nil {
+		t.Error("Failed to import configuration due to error:", importErr)
+
+		return nil
+	}
+
+	parseErr := c.ParseDocument(format, []byte(document), []byte("-e yes"))
+
+	if parseErr != nil {
+		t.Error("Failed to parse document due to error:", parseErr)
+
+		return nil
+	}
+
+	if cfg.Host != "local \"host\"" || cfg.Port != 8080 || !cfg.Enabled {
+		t.Errorf("Unexpected configuration: %+v", cfg)
+
+		return nil
+	}
+
+	if len(cfg.Names) != 2 || cfg.Names[0] != "a" || cfg.Names[1] != "b c" {
+		t.Errorf("Unexpected names: %v", cfg.Names)
+
+		return nil
+	}
+
+	if len(cfg.Mappings) != 2 ||
+		cfg.Mappings[0].ID != 1 || cfg.Mappings[0].Host != "one" ||
+		cfg.Mappings[1].ID != 2 || cfg.Mappings[1].Host != "two" {
+		t.Errorf("Unexpected mappings: %+v", cfg.Mappings)
+
+		return nil
+	}
+
+	return cfg
+}
+
+func TestParseDocumentJSON(t *testing.T) {
+	testDocument(t, DocumentJSON, `{
+		"host": "local \"host\"",
+		"port": 8080,
+		"enabled": false,
+		"names": ["a", "b c"],
+		"mapping": [
+			{"id": 1, "host": "one"},
+			{"id": 2, "host": "two"}
+		]
+	}`)
+}
+
+func TestParseDocumentYAML(t *testing.T) {
+	testDocument(t, DocumentYAML, `
+# Comment
+host: 'local "host"'
+port: 8080 # Comment
+names: [a, "b c"]
+mapping:
+- id: 1
+  host: one
+- {id: 2, host: two}
+`)
+}
+
+func TestParseDocumentTOML(t *testing.T) {
+	testDocument(t, DocumentTOML, `
+host = "local \"host\""
+port = 8_080
+names = [
+  "a",
+  'b c', # Comment
+]
+
+[[mapping]]
+id = 1
+host = "one"
+
+[[mapping]]
+id = 2
+host = "two"
+`)
+}
+
+func TestParseDocumentErrors(t *testing.T) {
+	tests := []struct {
+		format   string
+		document string
+		err      error
+		line     int
+		column   int
+	}{
+		{DocumentJSON, "{\n  \"port\": 0\n}", errDummyDocumentPortZero, 2, 3},
+		{DocumentJSON, "{\n  \"port\": 1,\n  \"what\": 1\n}",
+			ErrDocumentUnknownOption, 3, 3},
+		{DocumentJSON, "{\n  \"port\": 1,,\n}",
+			ErrDocumentUnexpectedCharacter, 2, 13},
+		{DocumentYAML, "port: 1\nmapping:\n  - id: 1\n    host: {a: b}\n",
+			ErrDocumentOptionMustNotBeMap, 4, 11},
+		{DocumentYAML, "port: 1\nhost:\n    a: b\n  c: d\n",
+			ErrDocumentInvalidIndent, 4, 3},
+		{DocumentYAML, "port: 1\nport: 2\n", ErrDocumentDuplicatedKey, 2, 1},
+		{DocumentTOML, "port = 1\nenabled = \"maybe\"\n",
+			ErrValueReflectInvalidBoolString, 2, 1},
+		{DocumentTOML, "port = 1\nhost = \"a\" b\n",
+			ErrDocumentUnexpectedCharacter, 2, 12},
+	}
+
+	for tIdx, test := range tests {
+		c, importErr := Import(&dummyDocumentConfig{})
+
+		if importErr != nil {
+			t.Error("Failed to import configuration due to error:", importErr)
+
+			return
+		}
+
+		parseErr := c.ParseDocument(
+			test.format, []byte(test.document), nil)
+
+		dErr, isDocumentErr := parseErr.(*DocumentError)
+
+		if !isDocumentErr {
+			t.Errorf("Test %d expecting a DocumentError, got %v",
+				tIdx, parseErr)
+
+			return
+		}
+
+		if !errors.Is(dErr, test.err) {
+			t.Errorf("Test %d expecting error %v, got %v",
+				tIdx, test.err, dErr)
+
+			return
+		}
+
+		line, column := dErr.Position()
+
+		if line != test.line || column != test.column {
+			t.Errorf("Test %d expecting error at %d:%d, got %d:%d",
+				tIdx, test.line, test.column, line, column)
+
+			return
+		}
+	}
+}
@@ -0,0 +1,624 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// tomlKeySegment is a segment of a dotted TOML key
+type tomlKeySegment struct {
+	key    string
+	line   int
+	column int
+}
+
+// decodeTOML decodes a TOML document
+func decodeTOML(document []byte) (*documentNode, error) {
+	s := newDocumentScanner(document, 1, 1)
+	root := containerNode(documentMap, 1, 1)
+	current := root
+
+	for {
+		s.tomlSkip(true)
+
+		if s.eof() {
+			return root, nil
+		}
+
+		if s.peek() != '[' {
+			kvErr := s.tomlKeyValue(current)
+
+			if kvErr != nil {
+				return nil, kvErr
+			}
+
+			lineEndErr := s.tomlLineEnd()
+
+			if lineEndErr != nil {
+				return nil, lineEndErr
+			}
+
+			continue
+		}
+
+		line, column := s.line, s.column
+		isArray := s.peekAt(1) == '['
+
+		s.next()
+
+		if isArray {
+			s.next()
+		}
+
+		path, pathErr := s.tomlKey()
+
+		if pathErr != nil {
+			return nil, pathErr
+		}
+
+		if s.peek() != ']' {
+			return nil, s.unexpected()
+		}
+
+		s.next()
+
+		if isArray {
+			if s.peek() != ']' {
+				return nil, s.unexpected()
+			}
+
+			s.next()
+		}
+
+		lineEndErr := s.tomlLineEnd()
+
+		if lineEndErr != nil {
+			return nil, lineEndErr
+		}
+
+		parent, parentErr := tomlWalk(root, path[:len(path)-1])
+
+		if parentErr != nil {
+			return nil, parentErr
+		}
+
+		last := path[len(path)-1]
+		existing := parent.get(last.key)
+
+		if !isArray {
+			switch {
+			case existing == nil:
+				current = containerNode(documentMap, line, column)
+
+				parent.set(last.key, last.line, last.column, current)
+
+			case existing.kind == documentMap:
+				current = existing
+
+			default:
+				return nil, newDocumentError(
+					ErrDocumentDuplicatedKey, last.key, last.line, last.column)
+			}
+
+			continue
+		}
+
+		if existing == nil {
+			existing = containerNode(documentList, line, column)
+
+			parent.set(last.key, last.line, last.column, existing)
+		} else if existing.kind != documentList {
+			return nil, newDocumentError(
+				ErrDocumentDuplicatedKey, last.key, last.line, last.column)
+		}
+
+		current = containerNode(documentMap, line, column)
+
+		existing.items = append(existing.items, current)
+	}
+}
+
+// tomlWalk returns the table of the path, tables will be created when
+// they're not existed
+func tomlWalk(
+	node *documentNode, path []tomlKeySegment) (*documentNode, error) {
+	for _, seg := range path {
+		child := node.get(seg.key)
+
+		if child == nil {
+			child = containerNode(documentMap, seg.line, seg.column)
+
+			node.set(seg.key, seg.line, seg.column, child)
+		}
+
+		if child.kind == documentList && len(child.items) > 0 {
+			child = child.items[len(child.items)-1]
+		}
+
+		if child.kind != documentMap {
+			return nil, newDocumentError(
+				ErrDocumentDuplicatedKey, seg.key, seg.line, seg.column)
+		}
+
+		node = child
+	}
+
+	return node, nil
+}
+
+// tomlSkip skips white spaces and comments. Line breaks will be skipped
+// too when newLine is true
+func (d *documentScanner) tomlSkip(newLine bool) {
+	for !d.eof() {
+		switch d.peek() {
+		case ' ', '\t':
+			d.next()
+
+		case '\r', '\n':
+			if !newLine {
+				return
+			}
+
+			d.next()
+
+		case '#':
+			for !d.eof() && d.peek() != '\n' {
+				d.next()
+			}
+
+		default:
+			return
+		}
+	}
+}
+
+// tomlLineEnd consumes the rest of current line, which may only contain
+// white spaces and comment
+func (d *documentScanner) tomlLineEnd() error {
+	d.tomlSkip(false)
+
+	if d.peek() == '\r' {
+		d.next()
+	}
+
+	if d.eof() {
+		return nil
+	}
+
+	if d.peek() != '\n' {
+		return d.unexpected()
+	}
+
+	d.next()
+
+	return nil
+}
+
+// tomlKey reads a dotted key
+func (d *documentScanner) tomlKey() ([]tomlKeySegment, error) {
+	path := make([]tomlKeySegment, 0, 4)
+
+	for {
+		d.tomlSkip(false)
+
+		line, column := d.line, d.column
+
+		var key string
+		var keyErr error
+
+		switch d.peek() {
+		case '"':
+			key, keyErr = d.tomlBasicString()
+
+		case '\'':
+			key, keyErr = d.tomlLiteralString()
+
+		default:
+			start := d.pos
+
+			for !d.eof() {
+				c := d.peek()
+
+				if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') &&
+					(c < '0' || c > '9') && c != '_' && c != '-' {
+					break
+				}
+
+				d.next()
+			}
+
+			if start == d.pos {
+				return nil, d.unexpected()
+			}
+
+			key = string(d.input[start:d.pos])
+		}
+
+		if keyErr != nil {
+			return nil, keyErr
+		}
+
+		path = append(path, tomlKeySegment{
+			key:    key,
+			line:   line,
+			column: column,
+		})
+
+		d.tomlSkip(false)
+
+		if d.peek() != '.' {
+			return path, nil
+		}
+
+		d.next()
+	}
+}
+
+// tomlKeyValue reads a key = value pair into the table
+func (d *documentScanner) tomlKeyValue(table *documentNode) error {
+	path, pathErr := d.tomlKey()
+
+	if pathErr != nil {
+		return pathErr
+	}
+
+	if d.peek() != '=' {
+		return d.unexpected()
+	}
+
+	d.next()
+	d.tomlSkip(false)
+
+	value, valueErr := d.tomlValue()
+
+	if valueErr != nil {
+		return valueErr
+	}
+
+	parent, parentErr := tomlWalk(table, path[:len(path)-1])
+
+	if parentErr != nil {
+		return parentErr
+	}
+
+	last := path[len(path)-1]
+
+	return parent.set(last.key, last.line, last.column, value)
+}
+
+// tomlValue reads a value
+func (d *documentScanner) tomlValue() (*documentNode, error) {
+	line, column := d.line, d.column
+
+	switch d.peek() {
+	case '"', '\'':
+		var str string
+		var strErr error
+
+		if d.peek() == '"' {
+			str, strErr = d.tomlBasicString()
+		} else {
+			str, strErr = d.tomlLiteralString()
+		}
+
+		if strErr != nil {
+			return nil, strErr
+		}
+
+		return scalarNode(str, line, column), nil
+
+	case '[':
+		return d.tomlArray()
+
+	case '{':
+		return d.tomlInlineTable()
+	}
+
+	start := d.pos
+
+	for !d.eof() && strings.IndexByte(" \t\r\n,]}#", d.peek()) < 0 {
+		d.next()
+	}
+
+	literal := string(d.input[start:d.pos])
+
+	switch literal {
+	case "":
+		return nil, d.unexpected()
+
+	case "true", "false":
+		return scalarNode(literal, line, column), nil
+	}
+
+	number := strings.Replace(literal, "_", "", -1)
+	digits := strings.TrimLeft(number, "+-")
+
+	// Leading zeros are not allowed, and ParseInt would read them as octal
+	if len(digits) < 2 || digits[0] != '0' ||
+		digits[1] < '0' || digits[1] > '9' {
+		integer, intErr := strconv.ParseInt(number, 0, 64)
+
+		if intErr == nil {
+			return scalarNode(
+				strconv.FormatInt(integer, 10), line, column), nil
+		}
+
+		_, floatErr := strconv.ParseFloat(number, 64)
+
+		if floatErr == nil {
+			return scalarNode(number, line, column), nil
+		}
+	}
+
+	// Dates and times are kept as they are
+	if literal[0] >= '0' && literal[0] <= '9' &&
+		strings.ContainsAny(literal, "-:") &&
+		strings.Trim(literal, "0123456789-:.TZtz+") == "" {
+		return scalarNode(literal, line, column), nil
+	}
+
+	return nil, newDocumentError(ErrDocumentInvalidValue, literal, line, column)
+}
+
+// tomlArray reads an array
+func (d *documentScanner) tomlArray() (*documentNode, error) {
+	node := containerNode(documentList, d.line, d.column)
+
+	d.next()
+
+	for {
+		d.tomlSkip(true)
+
+		if d.peek() == ']' {
+			d.next()
+
+			return node, nil
+		}
+
+		item, itemErr := d.tomlValue()
+
+		if itemErr != nil {
+			return nil, itemErr
+		}
+
+		node.items = append(node.items, item)
+
+		d.tomlSkip(true)
+
+		switch d.peek() {
+		case ',':
+			d.next()
+
+		case ']':
+
+		default:
+			return nil, d.unexpected()
+		}
+	}
+}
+
+// tomlInlineTable reads an inline table
+func (d *documentScanner) tomlInlineTable() (*documentNode, error) {
+	node := containerNode(documentMap, d.line, d.column)
+
+	d.next()
+
+	for {
+		d.tomlSkip(false)
+
+		if d.peek() == '}' {
+			d.next()
+
+			return node, nil
+		}
+
+		kvErr := d.tomlKeyValue(node)
+
+		if kvErr != nil {
+			return nil, kvErr
+		}
+
+		d.tomlSkip(false)
+
+		switch d.peek() {
+		case ',':
+			d.next()
+
+		case '}':
+
+		default:
+			return nil, d.unexpected()
+		}
+	}
+}
+
+// tomlLiteralString reads a single quoted string, or a multi-line one
+// when it starts with three single quotes
+func (d *documentScanner) tomlLiteralString() (string, error) {
+	multiLine := d.peekAt(1) == '\'' && d.peekAt(2) == '\''
+
+	if !multiLine {
+		d.next()
+
+		start := d.pos
+
+		for d.peek() != '\'' {
+			if d.eof() || d.peek() == '\n' {
+				return "", d.error(ErrDocumentInvalidString, "")
+			}
+
+			d.next()
+		}
+
+		d.next()
+
+		return string(d.input[start : d.pos-1]), nil
+	}
+
+	d.next()
+	d.next()
+	d.next()
+	d.tomlSkipFirstNewLine()
+
+	start := d.pos
+
+	for {
+		if d.eof() {
+			return "", d.error(ErrDocumentInvalidString, "")
+		}
+
+		if d.peek() == '\'' && d.peekAt(1) == '\'' && d.peekAt(2) == '\'' {
+			result := string(d.input[start:d.pos])
+
+			d.next()
+			d.next()
+			d.next()
+
+			return result, nil
+		}
+
+		d.next()
+	}
+}
+
+// tomlSkipFirstNewLine skips the line break right after the opening
+// delimiter of a multi-line string
+func (d *documentScanner) tomlSkipFirstNewLine() {
+	if d.peek() == '\r' && d.peekAt(1) == '\n' {
+		d.next()
+	}
+
+	if d.peek() == '\n' {
+		d.next()
+	}
+}
+
+// tomlBasicString reads a double quoted string, or a multi-line one when
+// it starts with """
+func (d *documentScanner) tomlBasicString() (string, error) {
+	multiLine := d.peekAt(1) == '"' && d.peekAt(2) == '"'
+	result := make([]byte, 0, 64)
+
+	d.next()
+
+	if multiLine {
+		d.next()
+		d.next()
+		d.tomlSkipFirstNewLine()
+	}
+
+	for {
+		if d.eof() {
+			return "", d.error(ErrDocumentInvalidString, "")
+		}
+
+		c := d.peek()
+
+		switch {
+		case c == '"' && !multiLine:
+			d.next()
+
+			return string(result), nil
+
+		case c == '"' && d.peekAt(1) == '"' && d.peekAt(2) == '"':
+			d.next()
+			d.next()
+			d.next()
+
+			return string(result), nil
+
+		case c == '\n' && !multiLine:
+			return "", d.error(ErrDocumentInvalidString, "")
+
+		case c != '\\':
+			result = append(result, d.next())
+
+			continue
+		}
+
+		d.next()
+
+		escaped := d.peek()
+
+		switch escaped {
+		case '"', '\\':
+			result = append(result, escaped)
+
+		case 'b':
+			result = append(result, '\b')
+
+		case 'f':
+			result = append(result, '\f')
+
+		case 'n':
+			result = append(result, '\n')
+
+		case 'r':
+			result = append(result, '\r')
+
+		case 't':
+			result = append(result, '\t')
+
+		case 'u', 'U':
+			digits := 4
+
+			if escaped == 'U' {
+				digits = 8
+			}
+
+			if d.pos+1+digits > len(d.input) {
+				return "", d.error(ErrDocumentInvalidString, "")
+			}
+
+			r, rErr := strconv.ParseUint(
+				string(d.input[d.pos+1:d.pos+1+digits]), 16, 32)
+
+			if rErr != nil {
+				return "", d.error(ErrDocumentInvalidString, "")
+			}
+
+			result = append(result, string(rune(r))...)
+
+			for i := 0; i < digits; i++ {
+				d.next()
+			}
+
+		case ' ', '\t', '\r', '\n':
+			if !multiLine {
+				return "", d.error(ErrDocumentInvalidString, "")
+			}
+
+			// Line ending backslash trims all following white spaces
+			for !d.eof() && strings.IndexByte(" \t\r\n", d.peek()) >= 0 {
+				d.next()
+			}
+
+			continue
+
+		default:
+			return "", d.error(ErrDocumentInvalidString, "")
+		}
+
+		d.next()
+	}
+}
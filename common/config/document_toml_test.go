@@ -0,0 +1,85 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"testing"
+)
+
+func TestDecodeTOML(t *testing.T) {
+	testDocumentDecoding(t, DocumentTOML, []testDocumentDecode{
+		{"", "{}"},
+		{"# comment\n\n", "{}"},
+		{"a = \"b\"\nc = 1\nd = true\n", "{a:\"b\",c:\"1\",d:\"true\"}"},
+		{"a = 1 # comment\n# full line\nb = 2", "{a:\"1\",b:\"2\"}"},
+		{"a = 1_000\nb = 0xff\nc = -1.5e3\nd = +7\ne = 0\n",
+			"{a:\"1000\",b:\"255\",c:\"-1.5e3\",d:\"7\",e:\"0\"}"},
+		{"a = 1979-05-27T07:32:00Z\nb = 07:32:00\n",
+			"{a:\"1979-05-27T07:32:00Z\",b:\"07:32:00\"}"},
+		{"a.b.c = 1\na.b.d = 2\n\"e.f\" = 3\n",
+			"{a:{b:{c:\"1\",d:\"2\"}},e.f:\"3\"}"},
+		{"[a]\nb = 1\n[a.c]\nd = 2\n[e]\nf = 3\n",
+			"{a:{b:\"1\",c:{d:\"2\"}},e:{f:\"3\"}}"},
+		{"[a.b]\nc = 1\n[a]\nd = 2\n", "{a:{b:{c:\"1\"},d:\"2\"}}"},
+		{"[[a]]\nb = 1\n[[a]]\nb = 2\n[a.c]\nd = 3\n",
+			"{a:[{b:\"1\"},{b:\"2\",c:{d:\"3\"}}]}"},
+		{"[[a]]\n[[a.b]]\nc = 1\n[[a.b]]\nc = 2\n[[a]]\n",
+			"{a:[{b:[{c:\"1\"},{c:\"2\"}]},{}]}"},
+		{"[ a . 'b c' ]\nd = 1\n", "{a:{b c:{d:\"1\"}}}"},
+		{"a = [1, [2, 3], {b = 4}]\nc = []\n",
+			"{a:[\"1\",[\"2\",\"3\"],{b:\"4\"}],c:[]}"},
+		{"a = [\n  1, # one\n  2,\n]\n", "{a:[\"1\",\"2\"]}"},
+		{"a = {b = 1, c.d = \"e\"}\n", "{a:{b:\"1\",c:{d:\"e\"}}}"},
+		{"a = \"\\\"\\\\\\b\\f\\n\\r\\t\"\n",
+			"{a:\"\\\"\\\\\\b\\f\\n\\r\\t\"}"},
+		{"a = \"\\u00e9\\U0001F600\"\n", "{a:\"é😀\"}"},
+		{"a = 'C:\\path\\# not comment'\n",
+			"{a:\"C:\\\\path\\\\# not comment\"}"},
+		{"a = \"\"\"\nx\n  y\"\"\"\n", "{a:\"x\\n  y\"}"},
+		{"a = \"\"\"\nx \\\n    y\"\"\"\n", "{a:\"x y\"}"},
+		{"a = '''\nx\\n\n'y'\n'''\n", "{a:\"x\\\\n\\n'y'\\n\"}"},
+		{"a = 1\r\nb = 2\r\n", "{a:\"1\",b:\"2\"}"},
+	})
+}
+
+func TestDecodeTOMLErrors(t *testing.T) {
+	testDocumentDecodingErrors(t, DocumentTOML, []testDocumentDecodeError{
+		{"a = 1\na = 2\n", ErrDocumentDuplicatedKey, 2, 1},
+		{"a = 1\n[a]\n", ErrDocumentDuplicatedKey, 2, 2},
+		{"[a]\n[[a]]\n", ErrDocumentDuplicatedKey, 2, 3},
+		{"a.b = 1\na.b.c = 2\n", ErrDocumentDuplicatedKey, 2, 3},
+		{"a = 1 2\n", ErrDocumentUnexpectedCharacter, 1, 7},
+		{"a 1\n", ErrDocumentUnexpectedCharacter, 1, 3},
+		{"= 1\n", ErrDocumentUnexpectedCharacter, 1, 1},
+		{"a =\n", ErrDocumentUnexpectedCharacter, 1, 4},
+		{"[a\nb = 1\n", ErrDocumentUnexpectedCharacter, 1, 3},
+		{"[[a]\n", ErrDocumentUnexpectedCharacter, 1, 5},
+		{"a = yes\n", ErrDocumentInvalidValue, 1, 5},
+		{"\n  a = 010\n", ErrDocumentInvalidValue, 2, 7},
+		{"a = \"b\n\"\n", ErrDocumentInvalidString, 1, 7},
+		{"a = \"\\q\"\n", ErrDocumentInvalidString, 1, 7},
+		{"a = \"\\u12\"\n", ErrDocumentInvalidString, 1, 7},
+		{"a = 'b\n", ErrDocumentInvalidString, 1, 7},
+		{"a = \"\"\"b\n", ErrDocumentInvalidString, 2, 1},
+		{"a = [1, 2\n", ErrDocumentUnexpectedEnd, 2, 1},
+		{"a = {b = 1,, c = 2}\n", ErrDocumentUnexpectedCharacter, 1, 12},
+	})
+}
@@ -0,0 +1,693 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"strings"
+)
+
+// yamlLine is a meaningful line of a YAML document
+type yamlLine struct {
+	raw     int
+	line    int
+	indent  int
+	invalid bool
+	text    string
+}
+
+// yamlParser parses a subset of YAML which is enough to describe
+// configurations: block mappings and sequences, flow collections, plain,
+// quoted and block scalars and comments. Anchors, aliases and tags are not
+// supported
+type yamlParser struct {
+	raw   []string
+	lines []yamlLine
+	idx   int
+}
+
+// decodeYAML decodes a YAML document
+func decodeYAML(document []byte) (*documentNode, error) {
+	p := &yamlParser{
+		raw:   strings.Split(string(document), "\n"),
+		lines: make([]yamlLine, 0, 64),
+		idx:   0,
+	}
+
+	for rIdx := range p.raw {
+		p.raw[rIdx] = strings.TrimSuffix(p.raw[rIdx], "\r")
+
+		l := yamlStripComment(p.raw[rIdx])
+
+		text := strings.TrimLeft(l, " \t")
+		text = strings.TrimRight(text, " \t")
+
+		if text == "" || text == "---" || text == "..." {
+			continue
+		}
+
+		indent := len(l) - len(strings.TrimLeft(l, " "))
+
+		p.lines = append(p.lines, yamlLine{
+			raw:     rIdx,
+			line:    rIdx + 1,
+			indent:  indent,
+			invalid: l[indent] == '\t',
+			text:    text,
+		})
+	}
+
+	if len(p.lines) <= 0 {
+		return nullNode(1, 1), nil
+	}
+
+	root, rootErr := p.block(p.lines[0].indent)
+
+	if rootErr != nil {
+		return nil, rootErr
+	}
+
+	if p.idx < len(p.lines) {
+		return nil, p.invalidIndent(p.lines[p.idx])
+	}
+
+	return root, nil
+}
+
+// yamlStripComment removes comment from a line
+func yamlStripComment(l string) string {
+	quote := byte(0)
+
+	for i := 0; i < len(l); i++ {
+		c := l[i]
+
+		if quote != 0 {
+			switch {
+			case c == '\\' && quote == '"':
+				i++
+
+			case c == '\'' && quote == '\'' && i+1 < len(l) && l[i+1] == '\'':
+				i++
+
+			case c == quote:
+				quote = 0
+			}
+
+			continue
+		}
+
+		afterSpace := i == 0 || l[i-1] == ' ' || l[i-1] == '\t'
+
+		switch {
+		case c == '#' && afterSpace:
+			return l[:i]
+
+		case (c == '"' || c == '\'') &&
+			(afterSpace || strings.IndexByte("[{,", l[i-1]) >= 0):
+			quote = c
+		}
+	}
+
+	return l
+}
+
+// yamlIsSequenceItem returns whether or not the text is a sequence item
+func yamlIsSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlIsMapping returns whether or not the text is a mapping entry
+func yamlIsMapping(text string) bool {
+	if text == "" {
+		return false
+	}
+
+	_, _, _, isKey := yamlSplitKey(text)
+
+	return isKey
+}
+
+// yamlSplitKey splits a "key: value" text. It returns the key, the value and
+// the offset of the value in the text
+func yamlSplitKey(text string) (string, string, int, bool) {
+	key := ""
+	keyEnd := 0
+
+	switch text[0] {
+	case '"', '\'':
+		s := newDocumentScanner([]byte(text), 1, 1)
+
+		var keyErr error
+
+		if text[0] == '"' {
+			key, keyErr = s.jsonString()
+		} else {
+			key, keyErr = s.yamlSingleQuoted()
+		}
+
+		if keyErr != nil {
+			return "", "", 0, false
+		}
+
+		for s.peek() == ' ' {
+			s.next()
+		}
+
+		if s.peek() != ':' {
+			return "", "", 0, false
+		}
+
+		keyEnd = s.pos
+
+	case '[', '{', '-', '|', '>', '&', '*', '!':
+		return "", "", 0, false
+
+	default:
+		for keyEnd = 0; keyEnd < len(text); keyEnd++ {
+			if text[keyEnd] != ':' {
+				continue
+			}
+
+			if keyEnd+1 == len(text) || text[keyEnd+1] == ' ' {
+				break
+			}
+		}
+
+		if keyEnd >= len(text) {
+			return "", "", 0, false
+		}
+
+		key = strings.TrimSpace(text[:keyEnd])
+	}
+
+	if keyEnd+1 < len(text) && text[keyEnd+1] != ' ' {
+		return "", "", 0, false
+	}
+
+	value := strings.TrimLeft(text[keyEnd+1:], " ")
+
+	return key, value, len(text) - len(value), true
+}
+
+// invalidIndent returns an indentation error of the line
+func (y *yamlParser) invalidIndent(l yamlLine) error {
+	return newDocumentError(ErrDocumentInvalidIndent, "", l.line, l.indent+1)
+}
+
+// unexpected returns an error for unexpected line content
+func (y *yamlParser) unexpected(l yamlLine) error {
+	return newDocumentError(
+		ErrDocumentUnexpectedCharacter, l.text, l.line, l.indent+1)
+}
+
+// current returns current line, and whether or not it belongs to the block
+// of given indent
+func (y *yamlParser) current(indent int) (yamlLine, bool, error) {
+	if y.idx >= len(y.lines) {
+		return yamlLine{}, false, nil
+	}
+
+	l := y.lines[y.idx]
+
+	if l.invalid {
+		return yamlLine{}, false, y.invalidIndent(l)
+	}
+
+	if l.indent < indent {
+		return l, false, nil
+	}
+
+	if l.indent > indent {
+		return yamlLine{}, false, y.invalidIndent(l)
+	}
+
+	return l, true, nil
+}
+
+// block parses a block at given indent
+func (y *yamlParser) block(indent int) (*documentNode, error) {
+	if y.lines[y.idx].invalid {
+		return nil, y.invalidIndent(y.lines[y.idx])
+	}
+
+	if yamlIsSequenceItem(y.lines[y.idx].text) {
+		return y.sequence(indent)
+	}
+
+	return y.mapping(indent)
+}
+
+// children parses the nested value which starts from the next line. When
+// sequence is true, a sequence at the same indent is also accepted
+func (y *yamlParser) children(
+	indent int, sequence bool, line, column int) (*documentNode, error) {
+	if y.idx >= len(y.lines) {
+		return nullNode(line, column), nil
+	}
+
+	next := y.lines[y.idx]
+
+	switch {
+	case next.indent > indent:
+		return y.block(next.indent)
+
+	case sequence && next.indent == indent && yamlIsSequenceItem(next.text):
+		return y.sequence(indent)
+	}
+
+	return nullNode(line, column), nil
+}
+
+// sequence parses a block sequence
+func (y *yamlParser) sequence(indent int) (*documentNode, error) {
+	node := containerNode(
+		documentList, y.lines[y.idx].line, y.lines[y.idx].indent+1)
+
+	for {
+		l, ok, lErr := y.current(indent)
+
+		if lErr != nil {
+			return nil, lErr
+		}
+
+		if !ok || !yamlIsSequenceItem(l.text) {
+			return node, nil
+		}
+
+		rest := strings.TrimLeft(l.text[1:], " ")
+		restIndent := l.indent + len(l.text) - len(rest)
+
+		var item *documentNode
+		var itemErr error
+
+		switch {
+		case rest == "":
+			y.idx++
+
+			item, itemErr = y.children(indent, false, l.line, l.indent+1)
+
+		case yamlIsMapping(rest) || yamlIsSequenceItem(rest):
+			y.lines[y.idx].indent = restIndent
+			y.lines[y.idx].text = rest
+
+			item, itemErr = y.block(restIndent)
+
+		default:
+			y.idx++
+
+			item, itemErr = y.value(rest, l, restIndent+1, indent)
+		}
+
+		if itemErr != nil {
+			return nil, itemErr
+		}
+
+		if item.kind != documentNull {
+			node.items = append(node.items, item)
+		}
+	}
+}
+
+// mapping parses a block mapping
+func (y *yamlParser) mapping(indent int) (*documentNode, error) {
+	node := containerNode(
+		documentMap, y.lines[y.idx].line, y.lines[y.idx].indent+1)
+
+	for {
+		l, ok, lErr := y.current(indent)
+
+		if lErr != nil {
+			return nil, lErr
+		}
+
+		if !ok {
+			return node, nil
+		}
+
+		key, value, valueOffset, isKey := yamlSplitKey(l.text)
+
+		if !isKey {
+			return nil, y.unexpected(l)
+		}
+
+		y.idx++
+
+		var v *documentNode
+		var vErr error
+
+		if value == "" {
+			v, vErr = y.children(indent, true, l.line, l.indent+1)
+		} else {
+			v, vErr = y.value(value, l, l.indent+valueOffset+1, indent)
+		}
+
+		if vErr != nil {
+			return nil, vErr
+		}
+
+		setErr := node.set(key, l.line, l.indent+1, v)
+
+		if setErr != nil {
+			return nil, setErr
+		}
+	}
+}
+
+// value parses an inline value which starts at given column of the line
+func (y *yamlParser) value(
+	text string, l yamlLine, column int, indent int) (*documentNode, error) {
+	switch text[0] {
+	case '[', '{', '"', '\'':
+		s := newDocumentScanner([]byte(text), l.line, column)
+
+		v, vErr := s.yamlFlowValue(false)
+
+		if vErr != nil {
+			return nil, vErr
+		}
+
+		for s.peek() == ' ' {
+			s.next()
+		}
+
+		if !s.eof() {
+			return nil, s.unexpected()
+		}
+
+		return v, nil
+
+	case '|', '>':
+		return y.blockScalar(text, l, column, indent)
+
+	case '&', '*', '!', '%', '@', '`':
+		return nil, newDocumentError(ErrDocumentInvalidValue, text, l.line, column)
+	}
+
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nullNode(l.line, column), nil
+	}
+
+	return scalarNode(text, l.line, column), nil
+}
+
+// blockScalar parses a literal (|) or folded (>) block scalar
+func (y *yamlParser) blockScalar(
+	header string, l yamlLine, column int, indent int) (*documentNode, error) {
+	chomp := byte(0)
+
+	switch header[1:] {
+	case "":
+	case "-", "+":
+		chomp = header[1]
+
+	default:
+		return nil, newDocumentError(
+			ErrDocumentInvalidValue, header, l.line, column)
+	}
+
+	contentIndent := -1
+	lines := make([]string, 0, 16)
+	lastRaw := l.raw
+
+	for rIdx := l.raw + 1; rIdx < len(y.raw); rIdx++ {
+		r := y.raw[rIdx]
+		rIndent := len(r) - len(strings.TrimLeft(r, " "))
+
+		if strings.TrimSpace(r) == "" {
+			if rIdx == len(y.raw)-1 {
+				break
+			}
+
+			lines = append(lines, "")
+
+			continue
+		}
+
+		if contentIndent < 0 {
+			if rIndent <= indent {
+				break
+			}
+
+			contentIndent = rIndent
+		}
+
+		if rIndent < contentIndent {
+			break
+		}
+
+		lines = append(lines, r[contentIndent:])
+		lastRaw = rIdx
+	}
+
+	if chomp != '+' {
+		lines = lines[:lastRaw-l.raw]
+	}
+
+	for y.idx < len(y.lines) && y.lines[y.idx].raw <= lastRaw {
+		y.idx++
+	}
+
+	result := ""
+
+	if header[0] == '|' {
+		result = strings.Join(lines, "\n")
+	} else {
+		for lIdx, line := range lines {
+			switch {
+			case line == "":
+				result += "\n"
+
+			case lIdx == 0, lines[lIdx-1] == "":
+
+			default:
+				result += " "
+			}
+
+			result += line
+		}
+	}
+
+	content := strings.TrimRight(result, "\n")
+
+	switch chomp {
+	case '-':
+		result = content
+
+	case '+':
+		result += "\n"
+
+	default:
+		if content != "" {
+			result = content + "\n"
+		} else {
+			result = ""
+		}
+	}
+
+	return scalarNode(result, l.line, column), nil
+}
+
+// yamlSingleQuoted reads a single quoted YAML string
+func (d *documentScanner) yamlSingleQuoted() (string, error) {
+	result := make([]byte, 0, 64)
+
+	d.next()
+
+	for {
+		if d.eof() {
+			return "", d.unexpected()
+		}
+
+		c := d.next()
+
+		if c != '\'' {
+			result = append(result, c)
+
+			continue
+		}
+
+		if d.peek() != '\'' {
+			return string(result), nil
+		}
+
+		result = append(result, d.next())
+	}
+}
+
+// yamlFlowValue reads a value inside a YAML flow collection. When key is
+// true, the value is terminated by ':' as well
+func (d *documentScanner) yamlFlowValue(key bool) (*documentNode, error) {
+	for d.peek() == ' ' {
+		d.next()
+	}
+
+	line, column := d.line, d.column
+
+	switch d.peek() {
+	case '[':
+		return d.yamlFlowSequence()
+
+	case '{':
+		return d.yamlFlowMapping()
+
+	case '"':
+		str, strErr := d.jsonString()
+
+		if strErr != nil {
+			return nil, strErr
+		}
+
+		return scalarNode(str, line, column), nil
+
+	case '\'':
+		str, strErr := d.yamlSingleQuoted()
+
+		if strErr != nil {
+			return nil, strErr
+		}
+
+		return scalarNode(str, line, column), nil
+	}
+
+	start := d.pos
+
+	for !d.eof() {
+		c := d.peek()
+
+		if c == ',' || c == ']' || c == '}' || (key && c == ':') {
+			break
+		}
+
+		d.next()
+	}
+
+	text := strings.TrimSpace(string(d.input[start:d.pos]))
+
+	switch text {
+	case "":
+		return nil, d.unexpected()
+
+	case "~", "null", "Null", "NULL":
+		return nullNode(line, column), nil
+	}
+
+	return scalarNode(text, line, column), nil
+}
+
+// yamlFlowSequence reads a [a, b] sequence
+func (d *documentScanner) yamlFlowSequence() (*documentNode, error) {
+	node := containerNode(documentList, d.line, d.column)
+
+	d.next()
+
+	for {
+		for d.peek() == ' ' {
+			d.next()
+		}
+
+		if d.peek() == ']' {
+			d.next()
+
+			return node, nil
+		}
+
+		item, itemErr := d.yamlFlowValue(false)
+
+		if itemErr != nil {
+			return nil, itemErr
+		}
+
+		if item.kind != documentNull {
+			node.items = append(node.items, item)
+		}
+
+		for d.peek() == ' ' {
+			d.next()
+		}
+
+		switch d.peek() {
+		case ',':
+			d.next()
+
+		case ']':
+
+		default:
+			return nil, d.unexpected()
+		}
+	}
+}
+
+// yamlFlowMapping reads a {k: v} mapping
+func (d *documentScanner) yamlFlowMapping() (*documentNode, error) {
+	node := containerNode(documentMap, d.line, d.column)
+
+	d.next()
+
+	for {
+		for d.peek() == ' ' {
+			d.next()
+		}
+
+		if d.peek() == '}' {
+			d.next()
+
+			return node, nil
+		}
+
+		key, keyErr := d.yamlFlowValue(true)
+
+		if keyErr != nil {
+			return nil, keyErr
+		}
+
+		if key.kind != documentScalar || d.peek() != ':' {
+			return nil, d.unexpected()
+		}
+
+		d.next()
+
+		value, valueErr := d.yamlFlowValue(false)
+
+		if valueErr != nil {
+			return nil, valueErr
+		}
+
+		setErr := node.set(key.scalar, key.line, key.column, value)
+
+		if setErr != nil {
+			return nil, setErr
+		}
+
+		for d.peek() == ' ' {
+			d.next()
+		}
+
+		switch d.peek() {
+		case ',':
+			d.next()
+
+		case '}':
+
+		default:
+			return nil, d.unexpected()
+		}
+	}
+}
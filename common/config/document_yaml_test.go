@@ -0,0 +1,79 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"testing"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	testDocumentDecoding(t, DocumentYAML, []testDocumentDecode{
+		{"", "null"},
+		{"# comment only\n---\n", "null"},
+		{"a: b\nc: 1\n", "{a:\"b\",c:\"1\"}"},
+		{"a:\n  b:\n    c: d\n  e: f\ng: h\n",
+			"{a:{b:{c:\"d\"},e:\"f\"},g:\"h\"}"},
+		{"a:\n- 1\n- 2\nb:\n  - 3\n", "{a:[\"1\",\"2\"],b:[\"3\"]}"},
+		{"- a: 1\n  b: 2\n- a: 3\n", "[{a:\"1\",b:\"2\"},{a:\"3\"}]"},
+		{"- - 1\n  - 2\n- 3\n", "[[\"1\",\"2\"],\"3\"]"},
+		{"-\n  a: 1\n- ~\n- null\n", "[{a:\"1\"}]"},
+		{"a:\nb: ~\n", "{a:null,b:null}"},
+		{"a: [1, [2, 3], {b: c}]\nd: {e: [f], 'g': \"h\"}\n",
+			"{a:[\"1\",[\"2\",\"3\"],{b:\"c\"}],d:{e:[\"f\"],g:\"h\"}}"},
+		{"a: []\nb: {}\n", "{a:[],b:{}}"},
+		{"a: 'it''s # not a comment'\nb: \"x\\ty \\\"z\\\" \\u00e9\"\n",
+			"{a:\"it's # not a comment\",b:\"x\\ty \\\"z\\\" é\"}"},
+		{"\"a b\": 1\n'c: d': 2\n", "{a b:\"1\",c: d:\"2\"}"},
+		{"a: b # comment\nc: d#e\n# full line\n  # indented\nf: g\n",
+			"{a:\"b\",c:\"d#e\",f:\"g\"}"},
+		{"url: http://a:80/b\nk: a:b\n",
+			"{url:\"http://a:80/b\",k:\"a:b\"}"},
+		{"a: b\r\nc: d\r\n", "{a:\"b\",c:\"d\"}"},
+		{"a: |\n  x\n   y\n\n  z\nb: c\n",
+			"{a:\"x\\n y\\n\\nz\\n\",b:\"c\"}"},
+		{"a: |-\n  x\n  y\n\nb: c\n", "{a:\"x\\ny\",b:\"c\"}"},
+		{"a: |+\n  x\n\n\nb: c\n", "{a:\"x\\n\\n\\n\",b:\"c\"}"},
+		{"a: >\n  x\n  y\n\n  z\n\n\n  w\n", "{a:\"x y\\nz\\n\\nw\\n\"}"},
+		{"a: >-\n  x\n  y\n", "{a:\"x y\"}"},
+		{"a: |\n  # kept\n  x\n", "{a:\"# kept\\nx\\n\"}"},
+		{"a: |\nb: c\n", "{a:\"\",b:\"c\"}"},
+	})
+}
+
+func TestDecodeYAMLErrors(t *testing.T) {
+	testDocumentDecodingErrors(t, DocumentYAML, []testDocumentDecodeError{
+		{"a: b\n  c: d\n", ErrDocumentInvalidIndent, 2, 3},
+		{"a:\n    b: 1\n  c: 2\n", ErrDocumentInvalidIndent, 3, 3},
+		{"a:\n\tb: 1\n", ErrDocumentInvalidIndent, 2, 1},
+		{"a: 1\nb\n", ErrDocumentUnexpectedCharacter, 2, 1},
+		{"- 1\na: 2\n", ErrDocumentInvalidIndent, 2, 1},
+		{"a:\n  b: 1\n  b: 2\n", ErrDocumentDuplicatedKey, 3, 3},
+		{"a: {b: 1, b: 2}\n", ErrDocumentDuplicatedKey, 1, 11},
+		{"a: [1, 2\n", ErrDocumentUnexpectedEnd, 1, 9},
+		{"a: [1, 2] x\n", ErrDocumentUnexpectedCharacter, 1, 11},
+		{"a: {b}\n", ErrDocumentUnexpectedCharacter, 1, 6},
+		{"a: 'b\n", ErrDocumentUnexpectedEnd, 1, 6},
+		{"a: \"b\\q\"\n", ErrDocumentInvalidString, 1, 7},
+		{"a: &anchor b\n", ErrDocumentInvalidValue, 1, 4},
+		{"a:\n  b: *alias\n", ErrDocumentInvalidValue, 2, 6},
+		{"a: |2\n  x\n", ErrDocumentInvalidValue, 1, 4},
+	})
+}
@@ -83,6 +83,11 @@ func (s *ParseError) Is(err error) bool {
 	return true
 }
 
+// Unwrap returns the underlying error
+func (s *ParseError) Unwrap() error {
+	return s.err
+}
+
 // Error returns a formated error string
 func (s *ParseError) Error() string {
 	if s.tag == "" {
@@ -35,7 +35,9 @@ type field struct {
 	Path        string
 	Tag         string
 	Tags        []string
+	JSON        string
 	Description string
+	Secret      bool
 	Sub         fields
 }
 
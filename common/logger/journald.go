@@ -0,0 +1,196 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Consts
+const (
+	JournaldSocket = "/run/systemd/journal/socket"
+)
+
+type journald struct {
+	context    string
+	fields     logFields
+	identifier string
+	writer     io.Writer
+}
+
+type journaldNonDebug struct {
+	journald
+}
+
+// NewJournald creates a logger that will write entries to the io.Writer in
+// the journald native protocol, one entry per Write
+func NewJournald(w io.Writer, identifier string) Logger {
+	return &journald{
+		context:    "COWARD",
+		fields:     logFields{},
+		identifier: identifier,
+		writer:     w,
+	}
+}
+
+// NewJournaldNonDebug creates a journald logger that will not write debug
+// message
+func NewJournaldNonDebug(w io.Writer, identifier string) Logger {
+	return &journaldNonDebug{
+		journald: journald{
+			context:    "COWARD",
+			fields:     logFields{},
+			identifier: identifier,
+			writer:     w,
+		},
+	}
+}
+
+// journaldField appends a field to the entry. Values that contain new lines
+// will be written in the binary (length prefixed) form
+func journaldField(entry []byte, name string, value string) []byte {
+	entry = append(entry, name...)
+
+	if !strings.Contains(value, "\n") {
+		entry = append(entry, '=')
+		entry = append(entry, value...)
+
+		return append(entry, '\n')
+	}
+
+	size := [8]byte{}
+
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+
+	entry = append(entry, '\n')
+	entry = append(entry, size[:]...)
+	entry = append(entry, value...)
+
+	return append(entry, '\n')
+}
+
+func (s *journald) print(level Level, msg string, detail []interface{}) {
+	if len(detail) > 0 {
+		msg = fmt.Sprintf(msg, detail...)
+	}
+
+	entry := make([]byte, 0, 256)
+
+	entry = journaldField(entry, "PRIORITY", strconv.Itoa(priority(level)))
+	entry = journaldField(entry, "SYSLOG_IDENTIFIER", s.identifier)
+	entry = journaldField(entry, "COWARD_CONTEXT", s.context)
+
+	for _, f := range [...][2]string{
+		{"COWARD_CONNECTION_ID", s.fields.connection},
+		{"COWARD_CHANNEL_ID", s.fields.channel},
+		{"COWARD_CLIENT_ID", s.fields.client},
+		{"COWARD_DESTINATION", s.fields.destination},
+	} {
+		if f[1] == "" {
+			continue
+		}
+
+		entry = journaldField(entry, f[0], f[1])
+	}
+
+	entry = journaldField(entry, "MESSAGE", msg)
+
+	// Write the entire entry at once as one datagram
+	s.writer.Write(entry)
+}
+
+// Context enter a new level of log context
+func (s *journald) Context(ctx string) Logger {
+	return &journald{
+		context:    s.context + " > " + ctx,
+		fields:     s.fields,
+		identifier: s.identifier,
+		writer:     s.writer,
+	}
+}
+
+// Field attaches a structured field
+func (s *journald) Field(name Field, value string) Logger {
+	return &journald{
+		context:    s.context,
+		fields:     s.fields.with(name, value),
+		identifier: s.identifier,
+		writer:     s.writer,
+	}
+}
+
+// Debugf writes debug message
+func (s *journald) Debugf(msg string, detail ...interface{}) {
+	s.print(Debug, msg, detail)
+}
+
+// Infof writes general message
+func (s *journald) Infof(msg string, detail ...interface{}) {
+	s.print(Info, msg, detail)
+}
+
+// Warningf writes warning message
+func (s *journald) Warningf(msg string, detail ...interface{}) {
+	s.print(Warning, msg, detail)
+}
+
+// Errorf writes error information
+func (s *journald) Errorf(msg string, detail ...interface{}) {
+	s.print(Error, msg, detail)
+}
+
+// Write prints default information
+func (s *journald) Write(b []byte) (int, error) {
+	s.print(Default, strings.TrimRight(string(b), "\r\n"), nil)
+
+	return len(b), nil
+}
+
+// Debugf ditchs debug message
+func (s *journaldNonDebug) Debugf(msg string, detail ...interface{}) {}
+
+// Context enter a new level of log context
+func (s *journaldNonDebug) Context(ctx string) Logger {
+	return &journaldNonDebug{
+		journald: journald{
+			context:    s.context + " > " + ctx,
+			fields:     s.fields,
+			identifier: s.identifier,
+			writer:     s.writer,
+		},
+	}
+}
+
+// Field attaches a structured field
+func (s *journaldNonDebug) Field(name Field, value string) Logger {
+	return &journaldNonDebug{
+		journald: journald{
+			context:    s.context,
+			fields:     s.fields.with(name, value),
+			identifier: s.identifier,
+			writer:     s.writer,
+		},
+	}
+}
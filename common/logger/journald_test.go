@@ -0,0 +1,62 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package logger
+
+import "testing"
+
+func TestJournald(t *testing.T) {
+	listener, path := testListenUnixgram(t)
+
+	if listener == nil {
+		return
+	}
+
+	defer listener.Close()
+
+	conn, dialErr := DialUnixgram(path)
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	defer conn.Close()
+
+	log := NewJournald(conn, "coward")
+
+	WithField(log.Context("Server"), FieldDestination, "example.com:80").Errorf(
+		"Line 1\nLine 2")
+
+	entry := testReadUnixgram(t, listener)
+
+	expected := "PRIORITY=3\n" +
+		"SYSLOG_IDENTIFIER=coward\n" +
+		"COWARD_CONTEXT=COWARD > Server\n" +
+		"COWARD_DESTINATION=example.com:80\n" +
+		"MESSAGE\n\x0d\x00\x00\x00\x00\x00\x00\x00Line 1\nLine 2\n"
+
+	if entry != expected {
+		t.Errorf("Expecting journald entry %q, got %q", expected, entry)
+
+		return
+	}
+}
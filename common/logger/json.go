@@ -0,0 +1,199 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type jsonLog struct {
+	context []string
+	fields  logFields
+	writer  io.Writer
+}
+
+type jsonLogNonDebug struct {
+	jsonLog
+}
+
+type jsonEntry struct {
+	Time        string   `json:"time"`
+	Level       string   `json:"level"`
+	Context     []string `json:"context"`
+	Connection  string   `json:"connection_id,omitempty"`
+	Channel     string   `json:"channel_id,omitempty"`
+	Client      string   `json:"client_id,omitempty"`
+	Destination string   `json:"destination,omitempty"`
+	Message     string   `json:"message"`
+}
+
+// NewJSON creates a logger that will write log to a io.Writer as JSON
+// lines
+func NewJSON(w io.Writer) Logger {
+	return &jsonLog{
+		context: []string{"COWARD"},
+		fields:  logFields{},
+		writer:  w,
+	}
+}
+
+// NewJSONNonDebug creates a JSON logger that will not write debug message
+func NewJSONNonDebug(w io.Writer) Logger {
+	return &jsonLogNonDebug{
+		jsonLog: jsonLog{
+			context: []string{"COWARD"},
+			fields:  logFields{},
+			writer:  w,
+		},
+	}
+}
+
+// levelName returns the name of the Level used in JSON log
+func levelName(level Level) string {
+	switch level {
+	case Default:
+		return "default"
+
+	case Debug:
+		return "debug"
+
+	case Info:
+		return "info"
+
+	case Warning:
+		return "warning"
+
+	case Error:
+		return "error"
+	}
+
+	return "unknown"
+}
+
+func (s *jsonLog) subContext(ctx string) []string {
+	// Always copy, so the sub contexts will not share the same backend
+	// array
+	context := make([]string, len(s.context)+1)
+
+	copy(context, s.context)
+
+	context[len(s.context)] = ctx
+
+	return context
+}
+
+// Context enter a new level of log context
+func (s *jsonLog) Context(ctx string) Logger {
+	return &jsonLog{
+		context: s.subContext(ctx),
+		fields:  s.fields,
+		writer:  s.writer,
+	}
+}
+
+// Field attaches a structured field
+func (s *jsonLog) Field(name Field, value string) Logger {
+	return &jsonLog{
+		context: s.context,
+		fields:  s.fields.with(name, value),
+		writer:  s.writer,
+	}
+}
+
+func (s *jsonLog) print(level Level, msg string, detail []interface{}) {
+	if len(detail) > 0 {
+		msg = fmt.Sprintf(msg, detail...)
+	}
+
+	line, mErr := json.Marshal(jsonEntry{
+		Time:        time.Now().Format(time.RFC3339Nano),
+		Level:       levelName(level),
+		Context:     s.context,
+		Connection:  s.fields.connection,
+		Channel:     s.fields.channel,
+		Client:      s.fields.client,
+		Destination: s.fields.destination,
+		Message:     msg,
+	})
+
+	if mErr != nil {
+		return
+	}
+
+	// Write the entire line at once so it will not be mixed with others
+	s.writer.Write(append(line, '\n'))
+}
+
+// Debugf writes debug message
+func (s *jsonLog) Debugf(msg string, detail ...interface{}) {
+	s.print(Debug, msg, detail)
+}
+
+// Infof writes general message
+func (s *jsonLog) Infof(msg string, detail ...interface{}) {
+	s.print(Info, msg, detail)
+}
+
+// Warningf writes warning message
+func (s *jsonLog) Warningf(msg string, detail ...interface{}) {
+	s.print(Warning, msg, detail)
+}
+
+// Errorf writes error information
+func (s *jsonLog) Errorf(msg string, detail ...interface{}) {
+	s.print(Error, msg, detail)
+}
+
+// Write prints default information
+func (s *jsonLog) Write(b []byte) (int, error) {
+	s.print(Default, strings.TrimRight(string(b), "\r\n"), nil)
+
+	return len(b), nil
+}
+
+// Debugf ditchs debug message
+func (s *jsonLogNonDebug) Debugf(msg string, detail ...interface{}) {}
+
+// Context enter a new level of log context
+func (s *jsonLogNonDebug) Context(ctx string) Logger {
+	return &jsonLogNonDebug{
+		jsonLog: jsonLog{
+			context: s.subContext(ctx),
+			fields:  s.fields,
+			writer:  s.writer,
+		},
+	}
+}
+
+// Field attaches a structured field
+func (s *jsonLogNonDebug) Field(name Field, value string) Logger {
+	return &jsonLogNonDebug{
+		jsonLog: jsonLog{
+			context: s.context,
+			fields:  s.fields.with(name, value),
+			writer:  s.writer,
+		},
+	}
+}
@@ -0,0 +1,110 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0, 1024))
+
+	log := NewJSON(buf)
+
+	clientLog := WithField(
+		log.Context("Server").Context("Client"), FieldConnection, "C1")
+
+	WithField(clientLog, FieldDestination, "example.com:80").Infof(
+		"Hello %s", "World")
+
+	clientLog.Context("Second").Debugf("Bye")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+
+	if len(lines) != 2 {
+		t.Errorf("Expecting 2 lines of log, got %d", len(lines))
+
+		return
+	}
+
+	entries := [2]jsonEntry{}
+
+	for lIdx := range lines {
+		unmarshalErr := json.Unmarshal(lines[lIdx], &entries[lIdx])
+
+		if unmarshalErr != nil {
+			t.Error("Failed to unmarshal log due to error:", unmarshalErr)
+
+			return
+		}
+	}
+
+	if entries[0].Level != "info" || entries[0].Message != "Hello World" ||
+		entries[0].Connection != "C1" ||
+		entries[0].Destination != "example.com:80" ||
+		len(entries[0].Context) != 3 || entries[0].Context[2] != "Client" {
+		t.Errorf("Unexpected first entry: %+v", entries[0])
+
+		return
+	}
+
+	if entries[1].Level != "debug" || entries[1].Connection != "C1" ||
+		entries[1].Destination != "" || len(entries[1].Context) != 4 ||
+		entries[1].Context[3] != "Second" {
+		t.Errorf("Unexpected second entry: %+v", entries[1])
+
+		return
+	}
+}
+
+func TestJSONNonDebug(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0, 1024))
+
+	log := WithField(NewJSONNonDebug(buf).Context("A"), FieldChannel, "1")
+
+	log.Debugf("Debug")
+
+	if buf.Len() != 0 {
+		t.Errorf("Debug message must be ditched, got %s", buf.String())
+
+		return
+	}
+
+	log.Warningf("Warning")
+
+	entry := jsonEntry{}
+
+	unmarshalErr := json.Unmarshal(buf.Bytes(), &entry)
+
+	if unmarshalErr != nil {
+		t.Error("Failed to unmarshal log due to error:", unmarshalErr)
+
+		return
+	}
+
+	if entry.Level != "warning" || entry.Channel != "1" {
+		t.Errorf("Unexpected entry: %+v", entry)
+
+		return
+	}
+}
@@ -0,0 +1,301 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Errors
+var (
+	ErrLevelsInvalidRule = errors.New(
+		"Invalid log level rule")
+
+	ErrLevelsUnknownLevel = errors.New(
+		"Unknown log level")
+)
+
+// levelRule sets the minimal Level of the contexts matched by it's path
+type levelRule struct {
+	path  []string
+	level Level
+}
+
+// Levels is a set of log level rules which decides the minimal Level of
+// a log context. It can be updated at runtime, all Loggers created by
+// NewLeveled with it will follow the change
+type Levels struct {
+	lock  sync.RWMutex
+	base  Level
+	level Level
+	rules []levelRule
+}
+
+type leveled struct {
+	logger Logger
+	path   []string
+	levels *Levels
+}
+
+// NewLevels creates a new Levels which allows base Level and above when
+// no rule is matched
+func NewLevels(base Level) *Levels {
+	return &Levels{
+		lock:  sync.RWMutex{},
+		base:  base,
+		level: base,
+		rules: nil,
+	}
+}
+
+// ParseLevel parses the name of a Level
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return Debug, nil
+
+	case "info":
+		return Info, nil
+
+	case "warning":
+		return Warning, nil
+
+	case "error":
+		return Error, nil
+	}
+
+	return Default, ErrLevelsUnknownLevel
+}
+
+// Update replaces current rules with the new ones.
+//
+// Rules are separated by ",", each of them is a context path with it's
+// segments separated by ">", followed by "=" and the name of the Level,
+// for example "Proxy > Server=debug,Workers=warning". A rule applies to
+// every context which contains it's path, and a context segment like
+// "Server (127.0.0.1:1234)" can be matched by "Server". A rule without
+// a path ("warning") replaces the base Level.
+//
+// Rules will be kept unchanged if the new ones are invalid
+func (l *Levels) Update(rules string) error {
+	level := l.base
+	newRules := []levelRule{}
+
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+
+		if rule == "" {
+			continue
+		}
+
+		equal := strings.LastIndex(rule, "=")
+
+		if equal < 0 {
+			ruleLevel, levelErr := ParseLevel(rule)
+
+			if levelErr != nil {
+				return levelErr
+			}
+
+			level = ruleLevel
+
+			continue
+		}
+
+		ruleLevel, levelErr := ParseLevel(rule[equal+1:])
+
+		if levelErr != nil {
+			return levelErr
+		}
+
+		path := strings.Split(rule[:equal], ">")
+
+		for pIdx := range path {
+			path[pIdx] = strings.TrimSpace(path[pIdx])
+
+			if path[pIdx] == "" {
+				return ErrLevelsInvalidRule
+			}
+		}
+
+		newRules = append(newRules, levelRule{
+			path:  path,
+			level: ruleLevel,
+		})
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.level = level
+	l.rules = newRules
+
+	return nil
+}
+
+// segmentMatch returns whether or not the context segment is matched by
+// the segment of a rule
+func segmentMatch(segment string, rule string) bool {
+	if segment == rule {
+		return true
+	}
+
+	return strings.HasPrefix(segment, rule+" (")
+}
+
+// matchedAt returns the index of the path segment where the rule ends in
+// it's deepest match, or -1 when the rule is not matched
+func (r levelRule) matchedAt(path []string) int {
+	for end := len(path) - 1; end >= len(r.path)-1; end-- {
+		matched := true
+		start := end - len(r.path) + 1
+
+		for rIdx := range r.path {
+			if segmentMatch(path[start+rIdx], r.path[rIdx]) {
+				continue
+			}
+
+			matched = false
+
+			break
+		}
+
+		if matched {
+			return end
+		}
+	}
+
+	return -1
+}
+
+// Level returns the minimal Level of the context path. The rule which
+// matches the deepest part of the path wins, then the longer one, then the
+// later one
+func (l *Levels) Level(path []string) Level {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	level := l.level
+	matchedAt := -1
+	matchedLen := 0
+
+	for rIdx := range l.rules {
+		at := l.rules[rIdx].matchedAt(path)
+
+		if at < 0 || at < matchedAt {
+			continue
+		}
+
+		if at == matchedAt && len(l.rules[rIdx].path) < matchedLen {
+			continue
+		}
+
+		level = l.rules[rIdx].level
+		matchedAt = at
+		matchedLen = len(l.rules[rIdx].path)
+	}
+
+	return level
+}
+
+// NewLeveled creates a Logger which only writes messages that are allowed
+// by the Levels to the given Logger. The given Logger must write messages of
+// all Levels
+func NewLeveled(l Logger, levels *Levels) Logger {
+	return &leveled{
+		logger: l,
+		path:   []string{},
+		levels: levels,
+	}
+}
+
+func (s *leveled) allowed(level Level) bool {
+	return level >= s.levels.Level(s.path)
+}
+
+// Context enter a new level of log context
+func (s *leveled) Context(ctx string) Logger {
+	// Always copy, so the sub contexts will not share the same backend
+	// array
+	path := make([]string, len(s.path)+1)
+
+	copy(path, s.path)
+
+	path[len(s.path)] = ctx
+
+	return &leveled{
+		logger: s.logger.Context(ctx),
+		path:   path,
+		levels: s.levels,
+	}
+}
+
+// Field attaches a structured field
+func (s *leveled) Field(name Field, value string) Logger {
+	return &leveled{
+		logger: WithField(s.logger, name, value),
+		path:   s.path,
+		levels: s.levels,
+	}
+}
+
+// Debugf writes debug message when it's allowed
+func (s *leveled) Debugf(msg string, detail ...interface{}) {
+	if !s.allowed(Debug) {
+		return
+	}
+
+	s.logger.Debugf(msg, detail...)
+}
+
+// Infof writes general message when it's allowed
+func (s *leveled) Infof(msg string, detail ...interface{}) {
+	if !s.allowed(Info) {
+		return
+	}
+
+	s.logger.Infof(msg, detail...)
+}
+
+// Warningf writes warning message when it's allowed
+func (s *leveled) Warningf(msg string, detail ...interface{}) {
+	if !s.allowed(Warning) {
+		return
+	}
+
+	s.logger.Warningf(msg, detail...)
+}
+
+// Errorf writes error information when it's allowed
+func (s *leveled) Errorf(msg string, detail ...interface{}) {
+	if !s.allowed(Error) {
+		return
+	}
+
+	s.logger.Errorf(msg, detail...)
+}
+
+// Write writes default information
+func (s *leveled) Write(b []byte) (int, error) {
+	return s.logger.Write(b)
+}
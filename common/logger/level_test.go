@@ -0,0 +1,138 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLevelsLevel(t *testing.T) {
+	levels := NewLevels(Info)
+
+	updateErr := levels.Update(
+		"Proxy > Server=debug, Workers=warning, Worker=error")
+
+	if updateErr != nil {
+		t.Error("Failed to update levels due to error:", updateErr)
+
+		return
+	}
+
+	for _, c := range []struct {
+		path  []string
+		level Level
+	}{
+		{[]string{"Proxy"}, Info},
+		{[]string{"Proxy", "Server (127.0.0.1:1234)"}, Debug},
+		{[]string{"Proxy", "Server (127.0.0.1:1234)", "Client (1)"}, Debug},
+		{[]string{"Socks5", "Server (127.0.0.1:1234)"}, Info},
+		{[]string{"Proxy", "Workers"}, Warning},
+		{[]string{"Proxy", "Server (127.0.0.1:1234)", "Workers"}, Warning},
+		{[]string{"Proxy", "Workers", "Worker (1)"}, Error},
+		{[]string{"Proxy", "Serverless"}, Info},
+	} {
+		level := levels.Level(c.path)
+
+		if level == c.level {
+			continue
+		}
+
+		t.Errorf("Expecting level of %v to be %s, got %s",
+			c.path, c.level, level)
+
+		return
+	}
+
+	updateErr = levels.Update("error,Proxy=unknown")
+
+	if updateErr != ErrLevelsUnknownLevel {
+		t.Errorf("Expecting error %s, got %v",
+			ErrLevelsUnknownLevel, updateErr)
+
+		return
+	}
+
+	if levels.Level([]string{"Proxy", "Server"}) != Debug {
+		t.Error("Rules must be kept when the new ones are invalid")
+
+		return
+	}
+
+	updateErr = levels.Update("error")
+
+	if updateErr != nil {
+		t.Error("Failed to update levels due to error:", updateErr)
+
+		return
+	}
+
+	if levels.Level([]string{"Proxy", "Server"}) != Error {
+		t.Error("Base level must be changed")
+
+		return
+	}
+}
+
+func TestLeveled(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0, 1024))
+
+	levels := NewLevels(Info)
+
+	levels.Update("Proxy > Server=debug")
+
+	log := NewLeveled(NewWrite(buf), levels)
+
+	proxy := log.Context("Proxy")
+	server := WithField(
+		proxy.Context("Server (127.0.0.1:1234)"), FieldConnection, "C1")
+
+	proxy.Debugf("Proxy debug")
+	proxy.Infof("Proxy info")
+	server.Debugf("Server debug")
+
+	levels.Update("warning")
+
+	proxy.Infof("Proxy info again")
+	server.Debugf("Server debug again")
+	server.Errorf("Server error")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\r\n")
+
+	if len(lines) != 3 {
+		t.Errorf("Expecting 3 lines of log, got %d: %q", len(lines), lines)
+
+		return
+	}
+
+	for lIdx, expected := range []string{
+		"Proxy info", "Server debug", "Server error"} {
+		if strings.HasSuffix(lines[lIdx], "| "+expected) {
+			continue
+		}
+
+		t.Errorf("Expecting line %d to be %q, got %q",
+			lIdx, expected, lines[lIdx])
+
+		return
+	}
+}
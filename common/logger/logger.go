@@ -30,6 +30,35 @@ type Logger interface {
 	Write(b []byte) (int, error)
 }
 
+// Fielder is a Logger which records structured fields aside of the context
+type Fielder interface {
+	Field(name Field, value string) Logger
+}
+
+// Field is the name of a structured log field
+type Field byte
+
+// Log fields
+const (
+	FieldConnection  Field = 0x1
+	FieldChannel     Field = 0x2
+	FieldClient      Field = 0x3
+	FieldDestination Field = 0x4
+)
+
+// WithField attaches a structured field to the Logger. Loggers which don't
+// record fields will be returned as they are, the same information is
+// expected to be presented in their context
+func WithField(l Logger, name Field, value string) Logger {
+	fielder, isFielder := l.(Fielder)
+
+	if !isFielder {
+		return l
+	}
+
+	return fielder.Field(name, value)
+}
+
 // Level representing the Level information
 type Level byte
 
@@ -63,3 +92,30 @@ func (l Level) String() string {
 
 	return "UKN"
 }
+
+// logFields structured fields of a Logger
+type logFields struct {
+	connection  string
+	channel     string
+	client      string
+	destination string
+}
+
+// with returns a copy of the fields with given field set to the value
+func (f logFields) with(name Field, value string) logFields {
+	switch name {
+	case FieldConnection:
+		f.connection = value
+
+	case FieldChannel:
+		f.channel = value
+
+	case FieldClient:
+		f.client = value
+
+	case FieldDestination:
+		f.destination = value
+	}
+
+	return f
+}
@@ -0,0 +1,333 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Errors
+var (
+	ErrRotateClosed = errors.New(
+		"Log file has been closed")
+)
+
+// Rotater is a log file writer which rotates the file when it's grown too
+// large or too old
+type Rotater interface {
+	io.WriteCloser
+
+	Reopen() error
+	Flush() error
+}
+
+// RotateConfig Rotater configuration
+type RotateConfig struct {
+	MaxSize       int64
+	MaxAge        time.Duration
+	Retention     uint16
+	FlushInterval time.Duration
+}
+
+type rotate struct {
+	path     string
+	cfg      RotateConfig
+	lock     sync.Mutex
+	file     *os.File
+	buffer   *bufio.Writer
+	size     int64
+	opened   time.Time
+	closed   bool
+	closing  chan struct{}
+	flushing sync.WaitGroup
+}
+
+// NewRotate opens a log file in append mode and returns a Rotater of it.
+//
+// When MaxSize is greater than 0, the file will be rotated before it grows
+// larger than MaxSize bytes. When MaxAge is greater than 0, the file will be
+// rotated once it has been written for MaxAge, which for an existing file is
+// counted from the modification time of its last rotated file. Rotated files
+// are renamed to <path>.1, <path>.2 and so on with <path>.1 being the latest,
+// and only the latest Retention files will be kept (0 to keep all of them).
+//
+// Buffered data will be flushed to the file every FlushInterval
+func NewRotate(path string, cfg RotateConfig) (Rotater, error) {
+	r := &rotate{
+		path:     path,
+		cfg:      cfg,
+		lock:     sync.Mutex{},
+		file:     nil,
+		buffer:   nil,
+		size:     0,
+		opened:   time.Time{},
+		closed:   false,
+		closing:  make(chan struct{}),
+		flushing: sync.WaitGroup{},
+	}
+
+	openErr := r.open()
+
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	if cfg.FlushInterval > 0 {
+		r.flushing.Add(1)
+
+		go r.flusher()
+	}
+
+	return r, nil
+}
+
+// open opens the log file. Must be called with lock held
+func (r *rotate) open() error {
+	file, openErr := os.OpenFile(
+		r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+
+	if openErr != nil {
+		return openErr
+	}
+
+	info, statErr := file.Stat()
+
+	if statErr != nil {
+		file.Close()
+
+		return statErr
+	}
+
+	r.file = file
+	r.buffer = bufio.NewWriter(file)
+	r.size = info.Size()
+	r.opened = r.started(info)
+
+	return nil
+}
+
+// started returns the time when the opened log file has been started.
+// Restarting the application must not restart the age of a file that
+// already has records in it
+func (r *rotate) started(info os.FileInfo) time.Time {
+	if info.Size() <= 0 {
+		return time.Now()
+	}
+
+	// The file was created right after the previous one been rotated away,
+	// which is the last time the previous one was written
+	previous, statErr := os.Stat(r.rotated(1))
+
+	if statErr == nil && !previous.ModTime().After(info.ModTime()) {
+		return previous.ModTime()
+	}
+
+	return info.ModTime()
+}
+
+// close flushes and closes the log file. Must be called with lock held
+func (r *rotate) close() error {
+	flushErr := r.buffer.Flush()
+	closeErr := r.file.Close()
+
+	r.file = nil
+	r.buffer = nil
+
+	if flushErr != nil {
+		return flushErr
+	}
+
+	return closeErr
+}
+
+// rotated returns the file name of the Nth rotated file
+func (r *rotate) rotated(n uint64) string {
+	return r.path + "." + strconv.FormatUint(n, 10)
+}
+
+// rotate moves current log file aside and opens a new one. Must be called
+// with lock held
+func (r *rotate) rotate() error {
+	closeErr := r.close()
+
+	if closeErr != nil {
+		return closeErr
+	}
+
+	last := uint64(r.cfg.Retention)
+
+	if last > 0 {
+		os.Remove(r.rotated(last))
+	} else {
+		for last = 1; ; last++ {
+			_, statErr := os.Stat(r.rotated(last))
+
+			if statErr != nil {
+				break
+			}
+		}
+	}
+
+	for n := last; n > 1; n-- {
+		renameErr := os.Rename(r.rotated(n-1), r.rotated(n))
+
+		if renameErr != nil && !os.IsNotExist(renameErr) {
+			return renameErr
+		}
+	}
+
+	renameErr := os.Rename(r.path, r.rotated(1))
+
+	if renameErr != nil {
+		return renameErr
+	}
+
+	return r.open()
+}
+
+// flusher flushes the buffer periodically until the Rotater is closed
+func (r *rotate) flusher() {
+	defer r.flushing.Done()
+
+	ticker := time.NewTicker(r.cfg.FlushInterval)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.Flush()
+
+		case <-r.closing:
+			return
+		}
+	}
+}
+
+// Write writes data to the log file
+func (r *rotate) Write(b []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return 0, ErrRotateClosed
+	}
+
+	// Previous rotation has failed, try to open the file again
+	if r.file == nil {
+		openErr := r.open()
+
+		if openErr != nil {
+			return 0, openErr
+		}
+	}
+
+	needRotate := r.cfg.MaxSize > 0 && r.size > 0 &&
+		r.size+int64(len(b)) > r.cfg.MaxSize
+
+	if !needRotate && r.cfg.MaxAge > 0 {
+		needRotate = time.Since(r.opened) >= r.cfg.MaxAge
+	}
+
+	if needRotate {
+		rotateErr := r.rotate()
+
+		if rotateErr != nil {
+			return 0, rotateErr
+		}
+	}
+
+	wLen, wErr := r.buffer.Write(b)
+
+	r.size += int64(wLen)
+
+	return wLen, wErr
+}
+
+// Flush writes buffered data to the log file
+func (r *rotate) Flush() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return ErrRotateClosed
+	}
+
+	if r.file == nil {
+		return nil
+	}
+
+	return r.buffer.Flush()
+}
+
+// Reopen closes and reopens the log file, so the file can be moved away by
+// external tools such as logrotate
+func (r *rotate) Reopen() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return ErrRotateClosed
+	}
+
+	if r.file != nil {
+		closeErr := r.close()
+
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+
+	return r.open()
+}
+
+// Close flushes and closes the log file
+func (r *rotate) Close() error {
+	r.lock.Lock()
+
+	if r.closed {
+		r.lock.Unlock()
+
+		return ErrRotateClosed
+	}
+
+	r.closed = true
+
+	close(r.closing)
+
+	var closeErr error
+
+	if r.file != nil {
+		closeErr = r.close()
+	}
+
+	r.lock.Unlock()
+
+	r.flushing.Wait()
+
+	return closeErr
+}
@@ -0,0 +1,301 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testRotateRead(t *testing.T, path string) string {
+	data, readErr := os.ReadFile(path)
+
+	if readErr != nil {
+		t.Error("Failed to read log file due to error:", readErr)
+
+		return ""
+	}
+
+	return string(data)
+}
+
+func TestRotateSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coward.log")
+
+	writeErr := os.WriteFile(path, []byte("old\n"), 0600)
+
+	if writeErr != nil {
+		t.Error("Failed to prepare log file due to error:", writeErr)
+
+		return
+	}
+
+	r, rErr := NewRotate(path, RotateConfig{
+		MaxSize:       8,
+		MaxAge:        0,
+		Retention:     2,
+		FlushInterval: 0,
+	})
+
+	if rErr != nil {
+		t.Error("Failed to open log file due to error:", rErr)
+
+		return
+	}
+
+	for _, line := range []string{"1111\n", "2222\n", "3333\n", "4444\n"} {
+		_, wErr := r.Write([]byte(line))
+
+		if wErr != nil {
+			t.Error("Failed to write log due to error:", wErr)
+
+			return
+		}
+	}
+
+	closeErr := r.Close()
+
+	if closeErr != nil {
+		t.Error("Failed to close log file due to error:", closeErr)
+
+		return
+	}
+
+	if d := testRotateRead(t, path); d != "4444\n" {
+		t.Errorf("Unexpected current log file content: %q", d)
+
+		return
+	}
+
+	if d := testRotateRead(t, path+".1"); d != "3333\n" {
+		t.Errorf("Unexpected 1st rotated log file content: %q", d)
+
+		return
+	}
+
+	if d := testRotateRead(t, path+".2"); d != "2222\n" {
+		t.Errorf("Unexpected 2nd rotated log file content: %q", d)
+
+		return
+	}
+
+	_, statErr := os.Stat(path + ".3")
+
+	if !os.IsNotExist(statErr) {
+		t.Error("Expecting log files beyond retention to be removed")
+
+		return
+	}
+
+	_, wErr := r.Write([]byte("5555\n"))
+
+	if wErr != ErrRotateClosed {
+		t.Errorf("Expecting error %s, got %s", ErrRotateClosed, wErr)
+
+		return
+	}
+}
+
+func TestRotateAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coward.log")
+
+	r, rErr := NewRotate(path, RotateConfig{
+		MaxSize:       0,
+		MaxAge:        50 * time.Millisecond,
+		Retention:     0,
+		FlushInterval: 0,
+	})
+
+	if rErr != nil {
+		t.Error("Failed to open log file due to error:", rErr)
+
+		return
+	}
+
+	defer r.Close()
+
+	r.Write([]byte("1\n"))
+	r.Write([]byte("2\n"))
+
+	time.Sleep(100 * time.Millisecond)
+
+	r.Write([]byte("3\n"))
+
+	time.Sleep(100 * time.Millisecond)
+
+	r.Write([]byte("4\n"))
+	r.Flush()
+
+	if d := testRotateRead(t, path); d != "4\n" {
+		t.Errorf("Unexpected current log file content: %q", d)
+
+		return
+	}
+
+	if d := testRotateRead(t, path+".1"); d != "3\n" {
+		t.Errorf("Unexpected 1st rotated log file content: %q", d)
+
+		return
+	}
+
+	if d := testRotateRead(t, path+".2"); d != "1\n2\n" {
+		t.Errorf("Unexpected 2nd rotated log file content: %q", d)
+
+		return
+	}
+}
+
+func TestRotateAgeOfExistingFile(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		modified time.Time
+		previous time.Time
+		rotated  bool
+	}{
+		{now.Add(-10 * time.Minute), time.Time{}, false},
+		{now.Add(-2 * time.Hour), time.Time{}, true},
+		{now.Add(-10 * time.Minute), now.Add(-20 * time.Minute), false},
+		{now.Add(-10 * time.Minute), now.Add(-2 * time.Hour), true},
+	}
+
+	for tIdx, test := range tests {
+		path := filepath.Join(t.TempDir(), "coward.log")
+
+		files := map[string]time.Time{path: test.modified}
+
+		if !test.previous.IsZero() {
+			files[path+".1"] = test.previous
+		}
+
+		for p, modified := range files {
+			writeErr := os.WriteFile(p, []byte("old\n"), 0600)
+
+			if writeErr != nil {
+				t.Error("Failed to prepare log file due to error:", writeErr)
+
+				return
+			}
+
+			chErr := os.Chtimes(p, modified, modified)
+
+			if chErr != nil {
+				t.Error("Failed to prepare log file due to error:", chErr)
+
+				return
+			}
+		}
+
+		r, rErr := NewRotate(path, RotateConfig{
+			MaxSize:       0,
+			MaxAge:        time.Hour,
+			Retention:     0,
+			FlushInterval: 0,
+		})
+
+		if rErr != nil {
+			t.Error("Failed to open log file due to error:", rErr)
+
+			return
+		}
+
+		r.Write([]byte("new\n"))
+		r.Close()
+
+		expected := "old\nnew\n"
+
+		if test.rotated {
+			expected = "new\n"
+		}
+
+		if d := testRotateRead(t, path); d != expected {
+			t.Errorf("Test %d expecting current log file content %q, got %q",
+				tIdx, expected, d)
+
+			return
+		}
+	}
+}
+
+func TestRotateReopenAndFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coward.log")
+
+	r, rErr := NewRotate(path, RotateConfig{
+		MaxSize:       0,
+		MaxAge:        0,
+		Retention:     0,
+		FlushInterval: 10 * time.Millisecond,
+	})
+
+	if rErr != nil {
+		t.Error("Failed to open log file due to error:", rErr)
+
+		return
+	}
+
+	defer r.Close()
+
+	r.Write([]byte("1\n"))
+
+	time.Sleep(100 * time.Millisecond)
+
+	if d := testRotateRead(t, path); d != "1\n" {
+		t.Errorf("Expecting log to be flushed, got %q", d)
+
+		return
+	}
+
+	// Simulate logrotate
+	renameErr := os.Rename(path, path+".moved")
+
+	if renameErr != nil {
+		t.Error("Failed to move log file due to error:", renameErr)
+
+		return
+	}
+
+	r.Write([]byte("2\n"))
+
+	reopenErr := r.Reopen()
+
+	if reopenErr != nil {
+		t.Error("Failed to reopen log file due to error:", reopenErr)
+
+		return
+	}
+
+	r.Write([]byte("3\n"))
+	r.Flush()
+
+	if d := testRotateRead(t, path+".moved"); d != "1\n2\n" {
+		t.Errorf("Unexpected moved log file content: %q", d)
+
+		return
+	}
+
+	if d := testRotateRead(t, path); d != "3\n" {
+		t.Errorf("Unexpected reopened log file content: %q", d)
+
+		return
+	}
+}
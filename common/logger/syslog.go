@@ -0,0 +1,304 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Consts
+const (
+	SyslogSocket = "/dev/log"
+
+	// syslogFacility is the facility of all COWARD messages: daemon
+	syslogFacility = 3
+
+	// syslogSDID is the ID of the RFC 5424 structured data element which
+	// carries COWARD fields
+	syslogSDID = "coward@32473"
+
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+type syslog struct {
+	context  string
+	fields   logFields
+	hostname string
+	app      string
+	pid      string
+	writer   io.Writer
+}
+
+type syslogNonDebug struct {
+	syslog
+}
+
+type unixgram struct {
+	path string
+	lock sync.Mutex
+	conn net.Conn
+}
+
+// DialUnixgram connects to a local Unix datagram socket such as the syslog
+// or the journald socket. Every Write to the returned connection will be
+// sent as one datagram. When a Write has failed, for example because the
+// log daemon has been restarted, the socket will be dialed again
+func DialUnixgram(path string) (io.WriteCloser, error) {
+	conn, dialErr := net.Dial("unixgram", path)
+
+	if dialErr != nil {
+		return nil, dialErr
+	}
+
+	return &unixgram{
+		path: path,
+		lock: sync.Mutex{},
+		conn: conn,
+	}, nil
+}
+
+// Write sends b as one datagram, and redials once when it has failed
+func (u *unixgram) Write(b []byte) (int, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if u.conn != nil {
+		wLen, wErr := u.conn.Write(b)
+
+		if wErr == nil {
+			return wLen, nil
+		}
+
+		u.conn.Close()
+		u.conn = nil
+	}
+
+	conn, dialErr := net.Dial("unixgram", u.path)
+
+	if dialErr != nil {
+		return 0, dialErr
+	}
+
+	u.conn = conn
+
+	return u.conn.Write(b)
+}
+
+// Close closes the connection
+func (u *unixgram) Close() error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if u.conn == nil {
+		return nil
+	}
+
+	closeErr := u.conn.Close()
+
+	u.conn = nil
+
+	return closeErr
+}
+
+// NewSyslog creates a logger that will write RFC 5424 syslog messages to
+// the io.Writer, one message per Write
+func NewSyslog(w io.Writer, app string) Logger {
+	return &syslog{
+		context:  "COWARD",
+		fields:   logFields{},
+		hostname: syslogHostname(),
+		app:      app,
+		pid:      strconv.FormatInt(int64(os.Getpid()), 10),
+		writer:   w,
+	}
+}
+
+// NewSyslogNonDebug creates a syslog logger that will not write debug
+// message
+func NewSyslogNonDebug(w io.Writer, app string) Logger {
+	return &syslogNonDebug{
+		syslog: syslog{
+			context:  "COWARD",
+			fields:   logFields{},
+			hostname: syslogHostname(),
+			app:      app,
+			pid:      strconv.FormatInt(int64(os.Getpid()), 10),
+			writer:   w,
+		},
+	}
+}
+
+// syslogHostname returns the host name used in syslog messages
+func syslogHostname() string {
+	hostname, hostnameErr := os.Hostname()
+
+	if hostnameErr != nil || hostname == "" {
+		return "-"
+	}
+
+	return hostname
+}
+
+// priority returns the syslog severity of the Level
+func priority(level Level) int {
+	switch level {
+	case Debug:
+		return 7
+
+	case Info:
+		return 6
+
+	case Warning:
+		return 4
+
+	case Error:
+		return 3
+	}
+
+	// Notice
+	return 5
+}
+
+// syslogParam escapes a RFC 5424 structured data parameter value
+func syslogParam(value string) string {
+	return strings.NewReplacer(
+		"\\", "\\\\", "\"", "\\\"", "]", "\\]").Replace(value)
+}
+
+func (s *syslog) structuredData() string {
+	data := "[" + syslogSDID + " context=\"" + syslogParam(s.context) + "\""
+
+	for _, f := range [...][2]string{
+		{"connection_id", s.fields.connection},
+		{"channel_id", s.fields.channel},
+		{"client_id", s.fields.client},
+		{"destination", s.fields.destination},
+	} {
+		if f[1] == "" {
+			continue
+		}
+
+		data += " " + f[0] + "=\"" + syslogParam(f[1]) + "\""
+	}
+
+	return data + "]"
+}
+
+func (s *syslog) print(level Level, msg string, detail []interface{}) {
+	if len(detail) > 0 {
+		msg = fmt.Sprintf(msg, detail...)
+	}
+
+	// Write the entire message at once as one datagram
+	s.writer.Write([]byte(fmt.Sprintf("<%d>1 %s %s %s %s - %s %s",
+		syslogFacility*8+priority(level),
+		time.Now().Format(syslogTimeFormat),
+		s.hostname, s.app, s.pid, s.structuredData(), msg)))
+}
+
+// Context enter a new level of log context
+func (s *syslog) Context(ctx string) Logger {
+	return &syslog{
+		context:  s.context + " > " + ctx,
+		fields:   s.fields,
+		hostname: s.hostname,
+		app:      s.app,
+		pid:      s.pid,
+		writer:   s.writer,
+	}
+}
+
+// Field attaches a structured field
+func (s *syslog) Field(name Field, value string) Logger {
+	return &syslog{
+		context:  s.context,
+		fields:   s.fields.with(name, value),
+		hostname: s.hostname,
+		app:      s.app,
+		pid:      s.pid,
+		writer:   s.writer,
+	}
+}
+
+// Debugf writes debug message
+func (s *syslog) Debugf(msg string, detail ...interface{}) {
+	s.print(Debug, msg, detail)
+}
+
+// Infof writes general message
+func (s *syslog) Infof(msg string, detail ...interface{}) {
+	s.print(Info, msg, detail)
+}
+
+// Warningf writes warning message
+func (s *syslog) Warningf(msg string, detail ...interface{}) {
+	s.print(Warning, msg, detail)
+}
+
+// Errorf writes error information
+func (s *syslog) Errorf(msg string, detail ...interface{}) {
+	s.print(Error, msg, detail)
+}
+
+// Write prints default information
+func (s *syslog) Write(b []byte) (int, error) {
+	s.print(Default, strings.TrimRight(string(b), "\r\n"), nil)
+
+	return len(b), nil
+}
+
+// Debugf ditchs debug message
+func (s *syslogNonDebug) Debugf(msg string, detail ...interface{}) {}
+
+// Context enter a new level of log context
+func (s *syslogNonDebug) Context(ctx string) Logger {
+	return &syslogNonDebug{
+		syslog: syslog{
+			context:  s.context + " > " + ctx,
+			fields:   s.fields,
+			hostname: s.hostname,
+			app:      s.app,
+			pid:      s.pid,
+			writer:   s.writer,
+		},
+	}
+}
+
+// Field attaches a structured field
+func (s *syslogNonDebug) Field(name Field, value string) Logger {
+	return &syslogNonDebug{
+		syslog: syslog{
+			context:  s.context,
+			fields:   s.fields.with(name, value),
+			hostname: s.hostname,
+			app:      s.app,
+			pid:      s.pid,
+			writer:   s.writer,
+		},
+	}
+}
@@ -0,0 +1,167 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testListenUnixgram(t *testing.T) (*net.UnixConn, string) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+
+	listener, listenErr := net.ListenUnixgram("unixgram", &net.UnixAddr{
+		Name: path,
+		Net:  "unixgram",
+	})
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return nil, ""
+	}
+
+	return listener, path
+}
+
+func testReadUnixgram(t *testing.T, listener *net.UnixConn) string {
+	buf := make([]byte, 4096)
+
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+
+	rLen, rErr := listener.Read(buf)
+
+	if rErr != nil {
+		t.Error("Failed to read datagram due to error:", rErr)
+
+		return ""
+	}
+
+	return string(buf[:rLen])
+}
+
+func TestSyslog(t *testing.T) {
+	listener, path := testListenUnixgram(t)
+
+	if listener == nil {
+		return
+	}
+
+	defer listener.Close()
+
+	conn, dialErr := DialUnixgram(path)
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	defer conn.Close()
+
+	log := NewSyslogNonDebug(conn, "coward")
+
+	log.Debugf("Ditched")
+
+	WithField(log.Context("Server \"1\""), FieldConnection, "C1").Warningf(
+		"Hello %s", "World")
+
+	msg := testReadUnixgram(t, listener)
+
+	if !strings.HasPrefix(msg, "<28>1 ") {
+		t.Errorf("Expecting a daemon warning RFC 5424 message, got %q", msg)
+
+		return
+	}
+
+	expected := " coward " + strconv.Itoa(os.Getpid()) + " - " +
+		"[coward@32473 context=\"COWARD > Server \\\"1\\\"\" " +
+		"connection_id=\"C1\"] Hello World"
+
+	if !strings.HasSuffix(msg, expected) {
+		t.Errorf("Expecting syslog message ends with %q, got %q",
+			expected, msg)
+
+		return
+	}
+}
+
+func TestUnixgramRedial(t *testing.T) {
+	listener, path := testListenUnixgram(t)
+
+	if listener == nil {
+		return
+	}
+
+	conn, dialErr := DialUnixgram(path)
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	defer conn.Close()
+
+	// Restart the log daemon
+	listener.Close()
+	os.Remove(path)
+
+	_, wErr := conn.Write([]byte("Lost"))
+
+	if wErr == nil {
+		t.Error("Expecting Write to fail while the socket is gone")
+
+		return
+	}
+
+	listener, listenErr := net.ListenUnixgram("unixgram", &net.UnixAddr{
+		Name: path,
+		Net:  "unixgram",
+	})
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return
+	}
+
+	defer listener.Close()
+
+	_, wErr = conn.Write([]byte("Hello"))
+
+	if wErr != nil {
+		t.Error("Failed to write after redial due to error:", wErr)
+
+		return
+	}
+
+	if msg := testReadUnixgram(t, listener); msg != "Hello" {
+		t.Errorf("Expecting %q, got %q", "Hello", msg)
+
+		return
+	}
+}
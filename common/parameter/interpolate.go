@@ -0,0 +1,163 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package parameter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Interpolation errors
+var (
+	ErrInterpolationNotClosed = errors.New(
+		"Interpolation is not closed")
+
+	ErrInterpolationUnknownSource = errors.New(
+		"Unknown interpolation source")
+
+	ErrInterpolationEnvironmentUndefined = errors.New(
+		"Environment variable is not defined")
+
+	ErrInterpolationFileUnreadable = errors.New(
+		"Secret file is unreadable")
+)
+
+// Interpolation sources
+const (
+	InterpolationEnvironment = "ENV"
+	InterpolationFile        = "FILE"
+)
+
+// Interpolation symbols
+const (
+	interpolationHead = byte('$')
+	interpolationTail = byte('}')
+)
+
+// InterpolationError is the error happened when we resolving an
+// interpolation
+type InterpolationError struct {
+	err     error
+	subject string
+}
+
+func newInterpolationError(err error, subject string) error {
+	return &InterpolationError{
+		err:     err,
+		subject: subject,
+	}
+}
+
+// Is check if inputted error is which current InterpolationError represented
+func (i *InterpolationError) Is(err error) bool {
+	if i.err != err {
+		return false
+	}
+
+	return true
+}
+
+// Error returns formated error string
+func (i *InterpolationError) Error() string {
+	return fmt.Sprintf("%s: \"%s\"", i.err.Error(), i.subject)
+}
+
+// Interpolate resolves all `${ENV:NAME}` and `${FILE:/path/to/file}` in the
+// data. Environment variables are used as they are, while the tailing line
+// breaks of the file will be removed. Use `$${` for a literal `${`
+func Interpolate(data []byte) ([]byte, error) {
+	start := bytes.Index(data, []byte("${"))
+
+	if start < 0 {
+		return data, nil
+	}
+
+	result := make([]byte, 0, len(data))
+
+	for start >= 0 {
+		if start > 0 && data[start-1] == '$' {
+			result = append(result, data[:start]...)
+			result = append(result, '{')
+			data = data[start+2:]
+			start = bytes.Index(data, []byte("${"))
+
+			continue
+		}
+
+		end := bytes.IndexByte(data[start:], '}')
+
+		if end < 0 {
+			return nil, newInterpolationError(
+				ErrInterpolationNotClosed, string(data[start:]))
+		}
+
+		resolved, resolveErr := interpolate(
+			string(data[start+2 : start+end]))
+
+		if resolveErr != nil {
+			return nil, resolveErr
+		}
+
+		result = append(result, data[:start]...)
+		result = append(result, resolved...)
+		data = data[start+end+1:]
+		start = bytes.Index(data, []byte("${"))
+	}
+
+	return append(result, data...), nil
+}
+
+// interpolate resolves a single `SOURCE:NAME` expression
+func interpolate(expr string) ([]byte, error) {
+	sourceEnd := bytes.IndexByte([]byte(expr), ':')
+
+	if sourceEnd < 0 {
+		return nil, newInterpolationError(ErrInterpolationUnknownSource, expr)
+	}
+
+	name := expr[sourceEnd+1:]
+
+	switch expr[:sourceEnd] {
+	case InterpolationEnvironment:
+		value, defined := os.LookupEnv(name)
+
+		if !defined {
+			return nil, newInterpolationError(
+				ErrInterpolationEnvironmentUndefined, name)
+		}
+
+		return []byte(value), nil
+
+	case InterpolationFile:
+		content, readErr := os.ReadFile(name)
+
+		if readErr != nil {
+			return nil, newInterpolationError(
+				ErrInterpolationFileUnreadable, name)
+		}
+
+		return bytes.TrimRight(content, "\r\n"), nil
+	}
+
+	return nil, newInterpolationError(ErrInterpolationUnknownSource, expr)
+}
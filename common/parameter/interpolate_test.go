@@ -0,0 +1,163 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package parameter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+
+	writeErr := os.WriteFile(secretFile, []byte("S3cret\r\n"), 0600)
+
+	if writeErr != nil {
+		t.Error("Failed to write secret file due to error:", writeErr)
+
+		return
+	}
+
+	t.Setenv("COWARD_TEST_KEY", "Key")
+
+	result, resultErr := Interpolate([]byte(
+		"${ENV:COWARD_TEST_KEY}-${FILE:" + secretFile + "}-$${ENV:X}"))
+
+	if resultErr != nil {
+		t.Error("Failed to interpolate due to error:", resultErr)
+
+		return
+	}
+
+	if string(result) != "Key-S3cret-${ENV:X}" {
+		t.Errorf("Unexpected interpolation result: %s", result)
+
+		return
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"${ENV:COWARD_TEST_UNDEFINED}", ErrInterpolationEnvironmentUndefined},
+		{"${FILE:/coward/test/undefined}", ErrInterpolationFileUnreadable},
+		{"${ENV:COWARD_TEST_UNDEFINED", ErrInterpolationNotClosed},
+		{"${DB:Key}", ErrInterpolationUnknownSource},
+		{"${Key}", ErrInterpolationUnknownSource},
+	}
+
+	for _, test := range tests {
+		_, resultErr := Interpolate([]byte(test.input))
+
+		iErr, isInterpolationErr := resultErr.(*InterpolationError)
+
+		if !isInterpolationErr || !iErr.Is(test.err) {
+			t.Errorf("Expecting error %v for %s, got %v",
+				test.err, test.input, resultErr)
+
+			return
+		}
+	}
+}
+
+func TestValueInterpolate(t *testing.T) {
+	t.Setenv("COWARD_TEST_KEY", "Key")
+
+	p, pErr := New([]byte("-a \"${ENV:COWARD_TEST_KEY} 1\" 2"), 3)
+
+	if pErr != nil {
+		t.Error("Failed to parse due to error:", pErr)
+
+		return
+	}
+
+	values := p.Value().Labels()[0].Values()
+
+	interpolated, interpolateErr := values[0].Interpolate()
+
+	if interpolateErr != nil {
+		t.Error("Failed to interpolate due to error:", interpolateErr)
+
+		return
+	}
+
+	if string(interpolated.Data()) != "Key 1" {
+		t.Errorf("Unexpected interpolated data: %s", interpolated.Data())
+
+		return
+	}
+
+	if interpolated.Start() != values[0].Start() ||
+		interpolated.End() != values[0].End() {
+		t.Error("Interpolated Value must keep the position of the original")
+
+		return
+	}
+}
+
+func TestInterpolationInBlock(t *testing.T) {
+	p, pErr := New([]byte("-a ${ENV:A} -b { -c x${ENV:C}y } -d {d}"), 3)
+
+	if pErr != nil {
+		t.Error("Failed to parse due to error:", pErr)
+
+		return
+	}
+
+	labels := p.Value().Labels()
+
+	if len(labels) != 3 {
+		t.Errorf("Expecting 3 labels, got %d", len(labels))
+
+		return
+	}
+
+	if string(labels[0].Values()[0].Data()) != "${ENV:A}" {
+		t.Errorf("Unexpected value: %s", labels[0].Values()[0].Data())
+
+		return
+	}
+
+	block := labels[1].Values()[0]
+
+	if block.Symbol() != SymbolBlock {
+		t.Error("Expecting a block")
+
+		return
+	}
+
+	sub := block.Labels()
+
+	if len(sub) != 1 || string(sub[0].Values()[0].Data()) != "x${ENV:C}y" {
+		t.Errorf("Unexpected block value: %s", block.Data())
+
+		return
+	}
+
+	if labels[2].Values()[0].Symbol() != SymbolBlock {
+		t.Error("Expecting a block")
+
+		return
+	}
+}
@@ -80,12 +80,13 @@ func (p *parameter) analyze() error {
 		sub:      tokens{},
 	}
 	current := &tree{
-		token:     root,
-		lastLabel: nil,
-		fragment:  valueFragment,
-		parent:    nil,
-		lastStart: 0,
-		level:     0,
+		token:         root,
+		lastLabel:     nil,
+		fragment:      valueFragment,
+		parent:        nil,
+		lastStart:     0,
+		level:         0,
+		interpolating: false,
 	}
 	treeItem := &tree{
 		token: &token{
@@ -96,11 +97,12 @@ func (p *parameter) analyze() error {
 			symbol:   SymbolValue,
 			sub:      tokens{},
 		},
-		lastLabel: nil,
-		fragment:  valueFragment,
-		parent:    nil,
-		lastStart: 0,
-		level:     current.level,
+		lastLabel:     nil,
+		fragment:      valueFragment,
+		parent:        nil,
+		lastStart:     0,
+		level:         current.level,
+		interpolating: false,
 	}
 
 	for index, charactor := range p.input {
@@ -123,11 +125,12 @@ func (p *parameter) analyze() error {
 					symbol:   SymbolValue,
 					sub:      tokens{},
 				},
-				lastLabel: current.lastLabel,
-				fragment:  valueFragment,
-				parent:    current,
-				lastStart: 0,
-				level:     current.level,
+				lastLabel:     current.lastLabel,
+				fragment:      valueFragment,
+				parent:        current,
+				lastStart:     0,
+				level:         current.level,
+				interpolating: false,
 			}
 
 			current.lastStart = treeItem.start
@@ -138,6 +141,16 @@ func (p *parameter) analyze() error {
 		} else if treeItem.fragment.keepSeeking {
 			lastChar = charactor
 
+			continue
+		} else if treeItem.interpolating {
+			// Inside a `${...}`, the `}` closes the interpolation rather
+			// than the parent block
+			if charactor == interpolationTail {
+				treeItem.interpolating = false
+			}
+
+			lastChar = charactor
+
 			continue
 		} else if treeItem.isFall(charactor) {
 			treeItem.end = index
@@ -158,11 +171,12 @@ func (p *parameter) analyze() error {
 					symbol:   SymbolValue,
 					sub:      tokens{},
 				},
-				lastLabel: current.lastLabel,
-				fragment:  valueFragment,
-				parent:    current,
-				lastStart: 0,
-				level:     current.level,
+				lastLabel:     current.lastLabel,
+				fragment:      valueFragment,
+				parent:        current,
+				lastStart:     0,
+				level:         current.level,
+				interpolating: false,
 			}
 
 			lastChar = charactor
@@ -200,17 +214,27 @@ func (p *parameter) analyze() error {
 					symbol:   SymbolLabel,
 					sub:      tokens{},
 				},
-				lastLabel: current.lastLabel,
-				fragment:  labelFragment,
-				parent:    current,
-				lastStart: 0,
-				level:     current.level,
+				lastLabel:     current.lastLabel,
+				fragment:      labelFragment,
+				parent:        current,
+				lastStart:     0,
+				level:         current.level,
+				interpolating: false,
 			}
 
 			current.lastStart = treeItem.start
 			current.lastLabel = treeItem.token
 
 		case blockFragment.head:
+			// `${` in a bare value starts an interpolation, not a block
+			if lastChar == interpolationHead && index > treeItem.start &&
+				treeItem.fragment.head == valueFragment.head {
+				treeItem.interpolating = true
+				lastChar = charactor
+
+				continue
+			}
+
 			if current.lastStart == treeItem.start &&
 				treeItem.fragment.head == blockFragment.head {
 				lastChar = charactor
@@ -236,11 +260,12 @@ func (p *parameter) analyze() error {
 					symbol:   SymbolBlock,
 					sub:      tokens{},
 				},
-				lastLabel: nil,
-				fragment:  blockFragment,
-				parent:    current,
-				lastStart: 0,
-				level:     current.level + 1,
+				lastLabel:     nil,
+				fragment:      blockFragment,
+				parent:        current,
+				lastStart:     0,
+				level:         current.level + 1,
+				interpolating: false,
 			}
 
 			current.lastStart = treeItem.start
@@ -259,11 +284,12 @@ func (p *parameter) analyze() error {
 					symbol:   SymbolValue,
 					sub:      tokens{},
 				},
-				lastLabel: nil,
-				fragment:  valueFragment,
-				parent:    current,
-				lastStart: 0,
-				level:     current.level,
+				lastLabel:     nil,
+				fragment:      valueFragment,
+				parent:        current,
+				lastStart:     0,
+				level:         current.level,
+				interpolating: false,
 			}
 
 			current.lastStart = treeItem.start
@@ -297,11 +323,12 @@ func (p *parameter) analyze() error {
 					symbol:   SymbolValue,
 					sub:      tokens{},
 				},
-				lastLabel: current.lastLabel,
-				fragment:  doubleQuoteFragment,
-				parent:    current,
-				lastStart: 0,
-				level:     current.level,
+				lastLabel:     current.lastLabel,
+				fragment:      doubleQuoteFragment,
+				parent:        current,
+				lastStart:     0,
+				level:         current.level,
+				interpolating: false,
 			}
 
 			current.lastStart = treeItem.start
@@ -335,11 +362,12 @@ func (p *parameter) analyze() error {
 					symbol:   SymbolValue,
 					sub:      tokens{},
 				},
-				lastLabel: current.lastLabel,
-				fragment:  quoteFragment,
-				parent:    current,
-				lastStart: 0,
-				level:     current.level,
+				lastLabel:     current.lastLabel,
+				fragment:      quoteFragment,
+				parent:        current,
+				lastStart:     0,
+				level:         current.level,
+				interpolating: false,
 			}
 
 			current.lastStart = treeItem.start
@@ -365,11 +393,12 @@ func (p *parameter) analyze() error {
 					symbol:   SymbolValue,
 					sub:      tokens{},
 				},
-				lastLabel: current.lastLabel,
-				fragment:  valueFragment,
-				parent:    current,
-				lastStart: 0,
-				level:     current.level,
+				lastLabel:     current.lastLabel,
+				fragment:      valueFragment,
+				parent:        current,
+				lastStart:     0,
+				level:         current.level,
+				interpolating: false,
 			}
 
 			current.lastStart = treeItem.start
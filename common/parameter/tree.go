@@ -25,11 +25,12 @@ const treeInitEnd = -1
 type tree struct {
 	*token
 
-	lastLabel *token
-	fragment  fragment
-	parent    *tree
-	lastStart int
-	level     int
+	lastLabel     *token
+	fragment      fragment
+	parent        *tree
+	lastStart     int
+	level         int
+	interpolating bool
 }
 
 func (tr *tree) isTail(c byte) bool {
@@ -24,12 +24,14 @@ package parameter
 type Value struct {
 	token *token
 	input []byte
+	data  []byte
 }
 
 func newValue(t *token, input []byte) *Value {
 	return &Value{
 		token: t,
 		input: input,
+		data:  nil,
 	}
 }
 
@@ -41,9 +43,29 @@ func (v *Value) Symbol() Symbol {
 // Data returns a section of inputted data which represented by current
 // Value
 func (v *Value) Data() []byte {
+	if v.data != nil {
+		return v.data
+	}
+
 	return v.token.fragment.unescape(v.input[v.token.start:v.token.end])
 }
 
+// Interpolate returns a copy of current Value in which all interpolations
+// has been resolved. See Interpolate for detail
+func (v *Value) Interpolate() (*Value, error) {
+	data, interpolateErr := Interpolate(v.Data())
+
+	if interpolateErr != nil {
+		return nil, interpolateErr
+	}
+
+	return &Value{
+		token: v.token,
+		input: v.input,
+		data:  data,
+	}, nil
+}
+
 // Start returns start position of the data
 func (v *Value) Start() int {
 	return v.token.start
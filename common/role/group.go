@@ -0,0 +1,179 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package role
+
+import (
+	"sync"
+	"time"
+)
+
+// group runs multiple Roles as one
+type group struct {
+	roles           []Role
+	notifiers       []UnspawnNotifier
+	spawned         int
+	unspawnNotifier UnspawnNotifier
+}
+
+// NewGroup creates a Role that spawns and unspawns all given Roles together
+func NewGroup(roles []Role) Role {
+	return &group{
+		roles:           roles,
+		notifiers:       make([]UnspawnNotifier, len(roles)),
+		spawned:         0,
+		unspawnNotifier: nil,
+	}
+}
+
+// release releases the Roles which will never be spawned
+func release(roles []Role) {
+	for rIdx := range roles {
+		releaser, isReleaser := roles[rIdx].(Releaser)
+
+		if !isReleaser {
+			continue
+		}
+
+		releaser.Release()
+	}
+}
+
+// Spawn spawns all Roles in order. If one of them failed, the Roles that
+// already spawned will be unspawned, and the rest will be released
+func (g *group) Spawn(unspawnNotifier UnspawnNotifier) error {
+	g.unspawnNotifier = unspawnNotifier
+
+	for rIdx := range g.roles {
+		g.notifiers[rIdx] = make(UnspawnNotifier, 1)
+
+		spawnErr := g.roles[rIdx].Spawn(g.notifiers[rIdx])
+
+		if spawnErr == nil {
+			g.spawned++
+
+			continue
+		}
+
+		// Unspawn the failed one as well, so it can release what it
+		// has already been initialized
+		g.roles[rIdx].Unspawn()
+
+		<-g.notifiers[rIdx]
+
+		g.unspawnAll()
+
+		release(g.roles[rIdx+1:])
+
+		return spawnErr
+	}
+
+	return nil
+}
+
+// unspawnAll unspawns all spawned Roles in reverse order
+func (g *group) unspawnAll() error {
+	var lastErr error
+
+	for rIdx := g.spawned - 1; rIdx >= 0; rIdx-- {
+		unspawnErr := g.roles[rIdx].Unspawn()
+
+		if unspawnErr != nil {
+			lastErr = unspawnErr
+
+			continue
+		}
+
+		<-g.notifiers[rIdx]
+	}
+
+	g.spawned = 0
+
+	return lastErr
+}
+
+// Drain drains all Roles which can be drained at the same time
+func (g *group) Drain(timeout time.Duration) error {
+	var lastErr error
+
+	drainWait := sync.WaitGroup{}
+	drainErrLock := sync.Mutex{}
+
+	for rIdx := 0; rIdx < g.spawned; rIdx++ {
+		drainer, isDrainer := g.roles[rIdx].(Drainer)
+
+		if !isDrainer {
+			continue
+		}
+
+		drainWait.Add(1)
+
+		go func(d Drainer) {
+			defer drainWait.Done()
+
+			drainErr := d.Drain(timeout)
+
+			if drainErr == nil {
+				return
+			}
+
+			drainErrLock.Lock()
+			defer drainErrLock.Unlock()
+
+			lastErr = drainErr
+		}(drainer)
+	}
+
+	drainWait.Wait()
+
+	return lastErr
+}
+
+// Reopen reopens files of all Roles which can be reopened
+func (g *group) Reopen() error {
+	var lastErr error
+
+	for rIdx := 0; rIdx < g.spawned; rIdx++ {
+		reopener, isReopener := g.roles[rIdx].(Reopener)
+
+		if !isReopener {
+			continue
+		}
+
+		reopenErr := reopener.Reopen()
+
+		if reopenErr == nil {
+			continue
+		}
+
+		lastErr = reopenErr
+	}
+
+	return lastErr
+}
+
+// Unspawn unspawns all Roles
+func (g *group) Unspawn() error {
+	unspawnErr := g.unspawnAll()
+
+	g.unspawnNotifier <- struct{}{}
+
+	return unspawnErr
+}
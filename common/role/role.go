@@ -20,6 +20,8 @@
 
 package role
 
+import "time"
+
 // UnspawnNotifier is a write only chan that will be written when Role has
 // been unspawned
 type UnspawnNotifier chan struct{}
@@ -29,3 +31,22 @@ type Role interface {
 	Spawn(unspawnNotifier UnspawnNotifier) error
 	Unspawn() error
 }
+
+// Drainer is a Role that can stop accepting new requests and wait for the
+// ongoing ones to complete before it's been Unspawned
+type Drainer interface {
+	Drain(timeout time.Duration) error
+}
+
+// Reopener is a Role that writes to files which can be reopened after
+// they've been moved away
+type Reopener interface {
+	Reopen() error
+}
+
+// Releaser is a Role that holds resources (i.e. tickers) since it's been
+// created. Release must be called when the Role will never be spawned,
+// otherwise the resources will be leaked
+type Releaser interface {
+	Release() error
+}
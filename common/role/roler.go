@@ -23,10 +23,13 @@ package role
 import (
 	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
 
 	"github.com/reinit/coward/common/config"
 	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/parameter"
 	"github.com/reinit/coward/common/print"
 )
 
@@ -43,6 +46,15 @@ var (
 
 	ErrNoOperation = errors.New(
 		"No operation")
+
+	ErrGroupEmpty = errors.New(
+		"At least one Role must be declared")
+
+	ErrGroupRoleNameMustBeDeclared = errors.New(
+		"Role name must be declared as a label")
+
+	ErrGroupRoleOptionsMustBeBlock = errors.New(
+		"Role Options must be enclosed in a block")
 )
 
 // Roler is the role manager
@@ -59,6 +71,42 @@ type Roler interface {
 		parameters []byte,
 		log logger.Logger,
 	) (Role, error)
+	InitParameterGroup(
+		screenOut print.Common,
+		parameters []byte,
+		log logger.Logger,
+	) (Role, error)
+	InitDocument(
+		screenOut print.Common,
+		name string,
+		format string,
+		document []byte,
+		parameters []byte,
+		log logger.Logger,
+	) (Role, error)
+	Configure(
+		screenOut print.Common,
+		name string,
+		parameters []byte,
+	) (config.Exported, error)
+	ConfigureParameterGroup(
+		screenOut print.Common,
+		parameters []byte,
+	) (config.Exported, error)
+	ConfigureDocument(
+		screenOut print.Common,
+		name string,
+		format string,
+		document []byte,
+		parameters []byte,
+	) (config.Exported, error)
+	Wizard(
+		screenOut print.Common,
+		name string,
+		input io.Reader,
+	) ([]byte, error)
+	Complete(name string, words []string) []string
+	Names() []string
 	List(screenOut print.Common)
 	MaxRoleNameLen() int
 }
@@ -231,6 +279,306 @@ func (r *roler) InitParameterString(
 	return r.init(screenOut, role, configuration, log)
 }
 
+// InitParameterGroup initialize a group of Roles with a parameter string in
+// which every Role is declared as a label and followed by blocks of it's
+// Role Options. For example:
+//
+//	-socks5 { -port 1080 ... } -mapper { ... } { ... }
+func (r *roler) InitParameterGroup(
+	screenOut print.Common,
+	parameters []byte,
+	log logger.Logger,
+) (Role, error) {
+	roles := make([]Role, 0, 4)
+
+	groupErr := r.eachGroupRole(
+		parameters, func(name string, options []byte) error {
+			newRole, initErr := r.InitParameterString(
+				screenOut, name, options, log)
+
+			if initErr != nil {
+				return initErr
+			}
+
+			roles = append(roles, newRole)
+
+			return nil
+		})
+
+	if groupErr != nil {
+		release(roles)
+
+		return nil, groupErr
+	}
+
+	return NewGroup(roles), nil
+}
+
+// eachGroupRole calls each with the name and the Role Options of every Role
+// declared in a group parameter string
+func (r *roler) eachGroupRole(
+	parameters []byte,
+	each func(name string, options []byte) error,
+) error {
+	// One more level than a single Role, for the block of Role Options
+	param, paramErr := parameter.New(parameters, 4)
+
+	if paramErr != nil {
+		return paramErr
+	}
+
+	found := 0
+
+	for _, labelled := range param.Value().Labels() {
+		label := labelled.Label()
+
+		if label == nil {
+			return ErrGroupRoleNameMustBeDeclared
+		}
+
+		name := string(label.Data())
+		values := labelled.Values()
+
+		if len(values) <= 0 {
+			return ErrGroupRoleOptionsMustBeBlock
+		}
+
+		for _, value := range values {
+			if value.Symbol() != parameter.SymbolBlock {
+				return ErrGroupRoleOptionsMustBeBlock
+			}
+
+			// Use raw input instead of value.Data() so the escapes inside
+			// the block will be left for the Role Options parser
+			eachErr := each(name, parameters[value.Start():value.End()])
+
+			if eachErr != nil {
+				return eachErr
+			}
+
+			found++
+		}
+	}
+
+	if found <= 0 {
+		return ErrGroupEmpty
+	}
+
+	return nil
+}
+
+// InitDocument initialize a new Role with a JSON, YAML or TOML document.
+// The parameters will be applied after the document so they can override
+// the settings in it
+func (r *roler) InitDocument(
+	screenOut print.Common,
+	name string,
+	format string,
+	document []byte,
+	parameters []byte,
+	log logger.Logger,
+) (Role, error) {
+	role, existed := r.roles[name]
+
+	if !existed {
+		r.config.OnUndefined(screenOut, name, r.maxRoleNameLen, r.roles)
+
+		return nil, ErrNotExisted
+	}
+
+	if role.configuator == nil {
+		return r.init(screenOut, role, nil, log)
+	}
+
+	configuration := role.configuator(r.config.Components)
+
+	configuator, configuatorErr := config.Import(configuration)
+
+	if configuatorErr != nil {
+		return nil, configuatorErr
+	}
+
+	cfgParseErr := configuator.ParseDocument(format, document, parameters)
+
+	if cfgParseErr != nil {
+		return nil, cfgParseErr
+	}
+
+	return r.init(screenOut, role, configuration, log)
+}
+
+// configure parses the configuration of the Role with parse and exports
+// the result without initializing the Role
+func (r *roler) configure(
+	screenOut print.Common,
+	name string,
+	parse func(configuator config.Configurator) error,
+) (config.Exported, error) {
+	role, existed := r.roles[name]
+
+	if !existed {
+		r.config.OnUndefined(screenOut, name, r.maxRoleNameLen, r.roles)
+
+		return nil, ErrNotExisted
+	}
+
+	if role.configuator == nil {
+		return config.Exported{}, nil
+	}
+
+	configuator, configuatorErr := config.Import(
+		role.configuator(r.config.Components))
+
+	if configuatorErr != nil {
+		return nil, configuatorErr
+	}
+
+	parseErr := parse(configuator)
+
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	return configuator.Export(), nil
+}
+
+// Configure parses and verifies the parameter string of a Role, then
+// returns the resolved configuration without initializing the Role
+func (r *roler) Configure(
+	screenOut print.Common,
+	name string,
+	parameters []byte,
+) (config.Exported, error) {
+	return r.configure(screenOut, name,
+		func(configuator config.Configurator) error {
+			return configuator.Parse(parameters)
+		})
+}
+
+// ConfigureParameterGroup parses and verifies a group of Roles, then
+// returns the resolved configuration of all of them as a "roles" list
+func (r *roler) ConfigureParameterGroup(
+	screenOut print.Common,
+	parameters []byte,
+) (config.Exported, error) {
+	roles := make([]interface{}, 0, 4)
+
+	groupErr := r.eachGroupRole(
+		parameters, func(name string, options []byte) error {
+			exported, cfgErr := r.Configure(screenOut, name, options)
+
+			if cfgErr != nil {
+				return cfgErr
+			}
+
+			roles = append(roles, config.Exported{
+				config.ExportedField{
+					Name:   "role",
+					Value:  name,
+					Secret: false,
+				},
+				config.ExportedField{
+					Name:   "options",
+					Value:  exported,
+					Secret: false,
+				},
+			})
+
+			return nil
+		})
+
+	if groupErr != nil {
+		return nil, groupErr
+	}
+
+	return config.Exported{
+		config.ExportedField{
+			Name:   "roles",
+			Value:  roles,
+			Secret: false,
+		},
+	}, nil
+}
+
+// ConfigureDocument parses and verifies the document of a Role, then
+// returns the resolved configuration without initializing the Role
+func (r *roler) ConfigureDocument(
+	screenOut print.Common,
+	name string,
+	format string,
+	document []byte,
+	parameters []byte,
+) (config.Exported, error) {
+	return r.configure(screenOut, name,
+		func(configuator config.Configurator) error {
+			return configuator.ParseDocument(format, document, parameters)
+		})
+}
+
+// Wizard asks for the Role Options of a Role from input and returns them
+// as a parameter string
+func (r *roler) Wizard(
+	screenOut print.Common,
+	name string,
+	input io.Reader,
+) ([]byte, error) {
+	role, existed := r.roles[name]
+
+	if !existed {
+		r.config.OnUndefined(screenOut, name, r.maxRoleNameLen, r.roles)
+
+		return nil, ErrNotExisted
+	}
+
+	if role.configuator == nil {
+		screenOut.Writeln([]byte(fmt.Sprintf(
+			"\"%s\" requires no option.\r\n", name)), 1, 2, 1)
+
+		return nil, ErrNoOperation
+	}
+
+	configuator, configuatorErr := config.Import(
+		role.configuator(r.config.Components))
+
+	if configuatorErr != nil {
+		return nil, configuatorErr
+	}
+
+	return configuator.Wizard(screenOut, input)
+}
+
+// Complete returns the completion candidates of the last word of the Role
+// Options
+func (r *roler) Complete(name string, words []string) []string {
+	role, existed := r.roles[name]
+
+	if !existed || role.configuator == nil {
+		return nil
+	}
+
+	configuator, configuatorErr := config.Import(
+		role.configuator(r.config.Components))
+
+	if configuatorErr != nil {
+		return nil
+	}
+
+	return configuator.Complete(words)
+}
+
+// Names returns the sorted names of all registered Roles
+func (r *roler) Names() []string {
+	names := make([]string, 0, len(r.roles))
+
+	for name := range r.roles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
 func (r *roler) List(screenOut print.Common) {
 	r.config.OnListScreen(screenOut, r.maxRoleNameLen, r.roles)
 }
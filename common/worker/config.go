@@ -22,10 +22,29 @@ package worker
 
 import "time"
 
-// Config coroutiner's Configuration
+// Config coroutiner's Configuration. When a Job has been waited for longer
+// than MaxQueueLatency, more Workers will be created at once. When the
+// memory usage exceeds the soft MemoryLimit, no more Worker will be created
+// and the Jobs which can't be picked up by an idle Worker will be rejected.
+// Once the Workers can't grow anymore, at most MaxQueued Jobs can wait for
+// a Worker, the rest will be rejected. Zero disables those limits
 type Config struct {
 	MaxWorkers        uint32
 	MinWorkers        uint32
 	MaxWorkerIdle     time.Duration
 	JobReceiveTimeout time.Duration
+	MaxQueueLatency   time.Duration
+	MemoryLimit       uint64
+	MaxQueued         uint32
+}
+
+// Stats is the state of the Workers
+type Stats struct {
+	Workers      uint32
+	Busy         uint32
+	Idle         uint32
+	Queued       uint32
+	Rejected     uint64
+	QueueLatency time.Duration
+	Memory       uint64
 }
@@ -23,8 +23,10 @@ package worker
 import (
 	"errors"
 	"fmt"
+	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/reinit/coward/common/logger"
@@ -42,6 +44,9 @@ var (
 	ErrJobJoinCanceled = errors.New(
 		"Job Join has been canceled")
 
+	ErrJobRejected = errors.New(
+		"Job has been rejected as the Workers are saturated")
+
 	ErrAlreadyUp = errors.New(
 		"Already serving")
 
@@ -58,6 +63,7 @@ type Workers interface {
 type Runner interface {
 	Run(log logger.Logger, j Job, cancel <-chan struct{}) (chan error, error)
 	RunWait(log logger.Logger, j Job, cancel <-chan struct{}) error
+	Stats() Stats
 	Close() error
 }
 
@@ -78,11 +84,38 @@ type workerClose struct {
 
 // workerCreate request
 type workerCreate struct {
-	Created chan struct{}
+	Created chan workerCreated
 }
 
+// workerCreated is the result of a workerCreate request
+type workerCreated byte
+
+// workerCreate results
+const (
+	workerCreatedNew workerCreated = iota
+	workerCreatedFull
+	workerCreatedMemoryExceeded
+)
+
+const (
+	// memorySampleInterval is the minimal interval between two memory
+	// usage samples
+	memorySampleInterval = 100 * time.Millisecond
+
+	// latencySmoothing is the weight of the history in the moving average
+	// of queue latency
+	latencySmoothing = 8
+)
+
 // workers implements Workers
 type workers struct {
+	rejected              uint64
+	latency               int64
+	memory                uint64
+	memorySampled         time.Time
+	workers               uint32
+	busy                  uint32
+	queued                uint32
 	log                   logger.Logger
 	ticker                ticker.Requester
 	cfg                   Config
@@ -98,6 +131,13 @@ type workers struct {
 // New creates a new Jobs
 func New(log logger.Logger, tick ticker.Requester, cfg Config) Workers {
 	return &workers{
+		rejected:              0,
+		latency:               0,
+		memory:                0,
+		memorySampled:         time.Time{},
+		workers:               0,
+		busy:                  0,
+		queued:                0,
 		log:                   log.Context("Workers"),
 		ticker:                tick,
 		cfg:                   cfg,
@@ -120,6 +160,7 @@ func (c *workers) maintainer(ready chan struct{}) {
 	log := c.log.Context("Maintainer")
 	workingWorkers := uint32(0)
 	lastWorkerID := uint32(0)
+	createBatch := c.cfg.MinWorkers
 	maxWorkersRelease := c.cfg.MinWorkers
 	nextRelease := time.Now().Add(c.cfg.MaxWorkerIdle)
 	closeSignal := make(chan workerClose)
@@ -175,18 +216,33 @@ func (c *workers) maintainer(ready chan struct{}) {
 			maxWorkersRelease = c.cfg.MinWorkers
 			nextRelease = time.Now().Add(c.cfg.MaxWorkerIdle)
 
-			func(cd workerCreate) {
-				defer func() {
-					cd.Created <- struct{}{}
-				}()
+			cd.Created <- func() workerCreated {
+				if c.memoryExceeded() {
+					log.Debugf("Memory limit has been exceeded, "+
+						"%d bytes in use", atomic.LoadUint64(&c.memory))
+
+					return workerCreatedMemoryExceeded
+				}
 
 				wCanCreate := c.cfg.MaxWorkers - workingWorkers
 
 				if wCanCreate <= 0 {
-					return
+					return workerCreatedFull
+				}
+
+				// Jobs are waiting too long, create more Workers at once
+				if c.cfg.MaxQueueLatency > 0 &&
+					c.queueLatency() > c.cfg.MaxQueueLatency {
+					createBatch *= 2
+				} else {
+					createBatch = c.cfg.MinWorkers
 				}
 
-				wToCreate := c.cfg.MinWorkers
+				if createBatch > c.cfg.MaxWorkers {
+					createBatch = c.cfg.MaxWorkers
+				}
+
+				wToCreate := createBatch
 
 				if wToCreate > wCanCreate {
 					wToCreate = wCanCreate
@@ -208,14 +264,20 @@ func (c *workers) maintainer(ready chan struct{}) {
 					lastWorkerID++
 					workingWorkers++
 					wCreated++
+
+					atomic.AddUint32(&c.workers, 1)
 				}
 
 				log.Debugf("%d Workers has been created", wCreated)
-			}(cd)
+
+				return workerCreatedNew
+			}()
 
 		case <-quitNotify:
 			workingWorkers--
 
+			atomic.AddUint32(&c.workers, ^uint32(0))
+
 			if shutdownReceive != nil {
 				continue
 			}
@@ -239,7 +301,8 @@ func (c *workers) maintainer(ready chan struct{}) {
 
 			releaseWait = releaseWaiter.Wait()
 
-			if time.Now().Before(nextRelease) {
+			// Release idle Workers early when memory is running out
+			if time.Now().Before(nextRelease) && !c.memoryExceeded() {
 				continue
 			}
 
@@ -306,8 +369,12 @@ func (c *workers) worker(
 			closeSignalReceive = closeSignal
 
 		case j := <-jobReceive:
+			atomic.AddUint32(&c.busy, 1)
+
 			j.Result <- j.Job(j.Logger.Context(workerName))
 
+			atomic.AddUint32(&c.busy, ^uint32(0))
+
 		case close, closeOK := <-closeSignalReceive:
 			if !closeOK {
 				return
@@ -320,6 +387,55 @@ func (c *workers) worker(
 	}
 }
 
+// memoryExceeded samples the memory usage and returns whether or not it
+// has exceeded the MemoryLimit. Must only be called by the maintainer
+func (c *workers) memoryExceeded() bool {
+	if c.cfg.MemoryLimit <= 0 {
+		return false
+	}
+
+	now := time.Now()
+
+	if now.Sub(c.memorySampled) >= memorySampleInterval {
+		memStats := runtime.MemStats{}
+
+		runtime.ReadMemStats(&memStats)
+
+		atomic.StoreUint64(&c.memory, memStats.HeapInuse+memStats.StackInuse)
+
+		c.memorySampled = now
+	}
+
+	return atomic.LoadUint64(&c.memory) > c.cfg.MemoryLimit
+}
+
+// queueLatency returns the moving average of the time Jobs waited for a
+// Worker
+func (c *workers) queueLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.latency))
+}
+
+// recordQueueLatency adds a sample to the moving average of queue latency.
+// Samples are recorded by many goroutines at the same time, so the average
+// is swapped in only when no other sample came in between
+func (c *workers) recordQueueLatency(d time.Duration) {
+	for {
+		latency := atomic.LoadInt64(&c.latency)
+
+		if atomic.CompareAndSwapInt64(&c.latency, latency,
+			latency+(int64(d)-latency)/latencySmoothing) {
+			return
+		}
+	}
+}
+
+// reject counts and returns a rejected Job
+func (c *workers) reject() error {
+	atomic.AddUint64(&c.rejected, 1)
+
+	return ErrJobRejected
+}
+
 // Serve start serve
 func (c *workers) Serve() (Runner, error) {
 	c.bootLock.Lock()
@@ -382,24 +498,42 @@ func (c *workers) Run(
 
 	select {
 	case c.job <- newJob:
+		c.recordQueueLatency(0)
+
 		return newJob.Result, nil
 
 	default:
-		jobCreate := workerCreate{
-			Created: make(chan struct{}),
-		}
+	}
 
-		select {
-		case c.create <- jobCreate:
-			<-jobCreate.Created
+	queued := atomic.AddUint32(&c.queued, 1)
+
+	defer atomic.AddUint32(&c.queued, ^uint32(0))
 
-		case <-joinWait.Wait():
-			return nil, ErrJobReceiveTimedout
+	queuedAt := time.Now()
+	jobCreate := workerCreate{
+		Created: make(chan workerCreated),
+	}
+
+	select {
+	case c.create <- jobCreate:
+		switch <-jobCreate.Created {
+		case workerCreatedMemoryExceeded:
+			return nil, c.reject()
+
+		case workerCreatedFull:
+			if c.cfg.MaxQueued > 0 && queued > c.cfg.MaxQueued {
+				return nil, c.reject()
+			}
 		}
+
+	case <-joinWait.Wait():
+		return nil, ErrJobReceiveTimedout
 	}
 
 	select {
 	case c.job <- newJob:
+		c.recordQueueLatency(time.Since(queuedAt))
+
 		return newJob.Result, nil
 
 	case <-cancel:
@@ -429,3 +563,24 @@ func (c *workers) RunWait(
 
 	return <-runResult
 }
+
+// Stats returns current state of the Workers
+func (c *workers) Stats() Stats {
+	workers := atomic.LoadUint32(&c.workers)
+	busy := atomic.LoadUint32(&c.busy)
+	idle := uint32(0)
+
+	if workers > busy {
+		idle = workers - busy
+	}
+
+	return Stats{
+		Workers:      workers,
+		Busy:         busy,
+		Idle:         idle,
+		Queued:       atomic.LoadUint32(&c.queued),
+		Rejected:     atomic.LoadUint64(&c.rejected),
+		QueueLatency: c.queueLatency(),
+		Memory:       atomic.LoadUint64(&c.memory),
+	}
+}
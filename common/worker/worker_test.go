@@ -21,6 +21,7 @@
 package worker
 
 import (
+	"sync"
 	"testing"
 	"time"
 
@@ -134,3 +135,189 @@ func TestJobServeRunWaitClose(t *testing.T) {
 		return
 	}
 }
+
+func testWaitStats(
+	t *testing.T, r Runner, check func(s Stats) bool) bool {
+	for i := 0; i < 100; i++ {
+		if check(r.Stats()) {
+			return true
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("Unexpected Stats: %+v", r.Stats())
+
+	return false
+}
+
+func TestJobRejectWhenSaturated(t *testing.T) {
+	tk, tkErr := ticker.New(300*time.Millisecond, 16).Serve()
+
+	if tkErr != nil {
+		t.Errorf("Failed to create ticker due to error: %s", tkErr)
+
+		return
+	}
+
+	serving, serveErr := New(logger.NewDitch(), tk, Config{
+		MaxWorkers:        2,
+		MinWorkers:        1,
+		MaxWorkerIdle:     10 * time.Second,
+		JobReceiveTimeout: 10 * time.Second,
+		MaxQueueLatency:   0,
+		MemoryLimit:       0,
+		MaxQueued:         1,
+	}).Serve()
+
+	if serveErr != nil {
+		t.Error("Failed to serve due to error:", serveErr)
+
+		return
+	}
+
+	defer serving.Close()
+
+	log := logger.NewDitch()
+	release := make(chan struct{})
+	job := func(logger.Logger) error {
+		<-release
+
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		_, runErr := serving.Run(log, job, nil)
+
+		if runErr != nil {
+			t.Error("Failed to run due to error:", runErr)
+
+			return
+		}
+	}
+
+	if !testWaitStats(t, serving, func(s Stats) bool {
+		return s.Workers == 2 && s.Busy == 2 && s.Idle == 0
+	}) {
+		return
+	}
+
+	queuedResult := make(chan error, 1)
+
+	go func() {
+		queuedResult <- serving.RunWait(log, job, nil)
+	}()
+
+	if !testWaitStats(t, serving, func(s Stats) bool {
+		return s.Queued == 1
+	}) {
+		return
+	}
+
+	_, runErr := serving.Run(log, job, nil)
+
+	if runErr != ErrJobRejected {
+		t.Errorf("Expecting error %v, got %v", ErrJobRejected, runErr)
+
+		return
+	}
+
+	if serving.Stats().Rejected != 1 {
+		t.Errorf("Expecting 1 rejected Job, got %+v", serving.Stats())
+
+		return
+	}
+
+	close(release)
+
+	queuedErr := <-queuedResult
+
+	if queuedErr != nil {
+		t.Error("Queued Job has failed due to error:", queuedErr)
+
+		return
+	}
+
+	testWaitStats(t, serving, func(s Stats) bool {
+		return s.Busy == 0 && s.Queued == 0 && s.Idle == s.Workers
+	})
+}
+
+func TestJobRejectWhenMemoryExceeded(t *testing.T) {
+	tk, tkErr := ticker.New(300*time.Millisecond, 16).Serve()
+
+	if tkErr != nil {
+		t.Errorf("Failed to create ticker due to error: %s", tkErr)
+
+		return
+	}
+
+	serving, serveErr := New(logger.NewDitch(), tk, Config{
+		MaxWorkers:        64,
+		MinWorkers:        16,
+		MaxWorkerIdle:     10 * time.Second,
+		JobReceiveTimeout: 10 * time.Second,
+		MaxQueueLatency:   0,
+		MemoryLimit:       1,
+		MaxQueued:         0,
+	}).Serve()
+
+	if serveErr != nil {
+		t.Error("Failed to serve due to error:", serveErr)
+
+		return
+	}
+
+	defer serving.Close()
+
+	runErr := serving.RunWait(logger.NewDitch(), func(logger.Logger) error {
+		return nil
+	}, nil)
+
+	if runErr != ErrJobRejected {
+		t.Errorf("Expecting error %v, got %v", ErrJobRejected, runErr)
+
+		return
+	}
+
+	stats := serving.Stats()
+
+	if stats.Workers != 0 || stats.Rejected != 1 || stats.Memory <= 1 {
+		t.Errorf("Unexpected Stats: %+v", stats)
+
+		return
+	}
+}
+
+func TestJobQueueLatencyConcurrentSamples(t *testing.T) {
+	const samples = 64
+	const sample = 8 * time.Second
+
+	expected := &workers{}
+
+	for i := 0; i < samples; i++ {
+		expected.recordQueueLatency(sample)
+	}
+
+	c := &workers{}
+	wait := sync.WaitGroup{}
+
+	for i := 0; i < samples; i++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			c.recordQueueLatency(sample)
+		}()
+	}
+
+	wait.Wait()
+
+	if c.queueLatency() != expected.queueLatency() {
+		t.Errorf("Expecting queue latency %s, got %s",
+			expected.queueLatency(), c.queueLatency())
+
+		return
+	}
+}
@@ -25,6 +25,8 @@ import (
 
 	"github.com/reinit/coward/application"
 	"github.com/reinit/coward/roles/common/codec"
+	"github.com/reinit/coward/roles/common/transceiver/clients"
+	"github.com/reinit/coward/roles/dns"
 	"github.com/reinit/coward/roles/mapper"
 	"github.com/reinit/coward/roles/project"
 	"github.com/reinit/coward/roles/projector"
@@ -42,11 +44,12 @@ func main() {
 		Copyright: "",
 		URL:       "",
 		Components: application.Components{
-			proxy.Role, socks5.Role, mapper.Role,
+			proxy.Role, socks5.Role, mapper.Role, dns.Role,
 			projector.Role, project.Role,
 			codec.Plain,
 			codec.AESCFB128, codec.AESCFB256,
 			codec.AESGCM128, codec.AESGCM256,
+			clients.NewShared(),
 		},
 	})
 
@@ -0,0 +1,151 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package access
+
+import (
+	"sync"
+	"time"
+
+	"github.com/reinit/coward/roles/common/relay"
+)
+
+// Consts
+const (
+	ReasonCompleted = "completed"
+)
+
+// Record is a single access log record of a finished relay session
+type Record struct {
+	Time        time.Time
+	Client      string
+	User        string
+	Destination string
+	Protocol    string
+	ProxyClient string
+	Inbound     uint64
+	Outbound    uint64
+	Duration    time.Duration
+	Reason      string
+}
+
+// Logger writes access log Records
+type Logger interface {
+	Log(r Record)
+}
+
+// Log is an access Logger which must be opened before use
+type Log interface {
+	Logger
+
+	Open() error
+	Reopen() error
+	Close() error
+}
+
+// Session collects information of a relay session for the access log
+type Session struct {
+	Traffic relay.Traffic
+
+	lock        sync.Mutex
+	start       time.Time
+	client      string
+	user        string
+	destination string
+	protocol    string
+	proxyClient string
+}
+
+// NewSession creates a new Session which starts from now
+func NewSession(client string, protocol string) *Session {
+	return &Session{
+		Traffic:     relay.Traffic{},
+		lock:        sync.Mutex{},
+		start:       time.Now(),
+		client:      client,
+		user:        "",
+		destination: "",
+		protocol:    protocol,
+		proxyClient: "",
+	}
+}
+
+// User sets the authenticated user of the Session
+func (s *Session) User(user string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.user = user
+}
+
+// Destination sets the destination of the Session
+func (s *Session) Destination(destination string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.destination = destination
+}
+
+// Protocol sets the protocol of the Session
+func (s *Session) Protocol(protocol string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.protocol = protocol
+}
+
+// ProxyClient sets the Proxy client which is chosen to relay the Session
+func (s *Session) ProxyClient(proxyClient string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.proxyClient = proxyClient
+}
+
+// Record builds the Record of a Session that has been finished with
+// the given error
+func (s *Session) Record(err error) Record {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reason := ReasonCompleted
+
+	if err != nil {
+		reason = err.Error()
+	}
+
+	return Record{
+		Time:        s.start,
+		Client:      s.client,
+		User:        s.user,
+		Destination: s.destination,
+		Protocol:    s.protocol,
+		ProxyClient: s.proxyClient,
+		Inbound:     s.Traffic.Inbound(),
+		Outbound:    s.Traffic.Outbound(),
+		Duration:    time.Since(s.start),
+		Reason:      reason,
+	}
+}
+
+// Finish writes the Record of the Session to the Logger
+func (s *Session) Finish(l Logger, err error) {
+	l.Log(s.Record(err))
+}
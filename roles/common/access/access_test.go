@@ -0,0 +1,180 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package access
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSessionRecord(t *testing.T) {
+	s := NewSession("127.0.0.1:1234", "tcp")
+
+	s.User("user")
+	s.Destination("example.com:80")
+	s.ProxyClient("1")
+
+	r := s.Record(nil)
+
+	if r.Client != "127.0.0.1:1234" || r.User != "user" ||
+		r.Destination != "example.com:80" || r.Protocol != "tcp" ||
+		r.ProxyClient != "1" {
+		t.Errorf("Unexpected Record: %+v", r)
+
+		return
+	}
+
+	if r.Reason != ReasonCompleted {
+		t.Errorf("Expecting reason to be %s, got %s",
+			ReasonCompleted, r.Reason)
+
+		return
+	}
+
+	r = s.Record(errors.New("Failed"))
+
+	if r.Reason != "Failed" {
+		t.Errorf("Expecting reason to be %s, got %s", "Failed", r.Reason)
+
+		return
+	}
+}
+
+func TestFileLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+
+	l := NewFile(path)
+
+	if l.Open() != nil {
+		t.Error("Failed to open access log")
+
+		return
+	}
+
+	s := NewSession("127.0.0.1:1234", "udp")
+
+	s.Destination("#1")
+
+	s.Finish(l, nil)
+	s.Finish(l, errors.New("Failed"))
+
+	closeErr := l.Close()
+
+	if closeErr != nil {
+		t.Error("Failed to close access log due to error:", closeErr)
+
+		return
+	}
+
+	data, readErr := os.ReadFile(path)
+
+	if readErr != nil {
+		t.Error("Failed to read access log due to error:", readErr)
+
+		return
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+
+	if len(lines) != 2 {
+		t.Errorf("Expecting 2 records, got %d", len(lines))
+
+		return
+	}
+
+	entry := fileEntry{}
+
+	unmarshalErr := json.Unmarshal([]byte(lines[1]), &entry)
+
+	if unmarshalErr != nil {
+		t.Error("Failed to decode record due to error:", unmarshalErr)
+
+		return
+	}
+
+	if entry.Client != "127.0.0.1:1234" || entry.Destination != "#1" ||
+		entry.Protocol != "udp" || entry.Reason != "Failed" {
+		t.Errorf("Unexpected record: %s", lines[1])
+
+		return
+	}
+}
+
+func TestFileLogReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	moved := path + ".1"
+
+	l := NewFile(path)
+
+	if l.Reopen() != ErrFileNotOpened {
+		t.Error("Expecting Reopen to fail before the log is opened")
+
+		return
+	}
+
+	if l.Open() != nil {
+		t.Error("Failed to open access log")
+
+		return
+	}
+
+	defer l.Close()
+
+	NewSession("127.0.0.1:1234", "tcp").Finish(l, nil)
+
+	renameErr := os.Rename(path, moved)
+
+	if renameErr != nil {
+		t.Error("Failed to move access log due to error:", renameErr)
+
+		return
+	}
+
+	reopenErr := l.Reopen()
+
+	if reopenErr != nil {
+		t.Error("Failed to reopen access log due to error:", reopenErr)
+
+		return
+	}
+
+	NewSession("127.0.0.1:1234", "tcp").Finish(l, errors.New("Failed"))
+
+	for _, p := range []string{moved, path} {
+		data, readErr := os.ReadFile(p)
+
+		if readErr != nil {
+			t.Error("Failed to read access log due to error:", readErr)
+
+			return
+		}
+
+		if strings.Count(string(data), "\n") != 1 {
+			t.Errorf("Expecting 1 record in %s, got %q", p, data)
+
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package access
+
+type ditch struct{}
+
+// NewDitch creates a access Log that will ditch all the Records
+func NewDitch() Log {
+	return ditch{}
+}
+
+// Open does nothing
+func (d ditch) Open() error {
+	return nil
+}
+
+// Reopen does nothing
+func (d ditch) Reopen() error {
+	return nil
+}
+
+// Log ditchs the Record
+func (d ditch) Log(r Record) {}
+
+// Close does nothing
+func (d ditch) Close() error {
+	return nil
+}
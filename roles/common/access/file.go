@@ -0,0 +1,157 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package access
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// Errors
+var (
+	ErrFileAlreadyOpened = errors.New(
+		"Access log file already opened")
+
+	ErrFileNotOpened = errors.New(
+		"Access log file is not opened")
+)
+
+type file struct {
+	path   string
+	lock   sync.Mutex
+	writer *os.File
+}
+
+type fileEntry struct {
+	Time        string  `json:"time"`
+	Client      string  `json:"client"`
+	User        string  `json:"user,omitempty"`
+	Destination string  `json:"destination"`
+	Protocol    string  `json:"protocol"`
+	ProxyClient string  `json:"proxy_client,omitempty"`
+	Inbound     uint64  `json:"bytes_in"`
+	Outbound    uint64  `json:"bytes_out"`
+	Duration    float64 `json:"duration"`
+	Reason      string  `json:"reason"`
+}
+
+// NewFile creates a access Log that appends Records to the file as
+// JSON lines
+func NewFile(path string) Log {
+	return &file{
+		path:   path,
+		lock:   sync.Mutex{},
+		writer: nil,
+	}
+}
+
+// Open opens the log file
+func (f *file) Open() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.writer != nil {
+		return ErrFileAlreadyOpened
+	}
+
+	writer, openErr := os.OpenFile(
+		f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+
+	if openErr != nil {
+		return openErr
+	}
+
+	f.writer = writer
+
+	return nil
+}
+
+// Reopen opens the log file again and closes the old one, so the file can
+// be moved away by log rotators
+func (f *file) Reopen() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.writer == nil {
+		return ErrFileNotOpened
+	}
+
+	writer, openErr := os.OpenFile(
+		f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+
+	if openErr != nil {
+		return openErr
+	}
+
+	closeErr := f.writer.Close()
+
+	f.writer = writer
+
+	return closeErr
+}
+
+// Log writes the Record to the log file
+func (f *file) Log(r Record) {
+	line, mErr := json.Marshal(fileEntry{
+		Time:        r.Time.Format(time.RFC3339Nano),
+		Client:      r.Client,
+		User:        r.User,
+		Destination: r.Destination,
+		Protocol:    r.Protocol,
+		ProxyClient: r.ProxyClient,
+		Inbound:     r.Inbound,
+		Outbound:    r.Outbound,
+		Duration:    r.Duration.Seconds(),
+		Reason:      r.Reason,
+	})
+
+	if mErr != nil {
+		return
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.writer == nil {
+		return
+	}
+
+	f.writer.Write(append(line, '\n'))
+}
+
+// Close closes the log file
+func (f *file) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.writer == nil {
+		return ErrFileNotOpened
+	}
+
+	closeErr := f.writer.Close()
+
+	f.writer = nil
+
+	return closeErr
+}
@@ -36,6 +36,11 @@ func (p plain) Decode(i io.Reader) io.Reader {
 	return i
 }
 
+// Transparent returns true as data is carried as it is
+func (p plain) Transparent() bool {
+	return true
+}
+
 // New returns a new Plain codc
 func New() (rw.Codec, error) {
 	return plain{}, nil
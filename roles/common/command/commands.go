@@ -52,6 +52,13 @@ type Command interface {
 	New(rw.ReadWriteDepleteDoner, logger.Logger) fsm.Machine
 }
 
+// Completer is a Command context which can be completed without reading
+// another segment. Once it's completed, the next segment will be handled
+// as a new Command
+type Completer interface {
+	Completed() bool
+}
+
 // Consts
 const (
 	MaxCommands = ID(64)
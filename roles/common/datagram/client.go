@@ -0,0 +1,192 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// Errors
+var (
+	ErrProbeFailed = errors.New(
+		"Datagram Server did not respond to the probes")
+)
+
+// clientAssociation is an Association on the client side
+type clientAssociation struct {
+	conn    *net.UDPConn
+	crypter *crypter
+	buf     []byte
+}
+
+// Dial creates an Association to the Server on the address, and probes
+// it to make sure datagrams can actually reach the Server and back
+func Dial(
+	address string,
+	s Secret,
+	probes int,
+	wait time.Duration,
+) (Association, error) {
+	addr, resolveErr := net.ResolveUDPAddr("udp", address)
+
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+
+	c, cErr := newCrypter(s, toServer, toClient)
+
+	if cErr != nil {
+		return nil, cErr
+	}
+
+	conn, dialErr := net.DialUDP("udp", nil, addr)
+
+	if dialErr != nil {
+		return nil, dialErr
+	}
+
+	assoc := &clientAssociation{
+		conn:    conn,
+		crypter: c,
+		buf:     make([]byte, maxPacketSize),
+	}
+
+	for pIdx := 0; pIdx < probes; pIdx++ {
+		probed, probeErr := assoc.probe(wait)
+
+		if probeErr != nil {
+			conn.Close()
+
+			return nil, probeErr
+		}
+
+		if probed {
+			return assoc, nil
+		}
+	}
+
+	conn.Close()
+
+	return nil, ErrProbeFailed
+}
+
+// probe sends a probe and waits for the reply
+func (c *clientAssociation) probe(wait time.Duration) (bool, error) {
+	packet, sealErr := c.crypter.seal(packetProbe, nil)
+
+	if sealErr != nil {
+		return false, sealErr
+	}
+
+	_, wErr := c.conn.Write(packet)
+
+	if wErr != nil {
+		return false, wErr
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(wait))
+
+	for {
+		rLen, rErr := c.conn.Read(c.buf)
+
+		if rErr != nil {
+			netErr, isNetErr := rErr.(net.Error)
+
+			if isNetErr && netErr.Timeout() {
+				return false, nil
+			}
+
+			// Errors such as ICMP Port Unreachable, wait for the
+			// deadline before next try
+			continue
+		}
+
+		t, _, openErr := c.crypter.open(c.buf[:rLen])
+
+		if openErr != nil || t != packetProbeReply {
+			continue
+		}
+
+		return true, nil
+	}
+}
+
+// Receive receives the payload of a datagram
+func (c *clientAssociation) Receive(
+	b []byte, wait time.Duration) (int, error) {
+	c.conn.SetReadDeadline(time.Now().Add(wait))
+
+	for {
+		rLen, rErr := c.conn.Read(c.buf)
+
+		if rErr != nil {
+			netErr, isNetErr := rErr.(net.Error)
+
+			if isNetErr && netErr.Timeout() {
+				return 0, ErrTimeout
+			}
+
+			return 0, rErr
+		}
+
+		t, payload, openErr := c.crypter.open(c.buf[:rLen])
+
+		if openErr != nil || t != packetData {
+			continue
+		}
+
+		return copy(b, payload), nil
+	}
+}
+
+// Send sends a datagram to the Server
+func (c *clientAssociation) Send(b []byte) error {
+	packet, sealErr := c.crypter.seal(packetData, b)
+
+	if sealErr != nil {
+		return sealErr
+	}
+
+	_, wErr := c.conn.Write(packet)
+
+	return wErr
+}
+
+// Keepalive tells the Server that the client is still there, so the
+// Server (and the NATs in between) keeps the client address
+func (c *clientAssociation) Keepalive() error {
+	packet, sealErr := c.crypter.seal(packetKeepalive, nil)
+
+	if sealErr != nil {
+		return sealErr
+	}
+
+	_, wErr := c.conn.Write(packet)
+
+	return wErr
+}
+
+// Close closes the Association
+func (c *clientAssociation) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,224 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Consts
+const (
+	counterSize      = 8
+	packetHeaderSize = idSize + counterSize
+	replayWindowSize = 64
+)
+
+// Errors
+var (
+	ErrInvalidPacket = errors.New(
+		"Invalid datagram packet")
+
+	ErrReplayedPacket = errors.New(
+		"Replayed datagram packet")
+)
+
+// packetType is the type of a datagram packet
+type packetType byte
+
+// Packet types
+const (
+	packetData       packetType = 0x00
+	packetProbe      packetType = 0x01
+	packetProbeReply packetType = 0x02
+	packetKeepalive  packetType = 0x03
+)
+
+// direction prevents a packet from been reflected back to it's sender
+type direction [4]byte
+
+// Directions
+var (
+	toServer = direction{0x00, 0x00, 0x00, 0x01}
+	toClient = direction{0x00, 0x00, 0x00, 0x02}
+)
+
+// replayWindow records recently received packet counters
+type replayWindow struct {
+	highest uint64
+	seen    uint64
+}
+
+// crypter seals and opens packets of an Association.
+//
+// A packet is formed by the Association ID, a 8 bytes counter and the
+// sealed packet type and payload. The counter and the direction of the
+// packet is used as the nonce, so every packet can only be accepted once
+type crypter struct {
+	aead       cipher.AEAD
+	id         ID
+	send       direction
+	receive    direction
+	counter    uint64
+	window     replayWindow
+	windowLock sync.Mutex
+}
+
+// accept records the counter and returns whether or not it's first seen
+func (w *replayWindow) accept(counter uint64) bool {
+	if counter == 0 {
+		return false
+	}
+
+	if counter > w.highest {
+		shift := counter - w.highest
+
+		if shift >= replayWindowSize {
+			w.seen = 0
+		} else {
+			w.seen <<= shift
+		}
+
+		w.seen |= 1
+		w.highest = counter
+
+		return true
+	}
+
+	offset := w.highest - counter
+
+	if offset >= replayWindowSize {
+		return false
+	}
+
+	mask := uint64(1) << offset
+
+	if w.seen&mask != 0 {
+		return false
+	}
+
+	w.seen |= mask
+
+	return true
+}
+
+// packetID returns the Association ID of the packet
+func packetID(packet []byte) (ID, bool) {
+	id := ID{}
+
+	if len(packet) < packetHeaderSize {
+		return id, false
+	}
+
+	copy(id[:], packet[:idSize])
+
+	return id, true
+}
+
+func newCrypter(s Secret, send direction, receive direction) (*crypter, error) {
+	block, blockErr := aes.NewCipher(s.Key[:])
+
+	if blockErr != nil {
+		return nil, blockErr
+	}
+
+	aead, aeadErr := cipher.NewGCM(block)
+
+	if aeadErr != nil {
+		return nil, aeadErr
+	}
+
+	return &crypter{
+		aead:       aead,
+		id:         s.ID,
+		send:       send,
+		receive:    receive,
+		counter:    0,
+		window:     replayWindow{highest: 0, seen: 0},
+		windowLock: sync.Mutex{},
+	}, nil
+}
+
+func (c *crypter) nonce(d direction, counter uint64) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+
+	copy(nonce, d[:])
+
+	binary.BigEndian.PutUint64(nonce[len(d):], counter)
+
+	return nonce
+}
+
+// seal builds a packet
+func (c *crypter) seal(t packetType, payload []byte) ([]byte, error) {
+	if len(payload) > MaxPayloadSize {
+		return nil, ErrPayloadTooLarge
+	}
+
+	counter := atomic.AddUint64(&c.counter, 1)
+
+	packet := make([]byte, packetHeaderSize,
+		packetHeaderSize+1+len(payload)+c.aead.Overhead())
+
+	copy(packet[:idSize], c.id[:])
+
+	binary.BigEndian.PutUint64(packet[idSize:packetHeaderSize], counter)
+
+	plain := make([]byte, 1+len(payload))
+
+	plain[0] = byte(t)
+
+	copy(plain[1:], payload)
+
+	return c.aead.Seal(
+		packet, c.nonce(c.send, counter), plain, packet[:idSize]), nil
+}
+
+// open verifies and opens a packet
+func (c *crypter) open(packet []byte) (packetType, []byte, error) {
+	if len(packet) < packetHeaderSize+1+c.aead.Overhead() {
+		return 0, nil, ErrInvalidPacket
+	}
+
+	counter := binary.BigEndian.Uint64(packet[idSize:packetHeaderSize])
+
+	plain, openErr := c.aead.Open(nil, c.nonce(c.receive, counter),
+		packet[packetHeaderSize:], packet[:idSize])
+
+	if openErr != nil {
+		return 0, nil, ErrInvalidPacket
+	}
+
+	// Only authenticated packets can move the window
+	c.windowLock.Lock()
+	accepted := c.window.accept(counter)
+	c.windowLock.Unlock()
+
+	if !accepted {
+		return 0, nil, ErrReplayedPacket
+	}
+
+	return packetType(plain[0]), plain[1:], nil
+}
@@ -0,0 +1,158 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testCrypters(t *testing.T) (*crypter, *crypter) {
+	secret := Secret{
+		ID:  ID{1, 2, 3, 4, 5, 6, 7, 8},
+		Key: [keySize]byte{1, 2, 3},
+	}
+
+	client, clientErr := newCrypter(secret, toServer, toClient)
+
+	if clientErr != nil {
+		t.Error("Failed to create client crypter:", clientErr)
+
+		return nil, nil
+	}
+
+	server, serverErr := newCrypter(secret, toClient, toServer)
+
+	if serverErr != nil {
+		t.Error("Failed to create server crypter:", serverErr)
+
+		return nil, nil
+	}
+
+	return client, server
+}
+
+func TestCrypterSealOpen(t *testing.T) {
+	client, server := testCrypters(t)
+
+	if client == nil {
+		return
+	}
+
+	packet, sealErr := client.seal(packetData, []byte("Hello World"))
+
+	if sealErr != nil {
+		t.Error("Failed to seal:", sealErr)
+
+		return
+	}
+
+	id, idFound := packetID(packet)
+
+	if !idFound || id != client.id {
+		t.Errorf("Expecting packet ID to be %v, got %v", client.id, id)
+
+		return
+	}
+
+	pt, payload, openErr := server.open(packet)
+
+	if openErr != nil {
+		t.Error("Failed to open:", openErr)
+
+		return
+	}
+
+	if pt != packetData || !bytes.Equal(payload, []byte("Hello World")) {
+		t.Errorf("Unexpected packet %d %q", pt, payload)
+
+		return
+	}
+
+	// Replayed
+	_, _, openErr = server.open(packet)
+
+	if openErr != ErrReplayedPacket {
+		t.Errorf("Expecting error %s, got %v", ErrReplayedPacket, openErr)
+
+		return
+	}
+
+	// Reflected
+	packet, _ = client.seal(packetData, []byte("Hello World"))
+
+	_, _, openErr = client.open(packet)
+
+	if openErr != ErrInvalidPacket {
+		t.Errorf("Expecting error %s, got %v", ErrInvalidPacket, openErr)
+
+		return
+	}
+
+	// Tampered
+	packet[len(packet)-1] ^= 0xff
+
+	_, _, openErr = server.open(packet)
+
+	if openErr != ErrInvalidPacket {
+		t.Errorf("Expecting error %s, got %v", ErrInvalidPacket, openErr)
+
+		return
+	}
+
+	_, sealErr = client.seal(packetData, make([]byte, MaxPayloadSize+1))
+
+	if sealErr != ErrPayloadTooLarge {
+		t.Errorf("Expecting error %s, got %v", ErrPayloadTooLarge, sealErr)
+
+		return
+	}
+}
+
+func TestReplayWindow(t *testing.T) {
+	w := replayWindow{highest: 0, seen: 0}
+
+	for _, c := range []struct {
+		counter  uint64
+		accepted bool
+	}{
+		{0, false},
+		{1, true},
+		{1, false},
+		{3, true},
+		{2, true},
+		{2, false},
+		{100, true},
+		{36, false},
+		{37, true},
+		{37, false},
+		{99, true},
+		{300, true},
+		{100, false},
+	} {
+		if w.accept(c.counter) != c.accepted {
+			t.Errorf("Expecting counter %d to be accepted: %t",
+				c.counter, c.accepted)
+
+			return
+		}
+	}
+}
@@ -0,0 +1,120 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"errors"
+	"time"
+)
+
+// Consts
+const (
+	// MaxPayloadSize is the maximum size of a payload that can be carried
+	// by a single datagram
+	MaxPayloadSize = 4096
+
+	// SecretSize is the size of an encoded Secret
+	SecretSize = idSize + keySize
+
+	idSize  = 8
+	keySize = 32
+)
+
+// Negotiation responds
+const (
+	RespondOK          = 0x00
+	RespondUnavailable = 0x01
+)
+
+// Negotiation requests
+const (
+	RequestStart = 0x01
+)
+
+// Errors
+var (
+	ErrTimeout = errors.New(
+		"Timed out waiting for a datagram")
+
+	ErrClosed = errors.New(
+		"Datagram Association is closed")
+
+	ErrTooManyAssociations = errors.New(
+		"Too many datagram Associations")
+
+	ErrPayloadTooLarge = errors.New(
+		"Datagram payload was too large")
+
+	ErrInvalidSecret = errors.New(
+		"Invalid datagram Association secret")
+)
+
+// ID is the identifier of an Association
+type ID [idSize]byte
+
+// Secret is the secret of an Association, which must be sent to the
+// client through a secured channel
+type Secret struct {
+	ID  ID
+	Key [keySize]byte
+}
+
+// Association is an encrypted datagram channel between a client and a
+// Server. It's Secret is exchanged through the Transceiver connection, thus
+// protected by the same Codec
+type Association interface {
+	Receive(b []byte, wait time.Duration) (int, error)
+	Send(b []byte) error
+	Keepalive() error
+	Close() error
+}
+
+// Endpoint is the datagram Server of a COWARD Proxy. An Endpoint with an
+// empty Address is disabled
+type Endpoint struct {
+	Address   string
+	Keepalive time.Duration
+}
+
+// Enabled returns whether or not the Endpoint is enabled
+func (e Endpoint) Enabled() bool {
+	return e.Address != ""
+}
+
+// Encode writes the Secret into b which must be at least SecretSize long
+func (s Secret) Encode(b []byte) {
+	copy(b[:idSize], s.ID[:])
+	copy(b[idSize:SecretSize], s.Key[:])
+}
+
+// DecodeSecret reads a Secret from b
+func DecodeSecret(b []byte) (Secret, error) {
+	s := Secret{}
+
+	if len(b) < SecretSize {
+		return s, ErrInvalidSecret
+	}
+
+	copy(s.ID[:], b[:idSize])
+	copy(s.Key[:], b[idSize:SecretSize])
+
+	return s, nil
+}
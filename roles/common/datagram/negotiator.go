@@ -0,0 +1,210 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/relay"
+)
+
+// Consts
+const (
+	probes            = 3
+	probeWait         = 500 * time.Millisecond
+	unreachableExpire = 5 * time.Minute
+)
+
+// Errors
+var (
+	ErrNegotiationUnknownRespond = errors.New(
+		"Proxy responded an unknown datagram negotiation result")
+)
+
+// unreachableEndpoints are the Endpoints which recently failed the probe
+var unreachableEndpoints = newUnreachables(unreachableExpire)
+
+// negotiator asks the Proxy for an Association before initializing the
+// relay.Client
+type negotiator struct {
+	client   relay.Client
+	command  byte
+	endpoint Endpoint
+	runner   worker.Runner
+	cancel   <-chan struct{}
+	selected relay.Client
+}
+
+// Negotiator returns a relay.Client which asks the Proxy for an
+// Association by sending the command before initializing the given
+// relay.Client, and carries it's payload through the Association.
+//
+// If the Proxy didn't offer an Association, or the Association failed to
+// pass the probe, the payload will be carried by the relay as usual. An
+// Endpoint that failed the probe will not be tried again for a while
+func Negotiator(
+	client relay.Client,
+	command byte,
+	endpoint Endpoint,
+	runner worker.Runner,
+	cancel <-chan struct{},
+) relay.Client {
+	return &negotiator{
+		client:   client,
+		command:  command,
+		endpoint: endpoint,
+		runner:   runner,
+		cancel:   cancel,
+		selected: client,
+	}
+}
+
+// negotiate returns the Association, or nil if it's unavailable
+func (n *negotiator) negotiate(
+	l logger.Logger, server relay.Server) (Association, time.Duration, error) {
+	if unreachableEndpoints.Unreachable(n.endpoint.Address, time.Now()) {
+		return nil, 0, nil
+	}
+
+	_, wErr := rw.WriteFull(server, []byte{n.command})
+
+	if wErr != nil {
+		return nil, 0, wErr
+	}
+
+	resp := [1 + SecretSize + 2]byte{}
+
+	_, rErr := io.ReadFull(server, resp[:1])
+
+	if rErr != nil {
+		server.Done()
+
+		return nil, 0, rErr
+	}
+
+	switch resp[0] {
+	case RespondOK:
+
+	case RespondUnavailable:
+		server.Done()
+
+		l.Debugf("Datagram is unavailable on the Proxy")
+
+		return nil, 0, nil
+
+	default:
+		server.Done()
+
+		l.Debugf("Proxy responded with an unknown datagram negotiation "+
+			"result: %d", resp[0])
+
+		return nil, 0, ErrNegotiationUnknownRespond
+	}
+
+	_, rErr = io.ReadFull(server, resp[1:])
+
+	server.Done()
+
+	if rErr != nil {
+		return nil, 0, rErr
+	}
+
+	secret, secretErr := DecodeSecret(resp[1 : 1+SecretSize])
+
+	if secretErr != nil {
+		return nil, 0, secretErr
+	}
+
+	keepalive := time.Duration(uint16(resp[1+SecretSize])<<8|
+		uint16(resp[2+SecretSize])) * time.Second
+
+	if n.endpoint.Keepalive < keepalive {
+		keepalive = n.endpoint.Keepalive
+	}
+
+	if keepalive < minKeepalive {
+		keepalive = minKeepalive
+	}
+
+	assoc, dialErr := Dial(n.endpoint.Address, secret, probes, probeWait)
+
+	if dialErr != nil {
+		if dialErr == ErrProbeFailed {
+			unreachableEndpoints.Mark(n.endpoint.Address, time.Now())
+		}
+
+		l.Debugf("Datagram is unreachable, falling back to the relay: %s",
+			dialErr)
+
+		return nil, 0, nil
+	}
+
+	return assoc, keepalive, nil
+}
+
+func (n *negotiator) Initialize(l logger.Logger, server relay.Server) error {
+	assoc, keepalive, negoErr := n.negotiate(l, server)
+
+	if negoErr != nil {
+		return negoErr
+	}
+
+	// Without sending the RequestStart, the Proxy will handle the request
+	// of the relay.Client as usual
+	if assoc == nil {
+		return n.client.Initialize(l, server)
+	}
+
+	keepaliveSecs := uint16(keepalive / time.Second)
+
+	_, wErr := rw.WriteFull(server, []byte{
+		RequestStart, byte(keepaliveSecs >> 8), byte(keepaliveSecs)})
+
+	if wErr != nil {
+		assoc.Close()
+
+		return wErr
+	}
+
+	n.selected = Relay(n.client, assoc, n.runner, keepalive, n.cancel)
+
+	initErr := n.selected.Initialize(l, server)
+
+	if initErr != nil {
+		n.selected = n.client
+	}
+
+	return initErr
+}
+
+func (n *negotiator) Abort(l logger.Logger, aborter relay.Aborter) error {
+	return n.selected.Abort(l, aborter)
+}
+
+func (n *negotiator) Client(
+	l logger.Logger, server relay.Server) (io.ReadWriteCloser, error) {
+	return n.selected.Client(l, server)
+}
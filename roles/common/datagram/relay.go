@@ -0,0 +1,217 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/relay"
+)
+
+// Consts
+const (
+	keepaliveSignal = 0x00
+	minKeepalive    = 1 * time.Second
+)
+
+// relayClient carries the payload of a relay.Client through an Association
+type relayClient struct {
+	client    relay.Client
+	assoc     Association
+	runner    worker.Runner
+	keepalive time.Duration
+	cancel    <-chan struct{}
+}
+
+// control is the relay.Client conn of a relayClient. Only keepalives will
+// be exchanged through it, while the payload is exchanged between the
+// conn of the relay.Client and the Association
+type control struct {
+	local     io.ReadWriteCloser
+	assoc     Association
+	keepalive time.Duration
+	next      time.Time
+	buf       []byte
+	sent      chan error
+	closeOnce sync.Once
+}
+
+// Keepalive returns the keepalive interval for a connection which will be
+// disconnected after been idled for the given time
+func Keepalive(idle time.Duration) time.Duration {
+	keepalive := idle / 3
+
+	if keepalive < minKeepalive {
+		return minKeepalive
+	}
+
+	return keepalive
+}
+
+// Relay returns a relay.Client which initializes the given relay.Client
+// as usual, but carries it's payload through the Association.
+//
+// The relay only exchanges keepalive during the meantime, so the
+// connection where the relay is running on will not be idled out
+func Relay(
+	client relay.Client,
+	assoc Association,
+	runner worker.Runner,
+	keepalive time.Duration,
+	cancel <-chan struct{},
+) relay.Client {
+	return relayClient{
+		client:    client,
+		assoc:     assoc,
+		runner:    runner,
+		keepalive: keepalive,
+		cancel:    cancel,
+	}
+}
+
+func (r relayClient) Initialize(l logger.Logger, server relay.Server) error {
+	initErr := r.client.Initialize(l, server)
+
+	if initErr != nil {
+		r.assoc.Close()
+	}
+
+	return initErr
+}
+
+func (r relayClient) Abort(l logger.Logger, aborter relay.Aborter) error {
+	r.assoc.Close()
+
+	return r.client.Abort(l, aborter)
+}
+
+func (r relayClient) Client(
+	l logger.Logger, server relay.Server) (io.ReadWriteCloser, error) {
+	local, localErr := r.client.Client(l, server)
+
+	if localErr != nil {
+		r.assoc.Close()
+
+		return nil, localErr
+	}
+
+	c := &control{
+		local:     local,
+		assoc:     r.assoc,
+		keepalive: r.keepalive,
+		next:      time.Now().Add(r.keepalive),
+		buf:       make([]byte, MaxPayloadSize),
+		sent:      nil,
+		closeOnce: sync.Once{},
+	}
+
+	sent, runErr := r.runner.Run(l.Context("Datagram"), c.sender, r.cancel)
+
+	if runErr != nil {
+		local.Close()
+		r.assoc.Close()
+
+		return nil, runErr
+	}
+
+	c.sent = sent
+
+	return c, nil
+}
+
+// sender sends data read from the local conn through the Association
+func (c *control) sender(l logger.Logger) error {
+	// Close the Association so the Read will be unblocked
+	defer c.assoc.Close()
+
+	buf := make([]byte, MaxPayloadSize)
+
+	for {
+		rLen, rErr := c.local.Read(buf)
+
+		if rErr != nil {
+			return rErr
+		}
+
+		sErr := c.assoc.Send(buf[:rLen])
+
+		if sErr == nil {
+			continue
+		}
+
+		l.Debugf("Failed to send datagram: %s", sErr)
+	}
+}
+
+// Read receives datagrams from the Association and writes them to the
+// local conn, and returns a keepalive every once a while
+func (c *control) Read(b []byte) (int, error) {
+	for {
+		wait := time.Until(c.next)
+
+		if wait <= 0 {
+			c.next = time.Now().Add(c.keepalive)
+
+			c.assoc.Keepalive()
+
+			b[0] = keepaliveSignal
+
+			return 1, nil
+		}
+
+		rLen, rErr := c.assoc.Receive(c.buf, wait)
+
+		if rErr == ErrTimeout {
+			continue
+		}
+
+		if rErr != nil {
+			return 0, rErr
+		}
+
+		// Ignore the error just like what the relay does
+		c.local.Write(c.buf[:rLen])
+	}
+}
+
+// Write discards the keepalives from the opponent
+func (c *control) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// Close closes both the local conn and the Association
+func (c *control) Close() error {
+	var closeErr error
+
+	c.closeOnce.Do(func() {
+		closeErr = c.local.Close()
+
+		c.assoc.Close()
+
+		<-c.sent
+	})
+
+	return closeErr
+}
@@ -0,0 +1,297 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"crypto/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+)
+
+// Consts
+const (
+	maxPacketSize        = packetHeaderSize + 1 + MaxPayloadSize + 16
+	maxReceivedDatagrams = 64
+)
+
+// Server receives datagrams of all Associations on a single UDP socket
+type Server interface {
+	Associate() (Association, Secret, error)
+	Listening() net.Addr
+	Close() error
+}
+
+// server implements Server
+type server struct {
+	conn         *net.UDPConn
+	logger       logger.Logger
+	capacity     uint32
+	associations map[ID]*serverAssociation
+	lock         sync.RWMutex
+	closed       chan struct{}
+	down         chan struct{}
+}
+
+// serverAssociation is an Association on the Server side. It only knows
+// where the client is after received a valid packet from it
+type serverAssociation struct {
+	server     *server
+	crypter    *crypter
+	client     *net.UDPAddr
+	clientLock sync.RWMutex
+	received   chan []byte
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+// Listen listens the UDP address and serves at most capacity Associations
+// on it.
+//
+// The port is not shared with other sockets: a shared UDP port spreads the
+// datagrams among all sockets on it, so the Associations of a reloaded
+// Server could lose their datagrams to the old one
+func Listen(
+	addr *net.UDPAddr,
+	capacity uint32,
+	log logger.Logger,
+) (Server, error) {
+	conn, listenErr := net.ListenUDP("udp", addr)
+
+	if listenErr != nil {
+		return nil, listenErr
+	}
+
+	s := &server{
+		conn:         conn,
+		logger:       log.Context("Datagram"),
+		capacity:     capacity,
+		associations: make(map[ID]*serverAssociation, 256),
+		lock:         sync.RWMutex{},
+		closed:       make(chan struct{}),
+		down:         make(chan struct{}),
+	}
+
+	go s.receiver()
+
+	return s, nil
+}
+
+// receiver dispatchs received packets to their Associations
+func (s *server) receiver() {
+	defer close(s.down)
+
+	buf := make([]byte, maxPacketSize)
+
+	for {
+		rLen, rAddr, rErr := s.conn.ReadFromUDP(buf)
+
+		if rErr != nil {
+			select {
+			case <-s.closed:
+				return
+
+			default:
+			}
+
+			s.logger.Debugf("Failed to receive datagram: %s", rErr)
+
+			continue
+		}
+
+		id, idFound := packetID(buf[:rLen])
+
+		if !idFound {
+			continue
+		}
+
+		s.lock.RLock()
+		assoc, found := s.associations[id]
+		s.lock.RUnlock()
+
+		if !found {
+			continue
+		}
+
+		assoc.receive(buf[:rLen], rAddr)
+	}
+}
+
+// Associate creates a new Association
+func (s *server) Associate() (Association, Secret, error) {
+	secret := Secret{}
+
+	_, rErr := rand.Read(secret.Key[:])
+
+	if rErr != nil {
+		return nil, secret, rErr
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if uint32(len(s.associations)) >= s.capacity {
+		return nil, secret, ErrTooManyAssociations
+	}
+
+	for {
+		_, rErr = rand.Read(secret.ID[:])
+
+		if rErr != nil {
+			return nil, secret, rErr
+		}
+
+		if _, found := s.associations[secret.ID]; !found {
+			break
+		}
+	}
+
+	c, cErr := newCrypter(secret, toClient, toServer)
+
+	if cErr != nil {
+		return nil, secret, cErr
+	}
+
+	assoc := &serverAssociation{
+		server:     s,
+		crypter:    c,
+		client:     nil,
+		clientLock: sync.RWMutex{},
+		received:   make(chan []byte, maxReceivedDatagrams),
+		closed:     make(chan struct{}),
+		closeOnce:  sync.Once{},
+	}
+
+	s.associations[secret.ID] = assoc
+
+	return assoc, secret, nil
+}
+
+// Listening returns the listening address
+func (s *server) Listening() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Close closes the Server
+func (s *server) Close() error {
+	close(s.closed)
+
+	closeErr := s.conn.Close()
+
+	<-s.down
+
+	return closeErr
+}
+
+// receive handles a packet that was sent to current Association
+func (a *serverAssociation) receive(packet []byte, from *net.UDPAddr) {
+	t, payload, openErr := a.crypter.open(packet)
+
+	if openErr != nil {
+		return
+	}
+
+	// Client may change it's address (NAT rebinding for example), always
+	// reply to the latest one
+	a.clientLock.Lock()
+	a.client = from
+	a.clientLock.Unlock()
+
+	switch t {
+	case packetProbe:
+		reply, sealErr := a.crypter.seal(packetProbeReply, nil)
+
+		if sealErr != nil {
+			return
+		}
+
+		a.server.conn.WriteToUDP(reply, from)
+
+	case packetData:
+		select {
+		case a.received <- payload:
+		case <-a.closed:
+		default:
+			// Drop it just like what a full network buffer would do
+		}
+	}
+}
+
+// Receive receives the payload of a datagram
+func (a *serverAssociation) Receive(
+	b []byte, wait time.Duration) (int, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case payload := <-a.received:
+		return copy(b, payload), nil
+
+	case <-timer.C:
+		return 0, ErrTimeout
+
+	case <-a.closed:
+		return 0, ErrClosed
+	}
+}
+
+// Send sends a datagram to the client. The datagram will be dropped if
+// the client is still unknown
+func (a *serverAssociation) Send(b []byte) error {
+	a.clientLock.RLock()
+	client := a.client
+	a.clientLock.RUnlock()
+
+	if client == nil {
+		return nil
+	}
+
+	packet, sealErr := a.crypter.seal(packetData, b)
+
+	if sealErr != nil {
+		return sealErr
+	}
+
+	_, wErr := a.server.conn.WriteToUDP(packet, client)
+
+	return wErr
+}
+
+// Keepalive does nothing as it's the client who keeps the Association
+// alive
+func (a *serverAssociation) Keepalive() error {
+	return nil
+}
+
+// Close closes the Association
+func (a *serverAssociation) Close() error {
+	a.closeOnce.Do(func() {
+		close(a.closed)
+
+		a.server.lock.Lock()
+		delete(a.server.associations, a.crypter.id)
+		a.server.lock.Unlock()
+	})
+
+	return nil
+}
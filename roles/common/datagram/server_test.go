@@ -0,0 +1,169 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+)
+
+func TestServerAndClient(t *testing.T) {
+	s, listenErr := Listen(&net.UDPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: 0,
+		Zone: "",
+	}, 1, logger.NewDitch())
+
+	if listenErr != nil {
+		t.Error("Failed to listen:", listenErr)
+
+		return
+	}
+
+	defer s.Close()
+
+	serverAssoc, secret, assocErr := s.Associate()
+
+	if assocErr != nil {
+		t.Error("Failed to associate:", assocErr)
+
+		return
+	}
+
+	defer serverAssoc.Close()
+
+	_, _, assocErr = s.Associate()
+
+	if assocErr != ErrTooManyAssociations {
+		t.Errorf("Expecting error %s, got %v", ErrTooManyAssociations, assocErr)
+
+		return
+	}
+
+	// Server doesn't know where the client is yet, so this will be dropped
+	sErr := serverAssoc.Send([]byte("Dropped"))
+
+	if sErr != nil {
+		t.Error("Failed to send:", sErr)
+
+		return
+	}
+
+	badSecret := secret
+	badSecret.Key[0] ^= 0xff
+
+	_, dialErr := Dial(
+		s.Listening().String(), badSecret, 1, 100*time.Millisecond)
+
+	if dialErr != ErrProbeFailed {
+		t.Errorf("Expecting error %s, got %v", ErrProbeFailed, dialErr)
+
+		return
+	}
+
+	clientAssoc, dialErr := Dial(
+		s.Listening().String(), secret, 3, 500*time.Millisecond)
+
+	if dialErr != nil {
+		t.Error("Failed to dial:", dialErr)
+
+		return
+	}
+
+	defer clientAssoc.Close()
+
+	buf := make([]byte, MaxPayloadSize)
+
+	sErr = clientAssoc.Send([]byte("Hello Server"))
+
+	if sErr != nil {
+		t.Error("Failed to send:", sErr)
+
+		return
+	}
+
+	rLen, rErr := serverAssoc.Receive(buf, 1*time.Second)
+
+	if rErr != nil {
+		t.Error("Failed to receive:", rErr)
+
+		return
+	}
+
+	if string(buf[:rLen]) != "Hello Server" {
+		t.Errorf("Expecting to receive %q, got %q",
+			"Hello Server", buf[:rLen])
+
+		return
+	}
+
+	sErr = serverAssoc.Send([]byte("Hello Client"))
+
+	if sErr != nil {
+		t.Error("Failed to send:", sErr)
+
+		return
+	}
+
+	rLen, rErr = clientAssoc.Receive(buf, 1*time.Second)
+
+	if rErr != nil {
+		t.Error("Failed to receive:", rErr)
+
+		return
+	}
+
+	if string(buf[:rLen]) != "Hello Client" {
+		t.Errorf("Expecting to receive %q, got %q",
+			"Hello Client", buf[:rLen])
+
+		return
+	}
+
+	_, rErr = clientAssoc.Receive(buf, 10*time.Millisecond)
+
+	if rErr != ErrTimeout {
+		t.Errorf("Expecting error %s, got %v", ErrTimeout, rErr)
+
+		return
+	}
+
+	serverAssoc.Close()
+
+	_, rErr = serverAssoc.Receive(buf, 1*time.Second)
+
+	if rErr != ErrClosed {
+		t.Errorf("Expecting error %s, got %v", ErrClosed, rErr)
+
+		return
+	}
+
+	_, _, assocErr = s.Associate()
+
+	if assocErr != nil {
+		t.Error("Failed to associate after the old one is closed:", assocErr)
+
+		return
+	}
+}
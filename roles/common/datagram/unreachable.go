@@ -0,0 +1,81 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"sync"
+	"time"
+)
+
+// unreachables records the Endpoints which failed to pass the probe, so
+// the following requests can skip the probe and use the relay directly
+type unreachables struct {
+	expire time.Duration
+	failed map[string]time.Time
+	lock   sync.Mutex
+}
+
+// newUnreachables creates a new unreachables which remembers a failure for
+// the expire duration
+func newUnreachables(expire time.Duration) *unreachables {
+	return &unreachables{
+		expire: expire,
+		failed: make(map[string]time.Time, 16),
+		lock:   sync.Mutex{},
+	}
+}
+
+// Mark records that the address failed to pass the probe
+func (u *unreachables) Mark(address string, now time.Time) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	for addr, failedAt := range u.failed {
+		if now.Sub(failedAt) < u.expire {
+			continue
+		}
+
+		delete(u.failed, addr)
+	}
+
+	u.failed[address] = now
+}
+
+// Unreachable returns whether or not the address has failed to pass the
+// probe recently
+func (u *unreachables) Unreachable(address string, now time.Time) bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	failedAt, found := u.failed[address]
+
+	if !found {
+		return false
+	}
+
+	if now.Sub(failedAt) < u.expire {
+		return true
+	}
+
+	delete(u.failed, address)
+
+	return false
+}
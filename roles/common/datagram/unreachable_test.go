@@ -0,0 +1,67 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package datagram
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnreachables(t *testing.T) {
+	u := newUnreachables(10 * time.Second)
+	now := time.Now()
+
+	if u.Unreachable("127.0.0.1:1", now) {
+		t.Error("Expecting the address to be reachable before it failed")
+
+		return
+	}
+
+	u.Mark("127.0.0.1:1", now)
+
+	if !u.Unreachable("127.0.0.1:1", now.Add(9*time.Second)) {
+		t.Error("Expecting the address to be unreachable after it failed")
+
+		return
+	}
+
+	if u.Unreachable("127.0.0.1:2", now.Add(9*time.Second)) {
+		t.Error("Expecting other addresses to be reachable")
+
+		return
+	}
+
+	if u.Unreachable("127.0.0.1:1", now.Add(10*time.Second)) {
+		t.Error("Expecting the address to be reachable again after expired")
+
+		return
+	}
+
+	u.Mark("127.0.0.1:1", now)
+	u.Mark("127.0.0.1:2", now.Add(10*time.Second))
+
+	if len(u.failed) != 1 {
+		t.Errorf("Expecting expired failures to be removed, got %d left",
+			len(u.failed))
+
+		return
+	}
+}
@@ -237,3 +237,9 @@ func (c *connection) Close() error {
 func (c *connection) Closed() <-chan struct{} {
 	return c.close
 }
+
+// Unwrap returns the wrapped net.Conn. Data read from it directly will
+// bypass the timeouts of current wrapper
+func (c *connection) Unwrap() net.Conn {
+	return c.Conn
+}
@@ -0,0 +1,182 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package tcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/reinit/coward/roles/common/network/resolve"
+)
+
+// Consts
+const (
+	// Connection Attempt Delay suggested by RFC 8305
+	attemptDelay = 250 * time.Millisecond
+)
+
+// Errors
+var (
+	ErrNoAddress = errors.New(
+		"No address to dial")
+)
+
+// attempt dials one address
+type attempt func(ctx context.Context, address string) (net.Conn, error)
+
+// eyeballs races connection attempts to a set of addresses as described
+// in RFC 8305 (Happy Eyeballs Version 2)
+type eyeballs struct {
+	delay   time.Duration
+	attempt attempt
+}
+
+type attemptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// interleave sorts the addresses so the address families are alternated.
+// As RFC 8305 suggested, IPv6 will go first unless IPv4 is preferred
+func interleave(ips []net.IP, prefer resolve.Preference) []net.IP {
+	first := make([]net.IP, 0, len(ips))
+	second := make([]net.IP, 0, len(ips))
+
+	for ipIdx := range ips {
+		if (ips[ipIdx].To4() != nil) == (prefer == resolve.PreferIPv4) {
+			first = append(first, ips[ipIdx])
+		} else {
+			second = append(second, ips[ipIdx])
+		}
+	}
+
+	result := make([]net.IP, 0, len(ips))
+
+	for len(first) > 0 || len(second) > 0 {
+		if len(first) > 0 {
+			result = append(result, first[0])
+			first = first[1:]
+		}
+
+		if len(second) > 0 {
+			result = append(result, second[0])
+			second = second[1:]
+		}
+	}
+
+	return result
+}
+
+// dialAttempt dials the address through a net.Dialer
+func dialAttempt(ctx context.Context, address string) (net.Conn, error) {
+	dialer := net.Dialer{}
+
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// dial starts an attempt for each address in order. A new attempt will be
+// started once the previous one has failed or the delay has passed,
+// whichever comes first. The first established connection will be
+// returned, and the rest will be canceled
+func (e eyeballs) dial(
+	addresses []string, deadline time.Time) (net.Conn, error) {
+	if len(addresses) <= 0 {
+		return nil, ErrNoAddress
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	// Zero deadline means there is no timeout
+	if deadline.IsZero() {
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithDeadline(context.Background(), deadline)
+	}
+
+	defer cancel()
+
+	var wait <-chan time.Time
+	var lastErr error
+
+	results := make(chan attemptResult, len(addresses))
+	started := 0
+	running := 0
+
+	start := func() {
+		go func(address string) {
+			conn, err := e.attempt(ctx, address)
+
+			results <- attemptResult{
+				conn: conn,
+				err:  err,
+			}
+		}(addresses[started])
+
+		started++
+		running++
+
+		if started < len(addresses) {
+			wait = time.After(e.delay)
+		} else {
+			wait = nil
+		}
+	}
+
+	start()
+
+	for running > 0 {
+		select {
+		case result := <-results:
+			running--
+
+			if result.err == nil {
+				// Close the connections which are established later
+				go func(remaining int) {
+					for ; remaining > 0; remaining-- {
+						late := <-results
+
+						if late.conn == nil {
+							continue
+						}
+
+						late.conn.Close()
+					}
+				}(running)
+
+				return result.conn, nil
+			}
+
+			lastErr = result.err
+
+			if started < len(addresses) {
+				start()
+			}
+
+		case <-wait:
+			start()
+		}
+	}
+
+	return nil, lastErr
+}
@@ -0,0 +1,320 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package tcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/network/resolve"
+)
+
+type dummyResolver struct {
+	ips []net.IP
+}
+
+func (d dummyResolver) Resolve(host string) ([]net.IP, error) {
+	return d.ips, nil
+}
+
+func (d dummyResolver) Reverse(ip net.IP) (string, error) {
+	return "", nil
+}
+
+func testListen(t *testing.T) (*net.TCPListener, func()) {
+	listener, listenErr := net.ListenTCP("tcp", &net.TCPAddr{
+		IP:   net.IPv4(127, 0, 0, 1),
+		Port: 0,
+		Zone: "",
+	})
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return nil, nil
+	}
+
+	accepted := make(chan struct{})
+
+	go func() {
+		defer close(accepted)
+
+		for {
+			conn, acceptErr := listener.Accept()
+
+			if acceptErr != nil {
+				return
+			}
+
+			conn.Close()
+		}
+	}()
+
+	return listener, func() {
+		listener.Close()
+
+		<-accepted
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	ipv6First := []net.IP{
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("2001:db8::2"),
+		net.ParseIP("2001:db8::3"),
+		net.ParseIP("192.0.2.1"),
+		net.ParseIP("192.0.2.2"),
+	}
+	ipv4First := []net.IP{
+		net.ParseIP("192.0.2.1"),
+		net.ParseIP("192.0.2.2"),
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("2001:db8::2"),
+		net.ParseIP("2001:db8::3"),
+	}
+
+	tests := []struct {
+		ips      []net.IP
+		prefer   resolve.Preference
+		expected []string
+	}{
+		{ipv6First, resolve.PreferNone, []string{
+			"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2",
+			"2001:db8::3"}},
+		{ipv4First, resolve.PreferNone, []string{
+			"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2",
+			"2001:db8::3"}},
+		{ipv4First, resolve.PreferIPv6, []string{
+			"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2",
+			"2001:db8::3"}},
+		{ipv6First, resolve.PreferIPv4, []string{
+			"192.0.2.1", "2001:db8::1", "192.0.2.2", "2001:db8::2",
+			"2001:db8::3"}},
+		{ipv4First[:2], resolve.PreferNone, []string{
+			"192.0.2.1", "192.0.2.2"}},
+	}
+
+	for tIdx, test := range tests {
+		result := interleave(test.ips, test.prefer)
+
+		if len(result) != len(test.expected) {
+			t.Errorf("Test %d: Expecting %d addresses, got %d",
+				tIdx, len(test.expected), len(result))
+
+			return
+		}
+
+		for rIdx := range result {
+			if result[rIdx].String() == test.expected[rIdx] {
+				continue
+			}
+
+			t.Errorf("Test %d: Expecting address %d to be %s, got %s",
+				tIdx, rIdx, test.expected[rIdx], result[rIdx])
+
+			return
+		}
+	}
+}
+
+func TestEyeballsStaggered(t *testing.T) {
+	listener, closer := testListen(t)
+
+	if listener == nil {
+		return
+	}
+
+	defer closer()
+
+	canceled := make(chan error, 1)
+	started := time.Now()
+
+	conn, dialErr := eyeballs{
+		delay: 100 * time.Millisecond,
+		attempt: func(ctx context.Context, address string) (net.Conn, error) {
+			if address != listener.Addr().String() {
+				// Unresponsive address
+				<-ctx.Done()
+
+				canceled <- ctx.Err()
+
+				return nil, ctx.Err()
+			}
+
+			return dialAttempt(ctx, address)
+		},
+	}.dial([]string{"[2001:db8::1]:80", listener.Addr().String()}, time.Time{})
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	conn.Close()
+
+	if time.Since(started) < 100*time.Millisecond {
+		t.Error("The second attempt must be started after the delay")
+
+		return
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(1 * time.Second):
+		t.Error("The unresponsive attempt must be canceled")
+
+		return
+	}
+}
+
+func TestEyeballsFailedStartsNext(t *testing.T) {
+	listener, closer := testListen(t)
+
+	if listener == nil {
+		return
+	}
+
+	defer closer()
+
+	refused := errors.New("Refused")
+	started := time.Now()
+
+	conn, dialErr := eyeballs{
+		delay: 1 * time.Hour,
+		attempt: func(ctx context.Context, address string) (net.Conn, error) {
+			if address != listener.Addr().String() {
+				return nil, refused
+			}
+
+			return dialAttempt(ctx, address)
+		},
+	}.dial([]string{"[2001:db8::1]:80", listener.Addr().String()}, time.Time{})
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	conn.Close()
+
+	if time.Since(started) > 1*time.Second {
+		t.Error("A failed attempt must start the next one immediately")
+
+		return
+	}
+
+	_, dialErr = eyeballs{
+		delay: 1 * time.Hour,
+		attempt: func(ctx context.Context, address string) (net.Conn, error) {
+			return nil, refused
+		},
+	}.dial([]string{"[2001:db8::1]:80", "192.0.2.1:80"}, time.Time{})
+
+	if dialErr != refused {
+		t.Errorf("Expecting error %s, got %s", refused, dialErr)
+
+		return
+	}
+
+	_, dialErr = eyeballs{
+		delay:   1 * time.Hour,
+		attempt: dialAttempt,
+	}.dial([]string{}, time.Time{})
+
+	if dialErr != ErrNoAddress {
+		t.Errorf("Expecting error %s, got %s", ErrNoAddress, dialErr)
+
+		return
+	}
+}
+
+func TestEyeballsTimeout(t *testing.T) {
+	started := time.Now()
+
+	_, dialErr := eyeballs{
+		delay: 100 * time.Millisecond,
+		attempt: func(ctx context.Context, address string) (net.Conn, error) {
+			<-ctx.Done()
+
+			return nil, ctx.Err()
+		},
+	}.dial([]string{"[2001:db8::1]:80", "192.0.2.1:80"},
+		time.Now().Add(300*time.Millisecond))
+
+	if dialErr != context.DeadlineExceeded {
+		t.Errorf("Expecting error %s, got %s",
+			context.DeadlineExceeded, dialErr)
+
+		return
+	}
+
+	if time.Since(started) > 1*time.Second {
+		t.Error("Dial must be stopped on deadline")
+
+		return
+	}
+}
+
+func TestDialResolved(t *testing.T) {
+	listener, closer := testListen(t)
+
+	if listener == nil {
+		return
+	}
+
+	defer closer()
+
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+
+	// The IPv6 address will never been connected, so the dial must fall
+	// back to the IPv4 one
+	dialer := NewResolved("localhost", port, 3*time.Second, dummyResolver{
+		ips: []net.IP{
+			net.ParseIP("2001:db8::1"),
+			net.ParseIP("127.0.0.1"),
+		},
+	}, func(c net.Conn) network.Connection {
+		c.Close()
+
+		return nil
+	}).Dialer()
+
+	_, dialErr := dialer.Dial()
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	if dialer.String() != listener.Addr().String() {
+		t.Errorf("Expecting dialed address to be %s, got %s",
+			listener.Addr(), dialer.String())
+
+		return
+	}
+}
@@ -21,17 +21,22 @@
 package tcp
 
 import (
+	"context"
 	"net"
 	"strconv"
 	"time"
 
 	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/network/egress"
+	"github.com/reinit/coward/roles/common/network/resolve"
 )
 
 type dialer struct {
 	host        string
 	port        uint16
 	timeout     time.Duration
+	resolver    resolve.Resolver
+	egress      egress.Egress
 	connWrapper network.ConnectionWrapper
 }
 
@@ -41,6 +46,8 @@ type dial struct {
 	host        string
 	port        uint16
 	timeout     time.Duration
+	resolver    resolve.Resolver
+	egress      egress.Egress
 	connWrapper network.ConnectionWrapper
 }
 
@@ -50,11 +57,41 @@ func New(
 	port uint16,
 	timeout time.Duration,
 	connWrapper network.ConnectionWrapper,
+) network.Dialer {
+	return NewResolved(host, port, timeout, nil, connWrapper)
+}
+
+// NewResolved returns a new TCP Dialer which resolves the host through
+// the given Resolver. Resolved addresses will be raced as described in
+// RFC 8305 until the timeout. If the resolver is nil, the system resolver
+// will be used
+func NewResolved(
+	host string,
+	port uint16,
+	timeout time.Duration,
+	resolver resolve.Resolver,
+	connWrapper network.ConnectionWrapper,
+) network.Dialer {
+	return NewBound(host, port, timeout, resolver, nil, connWrapper)
+}
+
+// NewBound returns a new TCP Dialer which works like the one returned by
+// NewResolved, but dials from the Source selected by the given Egress. If
+// the Egress is nil, the connection will leave from the system default
+func NewBound(
+	host string,
+	port uint16,
+	timeout time.Duration,
+	resolver resolve.Resolver,
+	egress egress.Egress,
+	connWrapper network.ConnectionWrapper,
 ) network.Dialer {
 	return dialer{
 		host:        host,
 		port:        port,
 		timeout:     timeout,
+		resolver:    resolver,
+		egress:      egress,
 		connWrapper: connWrapper,
 	}
 }
@@ -66,6 +103,8 @@ func (d dialer) Dialer() network.Dial {
 		host:        d.host,
 		port:        d.port,
 		timeout:     d.timeout,
+		resolver:    d.resolver,
+		egress:      d.egress,
 		connWrapper: d.connWrapper,
 	}
 }
@@ -84,8 +123,109 @@ func (d *dial) resolvedAddress() string {
 	return address
 }
 
+// resolve resolves the host through the Resolver, or the system resolver
+// when the Resolver is nil
+func (d *dial) resolve(deadline time.Time) ([]net.IP, error) {
+	if d.resolver != nil {
+		return d.resolver.Resolve(d.host)
+	}
+
+	ctx := context.Background()
+
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+
+		defer cancel()
+	}
+
+	resolved, resolveErr := net.DefaultResolver.LookupIPAddr(ctx, d.host)
+
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+
+	ips := make([]net.IP, len(resolved))
+
+	for rIdx := range resolved {
+		ips[rIdx] = resolved[rIdx].IP
+	}
+
+	return ips, nil
+}
+
+// addresses returns the addresses to dial, ordered for Happy Eyeballs
+func (d *dial) addresses(deadline time.Time) ([]string, error) {
+	if (d.resolved != nil && d.useResolved) || net.ParseIP(d.host) != nil {
+		return []string{d.resolvedAddress()}, nil
+	}
+
+	resolved, resolveErr := d.resolve(deadline)
+
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+
+	prefer := resolve.PreferNone
+	preferred, isPreferred := d.resolver.(resolve.PreferredResolver)
+
+	if isPreferred {
+		prefer = preferred.Preference()
+	}
+
+	resolved = interleave(resolved, prefer)
+	port := strconv.FormatUint(uint64(d.port), 10)
+	addresses := make([]string, len(resolved))
+
+	for rIdx := range resolved {
+		addresses[rIdx] = net.JoinHostPort(resolved[rIdx].String(), port)
+	}
+
+	return addresses, nil
+}
+
+// attempt dials the address from the Source selected by the Egress
+func (d *dial) attempt(ctx context.Context, address string) (net.Conn, error) {
+	if d.egress == nil {
+		return dialAttempt(ctx, address)
+	}
+
+	host, _, spErr := net.SplitHostPort(address)
+
+	if spErr != nil {
+		return nil, spErr
+	}
+
+	source, sourceErr := d.egress.Source(net.ParseIP(host))
+
+	if sourceErr != nil {
+		return nil, sourceErr
+	}
+
+	dialer := source.Dialer()
+
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
 func (d *dial) Dial() (network.Connection, error) {
-	dialed, dialErr := net.DialTimeout("tcp", d.resolvedAddress(), d.timeout)
+	var deadline time.Time
+
+	// Zero timeout means there is no timeout
+	if d.timeout > 0 {
+		deadline = time.Now().Add(d.timeout)
+	}
+
+	addresses, resolveErr := d.addresses(deadline)
+
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+
+	dialed, dialErr := eyeballs{
+		delay:   attemptDelay,
+		attempt: d.attempt,
+	}.dial(addresses, deadline)
 
 	if dialErr != nil {
 		d.useResolved = !d.useResolved
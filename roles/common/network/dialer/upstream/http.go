@@ -0,0 +1,136 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package upstream
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/reinit/coward/common/rw"
+)
+
+// Errors
+var (
+	ErrHTTPResponseTooLarge = errors.New(
+		"Upstream HTTP proxy has responded a header which is too large")
+
+	ErrHTTPInvalidResponse = errors.New(
+		"Upstream HTTP proxy has responded an invalid response")
+
+	ErrHTTPConnectFailed = errors.New(
+		"Upstream HTTP proxy has failed to connect the destination")
+
+	ErrHTTPInvalidHost = errors.New(
+		"Destination host is neither an IP address nor a valid host name")
+)
+
+// Consts
+const (
+	httpMaxResponseSize = 8192
+)
+
+// httpValidHost returns whether the host is an IP address or a host name
+// which only contains letters, digits, '-', '_' and '.', so it can't
+// inject anything into the request
+func httpValidHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+
+	if len(host) <= 0 || len(host) > 255 {
+		return false
+	}
+
+	for _, c := range []byte(host) {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// httpHandshake connects to the destination through the HTTP CONNECT
+// method of the upstream HTTP proxy
+func httpHandshake(
+	conn net.Conn, u Upstream, host string, port uint16) error {
+	if !httpValidHost(host) {
+		return ErrHTTPInvalidHost
+	}
+
+	target := net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))
+	req := "CONNECT " + target + " HTTP/1.1\r\nHost: " + target + "\r\n"
+
+	if u.Username != "" {
+		req += "Proxy-Authorization: Basic " +
+			base64.StdEncoding.EncodeToString(
+				[]byte(u.Username+":"+u.Password)) + "\r\n"
+	}
+
+	_, wErr := rw.WriteFull(conn, []byte(req+"\r\n"))
+
+	if wErr != nil {
+		return wErr
+	}
+
+	// Read the response one byte at a time, so the data following the
+	// header will not be consumed
+	resp := make([]byte, 0, 256)
+	b := [1]byte{}
+
+	for !bytes.HasSuffix(resp, []byte("\r\n\r\n")) {
+		if len(resp) >= httpMaxResponseSize {
+			return ErrHTTPResponseTooLarge
+		}
+
+		_, rErr := io.ReadFull(conn, b[:])
+
+		if rErr != nil {
+			return rErr
+		}
+
+		resp = append(resp, b[0])
+	}
+
+	// Status line: HTTP/1.1 200 Connection established
+	status := strings.SplitN(
+		string(resp[:bytes.Index(resp, []byte("\r\n"))]), " ", 3)
+
+	if len(status) < 2 || !strings.HasPrefix(status[0], "HTTP/") {
+		return ErrHTTPInvalidResponse
+	}
+
+	if len(status[1]) != 3 || status[1][0] != '2' {
+		return ErrHTTPConnectFailed
+	}
+
+	return nil
+}
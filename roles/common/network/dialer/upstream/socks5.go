@@ -0,0 +1,206 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package upstream
+
+import (
+	"errors"
+	"io"
+	"net"
+
+	"github.com/reinit/coward/common/rw"
+)
+
+// Errors
+var (
+	ErrSOCKS5UnexpectedVersion = errors.New(
+		"Upstream SOCKS5 proxy has responded an unexpected version")
+
+	ErrSOCKS5NoAcceptableMethod = errors.New(
+		"Upstream SOCKS5 proxy has accepted no authentication method")
+
+	ErrSOCKS5AuthFailed = errors.New(
+		"Upstream SOCKS5 proxy has rejected the credential")
+
+	ErrSOCKS5ConnectFailed = errors.New(
+		"Upstream SOCKS5 proxy has failed to connect the destination")
+
+	ErrSOCKS5UnknownAddressType = errors.New(
+		"Upstream SOCKS5 proxy has responded an unknown address type")
+)
+
+// SOCKS5 consts
+const (
+	socks5Version       = 0x05
+	socks5AuthVersion   = 0x01
+	socks5MethodNoAuth  = 0x00
+	socks5MethodUser    = 0x02
+	socks5CmdConnect    = 0x01
+	socks5AddressIPv4   = 0x01
+	socks5AddressDomain = 0x03
+	socks5AddressIPv6   = 0x04
+	socks5ReplySucceed  = 0x00
+)
+
+// socks5Handshake connects to the destination through the upstream SOCKS5
+// proxy. See RFC 1928 and RFC 1929
+func socks5Handshake(
+	conn net.Conn, u Upstream, host string, port uint16) error {
+	buf := [512]byte{}
+
+	// Method selection
+	methods := []byte{socks5Version, 1, socks5MethodNoAuth}
+
+	if u.Username != "" {
+		methods = []byte{
+			socks5Version, 2, socks5MethodNoAuth, socks5MethodUser}
+	}
+
+	_, wErr := rw.WriteFull(conn, methods)
+
+	if wErr != nil {
+		return wErr
+	}
+
+	_, rErr := io.ReadFull(conn, buf[:2])
+
+	if rErr != nil {
+		return rErr
+	}
+
+	if buf[0] != socks5Version {
+		return ErrSOCKS5UnexpectedVersion
+	}
+
+	switch buf[1] {
+	case socks5MethodNoAuth:
+
+	case socks5MethodUser:
+		authErr := socks5Auth(conn, u, buf[:])
+
+		if authErr != nil {
+			return authErr
+		}
+
+	default:
+		return ErrSOCKS5NoAcceptableMethod
+	}
+
+	// Connect request
+	req := append(buf[:0], socks5Version, socks5CmdConnect, 0x00)
+	ip := net.ParseIP(host)
+
+	switch {
+	case ip != nil && ip.To4() != nil:
+		req = append(req, socks5AddressIPv4)
+		req = append(req, ip.To4()...)
+
+	case ip != nil:
+		req = append(req, socks5AddressIPv6)
+		req = append(req, ip.To16()...)
+
+	case len(host) > 255:
+		return ErrHostTooLong
+
+	default:
+		req = append(req, socks5AddressDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+
+	req = append(req, byte(port>>8), byte(port))
+
+	_, wErr = rw.WriteFull(conn, req)
+
+	if wErr != nil {
+		return wErr
+	}
+
+	// Reply
+	_, rErr = io.ReadFull(conn, buf[:4])
+
+	if rErr != nil {
+		return rErr
+	}
+
+	if buf[0] != socks5Version {
+		return ErrSOCKS5UnexpectedVersion
+	}
+
+	if buf[1] != socks5ReplySucceed {
+		return ErrSOCKS5ConnectFailed
+	}
+
+	var addrLen int
+
+	switch buf[3] {
+	case socks5AddressIPv4:
+		addrLen = net.IPv4len
+
+	case socks5AddressIPv6:
+		addrLen = net.IPv6len
+
+	case socks5AddressDomain:
+		_, rErr = io.ReadFull(conn, buf[:1])
+
+		if rErr != nil {
+			return rErr
+		}
+
+		addrLen = int(buf[0])
+
+	default:
+		return ErrSOCKS5UnknownAddressType
+	}
+
+	// Bound address and port are useless to us
+	_, rErr = io.ReadFull(conn, buf[:addrLen+2])
+
+	return rErr
+}
+
+// socks5Auth authenticates with username and password
+func socks5Auth(conn net.Conn, u Upstream, buf []byte) error {
+	if len(u.Username) > 255 || len(u.Password) > 255 {
+		return ErrCredentialTooLong
+	}
+
+	req := append(buf[:0], socks5AuthVersion, byte(len(u.Username)))
+	req = append(req, u.Username...)
+	req = append(req, byte(len(u.Password)))
+	req = append(req, u.Password...)
+
+	_, wErr := rw.WriteFull(conn, req)
+
+	if wErr != nil {
+		return wErr
+	}
+
+	_, rErr := io.ReadFull(conn, buf[:2])
+
+	if rErr != nil {
+		return rErr
+	}
+
+	if buf[1] != 0x00 {
+		return ErrSOCKS5AuthFailed
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package egress
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+)
+
+// Errors
+var (
+	ErrNoSourceAddress = errors.New(
+		"No source address is available for the destination")
+
+	ErrInvalidAddress = errors.New(
+		"Invalid source address")
+)
+
+// Source is the local end where an outbound connection will leave from
+type Source struct {
+	IP     net.IP
+	Device string
+}
+
+// Egress selects Sources for outbound connections
+type Egress interface {
+	Source(destination net.IP) (Source, error)
+}
+
+type egress struct {
+	addresses []net.IP
+	device    string
+	rotate    bool
+	next      uint32
+}
+
+// New creates a new Egress. Outbound connections will leave from one of the
+// given addresses through the given network interface device. When rotate
+// is enabled, the addresses will be used in turn, otherwise the first one
+// which matches the destination will be used.
+//
+// It returns nil when neither address nor device is given
+func New(addresses []net.IP, device string, rotate bool) Egress {
+	if len(addresses) <= 0 && device == "" {
+		return nil
+	}
+
+	return &egress{
+		addresses: addresses,
+		device:    device,
+		rotate:    rotate,
+		next:      0,
+	}
+}
+
+// ParseAddresses parses a list of source addresses
+func ParseAddresses(addresses []string) ([]net.IP, error) {
+	result := make([]net.IP, len(addresses))
+
+	for aIdx := range addresses {
+		ip := net.ParseIP(addresses[aIdx])
+
+		if ip == nil {
+			return nil, ErrInvalidAddress
+		}
+
+		result[aIdx] = ip
+	}
+
+	return result, nil
+}
+
+// Source selects a Source for the destination. Only the addresses of the
+// same IP version as the destination will be selected, or all of them if
+// the destination is nil
+func (e *egress) Source(destination net.IP) (Source, error) {
+	if len(e.addresses) <= 0 {
+		return Source{
+			IP:     nil,
+			Device: e.device,
+		}, nil
+	}
+
+	candidates := make([]net.IP, 0, len(e.addresses))
+
+	for aIdx := range e.addresses {
+		if destination != nil &&
+			(e.addresses[aIdx].To4() == nil) != (destination.To4() == nil) {
+			continue
+		}
+
+		candidates = append(candidates, e.addresses[aIdx])
+	}
+
+	if len(candidates) <= 0 {
+		return Source{}, ErrNoSourceAddress
+	}
+
+	selected := candidates[0]
+
+	if e.rotate {
+		selected = candidates[(atomic.AddUint32(&e.next, 1)-1)%
+			uint32(len(candidates))]
+	}
+
+	return Source{
+		IP:     selected,
+		Device: e.device,
+	}, nil
+}
+
+// Dialer returns a net.Dialer which dials from the Source
+func (s Source) Dialer() net.Dialer {
+	var localAddr net.Addr
+
+	if s.IP != nil {
+		localAddr = &net.TCPAddr{
+			IP:   s.IP,
+			Port: 0,
+			Zone: "",
+		}
+	}
+
+	return net.Dialer{
+		LocalAddr: localAddr,
+		Control:   s.control,
+	}
+}
+
+// DialUDP dials an UDP address from the Source
+func (s Source) DialUDP(addr *net.UDPAddr) (*net.UDPConn, error) {
+	var localAddr net.Addr
+
+	if s.IP != nil {
+		localAddr = &net.UDPAddr{
+			IP:   s.IP,
+			Port: 0,
+			Zone: "",
+		}
+	}
+
+	dialer := net.Dialer{
+		LocalAddr: localAddr,
+		Control:   s.control,
+	}
+
+	conn, dialErr := dialer.Dial("udp", addr.String())
+
+	if dialErr != nil {
+		return nil, dialErr
+	}
+
+	return conn.(*net.UDPConn), nil
+}
+
+// ListenUDP listens on a random UDP port of the Source
+func (s Source) ListenUDP() (*net.UDPConn, error) {
+	listenConfig := net.ListenConfig{
+		Control:   s.control,
+		KeepAlive: 0,
+	}
+
+	conn, listenErr := listenConfig.ListenPacket(
+		context.Background(), "udp", (&net.UDPAddr{
+			IP:   s.IP,
+			Port: 0,
+			Zone: "",
+		}).String())
+
+	if listenErr != nil {
+		return nil, listenErr
+	}
+
+	return conn.(*net.UDPConn), nil
+}
@@ -0,0 +1,45 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+//go:build linux
+// +build linux
+
+package egress
+
+import "syscall"
+
+// control binds the socket to the network interface device of the Source
+func (s Source) control(network, address string, c syscall.RawConn) error {
+	if s.Device == "" {
+		return nil
+	}
+
+	var bindErr error
+
+	ctlErr := c.Control(func(fd uintptr) {
+		bindErr = syscall.BindToDevice(int(fd), s.Device)
+	})
+
+	if ctlErr != nil {
+		return ctlErr
+	}
+
+	return bindErr
+}
@@ -0,0 +1,178 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package egress
+
+import (
+	"net"
+	"testing"
+)
+
+func TestEgressSource(t *testing.T) {
+	if New(nil, "", true) != nil {
+		t.Error("Egress must be nil when neither address nor device is given")
+
+		return
+	}
+
+	addresses, parseErr := ParseAddresses([]string{
+		"192.0.2.1", "2001:db8::1", "192.0.2.2",
+	})
+
+	if parseErr != nil {
+		t.Error("Failed to parse addresses due to error:", parseErr)
+
+		return
+	}
+
+	tests := []struct {
+		rotate      bool
+		destination net.IP
+		expected    []string
+	}{
+		{false, net.ParseIP("198.51.100.1"), []string{
+			"192.0.2.1", "192.0.2.1", "192.0.2.1"}},
+		{true, net.ParseIP("198.51.100.1"), []string{
+			"192.0.2.1", "192.0.2.2", "192.0.2.1"}},
+		{true, net.ParseIP("2001:db8::2"), []string{
+			"2001:db8::1", "2001:db8::1"}},
+		{true, nil, []string{
+			"192.0.2.1", "2001:db8::1", "192.0.2.2", "192.0.2.1"}},
+	}
+
+	for tIdx, test := range tests {
+		e := New(addresses, "", test.rotate)
+
+		for eIdx := range test.expected {
+			source, sourceErr := e.Source(test.destination)
+
+			if sourceErr != nil {
+				t.Errorf("Test %d: Failed to select source due to error: %s",
+					tIdx, sourceErr)
+
+				return
+			}
+
+			if source.IP.String() == test.expected[eIdx] {
+				continue
+			}
+
+			t.Errorf("Test %d: Expecting source %d to be %s, got %s",
+				tIdx, eIdx, test.expected[eIdx], source.IP)
+
+			return
+		}
+	}
+
+	_, sourceErr := New(addresses[:1], "", false).Source(
+		net.ParseIP("2001:db8::2"))
+
+	if sourceErr != ErrNoSourceAddress {
+		t.Errorf("Expecting error %s, got %s", ErrNoSourceAddress, sourceErr)
+
+		return
+	}
+
+	_, parseErr = ParseAddresses([]string{"192.0.2.1", "example.com"})
+
+	if parseErr != ErrInvalidAddress {
+		t.Errorf("Expecting error %s, got %s", ErrInvalidAddress, parseErr)
+
+		return
+	}
+}
+
+func TestSourceBind(t *testing.T) {
+	source, sourceErr := New([]net.IP{net.ParseIP("127.0.0.1")}, "", false).
+		Source(nil)
+
+	if sourceErr != nil {
+		t.Error("Failed to select source due to error:", sourceErr)
+
+		return
+	}
+
+	listener, listenErr := source.ListenUDP()
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return
+	}
+
+	defer listener.Close()
+
+	if !listener.LocalAddr().(*net.UDPAddr).IP.Equal(source.IP) {
+		t.Errorf("Expecting listener to be bound on %s, got %s",
+			source.IP, listener.LocalAddr())
+
+		return
+	}
+
+	conn, dialErr := source.DialUDP(listener.LocalAddr().(*net.UDPAddr))
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	defer conn.Close()
+
+	if !conn.LocalAddr().(*net.UDPAddr).IP.Equal(source.IP) {
+		t.Errorf("Expecting dialer to be bound on %s, got %s",
+			source.IP, conn.LocalAddr())
+
+		return
+	}
+
+	tcpListener, tcpListenErr := net.ListenTCP("tcp", &net.TCPAddr{
+		IP:   source.IP,
+		Port: 0,
+		Zone: "",
+	})
+
+	if tcpListenErr != nil {
+		t.Error("Failed to listen due to error:", tcpListenErr)
+
+		return
+	}
+
+	defer tcpListener.Close()
+
+	dialer := source.Dialer()
+
+	tcpConn, tcpDialErr := dialer.Dial("tcp", tcpListener.Addr().String())
+
+	if tcpDialErr != nil {
+		t.Error("Failed to dial due to error:", tcpDialErr)
+
+		return
+	}
+
+	defer tcpConn.Close()
+
+	if !tcpConn.LocalAddr().(*net.TCPAddr).IP.Equal(source.IP) {
+		t.Errorf("Expecting dialer to be bound on %s, got %s",
+			source.IP, tcpConn.LocalAddr())
+
+		return
+	}
+}
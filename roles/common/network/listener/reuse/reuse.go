@@ -0,0 +1,63 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package reuse
+
+import (
+	"context"
+	"net"
+)
+
+// ListenTCP listens a TCP address which can be shared with another listener
+// that also been created by this function, so a new listener can take over
+// the port before the old one is closed
+func ListenTCP(addr *net.TCPAddr) (*net.TCPListener, error) {
+	listenConfig := net.ListenConfig{
+		Control:   control,
+		KeepAlive: 0,
+	}
+
+	listener, listenErr := listenConfig.Listen(
+		context.Background(), "tcp", addr.String())
+
+	if listenErr != nil {
+		return nil, listenErr
+	}
+
+	return listener.(*net.TCPListener), nil
+}
+
+// ListenUDP listens a UDP address which can be shared with another listener
+// that also been created by this function
+func ListenUDP(addr *net.UDPAddr) (*net.UDPConn, error) {
+	listenConfig := net.ListenConfig{
+		Control:   control,
+		KeepAlive: 0,
+	}
+
+	conn, listenErr := listenConfig.ListenPacket(
+		context.Background(), "udp", addr.String())
+
+	if listenErr != nil {
+		return nil, listenErr
+	}
+
+	return conn.(*net.UDPConn), nil
+}
@@ -0,0 +1,46 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+// +build linux,!mips,!mipsle,!mips64,!mips64le
+
+package reuse
+
+import "syscall"
+
+// soReusePort is the value of SO_REUSEPORT on Linux, which is not defined by
+// the syscall package
+const soReusePort = 0x0F
+
+// control enables SO_REUSEPORT on the socket
+func control(network, address string, c syscall.RawConn) error {
+	var optErr error
+
+	ctlErr := c.Control(func(fd uintptr) {
+		optErr = syscall.SetsockoptInt(
+			int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+	})
+
+	if ctlErr != nil {
+		return ctlErr
+	}
+
+	return optErr
+}
@@ -0,0 +1,349 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Errors
+var (
+	ErrProxyProtocolInvalidHeader = errors.New(
+		"Invalid PROXY protocol header")
+
+	ErrProxyProtocolUnsupportedVersion = errors.New(
+		"Unsupported PROXY protocol version")
+
+	ErrProxyProtocolUnsupportedCommand = errors.New(
+		"Unsupported PROXY protocol command")
+
+	ErrProxyProtocolUnsupportedFamily = errors.New(
+		"Unsupported PROXY protocol address family")
+)
+
+const (
+	proxyProtocolV1MaxLength  = 107
+	proxyProtocolV2HeadLength = 16
+
+	// proxyProtocolHeaderTimeout is used when no header timeout is given,
+	// so the header read will never wait forever
+	proxyProtocolHeaderTimeout = 10 * time.Second
+)
+
+var (
+	proxyProtocolV1Signature = []byte("PROXY ")
+	proxyProtocolV2Signature = []byte{
+		0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+)
+
+// proxyProtocol contains PROXY protocol settings
+type proxyProtocol struct {
+	trusted       []*net.IPNet
+	headerTimeout time.Duration
+}
+
+// proxied is a connection which real remote address is carried in the
+// PROXY protocol header
+type proxied struct {
+	*net.TCPConn
+
+	reader *bufio.Reader
+	remote net.Addr
+}
+
+// isTrusted returns whether or not the PROXY protocol header from given
+// address should be accepted
+func (p proxyProtocol) isTrusted(addr net.Addr) bool {
+	if len(p.trusted) <= 0 {
+		return false
+	}
+
+	tcpAddr, isTCPAddr := addr.(*net.TCPAddr)
+
+	if !isTCPAddr {
+		return false
+	}
+
+	for _, trusted := range p.trusted {
+		if !trusted.Contains(tcpAddr.IP) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// accept reads PROXY protocol header from the connection
+func (p proxyProtocol) accept(conn *net.TCPConn) (net.Conn, error) {
+	headerTimeout := p.headerTimeout
+
+	if headerTimeout <= 0 {
+		headerTimeout = proxyProtocolHeaderTimeout
+	}
+
+	deadlineErr := conn.SetReadDeadline(time.Now().Add(headerTimeout))
+
+	if deadlineErr != nil {
+		return nil, deadlineErr
+	}
+
+	reader := bufio.NewReaderSize(conn, proxyProtocolV1MaxLength+1)
+
+	remote, parseErr := parseProxyProtocol(reader)
+
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	deadlineErr = conn.SetReadDeadline(time.Time{})
+
+	if deadlineErr != nil {
+		return nil, deadlineErr
+	}
+
+	// LOCAL command or UNKNOWN protocol: keep the original address
+	if remote == nil {
+		remote = conn.RemoteAddr()
+	}
+
+	return proxied{
+		TCPConn: conn,
+		reader:  reader,
+		remote:  remote,
+	}, nil
+}
+
+// parseProxyProtocol parses PROXY protocol v1 or v2 header and returns the
+// source address carried by it. A nil address will be returned when the
+// header carries no address
+func parseProxyProtocol(reader *bufio.Reader) (net.Addr, error) {
+	signature, peekErr := reader.Peek(len(proxyProtocolV1Signature))
+
+	if peekErr != nil {
+		return nil, peekErr
+	}
+
+	if bytes.Equal(signature, proxyProtocolV1Signature) {
+		return parseProxyProtocolV1(reader)
+	}
+
+	signature, peekErr = reader.Peek(len(proxyProtocolV2Signature))
+
+	if peekErr != nil {
+		return nil, peekErr
+	}
+
+	if bytes.Equal(signature, proxyProtocolV2Signature) {
+		return parseProxyProtocolV2(reader)
+	}
+
+	return nil, ErrProxyProtocolInvalidHeader
+}
+
+// parseProxyProtocolV1 parses the human-readable v1 header, for example:
+// PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n
+func parseProxyProtocolV1(reader *bufio.Reader) (net.Addr, error) {
+	line := make([]byte, 0, proxyProtocolV1MaxLength)
+
+	for {
+		b, readErr := reader.ReadByte()
+
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		line = append(line, b)
+
+		if b == '\n' {
+			break
+		}
+
+		if len(line) >= proxyProtocolV1MaxLength {
+			return nil, ErrProxyProtocolInvalidHeader
+		}
+	}
+
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, ErrProxyProtocolInvalidHeader
+	}
+
+	parts := bytes.Split(line[:len(line)-2], []byte{' '})
+
+	if len(parts) < 2 {
+		return nil, ErrProxyProtocolInvalidHeader
+	}
+
+	switch string(parts[1]) {
+	case "UNKNOWN":
+		return nil, nil
+
+	case "TCP4", "TCP6":
+
+	default:
+		return nil, ErrProxyProtocolUnsupportedFamily
+	}
+
+	if len(parts) != 6 {
+		return nil, ErrProxyProtocolInvalidHeader
+	}
+
+	ip := net.ParseIP(string(parts[2]))
+
+	if ip == nil {
+		return nil, ErrProxyProtocolInvalidHeader
+	}
+
+	if (ip.To4() != nil) != (string(parts[1]) == "TCP4") {
+		return nil, ErrProxyProtocolInvalidHeader
+	}
+
+	port, portErr := strconv.ParseUint(string(parts[4]), 10, 16)
+
+	if portErr != nil {
+		return nil, ErrProxyProtocolInvalidHeader
+	}
+
+	return &net.TCPAddr{
+		IP:   ip,
+		Port: int(port),
+		Zone: "",
+	}, nil
+}
+
+// parseProxyProtocolV2 parses the binary v2 header
+func parseProxyProtocolV2(reader *bufio.Reader) (net.Addr, error) {
+	head := [proxyProtocolV2HeadLength]byte{}
+
+	_, readErr := io.ReadFull(reader, head[:])
+
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	if head[12]>>4 != 0x02 {
+		return nil, ErrProxyProtocolUnsupportedVersion
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(head[14:16]))
+
+	_, readErr = io.ReadFull(reader, body)
+
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	switch head[12] & 0x0F {
+	case 0x00: // LOCAL
+		return nil, nil
+
+	case 0x01: // PROXY
+
+	default:
+		return nil, ErrProxyProtocolUnsupportedCommand
+	}
+
+	switch head[13] {
+	case 0x11: // TCP over IPv4
+		if len(body) < 12 {
+			return nil, ErrProxyProtocolInvalidHeader
+		}
+
+		return &net.TCPAddr{
+			IP:   net.IP(body[0:4]),
+			Port: int(binary.BigEndian.Uint16(body[8:10])),
+			Zone: "",
+		}, nil
+
+	case 0x21: // TCP over IPv6
+		if len(body) < 36 {
+			return nil, ErrProxyProtocolInvalidHeader
+		}
+
+		return &net.TCPAddr{
+			IP:   net.IP(body[0:16]),
+			Port: int(binary.BigEndian.Uint16(body[32:34])),
+			Zone: "",
+		}, nil
+
+	case 0x00: // UNSPEC
+		return nil, nil
+
+	default:
+		return nil, ErrProxyProtocolUnsupportedFamily
+	}
+}
+
+// Read reads data that follows the PROXY protocol header
+func (p proxied) Read(b []byte) (int, error) {
+	return p.reader.Read(b)
+}
+
+// RemoteAddr returns the address of the real client
+func (p proxied) RemoteAddr() net.Addr {
+	return p.remote
+}
+
+// ParseTrusted parses a list of CIDRs (or single IP addresses) which the
+// PROXY protocol header will be accepted from
+func ParseTrusted(trusted []string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0, len(trusted))
+
+	for tIdx := range trusted {
+		_, network, parseErr := net.ParseCIDR(trusted[tIdx])
+
+		if parseErr == nil {
+			result = append(result, network)
+
+			continue
+		}
+
+		ip := net.ParseIP(trusted[tIdx])
+
+		if ip == nil {
+			return nil, parseErr
+		}
+
+		if ip.To4() != nil {
+			result = append(result, &net.IPNet{
+				IP:   ip.To4(),
+				Mask: net.CIDRMask(32, 32),
+			})
+
+			continue
+		}
+
+		result = append(result, &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(128, 128),
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,301 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/reinit/coward/roles/common/network"
+	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
+)
+
+func TestParseProxyProtocolV1(t *testing.T) {
+	addr, parseErr := parseProxyProtocol(bufio.NewReader(bytes.NewReader(
+		[]byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\nDATA"))))
+
+	if parseErr != nil {
+		t.Error("Failed to parse due to error:", parseErr)
+
+		return
+	}
+
+	if addr.String() != "192.0.2.1:56324" {
+		t.Errorf("Expecting address to be \"192.0.2.1:56324\", got %s", addr)
+
+		return
+	}
+
+	addr, parseErr = parseProxyProtocol(bufio.NewReader(bytes.NewReader(
+		[]byte("PROXY UNKNOWN\r\n"))))
+
+	if parseErr != nil {
+		t.Error("Failed to parse due to error:", parseErr)
+
+		return
+	}
+
+	if addr != nil {
+		t.Errorf("Expecting no address, got %s", addr)
+
+		return
+	}
+
+	_, parseErr = parseProxyProtocol(bufio.NewReader(bytes.NewReader(
+		[]byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324\r\n"))))
+
+	if parseErr != ErrProxyProtocolInvalidHeader {
+		t.Error("Expecting ErrProxyProtocolInvalidHeader, got", parseErr)
+
+		return
+	}
+}
+
+func TestParseProxyProtocolV2(t *testing.T) {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, 0x21, 0x21, 0x00, 36)
+	header = append(header, net.ParseIP("2001:db8::1").To16()...)
+	header = append(header, net.ParseIP("2001:db8::2").To16()...)
+	header = append(header, 0x1F, 0x90, 0x01, 0xBB)
+
+	addr, parseErr := parseProxyProtocol(bufio.NewReader(
+		bytes.NewReader(header)))
+
+	if parseErr != nil {
+		t.Error("Failed to parse due to error:", parseErr)
+
+		return
+	}
+
+	if addr.String() != "[2001:db8::1]:8080" {
+		t.Errorf("Expecting address to be \"[2001:db8::1]:8080\", got %s",
+			addr)
+
+		return
+	}
+
+	header = append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, 0x20, 0x00, 0x00, 0x00)
+
+	addr, parseErr = parseProxyProtocol(bufio.NewReader(
+		bytes.NewReader(header)))
+
+	if parseErr != nil {
+		t.Error("Failed to parse due to error:", parseErr)
+
+		return
+	}
+
+	if addr != nil {
+		t.Errorf("Expecting no address for LOCAL command, got %s", addr)
+
+		return
+	}
+}
+
+func testProxyProtocolAccept(
+	t *testing.T,
+	trusted []*net.IPNet,
+	send []byte,
+) (network.Connection, []byte, error) {
+	listener := NewProxyProtocol(
+		net.ParseIP("127.0.0.1"), 0, trusted, 1*time.Second, tcpconn.Wrap)
+
+	acceptor, listenErr := listener.Listen()
+
+	if listenErr != nil {
+		return nil, nil, listenErr
+	}
+
+	defer acceptor.Close()
+
+	conn, dialErr := net.Dial("tcp", acceptor.Addr().String())
+
+	if dialErr != nil {
+		return nil, nil, dialErr
+	}
+
+	defer conn.Close()
+
+	_, writeErr := conn.Write(send)
+
+	if writeErr != nil {
+		return nil, nil, writeErr
+	}
+
+	accepted, acceptErr := acceptor.Accept()
+
+	if acceptErr != nil {
+		return nil, nil, acceptErr
+	}
+
+	defer accepted.Close()
+
+	data := make([]byte, 4)
+
+	_, readErr := io.ReadFull(accepted, data)
+
+	if readErr != nil {
+		return nil, nil, readErr
+	}
+
+	return accepted, data, nil
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+
+	accepted, data, acceptErr := testProxyProtocolAccept(
+		t,
+		[]*net.IPNet{loopback},
+		[]byte("PROXY TCP4 192.0.2.1 127.0.0.1 56324 443\r\nDATA"))
+
+	if acceptErr != nil {
+		t.Error("Failed to accept due to error:", acceptErr)
+
+		return
+	}
+
+	if accepted.RemoteAddr().String() != "192.0.2.1:56324" {
+		t.Errorf("Expecting remote address to be \"192.0.2.1:56324\", "+
+			"got %s", accepted.RemoteAddr())
+
+		return
+	}
+
+	if string(data) != "DATA" {
+		t.Errorf("Expecting data to be \"DATA\", got %s", data)
+
+		return
+	}
+
+	_, untrusted, _ := net.ParseCIDR("192.0.2.0/24")
+
+	accepted, data, acceptErr = testProxyProtocolAccept(
+		t, []*net.IPNet{untrusted}, []byte("DATA"))
+
+	if acceptErr != nil {
+		t.Error("Failed to accept due to error:", acceptErr)
+
+		return
+	}
+
+	if accepted.RemoteAddr().(*net.TCPAddr).IP.String() != "127.0.0.1" {
+		t.Errorf("Expecting remote address to be the original, got %s",
+			accepted.RemoteAddr())
+
+		return
+	}
+
+	if string(data) != "DATA" {
+		t.Errorf("Expecting data to be \"DATA\", got %s", data)
+
+		return
+	}
+}
+
+func TestProxyProtocolListenerSlowSource(t *testing.T) {
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+
+	listener := NewProxyProtocol(
+		net.ParseIP("127.0.0.1"), 0, []*net.IPNet{loopback}, 0, tcpconn.Wrap)
+
+	acceptor, listenErr := listener.Listen()
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return
+	}
+
+	defer acceptor.Close()
+
+	// Never sends the header
+	slow, dialErr := net.Dial("tcp", acceptor.Addr().String())
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	defer slow.Close()
+
+	conn, dialErr := net.Dial("tcp", acceptor.Addr().String())
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	defer conn.Close()
+
+	_, writeErr := conn.Write(
+		[]byte("PROXY TCP4 192.0.2.1 127.0.0.1 56324 443\r\nDATA"))
+
+	if writeErr != nil {
+		t.Error("Failed to write due to error:", writeErr)
+
+		return
+	}
+
+	acceptResult := make(chan network.Connection, 1)
+
+	go func() {
+		accepted, acceptErr := acceptor.Accept()
+
+		if acceptErr != nil {
+			acceptResult <- nil
+
+			return
+		}
+
+		acceptResult <- accepted
+	}()
+
+	select {
+	case accepted := <-acceptResult:
+		if accepted == nil {
+			t.Error("Failed to accept the connection")
+
+			return
+		}
+
+		defer accepted.Close()
+
+		if accepted.RemoteAddr().String() != "192.0.2.1:56324" {
+			t.Errorf("Expecting remote address to be \"192.0.2.1:56324\", "+
+				"got %s", accepted.RemoteAddr())
+
+			return
+		}
+
+	case <-time.After(3 * time.Second):
+		t.Error("Accept has been blocked by the slow source")
+
+		return
+	}
+}
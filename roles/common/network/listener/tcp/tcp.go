@@ -21,26 +21,44 @@
 package tcp
 
 import (
+	"errors"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/network/listener/reuse"
+)
+
+// Errors
+var (
+	ErrAcceptorClosed = errors.New(
+		"TCP acceptor is closed")
 )
 
 // listener is a TCP listener
 type listener struct {
 	host              net.IP
 	port              uint16
+	proxyProtocol     proxyProtocol
 	connectionWrapper network.ConnectionWrapper
 }
 
 // acceptor is a TCP acceptor
 type acceptor struct {
 	listener          *net.TCPListener
+	proxyProtocol     proxyProtocol
 	connectionWrapper network.ConnectionWrapper
+	handshaked        chan accepted
 	closed            chan struct{}
 }
 
+// accepted is the result of the background accepting
+type accepted struct {
+	conn network.Connection
+	err  error
+}
+
 // New creates a new TCP listener
 func New(
 	host net.IP,
@@ -48,15 +66,41 @@ func New(
 	connectionWrapper network.ConnectionWrapper,
 ) network.Listener {
 	return listener{
-		host:              host,
-		port:              port,
+		host: host,
+		port: port,
+		proxyProtocol: proxyProtocol{
+			trusted:       nil,
+			headerTimeout: 0,
+		},
+		connectionWrapper: connectionWrapper,
+	}
+}
+
+// NewProxyProtocol creates a new TCP listener which accepts PROXY protocol
+// (v1 and v2) header from trusted sources. Connections come from these
+// sources must carry a valid header, and their RemoteAddr will be the
+// address of the real client
+func NewProxyProtocol(
+	host net.IP,
+	port uint16,
+	trusted []*net.IPNet,
+	headerTimeout time.Duration,
+	connectionWrapper network.ConnectionWrapper,
+) network.Listener {
+	return listener{
+		host: host,
+		port: port,
+		proxyProtocol: proxyProtocol{
+			trusted:       trusted,
+			headerTimeout: headerTimeout,
+		},
 		connectionWrapper: connectionWrapper,
 	}
 }
 
 // Listen listens a TCP port
 func (t listener) Listen() (network.Acceptor, error) {
-	listener, listenErr := net.ListenTCP("tcp", &net.TCPAddr{
+	listener, listenErr := reuse.ListenTCP(&net.TCPAddr{
 		IP:   t.host,
 		Port: int(t.port), // Safe when not running on a system that below 16b
 		Zone: "",
@@ -66,11 +110,23 @@ func (t listener) Listen() (network.Acceptor, error) {
 		return nil, listenErr
 	}
 
-	return acceptor{
+	acc := acceptor{
 		listener:          listener,
+		proxyProtocol:     t.proxyProtocol,
 		connectionWrapper: t.connectionWrapper,
+		handshaked:        nil,
 		closed:            make(chan struct{}),
-	}, nil
+	}
+
+	// PROXY protocol headers are read in separated goroutines, so a slow
+	// trusted source can't hold up other incoming connections
+	if len(t.proxyProtocol.trusted) > 0 {
+		acc.handshaked = make(chan accepted)
+
+		go acc.accepting()
+	}
+
+	return acc, nil
 }
 
 // String returns current Listener information in string
@@ -84,8 +140,8 @@ func (a acceptor) Addr() net.Addr {
 	return a.listener.Addr()
 }
 
-// Accept accepts a TCP connection
-func (a acceptor) Accept() (network.Connection, error) {
+// acceptTCP accepts a TCP connection and setup it's options
+func (a acceptor) acceptTCP() (*net.TCPConn, error) {
 	accepted, acceptErr := a.listener.AcceptTCP()
 
 	if acceptErr != nil {
@@ -95,17 +151,106 @@ func (a acceptor) Accept() (network.Connection, error) {
 	optErr := accepted.SetLinger(0)
 
 	if optErr != nil {
+		accepted.Close()
+
 		return nil, optErr
 	}
 
-	// Delay data for sending on server, so the TCP can work more efficienly
+	// Delay data for sending on server, so the TCP can work more
+	// efficienly
 	optErr = accepted.SetNoDelay(false)
 
 	if optErr != nil {
+		accepted.Close()
+
 		return nil, optErr
 	}
 
-	return a.connectionWrapper(accepted), nil
+	return accepted, nil
+}
+
+// deliver hands the result over to the Accept. Returns false when the
+// acceptor is closed
+func (a acceptor) deliver(result accepted) bool {
+	select {
+	case a.handshaked <- result:
+		return true
+
+	case <-a.closed:
+		if result.conn != nil {
+			result.conn.Close()
+		}
+
+		return false
+	}
+}
+
+// accepting accepts TCP connections in background until the acceptor is
+// closed
+func (a acceptor) accepting() {
+	for {
+		conn, acceptErr := a.acceptTCP()
+
+		if acceptErr != nil {
+			if !a.deliver(accepted{conn: nil, err: acceptErr}) {
+				return
+			}
+
+			continue
+		}
+
+		if !a.proxyProtocol.isTrusted(conn.RemoteAddr()) {
+			if !a.deliver(accepted{
+				conn: a.connectionWrapper(conn),
+				err:  nil,
+			}) {
+				return
+			}
+
+			continue
+		}
+
+		go a.handshake(conn)
+	}
+}
+
+// handshake reads the PROXY protocol header of the connection
+func (a acceptor) handshake(conn *net.TCPConn) {
+	proxied, proxiedErr := a.proxyProtocol.accept(conn)
+
+	if proxiedErr != nil {
+		// Drop the connection silently, otherwise one broken header will
+		// cause the server to wait for AcceptErrorWait
+		conn.Close()
+
+		return
+	}
+
+	a.deliver(accepted{
+		conn: a.connectionWrapper(proxied),
+		err:  nil,
+	})
+}
+
+// Accept accepts a TCP connection
+func (a acceptor) Accept() (network.Connection, error) {
+	if a.handshaked == nil {
+		conn, acceptErr := a.acceptTCP()
+
+		if acceptErr != nil {
+			return nil, acceptErr
+		}
+
+		return a.connectionWrapper(conn), nil
+	}
+
+	select {
+	case result := <-a.handshaked:
+		return result.conn, result.err
+
+	case <-a.closed:
+		return nil, ErrAcceptorClosed
+	}
 }
 
 // Closed return whether or not current acceptor is closed
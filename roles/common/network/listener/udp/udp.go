@@ -28,6 +28,7 @@ import (
 
 	"github.com/reinit/coward/common/ticker"
 	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/network/listener/reuse"
 )
 
 type listener struct {
@@ -63,7 +64,7 @@ func New(
 
 // Listen start listening
 func (t listener) Listen() (network.Acceptor, error) {
-	listen, listenErr := net.ListenUDP("udp", &net.UDPAddr{
+	listen, listenErr := reuse.ListenUDP(&net.UDPAddr{
 		IP:   t.host,
 		Port: int(t.port),
 		Zone: "",
@@ -21,7 +21,6 @@
 package resolve
 
 import (
-	"context"
 	"errors"
 	"net"
 	"sync"
@@ -46,13 +45,12 @@ type cachedItem struct {
 }
 
 type cached struct {
-	cache          map[string]cachedItem
-	reverseCache   map[IPMark]string
-	cacheTTL       time.Duration
-	maxSize        uint32
-	resolver       net.Resolver
-	resolveTimeout time.Duration
-	lock           sync.RWMutex
+	cache        map[string]cachedItem
+	reverseCache map[IPMark]string
+	cacheTTL     time.Duration
+	maxSize      uint32
+	resolver     Resolver
+	lock         sync.RWMutex
 }
 
 // Cached returns a cached Resolver
@@ -60,19 +58,24 @@ func Cached(
 	cacheTTL time.Duration,
 	resolveTimeout time.Duration,
 	maxSize uint32,
+) Resolver {
+	return CachedResolver(DNS(resolveTimeout), cacheTTL, maxSize)
+}
+
+// CachedResolver returns a Resolver which caches the results of the given
+// Resolver
+func CachedResolver(
+	resolver Resolver,
+	cacheTTL time.Duration,
+	maxSize uint32,
 ) Resolver {
 	return &cached{
 		cache:        make(map[string]cachedItem, maxSize),
 		reverseCache: make(map[IPMark]string, maxSize),
 		cacheTTL:     cacheTTL,
 		maxSize:      maxSize,
-		resolver: net.Resolver{
-			PreferGo:     true,
-			StrictErrors: false,
-			Dial:         nil,
-		},
-		resolveTimeout: resolveTimeout,
-		lock:           sync.RWMutex{},
+		resolver:     resolver,
+		lock:         sync.RWMutex{},
 	}
 }
 
@@ -82,7 +85,7 @@ func (c *cached) Resolve(domain string) ([]net.IP, error) {
 	ip, found := c.cache[domain]
 
 	if found {
-		if !ip.Expire.After(time.Now()) {
+		if ip.Expire.After(time.Now()) {
 			addresses := make([]net.IP, len(ip.Addresses))
 
 			copy(addresses, ip.Addresses)
@@ -91,8 +94,6 @@ func (c *cached) Resolve(domain string) ([]net.IP, error) {
 
 			return addresses, nil
 		}
-
-		c.lock.RUnlock()
 	} else if uint32(len(c.cache)) >= c.maxSize {
 		c.lock.RUnlock()
 
@@ -101,12 +102,7 @@ func (c *cached) Resolve(domain string) ([]net.IP, error) {
 
 	c.lock.RUnlock()
 
-	ctx, cancel := context.WithDeadline(
-		context.Background(), time.Now().Add(c.resolveTimeout))
-
-	defer cancel()
-
-	resolved, resolveErr := c.resolver.LookupIPAddr(ctx, domain)
+	resolved, resolveErr := c.resolver.Resolve(domain)
 
 	if resolveErr != nil {
 		return nil, resolveErr
@@ -124,10 +120,10 @@ func (c *cached) Resolve(domain string) ([]net.IP, error) {
 	}
 
 	for rIdx := range resolved {
-		newResolve.Addresses[rIdx] = resolved[rIdx].IP
+		newResolve.Addresses[rIdx] = resolved[rIdx]
 
 		ipMark := IPMark{}
-		ipMark.Import(resolved[rIdx].IP)
+		ipMark.Import(resolved[rIdx])
 
 		c.reverseCache[ipMark] = domain
 	}
@@ -0,0 +1,267 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package resolve
+
+import (
+	"container/list"
+	"net"
+	"sync"
+	"time"
+)
+
+// LRUConfig is the configuration of the LRU cached Resolver
+type LRUConfig struct {
+	MaxSize     uint32
+	MinTTL      time.Duration
+	MaxTTL      time.Duration
+	NegativeTTL time.Duration
+}
+
+type lruEntry struct {
+	host      string
+	addresses []net.IP
+	err       error
+	expire    time.Time
+}
+
+type lruCall struct {
+	done      chan struct{}
+	addresses []net.IP
+	err       error
+}
+
+type lru struct {
+	resolver Resolver
+	cfg      LRUConfig
+	entries  map[string]*list.Element
+	order    *list.List
+	reverse  map[IPMark]*list.Element
+	calls    map[string]*lruCall
+	lock     sync.Mutex
+}
+
+// LRU returns a Resolver which caches at most cfg.MaxSize results of the
+// given Resolver, and evicts the least recently used one when it's full.
+//
+// Results are cached for the TTL reported by the Resolver (when it's a
+// TTLResolver) clamped between cfg.MinTTL and cfg.MaxTTL. Names that don't
+// exist or don't have any address will be cached for cfg.NegativeTTL.
+// Concurrent lookups of the same name will only reach the Resolver once
+func LRU(resolver Resolver, cfg LRUConfig) Resolver {
+	return &lru{
+		resolver: resolver,
+		cfg:      cfg,
+		entries:  make(map[string]*list.Element, cfg.MaxSize),
+		order:    list.New(),
+		reverse:  make(map[IPMark]*list.Element, cfg.MaxSize),
+		calls:    make(map[string]*lruCall),
+		lock:     sync.Mutex{},
+	}
+}
+
+// copyAddresses copies addresses so the cached ones won't be modified
+func copyAddresses(addresses []net.IP) []net.IP {
+	result := make([]net.IP, len(addresses))
+
+	copy(result, addresses)
+
+	return result
+}
+
+func (l *lru) Resolve(host string) ([]net.IP, error) {
+	ip := net.ParseIP(host)
+
+	if ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	l.lock.Lock()
+
+	element, found := l.entries[host]
+
+	if found {
+		entry := element.Value.(*lruEntry)
+
+		if time.Now().Before(entry.expire) {
+			l.order.MoveToFront(element)
+
+			l.lock.Unlock()
+
+			if entry.err != nil {
+				return nil, entry.err
+			}
+
+			return copyAddresses(entry.addresses), nil
+		}
+	}
+
+	call, calling := l.calls[host]
+
+	if calling {
+		l.lock.Unlock()
+
+		<-call.done
+
+		if call.err != nil {
+			return nil, call.err
+		}
+
+		return copyAddresses(call.addresses), nil
+	}
+
+	call = &lruCall{
+		done:      make(chan struct{}),
+		addresses: nil,
+		err:       nil,
+	}
+
+	l.calls[host] = call
+
+	l.lock.Unlock()
+
+	var ttl time.Duration
+
+	ttlResolver, isTTLResolver := l.resolver.(TTLResolver)
+
+	if isTTLResolver {
+		call.addresses, ttl, call.err = ttlResolver.ResolveTTL(host)
+	} else {
+		call.addresses, call.err = l.resolver.Resolve(host)
+	}
+
+	l.lock.Lock()
+
+	delete(l.calls, host)
+
+	l.store(host, call.addresses, ttl, call.err)
+
+	l.lock.Unlock()
+
+	close(call.done)
+
+	if call.err != nil {
+		return nil, call.err
+	}
+
+	return copyAddresses(call.addresses), nil
+}
+
+// store caches the result. It must be called with the lock held
+func (l *lru) store(
+	host string,
+	addresses []net.IP,
+	ttl time.Duration,
+	err error,
+) {
+	switch err {
+	case nil:
+		if ttl < l.cfg.MinTTL {
+			ttl = l.cfg.MinTTL
+		} else if ttl > l.cfg.MaxTTL {
+			ttl = l.cfg.MaxTTL
+		}
+
+	case ErrDNSNameNotFound, ErrDNSResolvedNoResult:
+		ttl = l.cfg.NegativeTTL
+
+	default:
+		// Other errors (time out for example) are not definite answers
+		return
+	}
+
+	if ttl <= 0 || l.cfg.MaxSize <= 0 {
+		return
+	}
+
+	element, found := l.entries[host]
+
+	if found {
+		l.remove(element)
+	}
+
+	for uint32(l.order.Len()) >= l.cfg.MaxSize {
+		l.remove(l.order.Back())
+	}
+
+	element = l.order.PushFront(&lruEntry{
+		host:      host,
+		addresses: addresses,
+		err:       err,
+		expire:    time.Now().Add(ttl),
+	})
+
+	l.entries[host] = element
+
+	for aIdx := range addresses {
+		ipMark := IPMark{}
+		ipMark.Import(addresses[aIdx])
+
+		l.reverse[ipMark] = element
+	}
+}
+
+// remove removes the entry together with it's reverse records. It must be
+// called with the lock held
+func (l *lru) remove(element *list.Element) {
+	entry := element.Value.(*lruEntry)
+
+	for aIdx := range entry.addresses {
+		ipMark := IPMark{}
+		ipMark.Import(entry.addresses[aIdx])
+
+		// The address maybe taken over by another host
+		if l.reverse[ipMark] != element {
+			continue
+		}
+
+		delete(l.reverse, ipMark)
+	}
+
+	delete(l.entries, entry.host)
+
+	l.order.Remove(element)
+}
+
+func (l *lru) Preference() Preference {
+	preferred, isPreferred := l.resolver.(PreferredResolver)
+
+	if !isPreferred {
+		return PreferNone
+	}
+
+	return preferred.Preference()
+}
+
+func (l *lru) Reverse(ip net.IP) (string, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	ipMark := IPMark{}
+	ipMark.Import(ip)
+
+	element, found := l.reverse[ipMark]
+
+	if !found {
+		return "", ErrCachedReverseNoResult
+	}
+
+	return element.Value.(*lruEntry).host, nil
+}
@@ -0,0 +1,275 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package resolve
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type dummyTTLResolver struct {
+	records map[string][]net.IP
+	ttl     time.Duration
+	err     error
+	wait    chan struct{}
+	calls   uint32
+}
+
+func (d *dummyTTLResolver) Resolve(host string) ([]net.IP, error) {
+	resolved, _, err := d.ResolveTTL(host)
+
+	return resolved, err
+}
+
+func (d *dummyTTLResolver) ResolveTTL(
+	host string,
+) ([]net.IP, time.Duration, error) {
+	atomic.AddUint32(&d.calls, 1)
+
+	if d.wait != nil {
+		<-d.wait
+	}
+
+	if d.err != nil {
+		return nil, 0, d.err
+	}
+
+	resolved, found := d.records[host]
+
+	if !found {
+		return nil, 0, ErrDNSNameNotFound
+	}
+
+	return resolved, d.ttl, nil
+}
+
+func (d *dummyTTLResolver) Reverse(ip net.IP) (string, error) {
+	return "", ErrDNSReverseUnsupported
+}
+
+func testLRUResolver() *dummyTTLResolver {
+	return &dummyTTLResolver{
+		records: map[string][]net.IP{
+			"a.test": {net.ParseIP("10.0.0.1")},
+			"b.test": {net.ParseIP("10.0.0.2")},
+			"c.test": {net.ParseIP("10.0.0.3").To4()},
+			"d.test": {net.ParseIP("10.0.0.1")},
+		},
+		ttl:   time.Hour,
+		err:   nil,
+		wait:  nil,
+		calls: 0,
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	resolver := testLRUResolver()
+	cache := LRU(resolver, LRUConfig{
+		MaxSize:     2,
+		MinTTL:      time.Minute,
+		MaxTTL:      time.Hour,
+		NegativeTTL: time.Minute,
+	})
+
+	for _, host := range []string{"a.test", "b.test", "a.test", "c.test"} {
+		_, resolveErr := cache.Resolve(host)
+
+		if resolveErr != nil {
+			t.Errorf("Failed to resolve %s due to error: %s", host, resolveErr)
+
+			return
+		}
+	}
+
+	if resolver.calls != 3 {
+		t.Errorf("Expecting 3 lookups, got %d", resolver.calls)
+
+		return
+	}
+
+	_, reverseErr := cache.Reverse(net.ParseIP("10.0.0.2"))
+
+	if reverseErr != ErrCachedReverseNoResult {
+		t.Error("Expecting the evicted host to be removed from reverse map")
+
+		return
+	}
+
+	host, reverseErr := cache.Reverse(net.ParseIP("10.0.0.3"))
+
+	if reverseErr != nil || host != "c.test" {
+		t.Errorf("Expecting 10.0.0.3 to be reversed to c.test, got %s", host)
+
+		return
+	}
+
+	// d.test takes over the address of a.test, so evicting a.test must not
+	// remove the reverse record of d.test
+	cache.Resolve("d.test")
+	cache.Resolve("b.test")
+
+	host, reverseErr = cache.Reverse(net.ParseIP("10.0.0.1"))
+
+	if reverseErr != nil || host != "d.test" {
+		t.Errorf("Expecting 10.0.0.1 to be reversed to d.test, got %s", host)
+
+		return
+	}
+}
+
+func TestLRUTTL(t *testing.T) {
+	resolver := testLRUResolver()
+	resolver.ttl = time.Second
+	cache := LRU(resolver, LRUConfig{
+		MaxSize:     8,
+		MinTTL:      10 * time.Millisecond,
+		MaxTTL:      50 * time.Millisecond,
+		NegativeTTL: time.Hour,
+	})
+
+	cache.Resolve("a.test")
+	cache.Resolve("a.test")
+
+	if resolver.calls != 1 {
+		t.Errorf("Expecting 1 lookup before expire, got %d", resolver.calls)
+
+		return
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	cache.Resolve("a.test")
+
+	if resolver.calls != 2 {
+		t.Errorf("Expecting the TTL to be clamped to MaxTTL, got %d lookups",
+			resolver.calls)
+
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		_, resolveErr := cache.Resolve("missing.test")
+
+		if resolveErr != ErrDNSNameNotFound {
+			t.Error("Expecting ErrDNSNameNotFound, got", resolveErr)
+
+			return
+		}
+	}
+
+	if resolver.calls != 3 {
+		t.Errorf("Expecting the missing name to be cached, got %d lookups",
+			resolver.calls)
+
+		return
+	}
+
+	resolver.err = errors.New("Timeout")
+
+	cache.Resolve("b.test")
+	cache.Resolve("b.test")
+
+	if resolver.calls != 5 {
+		t.Errorf("Expecting failures to be not cached, got %d lookups",
+			resolver.calls)
+
+		return
+	}
+}
+
+func TestLRUConcurrentLookup(t *testing.T) {
+	resolver := testLRUResolver()
+	resolver.wait = make(chan struct{})
+	cache := LRU(resolver, LRUConfig{
+		MaxSize:     8,
+		MinTTL:      time.Minute,
+		MaxTTL:      time.Hour,
+		NegativeTTL: time.Minute,
+	})
+
+	wg := sync.WaitGroup{}
+	failed := uint32(0)
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			resolved, resolveErr := cache.Resolve("a.test")
+
+			if resolveErr != nil || len(resolved) != 1 {
+				atomic.AddUint32(&failed, 1)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	close(resolver.wait)
+
+	wg.Wait()
+
+	if failed > 0 {
+		t.Errorf("%d concurrent lookups have failed", failed)
+
+		return
+	}
+
+	if resolver.calls != 1 {
+		t.Errorf("Expecting concurrent lookups to be deduplicated, got %d "+
+			"lookups", resolver.calls)
+
+		return
+	}
+}
+
+func TestLRUPreference(t *testing.T) {
+	cfg := LRUConfig{
+		MaxSize:     2,
+		MinTTL:      time.Second,
+		MaxTTL:      time.Minute,
+		NegativeTTL: time.Second,
+	}
+
+	preferred, isPreferred := LRU(
+		Upstreams(nil, time.Second, PreferIPv4), cfg).(PreferredResolver)
+
+	if !isPreferred || preferred.Preference() != PreferIPv4 {
+		t.Error("Expecting the Preference of the cached Resolver to be " +
+			"passed through")
+
+		return
+	}
+
+	preferred, isPreferred = LRU(testLRUResolver(), cfg).(PreferredResolver)
+
+	if !isPreferred || preferred.Preference() != PreferNone {
+		t.Error("Expecting no Preference when the cached Resolver don't " +
+			"have one")
+
+		return
+	}
+}
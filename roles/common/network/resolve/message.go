@@ -0,0 +1,227 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package resolve
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+)
+
+// Errors
+var (
+	ErrDNSInvalidName = errors.New(
+		"Invalid DNS name")
+
+	ErrDNSInvalidResponse = errors.New(
+		"Invalid DNS response")
+
+	ErrDNSTruncated = errors.New(
+		"DNS response was truncated")
+
+	ErrDNSNameNotFound = errors.New(
+		"DNS name was not found")
+
+	ErrDNSServerFailure = errors.New(
+		"DNS server has failed to resolve the name")
+)
+
+// DNS message constants
+const (
+	dnsTypeA          uint16 = 1
+	dnsTypeAAAA       uint16 = 28
+	dnsClassINET      uint16 = 1
+	dnsHeaderLen             = 12
+	dnsMaxNameLen            = 253
+	dnsMaxLabelLen           = 63
+	dnsRcodeSuccess          = 0
+	dnsRcodeNameError        = 3
+)
+
+// dnsAnswer is the result of a parsed DNS response
+type dnsAnswer struct {
+	IPs []net.IP
+	TTL uint32
+}
+
+// dnsID generates a random DNS message ID
+func dnsID() uint16 {
+	id := [2]byte{}
+
+	rand.Read(id[:])
+
+	return binary.BigEndian.Uint16(id[:])
+}
+
+// dnsQuery builds a recursive DNS query message
+func dnsQuery(id uint16, name string, qType uint16) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+
+	if len(name) <= 0 || len(name) > dnsMaxNameLen {
+		return nil, ErrDNSInvalidName
+	}
+
+	msg := make([]byte, dnsHeaderLen, dnsHeaderLen+len(name)+6)
+
+	binary.BigEndian.PutUint16(msg[0:2], id)
+
+	// Recursion Desired
+	msg[2] = 0x01
+
+	// QDCOUNT
+	msg[5] = 1
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) <= 0 || len(label) > dnsMaxLabelLen {
+			return nil, ErrDNSInvalidName
+		}
+
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+
+	msg = append(msg, 0,
+		byte(qType>>8), byte(qType),
+		byte(dnsClassINET>>8), byte(dnsClassINET))
+
+	return msg, nil
+}
+
+// dnsSkipName skips a (maybe compressed) name in the message and returns
+// the offset right after it
+func dnsSkipName(msg []byte, offset int) (int, error) {
+	for {
+		if offset >= len(msg) {
+			return 0, ErrDNSInvalidResponse
+		}
+
+		labelLen := int(msg[offset])
+
+		switch {
+		case labelLen == 0:
+			return offset + 1, nil
+
+		case labelLen&0xC0 == 0xC0:
+			if offset+2 > len(msg) {
+				return 0, ErrDNSInvalidResponse
+			}
+
+			return offset + 2, nil
+
+		case labelLen&0xC0 != 0:
+			return 0, ErrDNSInvalidResponse
+
+		default:
+			offset += labelLen + 1
+		}
+	}
+}
+
+// dnsParse parses the response of a query, and collects the addresses of
+// qType in it's answer section
+func dnsParse(id uint16, qType uint16, msg []byte) (dnsAnswer, error) {
+	result := dnsAnswer{
+		IPs: nil,
+		TTL: 0,
+	}
+
+	if len(msg) < dnsHeaderLen ||
+		binary.BigEndian.Uint16(msg[0:2]) != id || msg[2]&0x80 == 0 {
+		return result, ErrDNSInvalidResponse
+	}
+
+	if msg[2]&0x02 != 0 {
+		return result, ErrDNSTruncated
+	}
+
+	switch msg[3] & 0x0F {
+	case dnsRcodeSuccess:
+
+	case dnsRcodeNameError:
+		return result, ErrDNSNameNotFound
+
+	default:
+		return result, ErrDNSServerFailure
+	}
+
+	questions := binary.BigEndian.Uint16(msg[4:6])
+	answers := binary.BigEndian.Uint16(msg[6:8])
+	offset := dnsHeaderLen
+
+	var skipErr error
+
+	for q := uint16(0); q < questions; q++ {
+		offset, skipErr = dnsSkipName(msg, offset)
+
+		if skipErr != nil {
+			return result, skipErr
+		}
+
+		offset += 4
+	}
+
+	addrLen := net.IPv4len
+
+	if qType == dnsTypeAAAA {
+		addrLen = net.IPv6len
+	}
+
+	for a := uint16(0); a < answers; a++ {
+		offset, skipErr = dnsSkipName(msg, offset)
+
+		if skipErr != nil {
+			return result, skipErr
+		}
+
+		if offset+10 > len(msg) {
+			return result, ErrDNSInvalidResponse
+		}
+
+		rrType := binary.BigEndian.Uint16(msg[offset : offset+2])
+		rrClass := binary.BigEndian.Uint16(msg[offset+2 : offset+4])
+		rrTTL := binary.BigEndian.Uint32(msg[offset+4 : offset+8])
+		rrLen := int(binary.BigEndian.Uint16(msg[offset+8 : offset+10]))
+
+		offset += 10
+
+		if offset+rrLen > len(msg) {
+			return result, ErrDNSInvalidResponse
+		}
+
+		if rrType == qType && rrClass == dnsClassINET && rrLen == addrLen {
+			ip := make(net.IP, addrLen)
+
+			copy(ip, msg[offset:offset+rrLen])
+
+			if len(result.IPs) <= 0 || rrTTL < result.TTL {
+				result.TTL = rrTTL
+			}
+
+			result.IPs = append(result.IPs, ip)
+		}
+
+		offset += rrLen
+	}
+
+	return result, nil
+}
@@ -20,7 +20,10 @@
 
 package resolve
 
-import "net"
+import (
+	"net"
+	"time"
+)
 
 // Resolver is the Host name Resolver
 type Resolver interface {
@@ -28,11 +31,30 @@ type Resolver interface {
 	Reverse(ip net.IP) (string, error)
 }
 
+// TTLResolver is a Resolver which also tells how long the resolved
+// addresses can be cached. Zero TTL means it's unknown
+type TTLResolver interface {
+	Resolver
+
+	ResolveTTL(host string) ([]net.IP, time.Duration, error)
+}
+
+// PreferredResolver is a Resolver which tells the address family that it
+// prefers
+type PreferredResolver interface {
+	Resolver
+
+	Preference() Preference
+}
+
 // IPMark is the Mark for IP address
 type IPMark [16]byte
 
-// Import imports the marker from an IP Address
+// Import imports the marker from an IP Address. IPv4 addresses in both
+// 4 and 16 bytes form will be imported as the same marker
 func (i *IPMark) Import(ip net.IP) {
+	ip = ip.To16()
+
 	for idx := range ip {
 		i[idx] = ip[idx]
 	}
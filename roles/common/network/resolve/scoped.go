@@ -0,0 +1,118 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package resolve
+
+import (
+	"net"
+	"sync"
+)
+
+type scoped struct {
+	resolver Resolver
+	hosts    map[string][]IPMark
+	reverse  map[IPMark]string
+	maxSize  uint32
+	lock     sync.RWMutex
+}
+
+// Scoped returns a Resolver which resolves through the given (usually
+// shared) Resolver, but only reverses the addresses resolved by itself.
+// At most maxSize host names can be resolved through it.
+//
+// Every lookup still reaches the given Resolver, so its caching rules
+// (like TTL) stay in effect
+func Scoped(resolver Resolver, maxSize uint32) Resolver {
+	return &scoped{
+		resolver: resolver,
+		hosts:    make(map[string][]IPMark, maxSize),
+		reverse:  make(map[IPMark]string, maxSize),
+		maxSize:  maxSize,
+		lock:     sync.RWMutex{},
+	}
+}
+
+func (s *scoped) Resolve(host string) ([]net.IP, error) {
+	s.lock.RLock()
+
+	_, found := s.hosts[host]
+
+	if !found && uint32(len(s.hosts)) >= s.maxSize {
+		s.lock.RUnlock()
+
+		return nil, ErrCachedCacheTooMany
+	}
+
+	s.lock.RUnlock()
+
+	resolved, resolveErr := s.resolver.Resolve(host)
+
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+
+	if len(resolved) <= 0 {
+		return nil, ErrCachedResolveNoResult
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found = s.hosts[host]; !found &&
+		uint32(len(s.hosts)) >= s.maxSize {
+		return nil, ErrCachedCacheTooMany
+	}
+
+	for _, ipMark := range s.hosts[host] {
+		if s.reverse[ipMark] != host {
+			continue
+		}
+
+		delete(s.reverse, ipMark)
+	}
+
+	ipMarks := make([]IPMark, len(resolved))
+
+	for rIdx := range resolved {
+		ipMarks[rIdx].Import(resolved[rIdx])
+
+		s.reverse[ipMarks[rIdx]] = host
+	}
+
+	s.hosts[host] = ipMarks
+
+	return resolved, nil
+}
+
+func (s *scoped) Reverse(ip net.IP) (string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	ipMark := IPMark{}
+	ipMark.Import(ip)
+
+	host, found := s.reverse[ipMark]
+
+	if !found {
+		return "", ErrCachedReverseNoResult
+	}
+
+	return host, nil
+}
@@ -0,0 +1,111 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package resolve
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+type dummyScopedResolver map[string][]net.IP
+
+func (d dummyScopedResolver) Resolve(host string) ([]net.IP, error) {
+	ips, found := d[host]
+
+	if !found {
+		return nil, errors.New("Not found")
+	}
+
+	return ips, nil
+}
+
+func (d dummyScopedResolver) Reverse(ip net.IP) (string, error) {
+	for host, ips := range d {
+		for _, hostIP := range ips {
+			if hostIP.Equal(ip) {
+				return host, nil
+			}
+		}
+	}
+
+	return "", errors.New("Not found")
+}
+
+func TestScoped(t *testing.T) {
+	shared := dummyScopedResolver{
+		"a.test": []net.IP{net.ParseIP("192.0.2.1")},
+		"b.test": []net.IP{net.ParseIP("192.0.2.2")},
+		"c.test": []net.IP{net.ParseIP("192.0.2.3")},
+	}
+
+	s1 := Scoped(shared, 2)
+	s2 := Scoped(shared, 2)
+
+	if _, err := s1.Resolve("a.test"); err != nil {
+		t.Error("Failed to resolve due to error:", err)
+
+		return
+	}
+
+	if _, err := s2.Resolve("b.test"); err != nil {
+		t.Error("Failed to resolve due to error:", err)
+
+		return
+	}
+
+	if host, err := s1.Reverse(net.ParseIP("192.0.2.1")); err != nil ||
+		host != "a.test" {
+		t.Errorf("Expecting \"a.test\", got %q (%v)", host, err)
+
+		return
+	}
+
+	if _, err := s1.Reverse(net.ParseIP("192.0.2.2")); err == nil {
+		t.Error("Addresses resolved by other Resolvers must not be reversed")
+
+		return
+	}
+
+	if _, err := s2.Reverse(net.ParseIP("192.0.2.1")); err == nil {
+		t.Error("Addresses resolved by other Resolvers must not be reversed")
+
+		return
+	}
+
+	if _, err := s1.Resolve("b.test"); err != nil {
+		t.Error("Failed to resolve due to error:", err)
+
+		return
+	}
+
+	if _, err := s1.Resolve("c.test"); err != ErrCachedCacheTooMany {
+		t.Error("Resolving too many hosts must cause ErrCachedCacheTooMany")
+
+		return
+	}
+
+	if _, err := s1.Resolve("a.test"); err != nil {
+		t.Error("Resolving a known host must not be limited:", err)
+
+		return
+	}
+}
@@ -0,0 +1,551 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Errors
+var (
+	ErrUpstreamInvalidAddress = errors.New(
+		"Invalid upstream DNS server address")
+
+	ErrUpstreamUnsupportedProtocol = errors.New(
+		"Unsupported upstream DNS server protocol")
+
+	ErrUpstreamHTTPFailed = errors.New(
+		"Upstream DNS server responded with a non-OK HTTP status")
+)
+
+// UpstreamProtocol is the protocol that used to talk to an upstream DNS
+// server
+type UpstreamProtocol uint8
+
+// Upstream protocols
+const (
+	UpstreamUDP UpstreamProtocol = iota
+	UpstreamTCP
+	UpstreamTLS
+	UpstreamHTTPS
+)
+
+// Preference of the address family
+type Preference uint8
+
+// Preferences
+const (
+	PreferNone Preference = iota
+	PreferIPv4
+	PreferIPv6
+)
+
+const (
+	upstreamMaxMessageSize = 65535
+	upstreamUDPBufferSize  = 1500
+)
+
+// Upstream is an upstream DNS server
+type Upstream struct {
+	Protocol  UpstreamProtocol
+	Address   string
+	tlsConfig *tls.Config
+	client    *http.Client
+}
+
+type upstreams struct {
+	servers []Upstream
+	timeout time.Duration
+	prefer  Preference
+	system  net.Resolver
+}
+
+type upstreamResult struct {
+	answer dnsAnswer
+	err    error
+}
+
+// ParseUpstream parses an upstream DNS server address in one of following
+// formats:
+//
+//	8.8.8.8, udp://8.8.8.8:53     Plain DNS over UDP
+//	tcp://8.8.8.8:53              Plain DNS over TCP
+//	tls://dns.google:853          DNS-over-TLS
+//	https://dns.google/dns-query  DNS-over-HTTPS
+//
+// The port can be omitted, then the default port of the protocol will
+// be used
+func ParseUpstream(address string) (Upstream, error) {
+	result := Upstream{
+		Protocol:  UpstreamUDP,
+		Address:   "",
+		tlsConfig: nil,
+		client:    nil,
+	}
+
+	if !strings.Contains(address, "://") {
+		address = "udp://" + address
+	}
+
+	u, parseErr := url.Parse(address)
+
+	if parseErr != nil || u.Host == "" {
+		return result, ErrUpstreamInvalidAddress
+	}
+
+	defaultPort := "53"
+
+	switch u.Scheme {
+	case "udp":
+		result.Protocol = UpstreamUDP
+
+	case "tcp":
+		result.Protocol = UpstreamTCP
+
+	case "tls":
+		result.Protocol = UpstreamTLS
+		defaultPort = "853"
+
+	case "https":
+		result.Protocol = UpstreamHTTPS
+		result.Address = u.String()
+
+		return result, nil
+
+	default:
+		return result, ErrUpstreamUnsupportedProtocol
+	}
+
+	if u.Path != "" || u.RawQuery != "" {
+		return result, ErrUpstreamInvalidAddress
+	}
+
+	if u.Port() == "" {
+		result.Address = net.JoinHostPort(u.Hostname(), defaultPort)
+	} else {
+		result.Address = u.Host
+	}
+
+	return result, nil
+}
+
+// Upstreams returns a Resolver which resolves names through the given
+// upstream DNS servers.
+//
+// Servers are tried in the given order, next server will only be used when
+// the previous one has failed to respond or has failed to resolve within
+// the timeout. Names that are reported as not exist will not be retried.
+//
+// If no server is given, the system resolver will be used instead. The
+// resolved addresses will be sorted according to the Preference
+func Upstreams(
+	servers []Upstream,
+	timeout time.Duration,
+	prefer Preference,
+) Resolver {
+	u := &upstreams{
+		servers: make([]Upstream, len(servers)),
+		timeout: timeout,
+		prefer:  prefer,
+		system: net.Resolver{
+			PreferGo:     true,
+			StrictErrors: false,
+			Dial:         nil,
+		},
+	}
+
+	copy(u.servers, servers)
+
+	for sIdx := range u.servers {
+		if u.servers[sIdx].Protocol != UpstreamHTTPS {
+			continue
+		}
+
+		u.servers[sIdx].client = &http.Client{
+			Transport: &http.Transport{
+				Proxy:             nil,
+				TLSClientConfig:   u.servers[sIdx].tlsConfig,
+				ForceAttemptHTTP2: true,
+				IdleConnTimeout:   timeout * 10,
+			},
+			Timeout: timeout,
+		}
+	}
+
+	return u
+}
+
+func (u *upstreams) Resolve(host string) ([]net.IP, error) {
+	resolved, _, resolveErr := u.ResolveTTL(host)
+
+	return resolved, resolveErr
+}
+
+func (u *upstreams) ResolveTTL(host string) ([]net.IP, time.Duration, error) {
+	ip := net.ParseIP(host)
+
+	if ip != nil {
+		return []net.IP{ip}, 0, nil
+	}
+
+	var answer dnsAnswer
+	var resolveErr error
+
+	if len(u.servers) <= 0 {
+		answer, resolveErr = u.lookupSystem(host)
+	} else {
+		answer, resolveErr = u.lookupUpstreams(host)
+	}
+
+	if resolveErr != nil {
+		return nil, 0, resolveErr
+	}
+
+	u.sort(answer.IPs)
+
+	return answer.IPs, time.Duration(answer.TTL) * time.Second, nil
+}
+
+func (u *upstreams) Reverse(ip net.IP) (string, error) {
+	return "", ErrDNSReverseUnsupported
+}
+
+func (u *upstreams) Preference() Preference {
+	return u.prefer
+}
+
+// sort moves the addresses of the preferred family to the front
+func (u *upstreams) sort(ips []net.IP) {
+	if u.prefer == PreferNone {
+		return
+	}
+
+	preferred := func(ip net.IP) bool {
+		return (ip.To4() != nil) == (u.prefer == PreferIPv4)
+	}
+
+	sort.SliceStable(ips, func(i, j int) bool {
+		return preferred(ips[i]) && !preferred(ips[j])
+	})
+}
+
+// lookupSystem resolves the host through the system resolver, which
+// don't tell us the TTL
+func (u *upstreams) lookupSystem(host string) (dnsAnswer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), u.timeout)
+
+	defer cancel()
+
+	resolved, resolveErr := u.system.LookupIPAddr(ctx, host)
+
+	if resolveErr != nil {
+		return dnsAnswer{}, resolveErr
+	}
+
+	if len(resolved) <= 0 {
+		return dnsAnswer{}, ErrDNSResolvedNoResult
+	}
+
+	answer := dnsAnswer{
+		IPs: make([]net.IP, len(resolved)),
+		TTL: 0,
+	}
+
+	for i := range resolved {
+		answer.IPs[i] = resolved[i].IP
+	}
+
+	return answer, nil
+}
+
+// lookupUpstreams resolves the host through upstream servers one by one
+// until one of them gives a definite answer
+func (u *upstreams) lookupUpstreams(host string) (dnsAnswer, error) {
+	var lastErr error
+
+	for sIdx := range u.servers {
+		answer, lookupErr := u.lookup(&u.servers[sIdx], host)
+
+		switch lookupErr {
+		case nil:
+			return answer, nil
+
+		case ErrDNSNameNotFound, ErrDNSResolvedNoResult, ErrDNSInvalidName:
+			return dnsAnswer{}, lookupErr
+		}
+
+		lastErr = lookupErr
+	}
+
+	return dnsAnswer{}, lastErr
+}
+
+// lookup queries both A and AAAA records of the host from the server
+func (u *upstreams) lookup(server *Upstream, host string) (dnsAnswer, error) {
+	deadline := time.Now().Add(u.timeout)
+	results := make(chan upstreamResult, 2)
+
+	for _, qType := range []uint16{dnsTypeA, dnsTypeAAAA} {
+		go func(qType uint16) {
+			answer, err := server.lookup(host, qType, deadline)
+
+			results <- upstreamResult{
+				answer: answer,
+				err:    err,
+			}
+		}(qType)
+	}
+
+	var lookupErr error
+
+	answer := dnsAnswer{
+		IPs: nil,
+		TTL: 0,
+	}
+
+	for i := 0; i < 2; i++ {
+		result := <-results
+
+		if result.err != nil {
+			if lookupErr == nil || result.err == ErrDNSNameNotFound {
+				lookupErr = result.err
+			}
+
+			continue
+		}
+
+		if len(result.answer.IPs) <= 0 {
+			continue
+		}
+
+		if len(answer.IPs) <= 0 || result.answer.TTL < answer.TTL {
+			answer.TTL = result.answer.TTL
+		}
+
+		answer.IPs = append(answer.IPs, result.answer.IPs...)
+	}
+
+	if len(answer.IPs) > 0 && lookupErr != ErrDNSNameNotFound {
+		return answer, nil
+	}
+
+	if lookupErr != nil {
+		return dnsAnswer{}, lookupErr
+	}
+
+	return dnsAnswer{}, ErrDNSResolvedNoResult
+}
+
+// lookup sends one query to the server and parses it's response
+func (s *Upstream) lookup(
+	host string,
+	qType uint16,
+	deadline time.Time,
+) (dnsAnswer, error) {
+	id := uint16(0)
+
+	// RFC 8484 recommends to use 0 as the ID for better HTTP caching
+	if s.Protocol != UpstreamHTTPS {
+		id = dnsID()
+	}
+
+	query, queryErr := dnsQuery(id, host, qType)
+
+	if queryErr != nil {
+		return dnsAnswer{}, queryErr
+	}
+
+	var response []byte
+	var exchangeErr error
+
+	switch s.Protocol {
+	case UpstreamUDP:
+		response, exchangeErr = s.exchangeUDP(query, deadline)
+
+	case UpstreamTCP, UpstreamTLS:
+		response, exchangeErr = s.exchangeStream(query, deadline)
+
+	case UpstreamHTTPS:
+		response, exchangeErr = s.exchangeHTTPS(query, deadline)
+
+	default:
+		exchangeErr = ErrUpstreamUnsupportedProtocol
+	}
+
+	if exchangeErr != nil {
+		return dnsAnswer{}, exchangeErr
+	}
+
+	answer, parseErr := dnsParse(id, qType, response)
+
+	if parseErr != ErrDNSTruncated || s.Protocol != UpstreamUDP {
+		return answer, parseErr
+	}
+
+	// Response is too large for UDP, retry with TCP
+	response, exchangeErr = s.exchangeStream(query, deadline)
+
+	if exchangeErr != nil {
+		return dnsAnswer{}, exchangeErr
+	}
+
+	return dnsParse(id, qType, response)
+}
+
+// exchangeUDP sends the query and receives the response through UDP
+func (s *Upstream) exchangeUDP(
+	query []byte,
+	deadline time.Time,
+) ([]byte, error) {
+	conn, dialErr := net.DialTimeout("udp", s.Address, time.Until(deadline))
+
+	if dialErr != nil {
+		return nil, dialErr
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(deadline)
+
+	_, wErr := conn.Write(query)
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	buf := make([]byte, upstreamUDPBufferSize)
+
+	for {
+		rLen, rErr := conn.Read(buf)
+
+		if rErr != nil {
+			return nil, rErr
+		}
+
+		// Ignore responses to other queries, they maybe spoofed or late
+		if rLen < 2 || !bytes.Equal(buf[:2], query[:2]) {
+			continue
+		}
+
+		return buf[:rLen], nil
+	}
+}
+
+// exchangeStream sends the query and receives the response through TCP or
+// TLS, where the messages are prefixed by their length
+func (s *Upstream) exchangeStream(
+	query []byte,
+	deadline time.Time,
+) ([]byte, error) {
+	var conn net.Conn
+	var dialErr error
+
+	dialer := &net.Dialer{
+		Deadline: deadline,
+	}
+
+	if s.Protocol == UpstreamTLS {
+		conn, dialErr = tls.DialWithDialer(
+			dialer, "tcp", s.Address, s.tlsConfig)
+	} else {
+		conn, dialErr = dialer.Dial("tcp", s.Address)
+	}
+
+	if dialErr != nil {
+		return nil, dialErr
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(deadline)
+
+	buf := make([]byte, 2, len(query)+2)
+
+	binary.BigEndian.PutUint16(buf, uint16(len(query)))
+
+	_, wErr := conn.Write(append(buf, query...))
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	_, rErr := io.ReadFull(conn, buf[:2])
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	response := make([]byte, binary.BigEndian.Uint16(buf[:2]))
+
+	_, rErr = io.ReadFull(conn, response)
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	return response, nil
+}
+
+// exchangeHTTPS sends the query and receives the response through HTTPS
+// as defined in RFC 8484
+func (s *Upstream) exchangeHTTPS(
+	query []byte,
+	deadline time.Time,
+) ([]byte, error) {
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+
+	defer cancel()
+
+	req, reqErr := http.NewRequestWithContext(
+		ctx, http.MethodPost, s.Address, bytes.NewReader(query))
+
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, respErr := s.client.Do(req)
+
+	if respErr != nil {
+		return nil, respErr
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrUpstreamHTTPFailed
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, upstreamMaxMessageSize))
+}
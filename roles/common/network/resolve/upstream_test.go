@@ -0,0 +1,402 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package resolve
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type dummyDNSStub struct {
+	records   map[string][]net.IP
+	truncated map[string]bool
+}
+
+// respond builds the response of a query which only has one question
+func (d dummyDNSStub) respond(query []byte, udp bool) []byte {
+	labels := []string{}
+	offset := dnsHeaderLen
+
+	for query[offset] != 0 {
+		labels = append(labels,
+			string(query[offset+1:offset+1+int(query[offset])]))
+
+		offset += int(query[offset]) + 1
+	}
+
+	qType := binary.BigEndian.Uint16(query[offset+1 : offset+3])
+	name := strings.Join(labels, ".")
+	response := append([]byte{}, query[:offset+5]...)
+
+	// QR, RD, RA
+	response[2] = 0x81
+	response[3] = 0x80
+
+	ips, found := d.records[name]
+
+	if !found {
+		response[3] |= dnsRcodeNameError
+
+		return response
+	}
+
+	if udp && d.truncated[name] {
+		response[2] |= 0x02
+
+		return response
+	}
+
+	answers := uint16(0)
+
+	for _, ip := range ips {
+		rData := ip.To4()
+		rType := dnsTypeA
+
+		if rData == nil {
+			rData = ip.To16()
+			rType = dnsTypeAAAA
+		}
+
+		if rType != qType {
+			continue
+		}
+
+		response = append(response, 0xC0, dnsHeaderLen,
+			byte(rType>>8), byte(rType), 0, 1, 0, 0, 0, 60,
+			0, byte(len(rData)))
+		response = append(response, rData...)
+
+		answers++
+	}
+
+	binary.BigEndian.PutUint16(response[6:8], answers)
+
+	return response
+}
+
+func (d dummyDNSStub) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, 512)
+
+	for {
+		rLen, addr, rErr := conn.ReadFrom(buf)
+
+		if rErr != nil {
+			return
+		}
+
+		conn.WriteTo(d.respond(buf[:rLen], true), addr)
+	}
+}
+
+func (d dummyDNSStub) serveStream(listener net.Listener) {
+	for {
+		conn, acceptErr := listener.Accept()
+
+		if acceptErr != nil {
+			return
+		}
+
+		go func(conn net.Conn) {
+			defer conn.Close()
+
+			lenBuf := make([]byte, 2)
+
+			for {
+				_, rErr := io.ReadFull(conn, lenBuf)
+
+				if rErr != nil {
+					return
+				}
+
+				query := make([]byte, binary.BigEndian.Uint16(lenBuf))
+
+				_, rErr = io.ReadFull(conn, query)
+
+				if rErr != nil {
+					return
+				}
+
+				response := d.respond(query, false)
+
+				binary.BigEndian.PutUint16(lenBuf, uint16(len(response)))
+
+				conn.Write(append(lenBuf, response...))
+			}
+		}(conn)
+	}
+}
+
+func (d dummyDNSStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query, rErr := io.ReadAll(r.Body)
+
+	if rErr != nil || r.Header.Get("Content-Type") != "application/dns-message" {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/dns-message")
+	w.Write(d.respond(query, false))
+}
+
+func testDNSStub() dummyDNSStub {
+	return dummyDNSStub{
+		records: map[string][]net.IP{
+			"host.test": {
+				net.ParseIP("10.0.0.1"),
+				net.ParseIP("fd00::1"),
+				net.ParseIP("10.0.0.2"),
+			},
+			"big.test": {
+				net.ParseIP("10.0.0.3"),
+			},
+			"empty.test": {},
+		},
+		truncated: map[string]bool{
+			"big.test": true,
+		},
+	}
+}
+
+func TestParseUpstream(t *testing.T) {
+	for _, test := range []struct {
+		Input    string
+		Protocol UpstreamProtocol
+		Address  string
+	}{
+		{"8.8.8.8", UpstreamUDP, "8.8.8.8:53"},
+		{"udp://8.8.8.8:5353", UpstreamUDP, "8.8.8.8:5353"},
+		{"tcp://[2001:db8::1]", UpstreamTCP, "[2001:db8::1]:53"},
+		{"tls://dns.google", UpstreamTLS, "dns.google:853"},
+		{"https://dns.google/dns-query", UpstreamHTTPS,
+			"https://dns.google/dns-query"},
+	} {
+		upstream, parseErr := ParseUpstream(test.Input)
+
+		if parseErr != nil {
+			t.Errorf("Failed to parse %s due to error: %s",
+				test.Input, parseErr)
+
+			return
+		}
+
+		if upstream.Protocol != test.Protocol ||
+			upstream.Address != test.Address {
+			t.Errorf("Expecting %s to be parsed as %d %s, got %d %s",
+				test.Input, test.Protocol, test.Address,
+				upstream.Protocol, upstream.Address)
+
+			return
+		}
+	}
+
+	for _, invalid := range []string{"", "ftp://8.8.8.8", "tcp://8.8.8.8/a"} {
+		_, parseErr := ParseUpstream(invalid)
+
+		if parseErr == nil {
+			t.Errorf("Expecting %s to be invalid", invalid)
+
+			return
+		}
+	}
+}
+
+func TestUpstreamsResolve(t *testing.T) {
+	stub := testDNSStub()
+
+	streamListener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Error("Failed to listen TCP due to error:", listenErr)
+
+		return
+	}
+
+	defer streamListener.Close()
+
+	go stub.serveStream(streamListener)
+
+	// UDP must listen on the same port as TCP for the truncated response
+	// to be retried
+	packetConn, listenErr := net.ListenPacket(
+		"udp", streamListener.Addr().String())
+
+	if listenErr != nil {
+		t.Error("Failed to listen UDP due to error:", listenErr)
+
+		return
+	}
+
+	defer packetConn.Close()
+
+	go stub.serveUDP(packetConn)
+
+	httpsServer := httptest.NewTLSServer(stub)
+
+	defer httpsServer.Close()
+
+	clientTLS := httpsServer.Client().Transport.(*http.Transport).
+		TLSClientConfig
+
+	tlsListener, listenErr := tls.Listen(
+		"tcp", "127.0.0.1:0", httpsServer.TLS)
+
+	if listenErr != nil {
+		t.Error("Failed to listen TLS due to error:", listenErr)
+
+		return
+	}
+
+	defer tlsListener.Close()
+
+	go stub.serveStream(tlsListener)
+
+	for _, server := range []Upstream{
+		{UpstreamUDP, packetConn.LocalAddr().String(), nil, nil},
+		{UpstreamTCP, streamListener.Addr().String(), nil, nil},
+		{UpstreamTLS, tlsListener.Addr().String(), clientTLS, nil},
+		{UpstreamHTTPS, httpsServer.URL + "/dns-query", clientTLS, nil},
+	} {
+		resolver := Upstreams([]Upstream{server}, time.Second, PreferIPv6)
+
+		resolved, resolveErr := resolver.Resolve("host.test")
+
+		if resolveErr != nil {
+			t.Errorf("Failed to resolve through %s due to error: %s",
+				server.Address, resolveErr)
+
+			return
+		}
+
+		if len(resolved) != 3 || !resolved[0].Equal(net.ParseIP("fd00::1")) {
+			t.Errorf("Expecting 3 addresses with the IPv6 one first "+
+				"through %s, got %s", server.Address, resolved)
+
+			return
+		}
+
+		resolved, resolveErr = resolver.Resolve("big.test")
+
+		if resolveErr != nil || len(resolved) != 1 ||
+			!resolved[0].Equal(net.ParseIP("10.0.0.3")) {
+			t.Errorf("Expecting big.test to be resolved through %s, "+
+				"got %s and error %v", server.Address, resolved, resolveErr)
+
+			return
+		}
+
+		_, resolveErr = resolver.Resolve("missing.test")
+
+		if resolveErr != ErrDNSNameNotFound {
+			t.Errorf("Expecting ErrDNSNameNotFound through %s, got %v",
+				server.Address, resolveErr)
+
+			return
+		}
+
+		_, resolveErr = resolver.Resolve("empty.test")
+
+		if resolveErr != ErrDNSResolvedNoResult {
+			t.Errorf("Expecting ErrDNSResolvedNoResult through %s, got %v",
+				server.Address, resolveErr)
+
+			return
+		}
+	}
+}
+
+func TestUpstreamsFailover(t *testing.T) {
+	stub := testDNSStub()
+
+	// A port that nobody listens on
+	closedConn, listenErr := net.ListenPacket("udp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Error("Failed to listen UDP due to error:", listenErr)
+
+		return
+	}
+
+	closedAddr := closedConn.LocalAddr().String()
+
+	closedConn.Close()
+
+	packetConn, listenErr := net.ListenPacket("udp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Error("Failed to listen UDP due to error:", listenErr)
+
+		return
+	}
+
+	defer packetConn.Close()
+
+	go stub.serveUDP(packetConn)
+
+	resolver := Upstreams([]Upstream{
+		{UpstreamUDP, closedAddr, nil, nil},
+		{UpstreamUDP, packetConn.LocalAddr().String(), nil, nil},
+	}, 300*time.Millisecond, PreferIPv4)
+
+	resolved, resolveErr := resolver.Resolve("host.test")
+
+	if resolveErr != nil {
+		t.Error("Failed to resolve due to error:", resolveErr)
+
+		return
+	}
+
+	if len(resolved) != 3 || resolved[0].To4() == nil ||
+		resolved[1].To4() == nil || resolved[2].To4() != nil {
+		t.Errorf("Expecting 3 addresses with IPv4 ones first, got %s",
+			resolved)
+
+		return
+	}
+
+	resolved, resolveErr = resolver.Resolve("127.0.0.9")
+
+	if resolveErr != nil || len(resolved) != 1 ||
+		!resolved[0].Equal(net.ParseIP("127.0.0.9")) {
+		t.Errorf("Expecting IP address to be returned as is, got %s and "+
+			"error %v", resolved, resolveErr)
+
+		return
+	}
+
+	_, resolveErr = Upstreams([]Upstream{
+		{UpstreamUDP, closedAddr, nil, nil},
+		{UpstreamTCP, closedAddr, nil, nil},
+	}, 300*time.Millisecond, PreferNone).Resolve("host.test")
+
+	if resolveErr == nil {
+		t.Error("Expecting an error when all upstream servers have failed")
+
+		return
+	}
+}
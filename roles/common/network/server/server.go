@@ -38,6 +38,9 @@ var (
 
 	ErrNotServing = errors.New(
 		"Not serving")
+
+	ErrDrainTimeout = errors.New(
+		"Timeout before all clients are disconnected")
 )
 
 // client registeration data
@@ -61,7 +64,9 @@ type server struct {
 	cfg        Config
 	accept     chan network.Connection
 	leave      chan leave
+	drain      chan chan struct{}
 	serving    bool
+	draining   bool
 	downLock   sync.Mutex
 	downWait   sync.WaitGroup
 	downNotify chan struct{}
@@ -88,7 +93,9 @@ func New(
 		cfg:        cfg,
 		accept:     make(chan network.Connection),
 		leave:      make(chan leave, cfg.MaxConnections),
+		drain:      make(chan chan struct{}),
 		serving:    false,
+		draining:   false,
 		downLock:   sync.Mutex{},
 		downNotify: make(chan struct{}, 1),
 		downWait:   sync.WaitGroup{},
@@ -111,9 +118,25 @@ func (s *server) acceptor() {
 	closing := false
 	currentClients := uint64(0)
 	maxClients := uint64(s.cfg.MaxConnections)
+	drainWaiters := []chan struct{}{}
+
+	defer func() {
+		for wIdx := range drainWaiters {
+			close(drainWaiters[wIdx])
+		}
+	}()
 
 	for {
 		select {
+		case w := <-s.drain:
+			if len(clients) <= 0 {
+				close(w)
+
+				continue
+			}
+
+			drainWaiters = append(drainWaiters, w)
+
 		case <-downNotify:
 			downNotify <- struct{}{}
 
@@ -165,7 +188,9 @@ func (s *server) acceptor() {
 			}
 
 			runResult, runJoinErr := s.runner.Run(
-				s.logger.Context("Client ("+cl.RemoteAddr().String()+")"),
+				logger.WithField(
+					s.logger.Context("Client ("+cl.RemoteAddr().String()+")"),
+					logger.FieldConnection, string(cl.ID())),
 				h.Handle,
 				cl.Closed())
 
@@ -217,6 +242,14 @@ func (s *server) acceptor() {
 					cl.Connection.RemoteAddr())
 			}
 
+			if len(clients) <= 0 {
+				for wIdx := range drainWaiters {
+					close(drainWaiters[wIdx])
+				}
+
+				drainWaiters = drainWaiters[:0]
+			}
+
 			if !closing {
 				continue
 			}
@@ -300,6 +333,52 @@ func (s serving) Listening() net.Addr {
 	return s.accepter.Addr()
 }
 
+// Drain stops accepting new connections, and waits until all existing
+// clients are disconnected or the timeout is reached. Server must still be
+// Closed after Drain
+func (s serving) Drain(timeout time.Duration) error {
+	s.server.downLock.Lock()
+
+	if !s.server.serving {
+		s.server.downLock.Unlock()
+
+		return ErrNotServing
+	}
+
+	if !s.server.draining {
+		s.server.draining = true
+
+		cErr := s.accepter.Close()
+
+		if cErr != nil {
+			s.server.downLock.Unlock()
+
+			return cErr
+		}
+	}
+
+	s.server.downLock.Unlock()
+
+	drained := make(chan struct{})
+	timer := time.NewTimer(timeout)
+
+	defer timer.Stop()
+
+	select {
+	case s.server.drain <- drained:
+	case <-timer.C:
+		return ErrDrainTimeout
+	}
+
+	select {
+	case <-drained:
+		return nil
+
+	case <-timer.C:
+		return ErrDrainTimeout
+	}
+}
+
 // Close shutdown current server
 func (s serving) Close() error {
 	s.server.downLock.Lock()
@@ -315,15 +394,18 @@ func (s serving) Close() error {
 		<-s.server.downNotify
 	}()
 
-	cErr := s.accepter.Close()
+	if !s.server.draining {
+		cErr := s.accepter.Close()
 
-	if cErr != nil {
-		return cErr
+		if cErr != nil {
+			return cErr
+		}
 	}
 
 	s.server.downWait.Wait()
 
 	s.server.serving = false
+	s.server.draining = false
 
 	return nil
 }
@@ -170,3 +170,63 @@ func TestServerUpDown(t *testing.T) {
 		return
 	}
 }
+
+func TestServerDrain(t *testing.T) {
+	tk, tkErr := ticker.New(300, 1024).Serve()
+
+	if tkErr != nil {
+		t.Errorf("Failed to create ticker due to error: %s", tkErr)
+
+		return
+	}
+
+	r, rErr := worker.New(logger.NewDitch(), tk, worker.Config{
+		MaxWorkers:        1024,
+		MinWorkers:        32,
+		MaxWorkerIdle:     10 * time.Second,
+		JobReceiveTimeout: 5 * time.Second,
+	}).Serve()
+
+	if rErr != nil {
+		t.Error("Failed to start runner due to error:", rErr)
+
+		return
+	}
+
+	s := New(&dummyListener{}, &dummyIncoming{}, logger.NewDitch(), r, Config{
+		AcceptErrorWait: 1 * time.Second,
+		MaxConnections:  1024,
+	})
+
+	serve, serveErr := s.Serve()
+
+	if serveErr != nil {
+		t.Error("Failed to serve due to error:", serveErr)
+
+		return
+	}
+
+	drainErr := serve.Drain(1 * time.Second)
+
+	if drainErr != nil {
+		t.Error("Failed to drain due to error:", drainErr)
+
+		return
+	}
+
+	closeErr := serve.Close()
+
+	if closeErr != nil {
+		t.Error("Failed to close due to error:", closeErr)
+
+		return
+	}
+
+	drainErr = serve.Drain(1 * time.Second)
+
+	if drainErr != ErrNotServing {
+		t.Error("Drain a closed server must resulting ErrNotServing")
+
+		return
+	}
+}
@@ -103,6 +103,8 @@ type relay struct {
 	clientEnabled    chan io.ReadWriteCloser
 	closeSent        bool
 	completed        bool
+	traffic          *Traffic
+	splicer          Splicer
 }
 
 // New creates a new Relay
@@ -113,6 +115,22 @@ func New(
 	serverBuffer []byte,
 	clientBuilder Client,
 	clientBuffer []byte,
+) Relay {
+	return NewMetered(
+		log, runner, server, serverBuffer, clientBuilder, clientBuffer,
+		&Traffic{})
+}
+
+// NewMetered creates a new Relay which counts it's client traffic into
+// the given Traffic
+func NewMetered(
+	log logger.Logger,
+	runner worker.Runner,
+	server rw.ReadWriteDepleteDoner,
+	serverBuffer []byte,
+	clientBuilder Client,
+	clientBuffer []byte,
+	traffic *Traffic,
 ) Relay {
 	return &relay{
 		logger:           log.Context("Relay"),
@@ -130,6 +148,8 @@ func New(
 		clientEnabled:    make(chan io.ReadWriteCloser, 1),
 		closeSent:        false,
 		completed:        false,
+		traffic:          traffic,
+		splicer:          nil,
 	}
 }
 
@@ -148,6 +168,15 @@ func (r *relay) Bootup(cancel <-chan struct{}) error {
 		return initErr
 	}
 
+	splicer, isSplicer := r.clientBuilder.(Splicer)
+
+	if isSplicer && splicer.Spliced() {
+		r.splicer = splicer
+		r.mode = r.splicing
+
+		return nil
+	}
+
 	clientResultChan, runErr := r.runner.Run(
 		r.logger, r.clientReceiver, cancel)
 
@@ -335,6 +364,8 @@ func (r *relay) clientReceiver(l logger.Logger) error {
 				return wErr
 			}
 
+			r.traffic.inbound(rLen)
+
 			continue
 		}
 
@@ -362,7 +393,9 @@ func (r *relay) serverReceiver(cil io.ReadWriteCloser) error {
 			return rErr
 		}
 
-		rw.WriteFull(cil, r.serverBuffer[:rLen])
+		wLen, _ := rw.WriteFull(cil, r.serverBuffer[:rLen])
+
+		r.traffic.outbound(wLen)
 	}
 }
 
@@ -557,6 +590,43 @@ func (r *relay) exchanging() error {
 	return nil
 }
 
+// splicing takes the server connection over and exchanges data between
+// it and the client directly until both of them are completed
+func (r *relay) splicing() error {
+	r.mode = nil
+
+	splicer := r.splicer
+
+	r.splicer = nil
+
+	client, idle, clientErr := splicer.Splice(r.logger.Context("Client"))
+
+	if clientErr != nil {
+		r.dropServer()
+
+		return clientErr
+	}
+
+	r.logger.Debugf("Splicing")
+
+	return spliceServer(
+		client, r.clientBuffer, r.server, r.serverBuffer, idle, r.traffic)
+}
+
+// dropServer closes the connection of the server which was about to
+// be spliced
+func (r *relay) dropServer() {
+	raw, isRaw := r.server.(Raw)
+
+	if !isRaw {
+		return
+	}
+
+	serverConn, _ := raw.Handover()
+
+	serverConn.Close()
+}
+
 // close close current relay
 func (r *relay) close() error {
 	if !r.Running() {
@@ -565,6 +635,16 @@ func (r *relay) close() error {
 
 	r.mode = nil
 
+	// A Relay that is about to be spliced has no client to close nor
+	// opponent Relay to notify, so just drop the server connection
+	if r.splicer != nil {
+		r.splicer = nil
+
+		r.dropServer()
+
+		return nil
+	}
+
 	// We don't care about error happened during client closing, because
 	// client will be closed regardless whether or not there is an error
 	// and we can't let error blocks release progress
@@ -194,7 +194,8 @@ func TestRelayRelay(t *testing.T) {
 	relay1ClientReading := make(chan io.Reader)
 	relay1ClientSends := bytes.NewBuffer(make([]byte, 0, 4096))
 
-	relay1 := New(logger.NewDitch(), runner, &dummyServerConn{
+	relay1Traffic := &Traffic{}
+	relay1 := NewMetered(logger.NewDitch(), runner, &dummyServerConn{
 		r: relay1Reading,
 		w: relay1Sends,
 	}, clientBuffer1[:], &dummyClientBuilder1{
@@ -202,7 +203,7 @@ func TestRelayRelay(t *testing.T) {
 			r: relay1ClientReading,
 			w: relay1ClientSends,
 		},
-	}, serverBuffer1[:])
+	}, serverBuffer1[:], relay1Traffic)
 
 	bootupErr := relay1.Bootup(nil)
 
@@ -260,7 +261,8 @@ func TestRelayRelay(t *testing.T) {
 	relay2ClientReading := make(chan io.Reader)
 	relay2ClientSends := bytes.NewBuffer(make([]byte, 0, 4096))
 
-	relay2 := New(logger.NewDitch(), runner, &dummyServerConn{
+	relay2Traffic := &Traffic{}
+	relay2 := NewMetered(logger.NewDitch(), runner, &dummyServerConn{
 		r: relay2Reading,
 		w: relay2Sends,
 	}, clientBuffer2[:], &dummyClientBuilder1{
@@ -268,7 +270,7 @@ func TestRelayRelay(t *testing.T) {
 			r: relay2ClientReading,
 			w: relay2ClientSends,
 		},
-	}, serverBuffer2[:])
+	}, serverBuffer2[:], relay2Traffic)
 
 	bootupErr = relay2.Bootup(nil)
 
@@ -319,6 +321,13 @@ func TestRelayRelay(t *testing.T) {
 		return
 	}
 
+	if relay2Traffic.Inbound() != 0 || relay2Traffic.Outbound() != 9 {
+		t.Errorf("Expecting Relay 2 to deliver 9 bytes, got %d in %d out",
+			relay2Traffic.Inbound(), relay2Traffic.Outbound())
+
+		return
+	}
+
 	closeErr := relay2.Close()
 
 	if closeErr == nil {
@@ -342,4 +351,11 @@ func TestRelayRelay(t *testing.T) {
 
 		return
 	}
+
+	if relay1Traffic.Inbound() != 9 || relay1Traffic.Outbound() != 0 {
+		t.Errorf("Expecting Relay 1 to receive 9 bytes, got %d in %d out",
+			relay1Traffic.Inbound(), relay1Traffic.Outbound())
+
+		return
+	}
 }
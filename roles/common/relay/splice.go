@@ -0,0 +1,327 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+)
+
+// Errors
+var (
+	ErrServerCantBeHandedOver = errors.New(
+		"Relay server connection can't be handed over")
+)
+
+const (
+	// spliceChunkSize is how many bytes will be moved before the idle
+	// deadline get refreshed. A stream that is slower than
+	// spliceChunkSize per idle period will be considered as idle
+	spliceChunkSize = 64 * 1024
+)
+
+// Raw is a Relay server which can hand it's underlying connection over
+type Raw interface {
+	Transparent() bool
+	Exclusive() bool
+	Handover() (net.Conn, []byte)
+}
+
+// Splicer is a Client which may ask the Relay to be spliced. When Spliced
+// returns true after Initialize, the Relay will take the connection of the
+// server over and Splice it with the client returned by Splice, instead of
+// exchanging data through the server
+type Splicer interface {
+	Spliced() bool
+	Splice(log logger.Logger) (client net.Conn, idle time.Duration, err error)
+}
+
+// Exclusive returns whether or not the server is the only user of it's
+// connection and carries data without encoding, so the connection can be
+// taken over by the Relay without affecting others
+func Exclusive(server io.ReadWriter) bool {
+	raw, isRaw := server.(Raw)
+
+	return isRaw && raw.Exclusive()
+}
+
+// unwrapper is a net.Conn wrapper which can give back the connection it
+// wrapped
+type unwrapper interface {
+	Unwrap() net.Conn
+}
+
+// unwrap returns the innermost connection of the wrappers. Only wrappers
+// that implements unwrapper (i.e. won't buffer any data by it self) will be
+// unwrapped
+func unwrap(conn net.Conn) net.Conn {
+	for {
+		u, isUnwrapper := conn.(unwrapper)
+
+		if !isUnwrapper {
+			return conn
+		}
+
+		conn = u.Unwrap()
+	}
+}
+
+// rawTCPConn returns the underlying *net.TCPConn of the connection
+func rawTCPConn(conn net.Conn) (*net.TCPConn, bool) {
+	tcpConn, isTCP := unwrap(conn).(*net.TCPConn)
+
+	return tcpConn, isTCP
+}
+
+// Splice exchanges data between a client and a server connection until
+// both directions are completed or one of them is failed, then close both
+// connections.
+//
+// When both connections are (or wraps) plain *net.TCPConn and the system
+// supports it, data will be moved by splice(2) inside the kernel without
+// being copied to the user space. Otherwise, the data will be copied through
+// the given buffers
+func Splice(
+	client net.Conn,
+	clientBuffer []byte,
+	server net.Conn,
+	serverBuffer []byte,
+	idle time.Duration,
+	traffic *Traffic,
+) error {
+	clientTCP, clientIsTCP := rawTCPConn(client)
+	serverTCP, serverIsTCP := rawTCPConn(server)
+
+	inbound := traffic.inbound
+	outbound := traffic.outbound
+
+	var inboundCopy, outboundCopy func() error
+
+	if spliceSupported && clientIsTCP && serverIsTCP {
+		inboundCopy = func() error {
+			return kernelCopy(serverTCP, clientTCP, idle, inbound)
+		}
+
+		outboundCopy = func() error {
+			return kernelCopy(clientTCP, serverTCP, idle, outbound)
+		}
+	} else {
+		// Wrappers may close the connection once they read an EOF, which
+		// will interrupt the other direction, so skip them
+		clientConn := unwrap(client)
+		serverConn := unwrap(server)
+
+		inboundCopy = func() error {
+			return bufferCopy(
+				serverConn, clientConn, clientBuffer, idle, inbound)
+		}
+
+		outboundCopy = func() error {
+			return bufferCopy(
+				clientConn, serverConn, serverBuffer, idle, outbound)
+		}
+	}
+
+	var outboundErr error
+
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		outboundErr = outboundCopy()
+
+		if outboundErr != nil {
+			server.Close()
+			client.Close()
+
+			return
+		}
+
+		closeWrite(client)
+	}()
+
+	inboundErr := inboundCopy()
+
+	if inboundErr != nil {
+		client.Close()
+		server.Close()
+	} else {
+		closeWrite(server)
+	}
+
+	wg.Wait()
+
+	if inboundErr == nil && outboundErr == nil {
+		lingerClose(client)
+		lingerClose(server)
+	}
+
+	client.Close()
+	server.Close()
+
+	if inboundErr != nil {
+		return inboundErr
+	}
+
+	return outboundErr
+}
+
+// spliceServer takes the connection of the server over and Splice it with
+// the client. Data that already been read from the server connection will
+// be sent to the client first
+func spliceServer(
+	client net.Conn,
+	clientBuffer []byte,
+	server rw.ReadWriteDepleteDoner,
+	serverBuffer []byte,
+	idle time.Duration,
+	traffic *Traffic,
+) error {
+	raw, isRaw := server.(Raw)
+
+	if !isRaw {
+		client.Close()
+
+		return ErrServerCantBeHandedOver
+	}
+
+	serverConn, leftover := raw.Handover()
+
+	if len(leftover) > 0 {
+		wLen, wErr := rw.WriteFull(client, leftover)
+
+		traffic.outbound(wLen)
+
+		if wErr != nil {
+			client.Close()
+			serverConn.Close()
+
+			return wErr
+		}
+	}
+
+	return Splice(
+		client, clientBuffer, serverConn, serverBuffer, idle, traffic)
+}
+
+// closeWrite tells the remote there will be no more data from us, so it can
+// finish it's own sending
+func closeWrite(conn net.Conn) {
+	tcpConn, isTCP := rawTCPConn(conn)
+
+	if !isTCP {
+		return
+	}
+
+	tcpConn.CloseWrite()
+}
+
+// lingerClose makes sure data that still been held by the system will be
+// sent before the connection is closed, even when the connection has been
+// set to be reset on close
+func lingerClose(conn net.Conn) {
+	tcpConn, isTCP := rawTCPConn(conn)
+
+	if !isTCP {
+		return
+	}
+
+	tcpConn.SetLinger(-1)
+}
+
+// kernelCopy copies data from src to dst by (*net.TCPConn).ReadFrom, which
+// uses splice(2) when possible
+func kernelCopy(
+	dst *net.TCPConn,
+	src *net.TCPConn,
+	idle time.Duration,
+	count func(n int),
+) error {
+	chunk := &io.LimitedReader{
+		R: src,
+		N: spliceChunkSize,
+	}
+
+	for {
+		if idle > 0 {
+			src.SetReadDeadline(time.Now().Add(idle))
+		}
+
+		chunk.N = spliceChunkSize
+
+		n, err := dst.ReadFrom(chunk)
+
+		count(int(n))
+
+		if err != nil {
+			return err
+		}
+
+		// ReadFrom only returns before the chunk is full when src is EOF
+		if chunk.N > 0 {
+			return nil
+		}
+	}
+}
+
+// bufferCopy copies data from src to dst through the buffer
+func bufferCopy(
+	dst net.Conn,
+	src net.Conn,
+	buf []byte,
+	idle time.Duration,
+	count func(n int),
+) error {
+	for {
+		if idle > 0 {
+			src.SetReadDeadline(time.Now().Add(idle))
+		}
+
+		rLen, rErr := src.Read(buf)
+
+		if rLen > 0 {
+			wLen, wErr := dst.Write(buf[:rLen])
+
+			count(wLen)
+
+			if wErr != nil {
+				return wErr
+			}
+		}
+
+		if rErr == io.EOF {
+			return nil
+		}
+
+		if rErr != nil {
+			return rErr
+		}
+	}
+}
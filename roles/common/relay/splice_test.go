@@ -0,0 +1,313 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/ticker"
+	"github.com/reinit/coward/common/worker"
+)
+
+type dummyWrappedConn struct {
+	net.Conn
+}
+
+type dummyCountedSink struct {
+	target  uint64
+	written uint64
+	done    chan struct{}
+}
+
+func (d *dummyCountedSink) Write(b []byte) (int, error) {
+	if len(b) < 2 || b[0] != byte(SignalData) {
+		return len(b), nil
+	}
+
+	if atomic.AddUint64(&d.written, uint64(len(b)-1)) == d.target {
+		close(d.done)
+	}
+
+	return len(b), nil
+}
+
+func testTCPPair(t testing.TB) (net.Conn, net.Conn) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Fatal("Failed to listen due to error:", listenErr)
+	}
+
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+
+	go func() {
+		conn, acceptErr := listener.Accept()
+
+		if acceptErr != nil {
+			close(accepted)
+
+			return
+		}
+
+		accepted <- conn
+	}()
+
+	dialed, dialErr := net.Dial("tcp", listener.Addr().String())
+
+	if dialErr != nil {
+		t.Fatal("Failed to dial due to error:", dialErr)
+	}
+
+	conn, ok := <-accepted
+
+	if !ok {
+		t.Fatal("Failed to accept connection")
+	}
+
+	return dialed, conn
+}
+
+func testSplice(t *testing.T, wrap func(net.Conn) net.Conn) {
+	clientPeer, client := testTCPPair(t)
+	server, serverPeer := testTCPPair(t)
+
+	defer clientPeer.Close()
+	defer serverPeer.Close()
+
+	clientBuffer := [4096]byte{}
+	serverBuffer := [4096]byte{}
+	traffic := &Traffic{}
+	spliceResult := make(chan error, 1)
+
+	go func() {
+		spliceResult <- Splice(
+			wrap(client), clientBuffer[:], wrap(server), serverBuffer[:],
+			10*time.Second, traffic)
+	}()
+
+	request := bytes.Repeat([]byte("Request "), 16*1024)
+	response := bytes.Repeat([]byte("Response"), 12*1024)
+
+	go func() {
+		clientPeer.Write(request)
+		clientPeer.(*net.TCPConn).CloseWrite()
+	}()
+
+	received := make([]byte, len(request))
+
+	_, rErr := io.ReadFull(serverPeer, received)
+
+	if rErr != nil {
+		t.Error("Failed to read request due to error:", rErr)
+
+		return
+	}
+
+	if !bytes.Equal(received, request) {
+		t.Error("Received request is different from the sent one")
+
+		return
+	}
+
+	go func() {
+		serverPeer.Write(response)
+		serverPeer.(*net.TCPConn).CloseWrite()
+	}()
+
+	received, rErr = io.ReadAll(clientPeer)
+
+	if rErr != nil {
+		t.Error("Failed to read response due to error:", rErr)
+
+		return
+	}
+
+	if !bytes.Equal(received, response) {
+		t.Error("Received response is different from the sent one")
+
+		return
+	}
+
+	spliceErr := <-spliceResult
+
+	if spliceErr != nil {
+		t.Error("Splice has failed due to error:", spliceErr)
+
+		return
+	}
+
+	if traffic.Inbound() != uint64(len(request)) ||
+		traffic.Outbound() != uint64(len(response)) {
+		t.Errorf("Expecting %d bytes in and %d bytes out, got %d in %d out",
+			len(request), len(response),
+			traffic.Inbound(), traffic.Outbound())
+
+		return
+	}
+}
+
+func TestSplice(t *testing.T) {
+	testSplice(t, func(c net.Conn) net.Conn {
+		return c
+	})
+}
+
+func TestSpliceFallback(t *testing.T) {
+	testSplice(t, func(c net.Conn) net.Conn {
+		return dummyWrappedConn{Conn: c}
+	})
+}
+
+func TestSpliceIdle(t *testing.T) {
+	clientPeer, client := testTCPPair(t)
+	server, serverPeer := testTCPPair(t)
+
+	defer clientPeer.Close()
+	defer serverPeer.Close()
+
+	clientBuffer := [4096]byte{}
+	serverBuffer := [4096]byte{}
+
+	spliceErr := Splice(
+		client, clientBuffer[:], server, serverBuffer[:],
+		100*time.Millisecond, &Traffic{})
+
+	if spliceErr == nil {
+		t.Error("Expecting an error when the connections are idle")
+
+		return
+	}
+}
+
+func benchmarkSplice(b *testing.B, wrap func(net.Conn) net.Conn) {
+	clientPeer, client := testTCPPair(b)
+	server, serverPeer := testTCPPair(b)
+
+	defer clientPeer.Close()
+	defer serverPeer.Close()
+
+	clientBuffer := [4096]byte{}
+	serverBuffer := [4096]byte{}
+
+	go Splice(
+		wrap(client), clientBuffer[:], wrap(server), serverBuffer[:],
+		0, &Traffic{})
+
+	data := make([]byte, 32*1024)
+	received := make([]byte, len(data))
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	go func() {
+		for i := 0; i < b.N; i++ {
+			clientPeer.Write(data)
+		}
+	}()
+
+	for i := 0; i < b.N; i++ {
+		_, rErr := io.ReadFull(serverPeer, received)
+
+		if rErr != nil {
+			b.Fatal("Failed to read due to error:", rErr)
+		}
+	}
+}
+
+func BenchmarkSplice(b *testing.B) {
+	benchmarkSplice(b, func(c net.Conn) net.Conn {
+		return c
+	})
+}
+
+func BenchmarkSpliceFallback(b *testing.B) {
+	benchmarkSplice(b, func(c net.Conn) net.Conn {
+		return dummyWrappedConn{Conn: c}
+	})
+}
+
+func BenchmarkRelay(b *testing.B) {
+	tk, tkErr := ticker.New(300, 1024).Serve()
+
+	if tkErr != nil {
+		b.Fatal("Failed to create ticker due to error:", tkErr)
+	}
+
+	defer tk.Close()
+
+	runner, runnerErr := worker.New(logger.NewDitch(), tk, worker.Config{
+		MaxWorkers:        4,
+		MinWorkers:        4,
+		MaxWorkerIdle:     5 * time.Minute,
+		JobReceiveTimeout: 300 * time.Millisecond,
+	}).Serve()
+
+	if runnerErr != nil {
+		b.Fatal("Failed to start Relay runner:", runnerErr)
+	}
+
+	defer runner.Close()
+
+	clientPeer, client := testTCPPair(b)
+
+	defer clientPeer.Close()
+
+	data := make([]byte, 32*1024)
+	sink := &dummyCountedSink{
+		target:  uint64(len(data)) * uint64(b.N),
+		written: 0,
+		done:    make(chan struct{}),
+	}
+
+	clientBuffer := [4096]byte{}
+	serverBuffer := [4096]byte{}
+	r := New(logger.NewDitch(), runner, &dummyServerConn{
+		r: make(chan io.Reader),
+		w: sink,
+	}, serverBuffer[:], &dummyClientBuilder1{
+		conn: client,
+	}, clientBuffer[:])
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	bootupErr := r.Bootup(nil)
+
+	if bootupErr != nil {
+		b.Fatal("Failed to boot up Relay due to error:", bootupErr)
+	}
+
+	go func() {
+		for i := 0; i < b.N; i++ {
+			clientPeer.Write(data)
+		}
+	}()
+
+	<-sink.done
+}
@@ -0,0 +1,49 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package relay
+
+import "sync/atomic"
+
+// Traffic counts the bytes a Relay has exchanged with it's client
+type Traffic struct {
+	in  uint64
+	out uint64
+}
+
+// inbound records bytes that been received from the client
+func (t *Traffic) inbound(n int) {
+	atomic.AddUint64(&t.in, uint64(n))
+}
+
+// outbound records bytes that been sent to the client
+func (t *Traffic) outbound(n int) {
+	atomic.AddUint64(&t.out, uint64(n))
+}
+
+// Inbound returns how many bytes has been received from the client
+func (t *Traffic) Inbound() uint64 {
+	return atomic.LoadUint64(&t.in)
+}
+
+// Outbound returns how many bytes has been sent to the client
+func (t *Traffic) Outbound() uint64 {
+	return atomic.LoadUint64(&t.out)
+}
@@ -205,8 +205,9 @@ func New(
 
 	return &client{
 		id: clientID,
-		log: log.Context("Transceiver (" +
-			strconv.FormatUint(uint64(clientID), 10) + ")"),
+		log: logger.WithField(log.Context("Transceiver ("+
+			strconv.FormatUint(uint64(clientID), 10)+")"),
+			logger.FieldClient, strconv.FormatUint(uint64(clientID), 10)),
 		dialers:  dls,
 		codec:    codec,
 		cfg:      cfg,
@@ -796,9 +797,10 @@ func (c *client) request(
 		c.connectionCloserLocks[ch.ConnectionID].L.Unlock()
 	}()
 
-	connLogger := log.Context("Connection (" +
-		strconv.FormatUint(uint64(ch.ConnectionID), 10) + ")",
-	).Context("Channel (" + strconv.FormatUint(uint64(ch.ChannelID), 10) + ")")
+	connLogger := logger.WithField(log.Context("Connection ("+
+		strconv.FormatUint(uint64(ch.ConnectionID), 10)+")",
+	).Context("Channel ("+strconv.FormatUint(uint64(ch.ChannelID), 10)+")"),
+		logger.FieldChannel, strconv.FormatUint(uint64(ch.ChannelID), 10))
 
 	ch.Running.Increase()
 
@@ -0,0 +1,227 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package clients
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/ticker"
+	"github.com/reinit/coward/roles/common/transceiver"
+)
+
+// SharedProxy describes a COWARD Proxy a shared Balancer will connect to.
+// Balancers will only be shared when all of their SharedProxy are the same
+type SharedProxy struct {
+	Host           string
+	Port           uint16
+	Connections    uint32
+	RequestRetries uint8
+	Timeout        uint16
+	RequestTimeout uint16
+	Channels       uint8
+	Persistent     bool
+	Codec          string
+	CodecSetting   []string
+}
+
+// SharedBuilder builds Transceiver Clients for a shared Balancer. The
+// given ticker is owned by the shared Balancer, and will be closed after
+// the last user of the Balancer is gone. The given logger is the one of
+// the shared Balancer rather than of a specific Role
+type SharedBuilder func(
+	tk ticker.Requester, log logger.Logger) []transceiver.Client
+
+// Shared is a registry of Transceiver Balancers that can be shared by
+// multiple Roles running in the same process
+type Shared struct {
+	lock      sync.Mutex
+	balancers map[string]*sharedEntry
+}
+
+// sharedEntry is a running shared Balancer
+type sharedEntry struct {
+	refs     uint32
+	ticker   ticker.RequestCloser
+	balanced transceiver.Balanced
+}
+
+// sharedBalancer implements transceiver.Balancer
+type sharedBalancer struct {
+	shared          *Shared
+	key             string
+	build           SharedBuilder
+	maxDestinations int
+	logger          logger.Logger
+}
+
+// sharedBalanced is a reference to a running shared Balancer
+type sharedBalanced struct {
+	transceiver.Balanced
+
+	shared    *Shared
+	key       string
+	closeLock sync.Mutex
+	closed    bool
+}
+
+// key returns the string which identifies the SharedProxy
+func (p SharedProxy) key() string {
+	return strconv.Quote(p.Host) +
+		" " + strconv.FormatUint(uint64(p.Port), 10) +
+		" " + strconv.FormatUint(uint64(p.Connections), 10) +
+		" " + strconv.FormatUint(uint64(p.RequestRetries), 10) +
+		" " + strconv.FormatUint(uint64(p.Timeout), 10) +
+		" " + strconv.FormatUint(uint64(p.RequestTimeout), 10) +
+		" " + strconv.FormatUint(uint64(p.Channels), 10) +
+		" " + strconv.FormatBool(p.Persistent) +
+		" " + strconv.Quote(p.Codec) +
+		" " + strconv.Itoa(len(p.CodecSetting)) +
+		" " + sharedQuoteAll(p.CodecSetting)
+}
+
+// sharedQuoteAll quotes and joins all the strings
+func sharedQuoteAll(s []string) string {
+	quoted := make([]string, len(s))
+
+	for sIdx := range s {
+		quoted[sIdx] = strconv.Quote(s[sIdx])
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// sharedKey returns the string which identifies the set of SharedProxy
+func sharedKey(proxies []SharedProxy) string {
+	keys := make([]string, len(proxies))
+
+	for pIdx := range proxies {
+		keys[pIdx] = "{" + proxies[pIdx].key() + "}"
+	}
+
+	return strings.Join(keys, ",")
+}
+
+// NewShared creates a new Shared
+func NewShared() *Shared {
+	return &Shared{
+		lock:      sync.Mutex{},
+		balancers: make(map[string]*sharedEntry, 16),
+	}
+}
+
+// Balancer returns a Balancer which will share it's Transceiver Clients with
+// other Balancers returned for the same set of Proxies. The log is the
+// logger of the calling Role, shared Clients will be built with a "Shared"
+// context of it, while requests are logged with the logger given to each
+// request
+func (s *Shared) Balancer(
+	proxies []SharedProxy,
+	build SharedBuilder,
+	maxDestinations int,
+	log logger.Logger,
+) transceiver.Balancer {
+	return &sharedBalancer{
+		shared:          s,
+		key:             sharedKey(proxies),
+		build:           build,
+		maxDestinations: maxDestinations,
+		logger:          log,
+	}
+}
+
+// Serve starts the shared Balancer if it's not already running
+func (s *sharedBalancer) Serve() (transceiver.Balanced, error) {
+	s.shared.lock.Lock()
+	defer s.shared.lock.Unlock()
+
+	entry, found := s.shared.balancers[s.key]
+
+	if !found {
+		tk, tkErr := ticker.New(300*time.Millisecond, 1024).Serve()
+
+		if tkErr != nil {
+			return nil, tkErr
+		}
+
+		balanced, serveErr := New(
+			s.build(tk, s.logger.Context("Shared")), s.maxDestinations).Serve()
+
+		if serveErr != nil {
+			tk.Close()
+
+			return nil, serveErr
+		}
+
+		entry = &sharedEntry{
+			refs:     0,
+			ticker:   tk,
+			balanced: balanced,
+		}
+
+		s.shared.balancers[s.key] = entry
+	}
+
+	entry.refs++
+
+	return &sharedBalanced{
+		Balanced:  entry.balanced,
+		shared:    s.shared,
+		key:       s.key,
+		closeLock: sync.Mutex{},
+		closed:    false,
+	}, nil
+}
+
+// Close releases the reference to the shared Balancer, and closes it when
+// it's no longer been used
+func (s *sharedBalanced) Close() error {
+	s.closeLock.Lock()
+	defer s.closeLock.Unlock()
+
+	if s.closed {
+		return ErrAlreadyClosed
+	}
+
+	s.closed = true
+
+	s.shared.lock.Lock()
+	defer s.shared.lock.Unlock()
+
+	entry := s.shared.balancers[s.key]
+
+	entry.refs--
+
+	if entry.refs > 0 {
+		return nil
+	}
+
+	delete(s.shared.balancers, s.key)
+
+	closeErr := entry.balanced.Close()
+
+	entry.ticker.Close()
+
+	return closeErr
+}
@@ -0,0 +1,198 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package clients
+
+import (
+	"testing"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/ticker"
+	"github.com/reinit/coward/roles/common/transceiver"
+)
+
+type dummySharedClient struct {
+	closed *int
+}
+
+type dummySharedRequester struct {
+	closed *int
+}
+
+func (d dummySharedClient) Serve() (transceiver.Requester, error) {
+	return dummySharedRequester{closed: d.closed}, nil
+}
+
+func (d dummySharedRequester) ID() transceiver.ClientID {
+	return 0
+}
+
+func (d dummySharedRequester) Available() bool {
+	return true
+}
+
+func (d dummySharedRequester) Full() bool {
+	return false
+}
+
+func (d dummySharedRequester) Request(
+	log logger.Logger,
+	req transceiver.RequestBuilder,
+	cancel <-chan struct{},
+	m transceiver.Meter,
+) (bool, error) {
+	return false, nil
+}
+
+func (d dummySharedRequester) Connections() uint32 {
+	return 1
+}
+
+func (d dummySharedRequester) Channels() uint32 {
+	return 1
+}
+
+func (d dummySharedRequester) Close() error {
+	*d.closed++
+
+	return nil
+}
+
+func TestShared(t *testing.T) {
+	builds := 0
+	closed := 0
+
+	shared := NewShared()
+	proxies := []SharedProxy{{
+		Host:           "localhost",
+		Port:           1,
+		Connections:    1,
+		RequestRetries: 1,
+		Timeout:        1,
+		RequestTimeout: 1,
+		Channels:       1,
+		Persistent:     false,
+		Codec:          "plain",
+		CodecSetting:   nil,
+	}}
+	build := func(
+		tk ticker.Requester, l logger.Logger) []transceiver.Client {
+		builds++
+
+		return []transceiver.Client{dummySharedClient{closed: &closed}}
+	}
+
+	b1, b1Err := shared.Balancer(
+		proxies, build, 16, logger.NewDitch()).Serve()
+
+	if b1Err != nil {
+		t.Error("Failed to serve Balancer due to error:", b1Err)
+
+		return
+	}
+
+	b2, b2Err := shared.Balancer(
+		proxies, build, 16, logger.NewDitch()).Serve()
+
+	if b2Err != nil {
+		t.Error("Failed to serve Balancer due to error:", b2Err)
+
+		return
+	}
+
+	if builds != 1 {
+		t.Errorf("Expecting Clients to be built once, got %d", builds)
+
+		return
+	}
+
+	b1.Close()
+
+	if closed != 0 {
+		t.Error("Shared Balancer must not be closed while it's still in use")
+
+		return
+	}
+
+	if b1.Close() != ErrAlreadyClosed {
+		t.Error("Close a closed reference must resulting ErrAlreadyClosed")
+
+		return
+	}
+
+	b2.Close()
+
+	if closed != 1 {
+		t.Error("Shared Balancer must be closed after all references are " +
+			"closed")
+
+		return
+	}
+}
+
+func TestSharedKey(t *testing.T) {
+	proxy := SharedProxy{
+		Host:           "localhost",
+		Port:           1,
+		Connections:    1,
+		RequestRetries: 1,
+		Timeout:        1,
+		RequestTimeout: 1,
+		Channels:       1,
+		Persistent:     false,
+		Codec:          "plain",
+		CodecSetting:   []string{"a b"},
+	}
+
+	same := proxy
+	same.CodecSetting = []string{"a b"}
+
+	if sharedKey([]SharedProxy{proxy}) != sharedKey([]SharedProxy{same}) {
+		t.Error("Expecting the same Proxies to have the same key")
+
+		return
+	}
+
+	split := proxy
+	split.CodecSetting = []string{"a", "b"}
+
+	if sharedKey([]SharedProxy{proxy}) == sharedKey([]SharedProxy{split}) {
+		t.Error("Expecting different Codec Settings to have different keys")
+
+		return
+	}
+
+	persistent := proxy
+	persistent.Persistent = true
+
+	if sharedKey([]SharedProxy{proxy}) ==
+		sharedKey([]SharedProxy{persistent}) {
+		t.Error("Expecting different Proxies to have different keys")
+
+		return
+	}
+
+	if sharedKey([]SharedProxy{proxy, persistent}) ==
+		sharedKey([]SharedProxy{persistent, proxy}) {
+		t.Error("Expecting the order of Proxies to be part of the key")
+
+		return
+	}
+}
@@ -32,7 +32,7 @@ type buffered struct {
 	reader *bufio.Reader
 }
 
-func newBuffered(c network.Connection, size int) network.Connection {
+func newBuffered(c network.Connection, size int) buffered {
 	return buffered{
 		Connection: c,
 		reader:     bufio.NewReaderSize(c, size),
@@ -42,3 +42,19 @@ func newBuffered(c network.Connection, size int) network.Connection {
 func (f buffered) Read(b []byte) (int, error) {
 	return f.reader.Read(b)
 }
+
+// leftover takes the data that already been read from the connection but
+// not yet been consumed out of the buffer
+func (f buffered) leftover() []byte {
+	bufferedLen := f.reader.Buffered()
+
+	if bufferedLen <= 0 {
+		return nil
+	}
+
+	left := make([]byte, bufferedLen)
+
+	rLen, _ := f.reader.Read(left)
+
+	return left[:rLen]
+}
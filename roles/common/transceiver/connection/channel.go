@@ -23,6 +23,7 @@ package connection
 import (
 	"io"
 	"math"
+	"net"
 	"sync"
 	"time"
 
@@ -53,6 +54,9 @@ var (
 	ErrChannelVirtualConnectionTimedout = NewError(
 		"Virtual Connection has timed out")
 
+	ErrChannelVirtualConnectionCanceled = NewError(
+		"Virtual Connection reading has been canceled")
+
 	ErrChannelVirtualConnectionSegmentDepleted = NewError(
 		"Data Segment of current Virtual Connection are depleted")
 
@@ -64,6 +68,9 @@ var (
 
 	ErrChannelAlreadyClosing = NewError(
 		"Channel already closing")
+
+	ErrChannelConnectionHandedOver = NewError(
+		"Channel Connection has been handed over")
 )
 
 // Consts
@@ -85,11 +92,20 @@ type Virtual interface {
 	rw.ReadWriteDepleteDoner
 
 	Timeout(time.Duration)
+	Cancel(<-chan struct{})
+}
+
+// transparent is a Codec which may carry data without encoding them
+type transparent interface {
+	Transparent() bool
 }
 
 // channelize implements Channelizer
 type channelize struct {
+	raw               network.Connection
 	conn              network.Connection
+	buffered          buffered
+	handedOver        chan struct{}
 	codec             rw.Codec
 	timeout           time.Duration
 	timeoutTicker     ticker.Requester
@@ -114,9 +130,10 @@ type channel struct {
 
 	codec         rw.Codec
 	id            ch.ID
-	parent        Channelizer
+	parent        *channelize
 	timeout       time.Duration
 	timeoutTicker ticker.Requester
+	cancel        <-chan struct{}
 	connClosed    <-chan struct{}
 	connReader    chan channelReader
 	currentReader channelReader
@@ -130,8 +147,13 @@ func Channelize(
 	codec rw.Codec,
 	timeoutTicker ticker.Requester,
 ) Channelizer {
+	buffered := newBuffered(errorconn{Connection: c}, 4096)
+
 	return &channelize{
-		conn:              newBuffered(errorconn{Connection: c}, 4096),
+		raw:               c,
+		conn:              buffered,
+		buffered:          buffered,
+		handedOver:        make(chan struct{}),
 		codec:             codec,
 		timeout:           0,
 		timeoutTicker:     timeoutTicker,
@@ -159,6 +181,13 @@ func (c *channelize) Timeout(t time.Duration) {
 
 // Initialize reads initialization data from Connection
 func (c *channelize) Dispatch(channels ch.Channels) (ch.ID, fsm.FSM, error) {
+	select {
+	case <-c.handedOver:
+		return 0, nil, ErrChannelConnectionHandedOver
+
+	default:
+	}
+
 	// Write will be blocked until someone released the lock
 	select {
 	case c.dispatchCompleted <- struct{}{}:
@@ -253,6 +282,7 @@ func (c *channelize) For(id ch.ID) Virtual {
 		parent:        c,
 		timeout:       c.timeout,
 		timeoutTicker: c.timeoutTicker,
+		cancel:        nil,
 		connClosed:    c.conn.Closed(),
 		connReader:    make(chan channelReader, 1),
 		currentReader: channelReader{
@@ -289,6 +319,53 @@ func (c *channel) Timeout(t time.Duration) {
 	c.timeout = t
 }
 
+// Cancel set a channel which cancels the waiting for a new segment once
+// it's closed. Set it to nil to disable the cancel
+func (c *channel) Cancel(cancel <-chan struct{}) {
+	c.cancel = cancel
+}
+
+// Transparent returns whether or not the data of current Virtual Channel
+// is carried without been encoded
+func (c *channel) Transparent() bool {
+	t, isTransparent := c.codec.(transparent)
+
+	return isTransparent && t.Transparent()
+}
+
+// Exclusive returns whether or not current Virtual Channel is the only
+// Channel of a Transparent connection
+func (c *channel) Exclusive() bool {
+	if !c.Transparent() {
+		return false
+	}
+
+	for cIdx := range c.parent.channels {
+		if c.parent.channels[cIdx] == nil || c.parent.channels[cIdx] == c {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// Handover gives the underlying connection together with the data that
+// already been read from it to the caller, and stops the dispatch.
+// The caller must not call Done on the current segment if it's dispatched
+// by another goroutine, otherwise the connection will be read again
+func (c *channel) Handover() (net.Conn, []byte) {
+	select {
+	case <-c.parent.handedOver:
+
+	default:
+		close(c.parent.handedOver)
+	}
+
+	return c.parent.raw, c.parent.buffered.leftover()
+}
+
 // Depleted returns whether or not there are still remaining data
 // in the current Virtual Channel to read
 func (c *channel) Depleted() bool {
@@ -368,6 +445,9 @@ func (c *channel) Read(b []byte) (int, error) {
 
 		case <-timeoutTicker:
 			return 0, ErrChannelVirtualConnectionTimedout
+
+		case <-c.cancel:
+			return 0, ErrChannelVirtualConnectionCanceled
 		}
 	} else if c.Depleted() {
 		return 0, ErrChannelVirtualConnectionSegmentDepleted
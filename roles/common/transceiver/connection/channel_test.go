@@ -175,6 +175,69 @@ func (d *dummyChannelMachine) Shutdown() error {
 	return nil
 }
 
+func TestChannelCancel(t *testing.T) {
+	d := &dummyConnection{
+		buf: bytes.NewBuffer(make([]byte, 0, 4096)),
+	}
+
+	requestWaitTicker, requestWaitErr := ticker.New(
+		100*time.Millisecond, 1024).Serve()
+
+	if requestWaitErr != nil {
+		t.Error("Failed to startup Ticker:", requestWaitErr)
+
+		return
+	}
+
+	defer requestWaitTicker.Close()
+
+	v := Channelize(d, dummyChannelCoder{}, requestWaitTicker)
+
+	defer v.Shutdown()
+
+	virtual := v.For(1)
+	cancel := make(chan struct{})
+
+	virtual.Timeout(10 * time.Second)
+	virtual.Cancel(cancel)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+
+		close(cancel)
+	}()
+
+	buf := [16]byte{}
+	start := time.Now()
+
+	_, rErr := virtual.Read(buf[:])
+
+	if rErr != ErrChannelVirtualConnectionCanceled {
+		t.Errorf("Expecting error %s, got %v",
+			ErrChannelVirtualConnectionCanceled, rErr)
+
+		return
+	}
+
+	if time.Since(start) >= 5*time.Second {
+		t.Error("Read must be canceled right after the cancel is closed")
+
+		return
+	}
+
+	virtual.Timeout(300 * time.Millisecond)
+	virtual.Cancel(nil)
+
+	_, rErr = virtual.Read(buf[:])
+
+	if rErr != ErrChannelVirtualConnectionTimedout {
+		t.Errorf("Expecting error %s, got %v",
+			ErrChannelVirtualConnectionTimedout, rErr)
+
+		return
+	}
+}
+
 func TestChannelReadWrite(t *testing.T) {
 	const expectedSegments = 2
 
@@ -0,0 +1,101 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package reverse
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"io"
+	"time"
+
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/transceiver"
+)
+
+// challenge sends a random challenge to the registrant and waits for it to
+// be sent back sealed by the Codec, so only the one who shares the same
+// Codec key can register
+func challenge(conn network.Connection, codec transceiver.CodecBuilder) error {
+	c, codecErr := codec()
+
+	if codecErr != nil {
+		return codecErr
+	}
+
+	challenged := [challengeSize]byte{}
+
+	_, randErr := rand.Read(challenged[:])
+
+	if randErr != nil {
+		return randErr
+	}
+
+	conn.SetDeadline(time.Now().Add(pingDelay))
+
+	_, wErr := rw.WriteFull(conn, challenged[:])
+
+	if wErr != nil {
+		return wErr
+	}
+
+	responded := [challengeSize]byte{}
+
+	_, rErr := io.ReadFull(c.Decode(conn), responded[:])
+
+	if rErr != nil {
+		return rErr
+	}
+
+	if subtle.ConstantTimeCompare(challenged[:], responded[:]) != 1 {
+		return ErrAuthenticationFailed
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+// respond seals the challenge sent by the Registry with the Codec and sends
+// it back
+func respond(conn network.Connection, codec transceiver.CodecBuilder) error {
+	c, codecErr := codec()
+
+	if codecErr != nil {
+		return codecErr
+	}
+
+	conn.SetDeadline(time.Now().Add(pingDelay))
+
+	challenged := [challengeSize]byte{}
+
+	_, rErr := io.ReadFull(conn, challenged[:])
+
+	if rErr != nil {
+		return rErr
+	}
+
+	_, wErr := rw.WriteFull(c.Encode(conn), challenged[:])
+
+	if wErr != nil {
+		return wErr
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
@@ -0,0 +1,101 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package reverse
+
+import (
+	"github.com/reinit/coward/roles/common/transceiver"
+)
+
+// balancer serves the Registries along with the wrapped Balancer
+type balancer struct {
+	transceiver.Balancer
+
+	registries []Registry
+}
+
+// balanced closes the Registries along with the wrapped Balanced
+type balanced struct {
+	transceiver.Balanced
+
+	registries []Registry
+}
+
+// Balancer wraps the Balancer so the given Registries will be served
+// before it, and closed after it
+func Balancer(
+	b transceiver.Balancer,
+	registries []Registry,
+) transceiver.Balancer {
+	if len(registries) <= 0 {
+		return b
+	}
+
+	return balancer{
+		Balancer:   b,
+		registries: registries,
+	}
+}
+
+// close closes the first count of Registries
+func (b balancer) close(count int) {
+	for rIdx := 0; rIdx < count; rIdx++ {
+		b.registries[rIdx].Close()
+	}
+}
+
+// Serve starts serving
+func (b balancer) Serve() (transceiver.Balanced, error) {
+	for rIdx := range b.registries {
+		serveErr := b.registries[rIdx].Serve()
+
+		if serveErr == nil {
+			continue
+		}
+
+		b.close(rIdx)
+
+		return nil, serveErr
+	}
+
+	served, serveErr := b.Balancer.Serve()
+
+	if serveErr != nil {
+		b.close(len(b.registries))
+
+		return nil, serveErr
+	}
+
+	return balanced{
+		Balanced:   served,
+		registries: b.registries,
+	}, nil
+}
+
+// Close closes the Balanced and the Registries
+func (b balanced) Close() error {
+	closeErr := b.Balanced.Close()
+
+	for rIdx := range b.registries {
+		b.registries[rIdx].Close()
+	}
+
+	return closeErr
+}
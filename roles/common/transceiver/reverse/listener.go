@@ -0,0 +1,309 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package reverse
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/transceiver"
+)
+
+// listener dials out to a Registry and keeps idle connections registered
+// there. The connections will be accepted once the Registry has started to
+// use them
+type listener struct {
+	dialer network.Dialer
+	codec  transceiver.CodecBuilder
+	idle   uint32
+	logger logger.Logger
+}
+
+// acceptor implements network.Acceptor
+type acceptor struct {
+	dialer   network.Dialer
+	codec    transceiver.CodecBuilder
+	logger   logger.Logger
+	address  address
+	accepted chan network.Connection
+	closed   chan struct{}
+	conns    map[network.ConnectionID]network.Connection
+	lock     sync.Mutex
+}
+
+// address is the address of the Registry
+type address string
+
+// NewListener creates a Listener which keeps the given amount of idle
+// connections registered to the Registry that the dialer dials to. The
+// codec must be the same one the Registry uses to authenticate
+func NewListener(
+	dialer network.Dialer,
+	codec transceiver.CodecBuilder,
+	idle uint32,
+	log logger.Logger,
+) network.Listener {
+	return listener{
+		dialer: dialer,
+		codec:  codec,
+		idle:   idle,
+		logger: log.Context("Reverse"),
+	}
+}
+
+// Listen starts registering connections to the Registry
+func (l listener) Listen() (network.Acceptor, error) {
+	a := &acceptor{
+		dialer:   l.dialer,
+		codec:    l.codec,
+		logger:   l.logger,
+		address:  address(l.String()),
+		accepted: make(chan network.Connection),
+		closed:   make(chan struct{}),
+		conns:    make(map[network.ConnectionID]network.Connection, l.idle),
+		lock:     sync.Mutex{},
+	}
+
+	for rIdx := uint32(0); rIdx < l.idle; rIdx++ {
+		go a.register(a.logger.Context(
+			"Registration (" + strconv.FormatUint(uint64(rIdx), 10) + ")"))
+	}
+
+	return a, nil
+}
+
+// String returns the address of the Registry
+func (l listener) String() string {
+	return l.dialer.Dialer().String()
+}
+
+// Network returns the network name of the address
+func (a address) Network() string {
+	return "tcp"
+}
+
+// String returns the address in string
+func (a address) String() string {
+	return string(a)
+}
+
+// track keeps the connection so it can be closed by Close. Returns false
+// when the acceptor is already closed
+func (a *acceptor) track(conn network.Connection) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	select {
+	case <-a.closed:
+		return false
+
+	default:
+	}
+
+	a.conns[conn.ID()] = conn
+
+	return true
+}
+
+// untrack removes the connection from the acceptor
+func (a *acceptor) untrack(conn network.Connection) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	delete(a.conns, conn.ID())
+}
+
+// wait waits for the redial delay, returns false when the acceptor is
+// closed during the wait
+func (a *acceptor) wait() bool {
+	select {
+	case <-a.closed:
+		return false
+
+	case <-time.After(redialDelay):
+		return true
+	}
+}
+
+// register keeps an idle connection registered to the Registry, and
+// delivers it to the Accept once it's been started
+func (a *acceptor) register(log logger.Logger) {
+	dial := a.dialer.Dialer()
+
+	for {
+		conn, dialErr := dial.Dial()
+
+		if dialErr != nil {
+			log.Debugf("Failed to register to \"%s\" due to error: %s",
+				dial, dialErr)
+
+			if !a.wait() {
+				return
+			}
+
+			continue
+		}
+
+		if !a.track(conn) {
+			conn.Close()
+
+			return
+		}
+
+		authErr := respond(conn, a.codec)
+
+		if authErr != nil {
+			a.untrack(conn)
+
+			conn.Close()
+
+			log.Debugf("Failed to authenticate to \"%s\" due to error: %s",
+				dial, authErr)
+
+			if !a.wait() {
+				return
+			}
+
+			continue
+		}
+
+		log.Debugf("Registered to \"%s\"", dial)
+
+		startErr := a.waitStart(conn)
+
+		a.untrack(conn)
+
+		if startErr != nil {
+			conn.Close()
+
+			log.Debugf("Registration has been dropped due to error: %s",
+				startErr)
+
+			if !a.wait() {
+				return
+			}
+
+			continue
+		}
+
+		select {
+		case a.accepted <- conn:
+
+		case <-a.closed:
+			conn.Close()
+
+			return
+		}
+	}
+}
+
+// waitStart pings the Registry until it starts to use the connection
+func (a *acceptor) waitStart(conn network.Connection) error {
+	buf := [1]byte{}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(pingDelay))
+
+		_, rErr := io.ReadFull(conn, buf[:])
+
+		if rErr != nil {
+			netErr, isNetErr := rErr.(net.Error)
+
+			if !isNetErr || !netErr.Timeout() {
+				return rErr
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(pingDelay))
+
+			_, wErr := rw.WriteFull(conn, []byte{signalPing})
+
+			if wErr != nil {
+				return wErr
+			}
+
+			continue
+		}
+
+		if buf[0] != signalStart {
+			return ErrUnexpectedSignal
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(pingDelay))
+
+		_, wErr := rw.WriteFull(conn, []byte{signalStarted})
+
+		if wErr != nil {
+			return wErr
+		}
+
+		return conn.SetDeadline(time.Time{})
+	}
+}
+
+// Addr returns the address of the Registry
+func (a *acceptor) Addr() net.Addr {
+	return a.address
+}
+
+// Accept accepts a connection which has been started by the Registry
+func (a *acceptor) Accept() (network.Connection, error) {
+	select {
+	case conn := <-a.accepted:
+		return conn, nil
+
+	case <-a.closed:
+		return nil, ErrListenerClosed
+	}
+}
+
+// Closed return whether or not current acceptor is closed
+func (a *acceptor) Closed() chan struct{} {
+	return a.closed
+}
+
+// Close stops registering and closes all idle connections
+func (a *acceptor) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	select {
+	case <-a.closed:
+		return ErrListenerClosed
+
+	default:
+	}
+
+	close(a.closed)
+
+	for cID := range a.conns {
+		a.conns[cID].Close()
+
+		delete(a.conns, cID)
+	}
+
+	return nil
+}
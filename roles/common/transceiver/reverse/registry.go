@@ -0,0 +1,453 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package reverse
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/transceiver"
+)
+
+// Registry accepts the connections registered by the reverse Listeners and
+// hands them out as a network.Dialer
+type Registry interface {
+	network.Dialer
+
+	Serve() error
+	Close() error
+}
+
+// registry implements Registry
+type registry struct {
+	listener  network.Listener
+	codec     transceiver.CodecBuilder
+	maxIdle   uint32
+	timeout   time.Duration
+	logger    logger.Logger
+	acceptor  network.Acceptor
+	idle      []*registered
+	pending   chan struct{}
+	available chan struct{}
+	closed    chan struct{}
+	lock      sync.Mutex
+}
+
+// registered is an idle registered connection
+type registered struct {
+	conn    network.Connection
+	taken   bool
+	stopped chan struct{}
+}
+
+// unwrapper is a network.Connection which can give back the net.Conn it
+// wrapped
+type unwrapper interface {
+	Unwrap() net.Conn
+}
+
+// registryDial implements network.Dial
+type registryDial struct {
+	registry *registry
+}
+
+// NewRegistry creates a new Registry which keeps at most maxIdle idle
+// connections accepted from the listener. Only the connections that can
+// seal a challenge with the codec will be accepted, and at most maxIdle
+// of them can be authenticating at the same time. The Dial will wait no
+// longer than the timeout for a connection to become available
+func NewRegistry(
+	listener network.Listener,
+	codec transceiver.CodecBuilder,
+	maxIdle uint32,
+	timeout time.Duration,
+	log logger.Logger,
+) Registry {
+	closed := make(chan struct{})
+
+	close(closed)
+
+	return &registry{
+		listener:  listener,
+		codec:     codec,
+		maxIdle:   maxIdle,
+		timeout:   timeout,
+		logger:    log.Context("Registry (" + listener.String() + ")"),
+		acceptor:  nil,
+		idle:      make([]*registered, 0, maxIdle),
+		pending:   make(chan struct{}, maxIdle),
+		available: make(chan struct{}, 1),
+		closed:    closed,
+		lock:      sync.Mutex{},
+	}
+}
+
+// Serve starts accepting registrations
+func (r *registry) Serve() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.acceptor != nil {
+		return ErrRegistryAlreadyServing
+	}
+
+	acc, listenErr := r.listener.Listen()
+
+	if listenErr != nil {
+		return listenErr
+	}
+
+	r.acceptor = acc
+	r.closed = make(chan struct{})
+
+	go r.accepting(acc, r.closed)
+
+	return nil
+}
+
+// Close stops accepting registrations and closes all idle connections
+func (r *registry) Close() error {
+	r.lock.Lock()
+
+	if r.acceptor == nil {
+		r.lock.Unlock()
+
+		return ErrRegistryClosed
+	}
+
+	acc := r.acceptor
+	idle := r.idle
+
+	r.acceptor = nil
+	r.idle = make([]*registered, 0, r.maxIdle)
+
+	close(r.closed)
+
+	for iIdx := range idle {
+		idle[iIdx].taken = true
+	}
+
+	r.lock.Unlock()
+
+	for iIdx := range idle {
+		idle[iIdx].conn.Close()
+	}
+
+	return acc.Close()
+}
+
+// Dialer returns a network.Dial which takes connections from the Registry
+func (r *registry) Dialer() network.Dial {
+	return registryDial{
+		registry: r,
+	}
+}
+
+// accepting accepts registrations until the acceptor is closed
+func (r *registry) accepting(acc network.Acceptor, closed chan struct{}) {
+	for {
+		conn, accErr := acc.Accept()
+
+		if accErr != nil {
+			select {
+			case <-acc.Closed():
+				return
+
+			default:
+			}
+
+			r.logger.Warningf("Failed to accept registration due to "+
+				"error: %s", accErr)
+
+			time.Sleep(acceptErrorWait)
+
+			continue
+		}
+
+		select {
+		case r.pending <- struct{}{}:
+			go r.register(closed, conn)
+
+		default:
+			conn.Close()
+
+			r.logger.Debugf("Registration from \"%s\" is refused as there "+
+				"are too many pending registrations", conn.RemoteAddr())
+		}
+	}
+}
+
+// register authenticates the connection and adds it to the idle pool of
+// the serving session identified by the closed channel
+func (r *registry) register(closed chan struct{}, conn network.Connection) {
+	authErr := challenge(conn, r.codec)
+
+	<-r.pending
+
+	if authErr != nil {
+		conn.Close()
+
+		r.logger.Debugf("Registration from \"%s\" is refused as it has "+
+			"failed the authentication: %s", conn.RemoteAddr(), authErr)
+
+		return
+	}
+
+	r.lock.Lock()
+
+	if r.closed != closed || uint32(len(r.idle)) >= r.maxIdle {
+		r.lock.Unlock()
+
+		conn.Close()
+
+		r.logger.Debugf("Registration from \"%s\" is refused as the "+
+			"pool is full", conn.RemoteAddr())
+
+		return
+	}
+
+	reg := &registered{
+		conn:    conn,
+		taken:   false,
+		stopped: make(chan struct{}),
+	}
+
+	r.idle = append(r.idle, reg)
+
+	r.notify()
+
+	r.lock.Unlock()
+
+	r.logger.Debugf("Registered \"%s\"", conn.RemoteAddr())
+
+	go r.keepalive(reg)
+}
+
+// notify tells one waiting Dial that a connection is available. Must be
+// called with the lock held
+func (r *registry) notify() {
+	select {
+	case r.available <- struct{}{}:
+
+	default:
+	}
+}
+
+// remove removes the registered connection from the idle pool. Must be
+// called with the lock held
+func (r *registry) remove(reg *registered) {
+	for iIdx := range r.idle {
+		if r.idle[iIdx] != reg {
+			continue
+		}
+
+		r.idle = append(r.idle[:iIdx], r.idle[iIdx+1:]...)
+
+		return
+	}
+}
+
+// take takes the most recently registered idle connection out of the
+// pool, returns nil when the pool is empty
+func (r *registry) take() *registered {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.idle) <= 0 {
+		return nil
+	}
+
+	reg := r.idle[len(r.idle)-1]
+
+	r.idle = r.idle[:len(r.idle)-1]
+	reg.taken = true
+
+	if len(r.idle) > 0 {
+		r.notify()
+	}
+
+	return reg
+}
+
+// keepalive receives pings on the idle connection until it's been taken
+func (r *registry) keepalive(reg *registered) {
+	buf := [1]byte{}
+
+	for {
+		r.lock.Lock()
+
+		if reg.taken {
+			r.lock.Unlock()
+
+			close(reg.stopped)
+
+			return
+		}
+
+		reg.conn.SetReadDeadline(time.Now().Add(pingTimeout))
+
+		r.lock.Unlock()
+
+		_, rErr := io.ReadFull(reg.conn, buf[:])
+
+		if rErr == nil && buf[0] == signalPing {
+			continue
+		}
+
+		r.lock.Lock()
+
+		if reg.taken {
+			r.lock.Unlock()
+
+			close(reg.stopped)
+
+			return
+		}
+
+		r.remove(reg)
+
+		r.lock.Unlock()
+
+		reg.conn.Close()
+
+		if rErr == nil {
+			rErr = ErrUnexpectedSignal
+		}
+
+		r.logger.Debugf("Registration from \"%s\" is dropped due to "+
+			"error: %s", reg.conn.RemoteAddr(), rErr)
+
+		return
+	}
+}
+
+// closedSignal returns the channel which will be closed when the Registry
+// is closed
+func (r *registry) closedSignal() chan struct{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.closed
+}
+
+// Dial takes a registered connection and starts it
+func (d registryDial) Dial() (network.Connection, error) {
+	var timeout <-chan time.Time
+
+	if d.registry.timeout > 0 {
+		timer := time.NewTimer(d.registry.timeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	closed := d.registry.closedSignal()
+
+	for {
+		reg := d.registry.take()
+
+		if reg == nil {
+			select {
+			case <-d.registry.available:
+				continue
+
+			case <-timeout:
+				return nil, ErrRegistryUnavailable
+
+			case <-closed:
+				return nil, ErrRegistryClosed
+			}
+		}
+
+		interrupt(reg.conn)
+
+		<-reg.stopped
+
+		startErr := start(reg.conn)
+
+		if startErr == nil {
+			return reg.conn, nil
+		}
+
+		reg.conn.Close()
+
+		d.registry.logger.Debugf("Failed to start registered connection "+
+			"\"%s\" due to error: %s", reg.conn.RemoteAddr(), startErr)
+	}
+}
+
+// String returns the address of the Registry
+func (d registryDial) String() string {
+	return d.registry.listener.String()
+}
+
+// interrupt wakes up the pending read of the keepalive so it will release
+// the connection. The deadline is set on the wrapped net.Conn if possible,
+// as it's safe to be called while the connection is been read
+func interrupt(conn network.Connection) {
+	u, isUnwrapper := conn.(unwrapper)
+
+	if !isUnwrapper {
+		conn.SetReadDeadline(time.Now())
+
+		return
+	}
+
+	u.Unwrap().SetReadDeadline(time.Now())
+}
+
+// start tells the reverse Listener that the connection is going to be
+// used, and waits for its confirmation
+func start(conn network.Connection) error {
+	conn.SetDeadline(time.Now().Add(pingDelay))
+
+	_, wErr := rw.WriteFull(conn, []byte{signalStart})
+
+	if wErr != nil {
+		return wErr
+	}
+
+	buf := [1]byte{}
+
+	for {
+		_, rErr := io.ReadFull(conn, buf[:])
+
+		if rErr != nil {
+			return rErr
+		}
+
+		switch buf[0] {
+		case signalPing:
+			continue
+
+		case signalStarted:
+			return conn.SetDeadline(time.Time{})
+
+		default:
+			return ErrUnexpectedSignal
+		}
+	}
+}
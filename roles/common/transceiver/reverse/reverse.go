@@ -0,0 +1,63 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package reverse
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors
+var (
+	ErrListenerClosed = errors.New(
+		"Reverse Listener is closed")
+
+	ErrRegistryClosed = errors.New(
+		"Registry is not serving")
+
+	ErrRegistryAlreadyServing = errors.New(
+		"Registry is already serving")
+
+	ErrRegistryUnavailable = errors.New(
+		"No registered connection is available")
+
+	ErrUnexpectedSignal = errors.New(
+		"Unexpected signal received from a registered connection")
+
+	ErrAuthenticationFailed = errors.New(
+		"Registrant has failed to seal the challenge with the Codec")
+)
+
+// Signals exchanged on a registered connection before it's been used
+const (
+	signalPing    = 0x01
+	signalStart   = 0x02
+	signalStarted = 0x03
+)
+
+// Consts
+const (
+	pingDelay       = 5 * time.Second
+	pingTimeout     = 3 * pingDelay
+	redialDelay     = 3 * time.Second
+	acceptErrorWait = 300 * time.Millisecond
+	challengeSize   = 32
+)
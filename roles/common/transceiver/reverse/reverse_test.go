@@ -0,0 +1,316 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package reverse
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/codec"
+	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
+	tcpdial "github.com/reinit/coward/roles/common/network/dialer/tcp"
+	tcplisten "github.com/reinit/coward/roles/common/network/listener/tcp"
+)
+
+func testFreePort(t *testing.T) uint16 {
+	l, lErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if lErr != nil {
+		t.Error("Failed to find a free port due to error:", lErr)
+
+		return 0
+	}
+
+	defer l.Close()
+
+	return uint16(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestReverse(t *testing.T) {
+	log := logger.NewDitch()
+	port := testFreePort(t)
+
+	if port == 0 {
+		return
+	}
+
+	reg := NewRegistry(tcplisten.New(
+		net.ParseIP("127.0.0.1"), port, tcpconn.Wrap),
+		codec.AESGCM128().Build([]string{"Test Key 1234567890"}),
+		2, 3*time.Second, log)
+
+	_, dialErr := reg.Dialer().Dial()
+
+	if dialErr != ErrRegistryClosed {
+		t.Errorf("Expecting error %s, got %s", ErrRegistryClosed, dialErr)
+
+		return
+	}
+
+	serveErr := reg.Serve()
+
+	if serveErr != nil {
+		t.Error("Failed to serve Registry due to error:", serveErr)
+
+		return
+	}
+
+	defer reg.Close()
+
+	acc, listenErr := NewListener(tcpdial.New(
+		"127.0.0.1", port, time.Second, tcpconn.Wrap),
+		codec.AESGCM128().Build([]string{"Test Key 1234567890"}),
+		2, log).Listen()
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return
+	}
+
+	defer acc.Close()
+
+	for i := 0; i < 3; i++ {
+		clientConn, dialErr := reg.Dialer().Dial()
+
+		if dialErr != nil {
+			t.Error("Failed to dial due to error:", dialErr)
+
+			return
+		}
+
+		serverConn, accErr := acc.Accept()
+
+		if accErr != nil {
+			t.Error("Failed to accept due to error:", accErr)
+
+			return
+		}
+
+		_, wErr := rw.WriteFull(clientConn, []byte("Hello"))
+
+		if wErr != nil {
+			t.Error("Failed to write due to error:", wErr)
+
+			return
+		}
+
+		buf := [5]byte{}
+
+		_, rErr := io.ReadFull(serverConn, buf[:])
+
+		if rErr != nil {
+			t.Error("Failed to read due to error:", rErr)
+
+			return
+		}
+
+		if string(buf[:]) != "Hello" {
+			t.Errorf("Expecting data to be %s, got %s", "Hello", buf[:])
+
+			return
+		}
+
+		clientConn.Close()
+		serverConn.Close()
+	}
+
+	closeErr := reg.Close()
+
+	if closeErr != nil {
+		t.Error("Failed to close Registry due to error:", closeErr)
+
+		return
+	}
+
+	_, dialErr = reg.Dialer().Dial()
+
+	if dialErr != ErrRegistryClosed {
+		t.Errorf("Expecting error %s, got %s", ErrRegistryClosed, dialErr)
+
+		return
+	}
+}
+
+func TestReverseUnauthenticated(t *testing.T) {
+	log := logger.NewDitch()
+	port := testFreePort(t)
+
+	if port == 0 {
+		return
+	}
+
+	reg := NewRegistry(tcplisten.New(
+		net.ParseIP("127.0.0.1"), port, tcpconn.Wrap),
+		codec.AESGCM128().Build([]string{"Test Key 1234567890"}),
+		2, 1*time.Second, log)
+
+	serveErr := reg.Serve()
+
+	if serveErr != nil {
+		t.Error("Failed to serve Registry due to error:", serveErr)
+
+		return
+	}
+
+	defer reg.Close()
+
+	acc, listenErr := NewListener(tcpdial.New(
+		"127.0.0.1", port, time.Second, tcpconn.Wrap),
+		codec.AESGCM128().Build([]string{"Wrong Key 1234567890"}),
+		2, log).Listen()
+
+	if listenErr != nil {
+		t.Error("Failed to listen due to error:", listenErr)
+
+		return
+	}
+
+	defer acc.Close()
+
+	conn, dialErr := net.Dial("tcp", net.JoinHostPort(
+		"127.0.0.1", strconv.FormatUint(uint64(port), 10)))
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+
+	challenged := [challengeSize]byte{}
+
+	_, rErr := io.ReadFull(conn, challenged[:])
+
+	if rErr != nil {
+		t.Error("Failed to read the challenge due to error:", rErr)
+
+		return
+	}
+
+	// Send back the challenge without sealing it
+	_, wErr := rw.WriteFull(conn, challenged[:])
+
+	if wErr != nil {
+		t.Error("Failed to write due to error:", wErr)
+
+		return
+	}
+
+	_, rErr = conn.Read(challenged[:])
+
+	if netErr, isNetErr := rErr.(net.Error); rErr == nil ||
+		(isNetErr && netErr.Timeout()) {
+		t.Errorf("Expecting the registration to be closed, got %v", rErr)
+
+		return
+	}
+
+	_, dialErr = reg.Dialer().Dial()
+
+	if dialErr != ErrRegistryUnavailable {
+		t.Errorf("Expecting error %s, got %v", ErrRegistryUnavailable,
+			dialErr)
+
+		return
+	}
+}
+
+func TestReversePendingLimit(t *testing.T) {
+	log := logger.NewDitch()
+	port := testFreePort(t)
+
+	if port == 0 {
+		return
+	}
+
+	reg := NewRegistry(tcplisten.New(
+		net.ParseIP("127.0.0.1"), port, tcpconn.Wrap),
+		codec.AESGCM128().Build([]string{"Test Key 1234567890"}),
+		2, 1*time.Second, log)
+
+	serveErr := reg.Serve()
+
+	if serveErr != nil {
+		t.Error("Failed to serve Registry due to error:", serveErr)
+
+		return
+	}
+
+	defer reg.Close()
+
+	address := net.JoinHostPort(
+		"127.0.0.1", strconv.FormatUint(uint64(port), 10))
+	challenged := [challengeSize]byte{}
+
+	// Hold all pending slots by never answering the challenge
+	for pIdx := 0; pIdx < 2; pIdx++ {
+		conn, dialErr := net.Dial("tcp", address)
+
+		if dialErr != nil {
+			t.Error("Failed to dial due to error:", dialErr)
+
+			return
+		}
+
+		defer conn.Close()
+
+		conn.SetDeadline(time.Now().Add(3 * time.Second))
+
+		_, rErr := io.ReadFull(conn, challenged[:])
+
+		if rErr != nil {
+			t.Error("Failed to read the challenge due to error:", rErr)
+
+			return
+		}
+	}
+
+	conn, dialErr := net.Dial("tcp", address)
+
+	if dialErr != nil {
+		t.Error("Failed to dial due to error:", dialErr)
+
+		return
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+
+	_, rErr := io.ReadFull(conn, challenged[:])
+
+	if netErr, isNetErr := rErr.(net.Error); rErr == nil ||
+		(isNetErr && netErr.Timeout()) {
+		t.Errorf("Expecting the registration over the pending limit to "+
+			"be closed, got %v", rErr)
+
+		return
+	}
+}
@@ -41,6 +41,7 @@ type handler struct {
 	conn       connection.Virtual
 	commands   command.Commands
 	runningCmd fsm.FSM
+	completer  command.Completer
 }
 
 func (h *handler) Bootup() (fsm.State, error) {
@@ -71,7 +72,8 @@ func (h *handler) Bootup() (fsm.State, error) {
 		return nil, cmdSelectErr
 	}
 
-	cmdRunner := fsm.New(cmd.New(h.conn, h.logger))
+	cmdMachine := cmd.New(h.conn, h.logger)
+	cmdRunner := fsm.New(cmdMachine)
 
 	// Notice the Bootup will continue reading the segment rather than
 	// request other one. So don't clear the h.conn before Bootup is called.
@@ -84,6 +86,7 @@ func (h *handler) Bootup() (fsm.State, error) {
 	}
 
 	h.runningCmd = cmdRunner
+	h.completer, _ = cmdMachine.(command.Completer)
 
 	h.logger.Debugf("Handling Command %d", cmd.ID())
 
@@ -91,6 +94,20 @@ func (h *handler) Bootup() (fsm.State, error) {
 }
 
 func (h *handler) run(f fsm.FSM) error {
+	// If the Command has completed by itself, current segment is the
+	// beginning of a new Command
+	if h.completer != nil && h.completer.Completed() {
+		sdErr := f.Shutdown()
+
+		if sdErr != nil {
+			h.conn.Done()
+
+			return sdErr
+		}
+
+		return f.Bootup()
+	}
+
 	tErr := h.runningCmd.Tick()
 
 	if tErr != nil {
@@ -111,6 +128,7 @@ func (h *handler) Shutdown() error {
 
 	runningCmd := h.runningCmd
 	h.runningCmd = nil
+	h.completer = nil
 
 	if !runningCmd.Running() {
 		h.logger.Debugf("Request completed")
@@ -82,8 +82,9 @@ func (s *server) Handle(
 	defer channelized.Shutdown()
 
 	channels := channel.New(func(id channel.ID) fsm.Machine {
-		sLog := log.Context(
-			"Channel (" + strconv.FormatUint(uint64(id), 10) + ")")
+		sLog := logger.WithField(log.Context(
+			"Channel ("+strconv.FormatUint(uint64(id), 10)+")"),
+			logger.FieldChannel, strconv.FormatUint(uint64(id), 10))
 
 		channelConn := channelized.For(id)
 
@@ -120,6 +121,12 @@ func (s *server) Handle(
 
 		channelID, machine, chGetErr := channelized.Dispatch(channels)
 
+		if chGetErr == connection.ErrChannelConnectionHandedOver {
+			log.Debugf("Connection has been handed over")
+
+			return nil
+		}
+
 		if chGetErr != nil {
 			connErr, isConnErr := chGetErr.(connection.Error)
 
@@ -0,0 +1,187 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"container/list"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// cacheEntry is a cached answer
+type cacheEntry struct {
+	key    string
+	answer []byte
+	stored time.Time
+	expire time.Time
+}
+
+// cache caches DNS answers, and evicts the least recently used one when
+// it's full
+type cache struct {
+	maxSize     uint32
+	maxTTL      time.Duration
+	negativeTTL time.Duration
+	entries     map[string]*list.Element
+	order       *list.List
+	lock        sync.Mutex
+}
+
+// newCache creates a new cache
+func newCache(
+	maxSize uint32, maxTTL time.Duration, negativeTTL time.Duration) *cache {
+	return &cache{
+		maxSize:     maxSize,
+		maxTTL:      maxTTL,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]*list.Element, maxSize),
+		order:       list.New(),
+		lock:        sync.Mutex{},
+	}
+}
+
+// Get returns a copy of the cached answer for the query with the query ID
+// and remaining TTLs applied
+func (c *cache) Get(q question, query []byte, now time.Time) ([]byte, bool) {
+	c.lock.Lock()
+
+	element, found := c.entries[q.key()]
+
+	if !found {
+		c.lock.Unlock()
+
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+
+	if !now.Before(entry.expire) {
+		c.order.Remove(element)
+
+		delete(c.entries, entry.key)
+
+		c.lock.Unlock()
+
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+
+	answer := make([]byte, len(entry.answer))
+
+	copy(answer, entry.answer)
+
+	stored := entry.stored
+
+	c.lock.Unlock()
+
+	if messageDecreaseTTL(answer, uint32(now.Sub(stored)/time.Second)) != nil {
+		return nil, false
+	}
+
+	copy(answer[0:2], query[0:2])
+
+	return answer, true
+}
+
+// Set caches the answer if it's cacheable. Successful answers are cached
+// for the minimum TTL of it's records, negative answers without any record
+// are cached for the negativeTTL
+func (c *cache) Set(q question, answer []byte, now time.Time) {
+	if len(answer) < messageHeaderSize || messageTruncated(answer) {
+		return
+	}
+
+	switch messageRCode(answer) {
+	case rCodeSuccess:
+	case rCodeNameNotFound:
+	default:
+		return
+	}
+
+	minTTL, hasRecord, ttlErr := messageMinTTL(answer)
+
+	if ttlErr != nil {
+		return
+	}
+
+	ttl := time.Duration(minTTL) * time.Second
+
+	if !hasRecord {
+		ttl = c.negativeTTL
+	}
+
+	if ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+
+	if ttl <= 0 {
+		return
+	}
+
+	entry := &cacheEntry{
+		key:    q.key(),
+		answer: make([]byte, len(answer)),
+		stored: now,
+		expire: now.Add(ttl),
+	}
+
+	copy(entry.answer, answer)
+
+	// Clear the ID so cached answers don't carry the ID of another client
+	binary.BigEndian.PutUint16(entry.answer[0:2], 0)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, found := c.entries[entry.key]
+
+	if found {
+		element.Value = entry
+
+		c.order.MoveToFront(element)
+
+		return
+	}
+
+	for uint32(c.order.Len()) >= c.maxSize {
+		oldest := c.order.Back()
+
+		if oldest == nil {
+			break
+		}
+
+		c.order.Remove(oldest)
+
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+
+	c.entries[entry.key] = c.order.PushFront(entry)
+}
+
+// Size returns how many answers are cached
+func (c *cache) Size() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.order.Len()
+}
@@ -0,0 +1,150 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := newCache(2, time.Hour, 30*time.Second)
+	now := time.Now()
+
+	query := testQuery(1, "a.example.com", 1)
+	q, _ := parseQuestion(query)
+
+	c.Set(q, testAnswer(query, rCodeSuccess, 60), now)
+
+	answer, found := c.Get(q, testQuery(2, "A.example.com", 1), now.Add(10*time.Second))
+
+	if !found {
+		t.Error("Expecting answer to be cached")
+
+		return
+	}
+
+	if answer[0] != 0 || answer[1] != 2 {
+		t.Errorf("Expecting cached answer to carry query ID 2, got %v",
+			answer[0:2])
+
+		return
+	}
+
+	minTTL, _, _ := messageMinTTL(answer)
+
+	if minTTL != 50 {
+		t.Errorf("Expecting remaining TTL 50, got %d", minTTL)
+
+		return
+	}
+
+	_, found = c.Get(q, query, now.Add(60*time.Second))
+
+	if found {
+		t.Error("Expecting cached answer to be expired")
+
+		return
+	}
+
+	if c.Size() != 0 {
+		t.Errorf("Expecting expired answer to be removed, got %d", c.Size())
+
+		return
+	}
+}
+
+func TestCacheNotCacheable(t *testing.T) {
+	c := newCache(2, time.Hour, 30*time.Second)
+	now := time.Now()
+
+	query := testQuery(1, "example.com", 1)
+	q, _ := parseQuestion(query)
+
+	c.Set(q, testAnswer(query, rCodeServerFailure), now)
+	c.Set(q, testAnswer(query, rCodeSuccess, 0), now)
+
+	truncated := testAnswer(query, rCodeSuccess, 60)
+	truncated[2] |= messageFlagTC
+
+	c.Set(q, truncated, now)
+
+	if c.Size() != 0 {
+		t.Errorf("Expecting nothing to be cached, got %d", c.Size())
+
+		return
+	}
+
+	c.Set(q, testAnswer(query, rCodeNameNotFound), now)
+
+	_, found := c.Get(q, query, now.Add(29*time.Second))
+
+	if !found {
+		t.Error("Expecting negative answer to be cached")
+
+		return
+	}
+
+	_, found = c.Get(q, query, now.Add(30*time.Second))
+
+	if found {
+		t.Error("Expecting negative answer to be expired")
+
+		return
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := newCache(2, time.Hour, 30*time.Second)
+	now := time.Now()
+	questions := make([]question, 3)
+
+	for qIdx, name := range []string{"a.com", "b.com", "c.com"} {
+		query := testQuery(1, name, 1)
+
+		questions[qIdx], _ = parseQuestion(query)
+
+		c.Set(questions[qIdx], testAnswer(query, rCodeSuccess, 60), now)
+
+		if qIdx == 1 {
+			// Use a.com so b.com become the least recently used one
+			c.Get(questions[0], query, now)
+		}
+	}
+
+	if c.Size() != 2 {
+		t.Errorf("Expecting 2 cached answers, got %d", c.Size())
+
+		return
+	}
+
+	if _, found := c.Get(questions[1], testQuery(1, "b.com", 1), now); found {
+		t.Error("Expecting b.com to be evicted")
+
+		return
+	}
+
+	if _, found := c.Get(questions[0], testQuery(1, "a.com", 1), now); !found {
+		t.Error("Expecting a.com to be kept")
+
+		return
+	}
+}
@@ -0,0 +1,146 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/reinit/coward/common/rw"
+)
+
+// Errors
+var (
+	ErrDirectQueryTooLarge = errors.New(
+		"DNS query is too large")
+)
+
+// Consts
+const (
+	directMaxMessageSize = 65535
+)
+
+// exchangeDirect sends the query to the server over UDP and returns it's
+// answer. The query will be retried over TCP when the answer is truncated
+func exchangeDirect(
+	server *net.UDPAddr, query []byte, timeout time.Duration,
+) ([]byte, error) {
+	deadline := time.Now().Add(timeout)
+
+	conn, dialErr := net.DialUDP("udp", nil, server)
+
+	if dialErr != nil {
+		return nil, dialErr
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(deadline)
+
+	_, wErr := conn.Write(query)
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	buf := make([]byte, directMaxMessageSize)
+
+	for {
+		rLen, rErr := conn.Read(buf)
+
+		if rErr != nil {
+			return nil, rErr
+		}
+
+		if rLen < messageHeaderSize || buf[0] != query[0] ||
+			buf[1] != query[1] || buf[2]&messageFlagQR == 0 {
+			continue
+		}
+
+		if messageTruncated(buf[:rLen]) {
+			return exchangeDirectTCP(server, query, deadline)
+		}
+
+		answer := make([]byte, rLen)
+
+		copy(answer, buf[:rLen])
+
+		return answer, nil
+	}
+}
+
+// exchangeDirectTCP sends the query to the server over TCP
+func exchangeDirectTCP(
+	server *net.UDPAddr, query []byte, deadline time.Time,
+) ([]byte, error) {
+	if len(query) > directMaxMessageSize {
+		return nil, ErrDirectQueryTooLarge
+	}
+
+	conn, dialErr := net.DialTimeout(
+		"tcp", server.String(), time.Until(deadline))
+
+	if dialErr != nil {
+		return nil, dialErr
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(deadline)
+
+	request := make([]byte, len(query)+2)
+
+	binary.BigEndian.PutUint16(request[0:2], uint16(len(query)))
+
+	copy(request[2:], query)
+
+	_, wErr := rw.WriteFull(conn, request)
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	sizeBuf := [2]byte{}
+
+	_, rErr := io.ReadFull(conn, sizeBuf[:])
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	answer := make([]byte, binary.BigEndian.Uint16(sizeBuf[:]))
+
+	_, rErr = io.ReadFull(conn, answer)
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	if len(answer) < messageHeaderSize ||
+		answer[0] != query[0] || answer[1] != query[1] {
+		return nil, ErrMessageInvalid
+	}
+
+	return answer, nil
+}
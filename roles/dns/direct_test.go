@@ -0,0 +1,238 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+)
+
+type testDirectServer struct {
+	udp      *net.UDPConn
+	tcp      *net.TCPListener
+	truncate bool
+	queries  uint32
+}
+
+func newTestDirectServer(t *testing.T, truncate bool) *testDirectServer {
+	tcpListener, tcpErr := net.ListenTCP("tcp", &net.TCPAddr{
+		IP: net.IPv4(127, 0, 0, 1), Port: 0, Zone: ""})
+
+	if tcpErr != nil {
+		t.Fatal("Failed to listen TCP due to error:", tcpErr)
+	}
+
+	udpListener, udpErr := net.ListenUDP("udp", &net.UDPAddr{
+		IP:   net.IPv4(127, 0, 0, 1),
+		Port: tcpListener.Addr().(*net.TCPAddr).Port,
+		Zone: "",
+	})
+
+	if udpErr != nil {
+		tcpListener.Close()
+
+		t.Fatal("Failed to listen UDP due to error:", udpErr)
+	}
+
+	s := &testDirectServer{
+		udp:      udpListener,
+		tcp:      tcpListener,
+		truncate: truncate,
+		queries:  0,
+	}
+
+	go s.serveUDP()
+	go s.serveTCP()
+
+	return s
+}
+
+func (s *testDirectServer) Addr() *net.UDPAddr {
+	return s.udp.LocalAddr().(*net.UDPAddr)
+}
+
+func (s *testDirectServer) Queries() uint32 {
+	return atomic.LoadUint32(&s.queries)
+}
+
+func (s *testDirectServer) serveUDP() {
+	buf := make([]byte, 512)
+
+	for {
+		rLen, rAddr, rErr := s.udp.ReadFromUDP(buf)
+
+		if rErr != nil {
+			return
+		}
+
+		atomic.AddUint32(&s.queries, 1)
+
+		answer := testAnswer(buf[:rLen], rCodeSuccess, 60)
+
+		if s.truncate {
+			answer = messageFailure(buf[:rLen], rCodeSuccess)
+			answer[2] |= messageFlagTC
+		}
+
+		s.udp.WriteToUDP(answer, rAddr)
+	}
+}
+
+func (s *testDirectServer) serveTCP() {
+	for {
+		conn, acceptErr := s.tcp.Accept()
+
+		if acceptErr != nil {
+			return
+		}
+
+		sizeBuf := [2]byte{}
+
+		if _, rErr := io.ReadFull(conn, sizeBuf[:]); rErr != nil {
+			conn.Close()
+
+			continue
+		}
+
+		query := make([]byte, binary.BigEndian.Uint16(sizeBuf[:]))
+
+		if _, rErr := io.ReadFull(conn, query); rErr != nil {
+			conn.Close()
+
+			continue
+		}
+
+		answer := testAnswer(query, rCodeSuccess, 60, 60)
+		response := make([]byte, len(answer)+2)
+
+		binary.BigEndian.PutUint16(response[0:2], uint16(len(answer)))
+
+		copy(response[2:], answer)
+
+		conn.Write(response)
+		conn.Close()
+	}
+}
+
+func (s *testDirectServer) Close() {
+	s.udp.Close()
+	s.tcp.Close()
+}
+
+func TestExchangeDirect(t *testing.T) {
+	server := newTestDirectServer(t, false)
+	defer server.Close()
+
+	query := testQuery(0x4321, "host.corp.example", 1)
+
+	answer, exErr := exchangeDirect(server.Addr(), query, time.Second)
+
+	if exErr != nil {
+		t.Error("Failed to exchange due to error:", exErr)
+
+		return
+	}
+
+	if answer[0] != 0x43 || answer[1] != 0x21 ||
+		binary.BigEndian.Uint16(answer[6:8]) != 1 {
+		t.Errorf("Unexpected answer: %v", answer)
+
+		return
+	}
+}
+
+func TestExchangeDirectTruncated(t *testing.T) {
+	server := newTestDirectServer(t, true)
+	defer server.Close()
+
+	answer, exErr := exchangeDirect(
+		server.Addr(), testQuery(1, "host.corp.example", 1), time.Second)
+
+	if exErr != nil {
+		t.Error("Failed to exchange due to error:", exErr)
+
+		return
+	}
+
+	// Only the TCP server answers with two records
+	if binary.BigEndian.Uint16(answer[6:8]) != 2 || messageTruncated(answer) {
+		t.Errorf("Expecting answer to be received through TCP, got %v",
+			answer)
+
+		return
+	}
+}
+
+func TestForwarderSplitAndCache(t *testing.T) {
+	server := newTestDirectServer(t, false)
+	defer server.Close()
+
+	fwd := forwarder{
+		transceiver: nil,
+		runner:      nil,
+		shb:         nil,
+		upstream:    nil,
+		rules:       Rules{{Domain: "corp.example", Server: server.Addr()}},
+		cache:       newCache(16, time.Hour, 30*time.Second),
+		timeout:     time.Second,
+	}
+
+	log := logger.NewDitch()
+
+	for qIdx := uint16(0); qIdx < 3; qIdx++ {
+		answer, fwdErr := fwd.Forward(
+			log, testQuery(qIdx, "host.corp.example", 1), nil)
+
+		if fwdErr != nil {
+			t.Error("Failed to forward due to error:", fwdErr)
+
+			return
+		}
+
+		if binary.BigEndian.Uint16(answer[0:2]) != qIdx {
+			t.Errorf("Expecting answer ID %d, got %v", qIdx, answer[0:2])
+
+			return
+		}
+	}
+
+	if server.Queries() != 1 {
+		t.Errorf("Expecting only 1 query to reach the server, got %d",
+			server.Queries())
+
+		return
+	}
+
+	answer, fwdErr := fwd.Forward(log, testQuery(9, "host.corp.example", 1)[:14], nil)
+
+	if fwdErr == nil || messageRCode(answer) != rCodeFormatError {
+		t.Errorf("Expecting a format error answer, got %v (%v)",
+			answer, fwdErr)
+
+		return
+	}
+}
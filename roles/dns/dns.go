@@ -0,0 +1,287 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/role"
+	"github.com/reinit/coward/common/ticker"
+	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/network"
+	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
+	udpconn "github.com/reinit/coward/roles/common/network/connection/udp"
+	tcplistener "github.com/reinit/coward/roles/common/network/listener/tcp"
+	udplistener "github.com/reinit/coward/roles/common/network/listener/udp"
+	"github.com/reinit/coward/roles/common/network/server"
+	"github.com/reinit/coward/roles/common/transceiver"
+	pcommon "github.com/reinit/coward/roles/proxy/common"
+	"github.com/reinit/coward/roles/socks5/common"
+)
+
+type dns struct {
+	clients         transceiver.Balancer
+	log             logger.Logger
+	cfg             Config
+	transceiver     transceiver.Balanced
+	ticker          ticker.RequestCloser
+	servers         []network.Serving
+	runner          worker.Runner
+	unspawnNotifier role.UnspawnNotifier
+}
+
+// New creates a new DNS server
+func New(
+	ticker ticker.RequestCloser,
+	balancer transceiver.Balancer,
+	log logger.Logger,
+	cfg Config,
+) role.Role {
+	return &dns{
+		clients:         balancer,
+		log:             log.Context("DNS"),
+		cfg:             cfg,
+		transceiver:     nil,
+		ticker:          ticker,
+		servers:         nil,
+		runner:          nil,
+		unspawnNotifier: nil,
+	}
+}
+
+func (s *dns) Spawn(unspawnNotifier role.UnspawnNotifier) error {
+	s.unspawnNotifier = unspawnNotifier
+
+	// Open transceiver client first
+	trServes, trServeErr := s.clients.Serve()
+
+	if trServeErr != nil {
+		s.log.Errorf("Failed to start Transceivers due to error: %s",
+			trServeErr)
+
+		return trServeErr
+	}
+
+	s.transceiver = trServes
+
+	// Start Corunner. Every client takes one worker, and every query sent
+	// through the Proxy takes another
+	minWorkers := pcommon.AutomaticalMinWorkerCount(s.cfg.Capacity*4, 128)
+
+	runner, runnerServeErr := worker.New(s.log, s.ticker, worker.Config{
+		MaxWorkers:        s.cfg.Capacity * 4,
+		MinWorkers:        minWorkers,
+		MaxWorkerIdle:     s.cfg.ConnectionTimeout * 2,
+		JobReceiveTimeout: s.cfg.QueryTimeout,
+		MaxQueueLatency:   pcommon.WorkerMaxQueueLatency,
+		MemoryLimit:       s.cfg.MemoryLimit,
+		MaxQueued:         minWorkers,
+	}).Serve()
+
+	if runnerServeErr != nil {
+		return runnerServeErr
+	}
+
+	s.runner = runner
+
+	// Build Transceiver Client read buffer
+	shb := &common.SharedBuffers{
+		Buf: make([]*common.SharedBuffer, s.transceiver.Size()),
+	}
+
+	s.transceiver.Clients(func(
+		client transceiver.ClientID,
+		req transceiver.Requester,
+	) {
+		shb.Buf[client] = &common.SharedBuffer{
+			Buffer: make([]byte, 4096*req.Connections()),
+			Size:   4096,
+		}
+	})
+
+	var answerCache *cache
+
+	if s.cfg.CacheSize > 0 {
+		answerCache = newCache(s.cfg.CacheSize,
+			pcommon.DNSCacheMaxTTL, pcommon.DNSCacheNegativeTTL)
+	}
+
+	fwd := forwarder{
+		transceiver: s.transceiver,
+		runner:      s.runner,
+		shb:         shb,
+		upstream:    s.cfg.Upstream,
+		rules:       s.cfg.Rules,
+		cache:       answerCache,
+		timeout:     s.cfg.QueryTimeout,
+	}
+
+	// Then, start servers. Stub resolvers usually send every query from a
+	// new port, so UDP clients are released soon after they're answered
+	udpServing, udpServeErr := server.New(udplistener.New(
+		s.cfg.Interface,
+		s.cfg.Port,
+		s.cfg.QueryTimeout,
+		s.cfg.Capacity,
+		make([]byte, 4096),
+		s.ticker,
+		udpconn.Wrap,
+	), udpHandler{
+		forwarder: fwd,
+		timeout:   s.cfg.QueryTimeout,
+	}, s.log.Context("UDP"), s.runner, server.Config{
+		AcceptErrorWait: 300 * time.Millisecond,
+		MaxConnections:  s.cfg.Capacity,
+	}).Serve()
+
+	if udpServeErr != nil {
+		s.log.Errorf("Failed to start UDP server due to error: %s",
+			udpServeErr)
+
+		return udpServeErr
+	}
+
+	s.servers = append(s.servers, udpServing)
+
+	tcpServing, tcpServeErr := server.New(tcplistener.New(
+		s.cfg.Interface,
+		s.cfg.Port,
+		tcpconn.Wrap,
+	), tcpHandler{
+		forwarder: fwd,
+		timeout:   s.cfg.ConnectionTimeout,
+	}, s.log.Context("TCP"), s.runner, server.Config{
+		AcceptErrorWait: 300 * time.Millisecond,
+		MaxConnections:  s.cfg.Capacity,
+	}).Serve()
+
+	if tcpServeErr != nil {
+		s.log.Errorf("Failed to start TCP server due to error: %s",
+			tcpServeErr)
+
+		return tcpServeErr
+	}
+
+	s.servers = append(s.servers, tcpServing)
+
+	s.log.Infof("Server is up, listening \"%s\" (UDP and TCP), "+
+		"forwarding queries to \"%s\"", udpServing.Listening(), s.cfg.Upstream)
+
+	return nil
+}
+
+// Drain stops accepting new queries and waits for ongoing ones to
+// complete, up to the timeout
+func (s *dns) Drain(timeout time.Duration) error {
+	var lastErr error
+
+	s.log.Infof("Draining")
+
+	deadline := time.Now().Add(timeout)
+
+	for sIdx := range s.servers {
+		drainErr := s.servers[sIdx].Drain(time.Until(deadline))
+
+		if drainErr == nil {
+			continue
+		}
+
+		s.log.Warningf("Failed to drain server \"%s\" due to error: %s",
+			s.servers[sIdx].Listening(), drainErr)
+
+		lastErr = drainErr
+	}
+
+	if lastErr != nil {
+		return lastErr
+	}
+
+	s.log.Infof("Server is drained")
+
+	return nil
+}
+
+// Release closes the ticker when the DNS server will never be spawned
+func (s *dns) Release() error {
+	if s.ticker != nil {
+		s.ticker.Close()
+		s.ticker = nil
+	}
+
+	return nil
+}
+
+func (s *dns) Unspawn() error {
+	s.log.Infof("Closing")
+
+	// Shutdown transceiver first so ongoing queries won't block servers
+	// from shutting down
+	if s.transceiver != nil {
+		trsmCloseErr := s.transceiver.Close()
+
+		if trsmCloseErr != nil {
+			s.log.Errorf(
+				"Failed to close Transceiver due to error: %s", trsmCloseErr)
+
+			return trsmCloseErr
+		}
+
+		s.transceiver = nil
+	}
+
+	for sIdx := range s.servers {
+		serverCloseErr := s.servers[sIdx].Close()
+
+		if serverCloseErr != nil {
+			s.log.Errorf("Failed to close server due to error: %s",
+				serverCloseErr)
+
+			return serverCloseErr
+		}
+	}
+
+	s.servers = nil
+
+	if s.runner != nil {
+		runnerCloseErr := s.runner.Close()
+
+		if runnerCloseErr != nil {
+			s.log.Errorf("Failed to close runner due to error: %s",
+				runnerCloseErr)
+
+			return runnerCloseErr
+		}
+
+		s.runner = nil
+	}
+
+	if s.ticker != nil {
+		s.ticker.Close()
+		s.ticker = nil
+	}
+
+	s.log.Infof("Server is closed")
+
+	s.unspawnNotifier <- struct{}{}
+
+	return nil
+}
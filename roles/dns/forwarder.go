@@ -0,0 +1,127 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"net"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/transceiver"
+	"github.com/reinit/coward/roles/dns/request"
+	"github.com/reinit/coward/roles/socks5/common"
+)
+
+// forwarder answers DNS queries from the cache, the split-horizon
+// servers or the Upstream behind the COWARD Proxy
+type forwarder struct {
+	transceiver transceiver.Balanced
+	runner      worker.Runner
+	shb         *common.SharedBuffers
+	upstream    *net.UDPAddr
+	rules       Rules
+	cache       *cache
+	timeout     time.Duration
+}
+
+// Forward answers the query. A failure answer will be returned together
+// with the error if the query can't be answered
+func (f forwarder) Forward(
+	log logger.Logger, query []byte, cancel <-chan struct{},
+) ([]byte, error) {
+	if len(query) < messageHeaderSize {
+		return nil, ErrMessageInvalid
+	}
+
+	q, qErr := parseQuestion(query)
+
+	if qErr != nil {
+		return messageFailure(query, rCodeFormatError), qErr
+	}
+
+	if f.cache != nil {
+		cached, cacheFound := f.cache.Get(q, query, time.Now())
+
+		if cacheFound {
+			log.Debugf("Answered \"%s\" from cache", q.Name)
+
+			return cached, nil
+		}
+	}
+
+	var answer []byte
+	var answerErr error
+
+	server, internal := f.rules.Match(q.Name)
+
+	if internal {
+		log.Debugf("Querying \"%s\" directly from \"%s\"", q.Name, server)
+
+		answer, answerErr = exchangeDirect(server, query, f.timeout)
+	} else {
+		log.Debugf("Querying \"%s\" from \"%s\" through Proxy",
+			q.Name, f.upstream)
+
+		answer, answerErr = f.proxy(log, query, cancel)
+	}
+
+	if answerErr != nil {
+		return messageFailure(query, rCodeServerFailure), answerErr
+	}
+
+	if f.cache != nil {
+		f.cache.Set(q, answer, time.Now())
+	}
+
+	return answer, nil
+}
+
+// proxy sends the query to the Upstream through the COWARD Proxy. The
+// answer is returned as soon as it's received, without waiting for the
+// request to be closed
+func (f forwarder) proxy(
+	log logger.Logger, query []byte, cancel <-chan struct{},
+) ([]byte, error) {
+	exchange := request.NewExchange(f.upstream, query, f.timeout)
+
+	reqResult, runErr := f.runner.Run(log, func(l logger.Logger) error {
+		return f.transceiver.Request(
+			l,
+			"DNS:"+transceiver.Destination(f.upstream.String()),
+			request.Query(exchange, f.runner, f.shb, cancel),
+			cancel)
+	}, cancel)
+
+	if runErr != nil {
+		return nil, runErr
+	}
+
+	select {
+	case <-exchange.Answered():
+	case reqErr := <-reqResult:
+		if reqErr != nil {
+			return nil, reqErr
+		}
+	}
+
+	return exchange.Answer()
+}
@@ -0,0 +1,167 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/network"
+)
+
+type udpHandler struct {
+	forwarder forwarder
+	timeout   time.Duration
+}
+
+type udpClient struct {
+	conn      network.Connection
+	logger    logger.Logger
+	forwarder forwarder
+	timeout   time.Duration
+}
+
+type tcpHandler struct {
+	forwarder forwarder
+	timeout   time.Duration
+}
+
+type tcpClient struct {
+	conn      network.Connection
+	logger    logger.Logger
+	forwarder forwarder
+	timeout   time.Duration
+}
+
+func (d udpHandler) New(
+	c network.Connection,
+	l logger.Logger,
+) (network.Client, error) {
+	return udpClient{
+		conn:      c,
+		logger:    l,
+		forwarder: d.forwarder,
+		timeout:   d.timeout,
+	}, nil
+}
+
+func (d udpClient) Serve() error {
+	d.logger.Debugf("Serving")
+	defer d.logger.Debugf("Closed")
+
+	d.conn.SetTimeout(d.timeout)
+
+	buf := make([]byte, 4096)
+
+	for {
+		rLen, rErr := d.conn.Read(buf)
+
+		if rErr != nil {
+			// UDP client is considered gone once it stopped sending
+			// queries for a while
+			return nil
+		}
+
+		answer, answerErr := d.forwarder.Forward(
+			d.logger, buf[:rLen], d.conn.Closed())
+
+		if answerErr != nil {
+			d.logger.Warningf("Failed to answer query: %s", answerErr)
+		}
+
+		if answer == nil {
+			continue
+		}
+
+		_, wErr := d.conn.Write(answer)
+
+		if wErr != nil {
+			return wErr
+		}
+	}
+}
+
+func (d tcpHandler) New(
+	c network.Connection,
+	l logger.Logger,
+) (network.Client, error) {
+	return tcpClient{
+		conn:      c,
+		logger:    l,
+		forwarder: d.forwarder,
+		timeout:   d.timeout,
+	}, nil
+}
+
+func (d tcpClient) Serve() error {
+	d.logger.Debugf("Serving")
+	defer d.logger.Debugf("Closed")
+
+	d.conn.SetTimeout(d.timeout)
+
+	sizeBuf := [2]byte{}
+
+	for {
+		_, rErr := io.ReadFull(d.conn, sizeBuf[:])
+
+		if rErr == io.EOF {
+			return nil
+		}
+
+		if rErr != nil {
+			return rErr
+		}
+
+		query := make([]byte, binary.BigEndian.Uint16(sizeBuf[:]))
+
+		_, rErr = io.ReadFull(d.conn, query)
+
+		if rErr != nil {
+			return rErr
+		}
+
+		answer, answerErr := d.forwarder.Forward(
+			d.logger, query, d.conn.Closed())
+
+		if answerErr != nil {
+			d.logger.Warningf("Failed to answer query: %s", answerErr)
+		}
+
+		if answer == nil {
+			return answerErr
+		}
+
+		response := make([]byte, len(answer)+2)
+
+		binary.BigEndian.PutUint16(response[0:2], uint16(len(answer)))
+
+		copy(response[2:], answer)
+
+		_, wErr := rw.WriteFull(d.conn, response)
+
+		if wErr != nil {
+			return wErr
+		}
+	}
+}
@@ -0,0 +1,286 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// Errors
+var (
+	ErrMessageInvalid = errors.New(
+		"Invalid DNS message")
+
+	ErrMessageUnsupportedQuestion = errors.New(
+		"DNS message must contain exactly one question")
+)
+
+// Consts
+const (
+	messageHeaderSize = 12
+	messageMaxNameLen = 255
+	messageTypeOPT    = 41
+	messageRCodeMask  = 0x0F
+	messageFlagQR     = 0x80
+	messageFlagTC     = 0x02
+)
+
+// DNS Response codes
+const (
+	rCodeSuccess       = 0
+	rCodeFormatError   = 1
+	rCodeServerFailure = 2
+	rCodeNameNotFound  = 3
+)
+
+// question is the question section of a DNS message
+type question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// key returns the cache key of the question
+func (q question) key() string {
+	return q.Name + "/" + string([]byte{
+		byte(q.Type >> 8), byte(q.Type), byte(q.Class >> 8), byte(q.Class)})
+}
+
+// messageSkipName returns the position right after the name which
+// starts at pos
+func messageSkipName(msg []byte, pos int) (int, error) {
+	for {
+		if pos >= len(msg) {
+			return 0, ErrMessageInvalid
+		}
+
+		labelLen := int(msg[pos])
+
+		switch {
+		case labelLen == 0:
+			return pos + 1, nil
+
+		case labelLen&0xC0 == 0xC0:
+			if pos+2 > len(msg) {
+				return 0, ErrMessageInvalid
+			}
+
+			return pos + 2, nil
+
+		case labelLen&0xC0 != 0:
+			return 0, ErrMessageInvalid
+		}
+
+		pos += labelLen + 1
+	}
+}
+
+// parseQuestion parses the only question of a DNS message
+func parseQuestion(msg []byte) (question, error) {
+	if len(msg) < messageHeaderSize {
+		return question{}, ErrMessageInvalid
+	}
+
+	if binary.BigEndian.Uint16(msg[4:6]) != 1 {
+		return question{}, ErrMessageUnsupportedQuestion
+	}
+
+	labels := make([]string, 0, 8)
+	pos := messageHeaderSize
+	nameLen := 0
+
+	for {
+		if pos >= len(msg) {
+			return question{}, ErrMessageInvalid
+		}
+
+		labelLen := int(msg[pos])
+
+		if labelLen == 0 {
+			pos++
+
+			break
+		}
+
+		// Compression is not expected in a question
+		if labelLen&0xC0 != 0 || pos+1+labelLen > len(msg) {
+			return question{}, ErrMessageInvalid
+		}
+
+		nameLen += labelLen + 1
+
+		if nameLen > messageMaxNameLen {
+			return question{}, ErrMessageInvalid
+		}
+
+		labels = append(labels, string(msg[pos+1:pos+1+labelLen]))
+
+		pos += labelLen + 1
+	}
+
+	if pos+4 > len(msg) {
+		return question{}, ErrMessageInvalid
+	}
+
+	return question{
+		Name:  strings.ToLower(strings.Join(labels, ".")),
+		Type:  binary.BigEndian.Uint16(msg[pos : pos+2]),
+		Class: binary.BigEndian.Uint16(msg[pos+2 : pos+4]),
+	}, nil
+}
+
+// messageRecords calls r with the TTL position and the type of every
+// resource record in the answer, authority and additional sections
+func messageRecords(msg []byte, r func(ttlPos int, rrType uint16)) error {
+	if len(msg) < messageHeaderSize {
+		return ErrMessageInvalid
+	}
+
+	questions := int(binary.BigEndian.Uint16(msg[4:6]))
+	records := int(binary.BigEndian.Uint16(msg[6:8])) +
+		int(binary.BigEndian.Uint16(msg[8:10])) +
+		int(binary.BigEndian.Uint16(msg[10:12]))
+	pos := messageHeaderSize
+
+	var skipErr error
+
+	for qIdx := 0; qIdx < questions; qIdx++ {
+		pos, skipErr = messageSkipName(msg, pos)
+
+		if skipErr != nil {
+			return skipErr
+		}
+
+		pos += 4
+	}
+
+	for rIdx := 0; rIdx < records; rIdx++ {
+		pos, skipErr = messageSkipName(msg, pos)
+
+		if skipErr != nil {
+			return skipErr
+		}
+
+		// Type 2, Class 2, TTL 4, RDLENGTH 2
+		if pos+10 > len(msg) {
+			return ErrMessageInvalid
+		}
+
+		rdLen := int(binary.BigEndian.Uint16(msg[pos+8 : pos+10]))
+
+		if pos+10+rdLen > len(msg) {
+			return ErrMessageInvalid
+		}
+
+		r(pos+4, binary.BigEndian.Uint16(msg[pos:pos+2]))
+
+		pos += 10 + rdLen
+	}
+
+	return nil
+}
+
+// messageMinTTL returns the minimum TTL of all resource records in the
+// message. The OPT pseudo record is not counted as it carries no TTL
+func messageMinTTL(msg []byte) (uint32, bool, error) {
+	minTTL := uint32(0)
+	found := false
+
+	walkErr := messageRecords(msg, func(ttlPos int, rrType uint16) {
+		if rrType == messageTypeOPT {
+			return
+		}
+
+		ttl := binary.BigEndian.Uint32(msg[ttlPos : ttlPos+4])
+
+		if !found || ttl < minTTL {
+			minTTL = ttl
+		}
+
+		found = true
+	})
+
+	if walkErr != nil {
+		return 0, false, walkErr
+	}
+
+	return minTTL, found, nil
+}
+
+// messageDecreaseTTL decreases TTL of all resource records in the message
+// by elapsed seconds
+func messageDecreaseTTL(msg []byte, elapsed uint32) error {
+	return messageRecords(msg, func(ttlPos int, rrType uint16) {
+		if rrType == messageTypeOPT {
+			return
+		}
+
+		ttl := binary.BigEndian.Uint32(msg[ttlPos : ttlPos+4])
+
+		if ttl > elapsed {
+			ttl -= elapsed
+		} else {
+			ttl = 0
+		}
+
+		binary.BigEndian.PutUint32(msg[ttlPos:ttlPos+4], ttl)
+	})
+}
+
+// messageRCode returns the response code of the message
+func messageRCode(msg []byte) byte {
+	return msg[3] & messageRCodeMask
+}
+
+// messageTruncated returns whether or not the message is truncated
+func messageTruncated(msg []byte) bool {
+	return msg[2]&messageFlagTC != 0
+}
+
+// messageFailure builds a answer of the query with the given rCode and
+// no records
+func messageFailure(query []byte, rCode byte) []byte {
+	qEnd := messageHeaderSize
+
+	if qPos, skipErr := messageSkipName(query, qEnd); skipErr == nil &&
+		qPos+4 <= len(query) && binary.BigEndian.Uint16(query[4:6]) == 1 {
+		qEnd = qPos + 4
+	}
+
+	answer := make([]byte, qEnd)
+
+	copy(answer, query[:qEnd])
+
+	answer[2] = (answer[2] | messageFlagQR) &^ messageFlagTC
+	answer[3] = 0x80 | rCode // Recursion Available
+
+	if qEnd == messageHeaderSize {
+		binary.BigEndian.PutUint16(answer[4:6], 0)
+	}
+
+	binary.BigEndian.PutUint16(answer[6:8], 0)
+	binary.BigEndian.PutUint16(answer[8:10], 0)
+	binary.BigEndian.PutUint16(answer[10:12], 0)
+
+	return answer
+}
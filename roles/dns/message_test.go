@@ -0,0 +1,180 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func testQuery(id uint16, name string, qType uint16) []byte {
+	query := make([]byte, messageHeaderSize)
+
+	binary.BigEndian.PutUint16(query[0:2], id)
+	binary.BigEndian.PutUint16(query[2:4], 0x0100) // Recursion Desired
+	binary.BigEndian.PutUint16(query[4:6], 1)
+
+	for _, label := range strings.Split(name, ".") {
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+
+	query = append(query, 0, byte(qType>>8), byte(qType), 0, 1)
+
+	return query
+}
+
+func testAnswer(query []byte, rCode byte, ttls ...uint32) []byte {
+	answer := make([]byte, len(query))
+
+	copy(answer, query)
+
+	answer[2] |= messageFlagQR
+	answer[3] = 0x80 | rCode
+
+	binary.BigEndian.PutUint16(answer[6:8], uint16(len(ttls)))
+
+	for _, ttl := range ttls {
+		answer = append(answer,
+			0xC0, messageHeaderSize, // Name pointer
+			0, 1, 0, 1, // A, IN
+			byte(ttl>>24), byte(ttl>>16), byte(ttl>>8), byte(ttl),
+			0, 4, 127, 0, 0, 1)
+	}
+
+	return answer
+}
+
+func TestParseQuestion(t *testing.T) {
+	q, qErr := parseQuestion(testQuery(1, "WWW.Example.COM", 28))
+
+	if qErr != nil {
+		t.Error("Failed to parse question due to error:", qErr)
+
+		return
+	}
+
+	if q.Name != "www.example.com" || q.Type != 28 || q.Class != 1 {
+		t.Errorf("Unexpected question: %+v", q)
+
+		return
+	}
+
+	noQuestion := testQuery(1, "example.com", 1)
+
+	binary.BigEndian.PutUint16(noQuestion[4:6], 0)
+
+	_, qErr = parseQuestion(noQuestion)
+
+	if qErr != ErrMessageUnsupportedQuestion {
+		t.Errorf("Expecting error %s, got %s",
+			ErrMessageUnsupportedQuestion, qErr)
+
+		return
+	}
+
+	query := testQuery(1, "example.com", 1)
+
+	_, qErr = parseQuestion(query[:len(query)-3])
+
+	if qErr != ErrMessageInvalid {
+		t.Errorf("Expecting error %s, got %s", ErrMessageInvalid, qErr)
+
+		return
+	}
+}
+
+func TestMessageTTL(t *testing.T) {
+	answer := testAnswer(testQuery(1, "example.com", 1), rCodeSuccess, 300, 60)
+
+	minTTL, found, ttlErr := messageMinTTL(answer)
+
+	if ttlErr != nil {
+		t.Error("Failed to read TTL due to error:", ttlErr)
+
+		return
+	}
+
+	if !found || minTTL != 60 {
+		t.Errorf("Expecting minimum TTL 60, got %d (%v)", minTTL, found)
+
+		return
+	}
+
+	decErr := messageDecreaseTTL(answer, 100)
+
+	if decErr != nil {
+		t.Error("Failed to decrease TTL due to error:", decErr)
+
+		return
+	}
+
+	minTTL, _, _ = messageMinTTL(answer)
+
+	if minTTL != 0 {
+		t.Errorf("Expecting TTL to be decreased to 0, got %d", minTTL)
+
+		return
+	}
+
+	_, found, _ = messageMinTTL(
+		testAnswer(testQuery(1, "example.com", 1), rCodeNameNotFound))
+
+	if found {
+		t.Error("Expecting no record to be found")
+
+		return
+	}
+
+	_, _, ttlErr = messageMinTTL(answer[:len(answer)-2])
+
+	if ttlErr != ErrMessageInvalid {
+		t.Errorf("Expecting error %s, got %s", ErrMessageInvalid, ttlErr)
+
+		return
+	}
+}
+
+func TestMessageFailure(t *testing.T) {
+	query := testQuery(0x1234, "example.com", 1)
+	failure := messageFailure(query, rCodeServerFailure)
+
+	if len(failure) != len(query) {
+		t.Errorf("Expecting the question to be kept, got %d bytes",
+			len(failure))
+
+		return
+	}
+
+	if failure[0] != 0x12 || failure[1] != 0x34 {
+		t.Error("Expecting query ID to be kept")
+
+		return
+	}
+
+	if failure[2]&messageFlagQR == 0 ||
+		messageRCode(failure) != rCodeServerFailure {
+		t.Errorf("Unexpected failure header: %v", failure[:4])
+
+		return
+	}
+}
@@ -0,0 +1,153 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/reinit/coward/common/fsm"
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/relay"
+	"github.com/reinit/coward/roles/common/transceiver"
+	"github.com/reinit/coward/roles/socks5/common"
+)
+
+// Errors
+var (
+	ErrQueryNotAnswered = errors.New(
+		"DNS query was not answered")
+)
+
+// Exchange is a DNS query which will be sent to the Upstream through
+// the COWARD Proxy
+type Exchange struct {
+	Upstream *net.UDPAddr
+	Query    []byte
+	Timeout  time.Duration
+
+	answer     []byte
+	answered   chan struct{}
+	answerOnce sync.Once
+}
+
+type query struct {
+	relay  relay.Relay
+	cancel <-chan struct{}
+}
+
+// NewExchange creates a new Exchange
+func NewExchange(
+	upstream *net.UDPAddr, q []byte, timeout time.Duration) *Exchange {
+	return &Exchange{
+		Upstream:   upstream,
+		Query:      q,
+		Timeout:    timeout,
+		answer:     nil,
+		answered:   make(chan struct{}),
+		answerOnce: sync.Once{},
+	}
+}
+
+// setAnswer saves the first received answer
+func (e *Exchange) setAnswer(answer []byte) {
+	e.answerOnce.Do(func() {
+		e.answer = answer
+
+		close(e.answered)
+	})
+}
+
+// Answered returns a channel which will be closed once the query is
+// answered
+func (e *Exchange) Answered() <-chan struct{} {
+	return e.answered
+}
+
+// Answer returns the answer of the query
+func (e *Exchange) Answer() ([]byte, error) {
+	select {
+	case <-e.answered:
+		return e.answer, nil
+
+	default:
+		return nil, ErrQueryNotAnswered
+	}
+}
+
+// Query returns a new DNS query request builder
+func Query(
+	exchange *Exchange,
+	runner worker.Runner,
+	shb *common.SharedBuffers,
+	cancel <-chan struct{},
+) transceiver.BalancedRequestBuilder {
+	return func(
+		cID transceiver.ClientID,
+		id transceiver.ConnectionID,
+		conn rw.ReadWriteDepleteDoner,
+		connCtl transceiver.ConnectionControl,
+		log logger.Logger,
+	) fsm.Machine {
+		return query{
+			relay: relay.New(
+				log, runner, conn, shb.For(cID).Select(id), &queryRelay{
+					exchange: exchange,
+					cancel:   cancel,
+				}, make([]byte, 4096)),
+			cancel: cancel,
+		}
+	}
+}
+
+func (q query) Bootup() (fsm.State, error) {
+	bootErr := q.relay.Bootup(q.cancel)
+
+	if bootErr != nil {
+		return nil, bootErr
+	}
+
+	return q.tick, nil
+}
+
+func (q query) tick(f fsm.FSM) error {
+	tErr := q.relay.Tick()
+
+	if tErr != nil {
+		return tErr
+	}
+
+	if !q.relay.Running() {
+		return f.Shutdown()
+	}
+
+	return nil
+}
+
+func (q query) Shutdown() error {
+	q.relay.Close()
+
+	return nil
+}
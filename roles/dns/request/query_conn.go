@@ -0,0 +1,171 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/reinit/coward/roles/proxy/request"
+)
+
+// Errors
+var (
+	ErrQueryInvalidUpstream = errors.New(
+		"Invalid DNS Upstream address")
+
+	ErrQueryTooLarge = errors.New(
+		"DNS query is too large")
+)
+
+// Consts
+const (
+	dnsHeaderSize = 12
+)
+
+type queryConn struct {
+	exchange  *Exchange
+	header    []byte
+	sent      bool
+	timeout   *time.Timer
+	cancel    <-chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// udpHeader builds the UDP Send address header of the upstream
+func udpHeader(upstream *net.UDPAddr) ([]byte, error) {
+	if upstream == nil {
+		return nil, ErrQueryInvalidUpstream
+	}
+
+	port := [2]byte{byte(upstream.Port >> 8), byte(upstream.Port)}
+
+	if ipAddr := upstream.IP.To4(); ipAddr != nil {
+		return []byte{
+			request.UDPSendIPv4,
+			ipAddr[0], ipAddr[1], ipAddr[2], ipAddr[3],
+			port[0], port[1]}, nil
+	}
+
+	if ipAddr := upstream.IP.To16(); ipAddr != nil {
+		header := make([]byte, 19)
+
+		header[0] = request.UDPSendIPv6
+
+		copy(header[1:17], ipAddr)
+
+		header[17] = port[0]
+		header[18] = port[1]
+
+		return header, nil
+	}
+
+	return nil, ErrQueryInvalidUpstream
+}
+
+// Read sends the query once, then waits until the query is answered,
+// timed out or canceled
+func (q *queryConn) Read(b []byte) (int, error) {
+	if !q.sent {
+		q.sent = true
+
+		if len(b) < len(q.header)+len(q.exchange.Query) {
+			return 0, ErrQueryTooLarge
+		}
+
+		rLen := copy(b, q.header)
+		rLen += copy(b[rLen:], q.exchange.Query)
+
+		return rLen, nil
+	}
+
+	select {
+	case <-q.exchange.answered:
+	case <-q.timeout.C:
+	case <-q.cancel:
+	case <-q.closed:
+	}
+
+	return 0, io.EOF
+}
+
+// Write receives the answer from the Upstream. Datagrams which is not an
+// answer of current query will be ignored
+func (q *queryConn) Write(b []byte) (int, error) {
+	bLen := len(b)
+	headerLen := 0
+
+	switch {
+	case bLen < 1:
+		return bLen, nil
+
+	case b[0] == request.UDPSendHost:
+		// Upstream IP may be reversed into a host name by the Proxy, so we
+		// can only rely on the query ID in this case
+		if bLen < 2 {
+			return bLen, nil
+		}
+
+		headerLen = int(b[1]) + 4
+
+	default:
+		if bLen < len(q.header) || !bytes.Equal(b[:len(q.header)], q.header) {
+			return bLen, nil
+		}
+
+		headerLen = len(q.header)
+	}
+
+	if bLen < headerLen+dnsHeaderSize {
+		return bLen, nil
+	}
+
+	answer := b[headerLen:]
+
+	// Answer must carry the same ID as the query, and the QR bit set
+	if answer[0] != q.exchange.Query[0] ||
+		answer[1] != q.exchange.Query[1] || answer[2]&0x80 == 0 {
+		return bLen, nil
+	}
+
+	answerCopy := make([]byte, len(answer))
+
+	copy(answerCopy, answer)
+
+	q.exchange.setAnswer(answerCopy)
+
+	return bLen, nil
+}
+
+func (q *queryConn) Close() error {
+	q.closeOnce.Do(func() {
+		q.timeout.Stop()
+
+		close(q.closed)
+	})
+
+	return nil
+}
@@ -0,0 +1,161 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reinit/coward/roles/proxy/request"
+)
+
+func testQueryConn(t *testing.T, exchange *Exchange) *queryConn {
+	header, headerErr := udpHeader(exchange.Upstream)
+
+	if headerErr != nil {
+		t.Fatal("Failed to build header due to error:", headerErr)
+	}
+
+	return &queryConn{
+		exchange:  exchange,
+		header:    header,
+		sent:      false,
+		timeout:   time.NewTimer(exchange.Timeout),
+		cancel:    nil,
+		closed:    make(chan struct{}),
+		closeOnce: sync.Once{},
+	}
+}
+
+func TestQueryConn(t *testing.T) {
+	query := []byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0}
+	exchange := NewExchange(&net.UDPAddr{
+		IP: net.IPv4(8, 8, 8, 8), Port: 53, Zone: ""}, query, time.Second)
+	conn := testQueryConn(t, exchange)
+	defer conn.Close()
+
+	buf := make([]byte, 128)
+
+	rLen, rErr := conn.Read(buf)
+
+	if rErr != nil {
+		t.Error("Failed to read due to error:", rErr)
+
+		return
+	}
+
+	header := []byte{request.UDPSendIPv4, 8, 8, 8, 8, 0, 53}
+
+	if rLen != len(header)+len(query) ||
+		string(buf[:len(header)]) != string(header) ||
+		string(buf[len(header):rLen]) != string(query) {
+		t.Errorf("Unexpected query data: %v", buf[:rLen])
+
+		return
+	}
+
+	answer := []byte{0x12, 0x34, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0}
+
+	// Answer from another address
+	conn.Write(append([]byte{request.UDPSendIPv4, 8, 8, 4, 4, 0, 53},
+		answer...))
+
+	// Answer with another ID
+	conn.Write(append(append([]byte{}, header...),
+		0x43, 0x21, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0))
+
+	if _, answerErr := exchange.Answer(); answerErr != ErrQueryNotAnswered {
+		t.Errorf("Expecting error %s, got %v", ErrQueryNotAnswered, answerErr)
+
+		return
+	}
+
+	// Upstream reversed into a host name by the Proxy
+	conn.Write(append([]byte{
+		request.UDPSendHost, 8, 'd', 'n', 's', '.', 't', 'e', 's', 't', 0, 53},
+		answer...))
+
+	received, answerErr := exchange.Answer()
+
+	if answerErr != nil {
+		t.Error("Failed to receive answer due to error:", answerErr)
+
+		return
+	}
+
+	if string(received) != string(answer) {
+		t.Errorf("Unexpected answer: %v", received)
+
+		return
+	}
+
+	_, rErr = conn.Read(buf)
+
+	if rErr != io.EOF {
+		t.Errorf("Expecting error %s, got %v", io.EOF, rErr)
+
+		return
+	}
+}
+
+func TestQueryConnTimeout(t *testing.T) {
+	exchange := NewExchange(&net.UDPAddr{
+		IP: net.ParseIP("fd00::53"), Port: 5353, Zone: ""},
+		[]byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0},
+		10*time.Millisecond)
+	conn := testQueryConn(t, exchange)
+	defer conn.Close()
+
+	buf := make([]byte, 128)
+
+	rLen, rErr := conn.Read(buf)
+
+	if rErr != nil {
+		t.Error("Failed to read due to error:", rErr)
+
+		return
+	}
+
+	if buf[0] != request.UDPSendIPv6 || rLen != 19+12 {
+		t.Errorf("Unexpected query data: %v", buf[:rLen])
+
+		return
+	}
+
+	_, rErr = conn.Read(buf)
+
+	if rErr != io.EOF {
+		t.Errorf("Expecting error %s, got %v", io.EOF, rErr)
+
+		return
+	}
+
+	_, answerErr := exchange.Answer()
+
+	if answerErr != ErrQueryNotAnswered {
+		t.Errorf("Expecting error %s, got %v", ErrQueryNotAnswered, answerErr)
+
+		return
+	}
+}
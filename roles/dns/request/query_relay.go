@@ -0,0 +1,118 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/relay"
+	"github.com/reinit/coward/roles/proxy/request"
+)
+
+// Errors
+var (
+	ErrQueryInvalidRequest = errors.New(
+		"Invalid DNS query request")
+
+	ErrQueryServerFailedToListen = errors.New(
+		"Remote has failed to initialize UDP listen")
+
+	ErrQueryServerRelayFailed = errors.New(
+		"Remote Relay failed to be initialized")
+
+	ErrQueryUnknownError = errors.New(
+		"Unknown DNS query error")
+)
+
+type queryRelay struct {
+	exchange *Exchange
+	cancel   <-chan struct{}
+}
+
+func (q *queryRelay) Initialize(l logger.Logger, server relay.Server) error {
+	_, wErr := rw.WriteFull(server, []byte{request.UDPCommandDelegate})
+
+	if wErr != nil {
+		return wErr
+	}
+
+	serverResp := [1]byte{}
+
+	_, crErr := io.ReadFull(server, serverResp[:])
+
+	if crErr != nil {
+		server.Done()
+
+		return crErr
+	}
+
+	server.Done()
+
+	switch serverResp[0] {
+	case request.UDPRespondOK:
+		return nil
+
+	case request.UDPRespondFailedToListen:
+		server.Goodbye()
+
+		return ErrQueryServerFailedToListen
+
+	case request.UDPRespondInvalidRequest:
+		return ErrQueryInvalidRequest
+
+	case byte(relay.SignalError):
+		return ErrQueryServerRelayFailed
+
+	default:
+		l.Debugf("Server responded with an unknown UDP initial result: %d",
+			serverResp[0])
+
+		return ErrQueryUnknownError
+	}
+}
+
+func (q *queryRelay) Abort(l logger.Logger, aborter relay.Aborter) error {
+	return aborter.Goodbye()
+}
+
+func (q *queryRelay) Client(
+	l logger.Logger, server relay.Server) (io.ReadWriteCloser, error) {
+	header, headerErr := udpHeader(q.exchange.Upstream)
+
+	if headerErr != nil {
+		return nil, headerErr
+	}
+
+	return &queryConn{
+		exchange:  q.exchange,
+		header:    header,
+		sent:      false,
+		timeout:   time.NewTimer(q.exchange.Timeout),
+		cancel:    q.cancel,
+		closed:    make(chan struct{}),
+		closeOnce: sync.Once{},
+	}, nil
+}
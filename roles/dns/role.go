@@ -0,0 +1,578 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/print"
+	"github.com/reinit/coward/common/role"
+	"github.com/reinit/coward/common/ticker"
+	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
+	"github.com/reinit/coward/roles/common/network/dialer/tcp"
+	"github.com/reinit/coward/roles/common/transceiver"
+	tclient "github.com/reinit/coward/roles/common/transceiver/client"
+	"github.com/reinit/coward/roles/common/transceiver/clients"
+)
+
+// Consts
+const (
+	maxDestinationRecords = 64
+)
+
+// ConfigProxy Proxy configurations
+type ConfigProxy struct {
+	components     []interface{}
+	selectedCodec  transceiver.Codec
+	Host           string   `json:"host" cfg:"h,-host:Host name of the remote COWARD Proxy server.\r\n\r\nMust matchs the setting on server."`
+	Port           uint16   `json:"port" cfg:"p,-port:Port number of the remote COWARD Proxy server.\r\n\r\nMust matchs the setting on server."`
+	Connections    uint32   `json:"connections" cfg:"c,-connections:The maximum concurrent connections that can be established to a COWARD Proxy server."`
+	RequestRetries uint8    `json:"retries" cfg:"r,-retries:How many times a failed Initial request can be retried."`
+	Timeout        uint16   `json:"timeout" cfg:"t,-timeout:The maximum idle time in second of the established proxy connection.\r\n\r\nIf the proxy connection consecutively idle during this period of time, then that connection will be considered as inactive and thus be disconnected.\r\n\r\nIt is recommended to set this value no greater than the related one on the COWARD Proxy server setting."`
+	RequestTimeout uint16   `json:"request_timeout" cfg:"rt,-request-timeout:The maximum wait time in second for the server to respond the Initial request of a client.\r\n\r\nIf the COWARD Proxy server has failed to respond the Initial request within this period of time, the connection will be considered broken and thus be closed.\r\n\r\nIt is recommended to set this value slightly greater than the \"--initial-timeout\" setting on the COWARD Proxy server."`
+	Channels       uint8    `json:"channels" cfg:"n,-channels:How many requests can be simultaneously opened on a single established connection.\r\n\r\nSet the value greater than 1 so a single connection can be use to transport multiple requests (Multiplexing).\r\n\r\nWARNING:\r\nThis value must matchs or smaller than the related setting on the COWARD Proxy server, otherwise the request will be come malformed and thus dropped."`
+	Persistent     bool     `json:"persist" cfg:"k,-persist:Whether or not to keep the connection to the COWARD Proxy active after all requests on the connection is completed."`
+	Codec          string   `json:"codec" cfg:"e,-codec:Specify which Codec will be used to encode and decode data payload to and from a connection."`
+	CodecSetting   []string `json:"codec_setting" secret:"true" cfg:"es,-codec-cfg:Configuration of the Codec as an array of string.\r\n\r\nThe actual configuration format of this setting is depend on the Codec of your choosing."`
+}
+
+// Init inits the configuration
+func (c *ConfigProxy) Init(parent *ConfigInput) {
+	c.components = parent.components
+}
+
+// VerifyPort Verify Port
+func (c *ConfigProxy) VerifyPort() error {
+	if c.Port < 1 {
+		return errors.New("Port must be greater than 0")
+	}
+
+	return nil
+}
+
+// VerifyConnections Verify Connections
+func (c *ConfigProxy) VerifyConnections() error {
+	if c.Connections < 1 {
+		return errors.New("Connections must be greater than 0")
+	}
+
+	if c.Connections > 8000000 {
+		return errors.New("Connections must be smaller than 8,000,000")
+	}
+
+	return nil
+}
+
+// VerifyRequestRetries Verify RequestRetries
+func (c *ConfigProxy) VerifyRequestRetries() error {
+	if c.RequestRetries < 1 {
+		return errors.New("Retries must be greater than 0")
+	}
+
+	return nil
+}
+
+// VerifyTimeout Verify Timeout
+func (c *ConfigProxy) VerifyTimeout() error {
+	if c.Timeout < c.RequestTimeout {
+		return errors.New(
+			"(Idle) Timeout must be greater than the Request Timeout")
+	}
+
+	return nil
+}
+
+// VerifyRequestTimeout Verify RequestTimeout
+func (c *ConfigProxy) VerifyRequestTimeout() error {
+	if c.RequestTimeout > c.Timeout {
+		return errors.New(
+			"Request Timeout must be smaller than the (Idle) Timeout")
+	}
+
+	return nil
+}
+
+// VerifyChannels Verify Channels
+func (c *ConfigProxy) VerifyChannels() error {
+	if c.Channels < 1 {
+		return errors.New("Channels must be greater than 0")
+	}
+
+	return nil
+}
+
+// VerifyCodec Verify Codec
+func (c *ConfigProxy) VerifyCodec() error {
+	for cIdx := range c.components {
+		codecBuilder, isCodecBuilder :=
+			c.components[cIdx].(func() transceiver.Codec)
+
+		if !isCodecBuilder {
+			continue
+		}
+
+		codecInfo := codecBuilder()
+
+		if codecInfo.Name == c.Codec {
+			c.selectedCodec = codecInfo
+
+			return nil
+		}
+	}
+
+	return errors.New("Specified Codec was not found")
+}
+
+// VerifyCodecSetting Verify CodecSetting
+func (c *ConfigProxy) VerifyCodecSetting() error {
+	if c.selectedCodec.Verify == nil {
+		return errors.New("Codec must be specified")
+	}
+
+	return c.selectedCodec.Verify(c.CodecSetting)
+}
+
+// Verify Verifies
+func (c *ConfigProxy) Verify() error {
+	if c.Host == "" {
+		return errors.New("Host must be defined")
+	}
+
+	if c.Port <= 0 {
+		return errors.New("Port must be defined")
+	}
+
+	if c.Connections <= 0 {
+		return errors.New("Connections must be defined")
+	}
+
+	if c.RequestRetries <= 0 {
+		c.RequestRetries = 3
+	}
+
+	if c.Timeout <= 0 {
+		return errors.New("(Idle) Timeout must be defined")
+	}
+
+	if c.RequestTimeout <= 0 {
+		if c.Timeout <= 10 {
+			c.RequestTimeout = 1
+		} else {
+			c.RequestTimeout = c.Timeout / 10
+		}
+	}
+
+	if c.Channels <= 0 {
+		return errors.New("Channels must be defined")
+	}
+
+	if c.Codec == "" {
+		return errors.New("Codec must be defined")
+	}
+
+	if c.selectedCodec.Verify != nil {
+		vErr := c.selectedCodec.Verify(c.CodecSetting)
+
+		if vErr != nil {
+			return errors.New("Codec Setting was invalid: " + vErr.Error())
+		}
+	}
+
+	return nil
+}
+
+// ConfigSplit Split-horizon configurations
+type ConfigSplit struct {
+	selectedDomain string
+	selectedServer *net.UDPAddr
+	Domain         string `json:"domain" cfg:"d,-domain:Domain which will be resolved by the internal DNS server.\r\n\r\nAll sub-domains of it will be resolved by the internal DNS server as well."`
+	Server         string `json:"server" cfg:"s,-server:Address of the internal DNS server in \"IP\" or \"IP:Port\" format.\r\n\r\nQueries will be sent to this server directly instead of through the COWARD Proxy."`
+}
+
+// VerifyDomain Verify Domain
+func (c *ConfigSplit) VerifyDomain() error {
+	domain, domainErr := ParseDomain(c.Domain)
+
+	if domainErr != nil {
+		return domainErr
+	}
+
+	c.selectedDomain = domain
+
+	return nil
+}
+
+// VerifyServer Verify Server
+func (c *ConfigSplit) VerifyServer() error {
+	server, serverErr := ParseServer(c.Server)
+
+	if serverErr != nil {
+		return serverErr
+	}
+
+	c.selectedServer = server
+
+	return nil
+}
+
+// Verify Verifies
+func (c *ConfigSplit) Verify() error {
+	if c.Domain == "" {
+		return errors.New("Domain must be defined")
+	}
+
+	if c.Server == "" {
+		return errors.New("Server must be defined")
+	}
+
+	return nil
+}
+
+// ConfigInput Configuration
+type ConfigInput struct {
+	components        []interface{}
+	selectedInterface net.IP
+	selectedUpstream  *net.UDPAddr
+	Proxies           []ConfigProxy `json:"proxies" cfg:"r,-proxies:Specify a set of remote COWARD Proxy servers.\r\n\r\nQueries will be dispatched to one of these proxies automatically."`
+	Interface         string        `json:"interface" cfg:"i,-interface:Specify a local network interface to serve the DNS server."`
+	Port              uint16        `json:"port" cfg:"p,-port:Specify a port to serve the DNS server.\r\n\r\nThe DNS server will listen on both UDP and TCP of this port."`
+	Upstream          string        `json:"upstream" cfg:"u,-upstream:Address of the DNS server in \"IP\" or \"IP:Port\" format which the COWARD Proxy will send queries to.\r\n\r\nQueries are always sent to it over UDP, even when they're received through TCP."`
+	Timeout           uint16        `json:"timeout" cfg:"t,-timeout:The maximum idle time in second of a TCP DNS client.\r\n\r\nIf server consecutively receives no query from a client during this period of time, then that client will be considered as inactive and thus be disconnected.\r\n\r\nUDP clients will be released once they stopped sending queries for the Query Timeout."`
+	QueryTimeout      uint16        `json:"query_timeout" cfg:"qt,-query-timeout:The maximum wait time in second for a query to be answered."`
+	Capacity          uint32        `json:"capacity" cfg:"c,-capacity:The maximum clients this DNS server can serve at the same time.\r\n\r\nWhen amount of clients reached this limitation, new queries will be dropped."`
+	Cache             uint32        `json:"cache" cfg:"ca,-cache:How many answers can be cached locally.\r\n\r\nAnswers are cached for the TTL of their records. Set to 0 (default) to disable the cache."`
+	Split             []ConfigSplit `json:"split" cfg:"s,-split:Split-horizon rules.\r\n\r\nQueries for the specified domains will be sent directly to their internal DNS server instead of through the COWARD Proxy."`
+	Share             bool          `json:"share" cfg:"sh,-share:Share connections to the COWARD Proxy servers with other Roles running in the same process.\r\n\r\nConnections will only be shared between Roles which have exactly the same Proxy settings (Including the Codec settings)."`
+	MemoryLimit       uint32        `json:"memory_limit" cfg:"ml,-memory-limit:Soft limit of the memory usage in megabytes.\r\n\r\nOnce the memory usage of the process has exceeded this limit, no more worker will be created and new queries which can't be picked up by an idle worker will be rejected.\r\n\r\nSet to 0 (default) to disable the limit."`
+}
+
+// GetCandidates gets candidate values of a field
+func (c ConfigInput) GetCandidates(fieldPath string) []string {
+	switch fieldPath {
+	case "/Interface":
+		ifAddrs, ifAddrsErr := net.InterfaceAddrs()
+
+		if ifAddrsErr != nil {
+			return nil
+		}
+
+		result := []string{"0.0.0.0"}
+
+		for idIdx := range ifAddrs {
+			ifIP, _, ifIPErr := net.ParseCIDR(ifAddrs[idIdx].String())
+
+			if ifIPErr != nil {
+				continue
+			}
+
+			result = append(result, ifIP.String())
+		}
+
+		return result
+
+	case "/Proxies/Codec":
+		result := []string{}
+
+		for cIdx := range c.components {
+			codecBuilder, isCodecBuilder :=
+				c.components[cIdx].(func() transceiver.Codec)
+
+			if !isCodecBuilder {
+				continue
+			}
+
+			result = append(result, codecBuilder().Name)
+		}
+
+		return result
+	}
+
+	return nil
+}
+
+// GetDescription gets description
+func (c ConfigInput) GetDescription(fieldPath string) string {
+	candidates := c.GetCandidates(fieldPath)
+
+	if len(candidates) <= 0 {
+		return ""
+	}
+
+	switch fieldPath {
+	case "/Interface":
+		return "Available network interfaces:\r\n- " +
+			strings.Join(candidates, "\r\n- ")
+
+	case "/Proxies/Codec":
+		return "Available codecs:\r\n- " +
+			strings.Join(candidates, "\r\n- ")
+	}
+
+	return ""
+}
+
+// VerifyInterface Verify Interface
+func (c *ConfigInput) VerifyInterface() error {
+	listenIP := net.ParseIP(c.Interface)
+
+	if listenIP == nil {
+		return errors.New("Invalid IP address")
+	}
+
+	c.selectedInterface = listenIP
+
+	return nil
+}
+
+// VerifyPort Verify Port
+func (c *ConfigInput) VerifyPort() error {
+	if c.Port <= 0 {
+		return errors.New("Port number must be greater than 0")
+	}
+
+	return nil
+}
+
+// VerifyUpstream Verify Upstream
+func (c *ConfigInput) VerifyUpstream() error {
+	upstream, upstreamErr := ParseServer(c.Upstream)
+
+	if upstreamErr != nil {
+		return upstreamErr
+	}
+
+	c.selectedUpstream = upstream
+
+	return nil
+}
+
+// VerifyTimeout Verify Timeout
+func (c *ConfigInput) VerifyTimeout() error {
+	if c.Timeout < c.QueryTimeout {
+		return errors.New(
+			"(Idle) Timeout must be greater than the Query Timeout")
+	}
+
+	return nil
+}
+
+// VerifyQueryTimeout Verify QueryTimeout
+func (c *ConfigInput) VerifyQueryTimeout() error {
+	if c.QueryTimeout > c.Timeout {
+		return errors.New(
+			"Query Timeout must be smaller than the (Idle) Timeout")
+	}
+
+	return nil
+}
+
+// VerifyCapacity Verify Capacity
+func (c *ConfigInput) VerifyCapacity() error {
+	if c.Capacity <= 0 {
+		return errors.New("Capacity must be greater than 0")
+	}
+
+	if c.Capacity > 8000000 {
+		return errors.New("Capacity must be smaller than 8,000,000")
+	}
+
+	return nil
+}
+
+// Verify Verifies
+func (c *ConfigInput) Verify() error {
+	if len(c.Proxies) <= 0 {
+		return errors.New("At least one Proxy is required")
+	}
+
+	if c.Interface == "" {
+		c.selectedInterface = net.ParseIP("127.0.0.1")
+	}
+
+	if c.Port <= 0 {
+		return errors.New("Port must be specified")
+	}
+
+	if c.Upstream == "" || c.selectedUpstream == nil {
+		return errors.New("Upstream must be specified")
+	}
+
+	if c.Timeout <= 0 {
+		return errors.New("(Idle) Timeout must be specified")
+	}
+
+	if c.QueryTimeout <= 0 {
+		if c.Timeout <= 10 {
+			c.QueryTimeout = 1
+		} else {
+			c.QueryTimeout = c.Timeout / 10
+		}
+	}
+
+	if c.Capacity <= 0 {
+		return errors.New("Capacity must be specified")
+	}
+
+	return nil
+}
+
+// shared returns the shared Transceiver registry from components
+func (c *ConfigInput) shared() *clients.Shared {
+	for cIdx := range c.components {
+		shared, isShared := c.components[cIdx].(*clients.Shared)
+
+		if !isShared {
+			continue
+		}
+
+		return shared
+	}
+
+	return nil
+}
+
+// Role register
+func Role() role.Registration {
+	return role.Registration{
+		Name: "dns",
+		Description: "A DNS server that will send DNS queries through a " +
+			"COWARD Proxy server",
+		Configurator: func(components role.Components) interface{} {
+			return &ConfigInput{
+				components:        components,
+				selectedInterface: nil,
+				selectedUpstream:  nil,
+				Proxies:           []ConfigProxy{},
+				Interface:         "",
+				Port:              0,
+				Upstream:          "",
+				Timeout:           0,
+				QueryTimeout:      0,
+				Capacity:          0,
+				Cache:             0,
+				Split:             []ConfigSplit{},
+				Share:             false,
+				MemoryLimit:       0,
+			}
+		},
+		Generater: func(
+			w print.Common,
+			config interface{},
+			log logger.Logger,
+		) (role.Role, error) {
+			cfg := config.(*ConfigInput)
+
+			tTicker, tTickerErr := ticker.New(
+				300*time.Millisecond, 1024).Serve()
+
+			if tTickerErr != nil {
+				return nil, tTickerErr
+			}
+
+			buildClients := func(
+				tk ticker.Requester, l logger.Logger) []transceiver.Client {
+				clients := make([]transceiver.Client, len(cfg.Proxies))
+
+				for cIdx := range cfg.Proxies {
+					clentID := transceiver.ClientID(cIdx)
+
+					clients[cIdx] = tclient.New(clentID, l, tcp.New(
+						cfg.Proxies[cIdx].Host,
+						cfg.Proxies[cIdx].Port,
+						time.Duration(
+							cfg.Proxies[cIdx].RequestTimeout)*time.Second,
+						tcpconn.Wrap,
+					), cfg.Proxies[cIdx].selectedCodec.Build(
+						cfg.Proxies[cIdx].CodecSetting,
+					), tk, tclient.Config{
+						MaxConcurrent:  cfg.Proxies[cIdx].Connections,
+						RequestRetries: cfg.Proxies[cIdx].RequestRetries,
+						InitialTimeout: time.Duration(
+							cfg.Proxies[cIdx].RequestTimeout) * time.Second,
+						IdleTimeout: time.Duration(
+							cfg.Proxies[cIdx].Timeout) * time.Second,
+						ConnectionPersistent: cfg.Proxies[cIdx].Persistent,
+						ConnectionChannels:   cfg.Proxies[cIdx].Channels,
+					})
+				}
+
+				return clients
+			}
+
+			var balancer transceiver.Balancer
+
+			shared := cfg.shared()
+
+			if cfg.Share && shared != nil {
+				sharedProxies := make(
+					[]clients.SharedProxy, len(cfg.Proxies))
+
+				for pIdx := range cfg.Proxies {
+					sharedProxies[pIdx] = clients.SharedProxy{
+						Host:           cfg.Proxies[pIdx].Host,
+						Port:           cfg.Proxies[pIdx].Port,
+						Connections:    cfg.Proxies[pIdx].Connections,
+						RequestRetries: cfg.Proxies[pIdx].RequestRetries,
+						Timeout:        cfg.Proxies[pIdx].Timeout,
+						RequestTimeout: cfg.Proxies[pIdx].RequestTimeout,
+						Channels:       cfg.Proxies[pIdx].Channels,
+						Persistent:     cfg.Proxies[pIdx].Persistent,
+						Codec:          cfg.Proxies[pIdx].Codec,
+						CodecSetting:   cfg.Proxies[pIdx].CodecSetting,
+					}
+				}
+
+				balancer = shared.Balancer(
+					sharedProxies, buildClients, maxDestinationRecords, log)
+			} else {
+				balancer = clients.New(
+					buildClients(tTicker, log), maxDestinationRecords)
+			}
+
+			rules := make(Rules, len(cfg.Split))
+
+			for sIdx := range cfg.Split {
+				rules[sIdx] = Rule{
+					Domain: cfg.Split[sIdx].selectedDomain,
+					Server: cfg.Split[sIdx].selectedServer,
+				}
+			}
+
+			return New(tTicker, balancer, log, Config{
+				Interface: cfg.selectedInterface,
+				Port:      cfg.Port,
+				Capacity:  cfg.Capacity,
+				ConnectionTimeout: time.Duration(
+					cfg.Timeout) * time.Second,
+				QueryTimeout: time.Duration(
+					cfg.QueryTimeout) * time.Second,
+				Upstream:    cfg.selectedUpstream,
+				Rules:       rules,
+				CacheSize:   cfg.Cache,
+				MemoryLimit: uint64(cfg.MemoryLimit) * 1024 * 1024,
+			}), nil
+		},
+	}
+}
@@ -0,0 +1,117 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Errors
+var (
+	ErrRuleInvalidServer = errors.New(
+		"Invalid DNS server address")
+
+	ErrRuleInvalidDomain = errors.New(
+		"Invalid domain")
+)
+
+// Consts
+const (
+	defaultDNSPort = 53
+)
+
+// Rule sends queries of a domain and it's sub-domains directly to the
+// specified Server instead of the COWARD Proxy
+type Rule struct {
+	Domain string
+	Server *net.UDPAddr
+}
+
+// Rules is a group of Rule
+type Rules []Rule
+
+// ParseServer parses a DNS server address in "IP" or "IP:Port" format
+func ParseServer(address string) (*net.UDPAddr, error) {
+	if ip := net.ParseIP(address); ip != nil {
+		return &net.UDPAddr{IP: ip, Port: defaultDNSPort, Zone: ""}, nil
+	}
+
+	host, port, splitErr := net.SplitHostPort(address)
+
+	if splitErr != nil {
+		return nil, ErrRuleInvalidServer
+	}
+
+	ip := net.ParseIP(host)
+
+	if ip == nil {
+		return nil, ErrRuleInvalidServer
+	}
+
+	portNum, portErr := strconv.ParseUint(port, 10, 16)
+
+	if portErr != nil || portNum == 0 {
+		return nil, ErrRuleInvalidServer
+	}
+
+	return &net.UDPAddr{IP: ip, Port: int(portNum), Zone: ""}, nil
+}
+
+// ParseDomain normalizes a domain so it can be used in a Rule
+func ParseDomain(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	domain = strings.TrimPrefix(strings.TrimPrefix(domain, "*"), ".")
+
+	if domain == "" || len(domain) > messageMaxNameLen ||
+		strings.Contains(domain, "..") {
+		return "", ErrRuleInvalidDomain
+	}
+
+	return domain, nil
+}
+
+// Match returns the Server of the Rule which has the longest Domain that
+// matches the name
+func (r Rules) Match(name string) (*net.UDPAddr, bool) {
+	var selected *Rule
+
+	for rIdx := range r {
+		if name != r[rIdx].Domain &&
+			!strings.HasSuffix(name, "."+r[rIdx].Domain) {
+			continue
+		}
+
+		if selected != nil && len(selected.Domain) >= len(r[rIdx].Domain) {
+			continue
+		}
+
+		selected = &r[rIdx]
+	}
+
+	if selected == nil {
+		return nil, false
+	}
+
+	return selected.Server, true
+}
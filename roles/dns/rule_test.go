@@ -0,0 +1,107 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseServer(t *testing.T) {
+	tests := []struct {
+		address string
+		ip      string
+		port    int
+		err     error
+	}{
+		{"10.0.0.53", "10.0.0.53", 53, nil},
+		{"10.0.0.53:5353", "10.0.0.53", 5353, nil},
+		{"[fd00::53]:53", "fd00::53", 53, nil},
+		{"fd00::53", "fd00::53", 53, nil},
+		{"dns.corp:53", "", 0, ErrRuleInvalidServer},
+		{"10.0.0.53:0", "", 0, ErrRuleInvalidServer},
+		{"", "", 0, ErrRuleInvalidServer},
+	}
+
+	for tIdx, test := range tests {
+		server, parseErr := ParseServer(test.address)
+
+		if parseErr != test.err {
+			t.Errorf("Test %d: Expecting error %v, got %v",
+				tIdx, test.err, parseErr)
+
+			return
+		}
+
+		if parseErr != nil {
+			continue
+		}
+
+		if !server.IP.Equal(net.ParseIP(test.ip)) || server.Port != test.port {
+			t.Errorf("Test %d: Unexpected server %s", tIdx, server)
+
+			return
+		}
+	}
+}
+
+func TestRulesMatch(t *testing.T) {
+	corp, _ := ParseServer("10.0.0.53")
+	lab, _ := ParseServer("10.1.0.53")
+	corpDomain, _ := ParseDomain("*.Corp.Example.")
+	labDomain, _ := ParseDomain("lab.corp.example")
+
+	rules := Rules{
+		{Domain: corpDomain, Server: corp},
+		{Domain: labDomain, Server: lab},
+	}
+
+	tests := []struct {
+		name   string
+		server *net.UDPAddr
+	}{
+		{"corp.example", corp},
+		{"www.corp.example", corp},
+		{"host.lab.corp.example", lab},
+		{"lab.corp.example", lab},
+		{"notcorp.example", nil},
+		{"example", nil},
+	}
+
+	for tIdx, test := range tests {
+		server, matched := rules.Match(test.name)
+
+		if matched != (test.server != nil) || server != test.server {
+			t.Errorf("Test %d: Unexpected match result for \"%s\": %v",
+				tIdx, test.name, server)
+
+			return
+		}
+	}
+
+	_, domainErr := ParseDomain("*.")
+
+	if domainErr != ErrRuleInvalidDomain {
+		t.Errorf("Expecting error %s, got %v", ErrRuleInvalidDomain, domainErr)
+
+		return
+	}
+}
@@ -24,7 +24,11 @@ import (
 	"net"
 	"time"
 
+	"github.com/reinit/coward/roles/common/access"
+	"github.com/reinit/coward/roles/common/datagram"
 	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/transceiver"
+	"github.com/reinit/coward/roles/common/transceiver/reverse"
 	proxycomm "github.com/reinit/coward/roles/proxy/common"
 )
 
@@ -59,5 +63,10 @@ type Config struct {
 	TransceiverIdleTimeout          time.Duration
 	TransceiverInitialTimeout       time.Duration
 	TransceiverChannels             uint8
+	TransceiverDatagram             datagram.Endpoint
+	TransceiverRegistry             reverse.Registry
+	Transceiver                     transceiver.Balancer
 	Mapping                         Mappeds
+	AccessLog                       access.Log
+	MemoryLimit                     uint64
 }
@@ -21,13 +21,14 @@
 package mapper
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/timer"
 	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/access"
 	"github.com/reinit/coward/roles/common/network"
-	"github.com/reinit/coward/roles/common/transceiver"
 	"github.com/reinit/coward/roles/mapper/common"
 	"github.com/reinit/coward/roles/mapper/request"
 	proxycommon "github.com/reinit/coward/roles/proxy/common"
@@ -38,9 +39,10 @@ type tcpHandler struct {
 	cfg         Config
 	runner      worker.Runner
 	shb         *common.SharedBuffer
-	transceiver transceiver.Requester
+	transceiver requester
 	timeout     time.Duration
 	reqTimeout  time.Duration
+	access      access.Logger
 }
 
 type tcpClient struct {
@@ -48,11 +50,12 @@ type tcpClient struct {
 	conn        network.Connection
 	logger      logger.Logger
 	cfg         Config
-	transceiver transceiver.Requester
+	transceiver requester
 	timeout     time.Duration
 	reqTimeout  time.Duration
 	shb         *common.SharedBuffer
 	runner      worker.Runner
+	access      access.Logger
 }
 
 func (d tcpHandler) New(
@@ -69,6 +72,7 @@ func (d tcpHandler) New(
 		reqTimeout:  d.reqTimeout,
 		shb:         d.shb,
 		runner:      d.runner,
+		access:      d.access,
 	}, nil
 }
 
@@ -83,11 +87,17 @@ func (d tcpClient) Serve() error {
 
 	d.conn.SetTimeout(d.reqTimeout)
 
+	session := access.NewSession(d.conn.RemoteAddr().String(), "tcp")
+
+	session.Destination("#" + strconv.FormatUint(uint64(d.mapper), 10))
+
 	_, reqErr := d.transceiver.Request(
 		d.logger,
-		request.TCP(d.mapper, d.conn, d.runner, d.timeout, d.shb),
+		request.TCP(d.mapper, d.conn, d.runner, d.timeout, d.shb, session),
 		d.conn.Closed(), metering)
 
+	session.Finish(d.access, reqErr)
+
 	if reqErr != nil {
 		d.logger.Warningf("Request has failed: %s", reqErr)
 
@@ -21,13 +21,15 @@
 package mapper
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/timer"
 	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/access"
+	"github.com/reinit/coward/roles/common/datagram"
 	"github.com/reinit/coward/roles/common/network"
-	"github.com/reinit/coward/roles/common/transceiver"
 	"github.com/reinit/coward/roles/mapper/common"
 	"github.com/reinit/coward/roles/mapper/request"
 	proxycommon "github.com/reinit/coward/roles/proxy/common"
@@ -38,9 +40,11 @@ type udpHandler struct {
 	cfg         Config
 	runner      worker.Runner
 	shb         *common.SharedBuffer
-	transceiver transceiver.Requester
+	transceiver requester
 	timeout     time.Duration
 	reqTimeout  time.Duration
+	datagram    datagram.Endpoint
+	access      access.Logger
 }
 
 type udpClient struct {
@@ -48,11 +52,13 @@ type udpClient struct {
 	conn        network.Connection
 	logger      logger.Logger
 	cfg         Config
-	transceiver transceiver.Requester
+	transceiver requester
 	timeout     time.Duration
 	reqTimeout  time.Duration
 	shb         *common.SharedBuffer
 	runner      worker.Runner
+	datagram    datagram.Endpoint
+	access      access.Logger
 }
 
 func (d udpHandler) New(
@@ -69,6 +75,8 @@ func (d udpHandler) New(
 		reqTimeout:  d.reqTimeout,
 		shb:         d.shb,
 		runner:      d.runner,
+		datagram:    d.datagram,
+		access:      d.access,
 	}, nil
 }
 
@@ -83,11 +91,18 @@ func (d udpClient) Serve() error {
 		request:    timer.New(),
 	}
 
+	session := access.NewSession(d.conn.RemoteAddr().String(), "udp")
+
+	session.Destination("#" + strconv.FormatUint(uint64(d.mapper), 10))
+
 	_, reqErr := d.transceiver.Request(
 		d.logger,
-		request.UDP(d.mapper, d.conn, d.runner, d.timeout, d.shb),
+		request.UDP(d.mapper, d.conn, d.runner, d.timeout, d.shb, session,
+			d.datagram),
 		d.conn.Closed(), metering)
 
+	session.Finish(d.access, reqErr)
+
 	if reqErr != nil {
 		d.logger.Warningf("Request has failed: %s", reqErr)
 
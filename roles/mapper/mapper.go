@@ -61,7 +61,7 @@ type mapper struct {
 	dialer          network.Dialer
 	log             logger.Logger
 	cfg             Config
-	transceiver     transceiver.Requester
+	transceiver     requester
 	ticker          ticker.RequestCloser
 	servers         []network.Serving
 	runner          worker.Runner
@@ -95,6 +95,15 @@ func (s *mapper) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 		return ErrNoTargetForMapping
 	}
 
+	accessOpenErr := s.cfg.AccessLog.Open()
+
+	if accessOpenErr != nil {
+		s.log.Errorf("Failed to open access log due to error: %s",
+			accessOpenErr)
+
+		return accessOpenErr
+	}
+
 	tticker, ttickerErr := ticker.New(tickDelay, 1024).Serve()
 
 	if ttickerErr != nil {
@@ -104,31 +113,65 @@ func (s *mapper) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 	s.ticker = tticker
 
 	// Open transceiver client first
-	trServe, trServeErr := tclient.New(
-		0, s.log, s.dialer, s.codec, s.ticker, tclient.Config{
-			MaxConcurrent:        s.cfg.TransceiverMaxConnections,
-			RequestRetries:       s.cfg.TransceiverRequestRetries,
-			IdleTimeout:          s.cfg.TransceiverIdleTimeout,
-			InitialTimeout:       s.cfg.TransceiverInitialTimeout,
-			ConnectionPersistent: s.cfg.TransceiverConnectionPersistent,
-			ConnectionChannels:   s.cfg.TransceiverChannels,
-		}).Serve()
-
-	if trServeErr != nil {
-		s.log.Errorf("Failed to start Transceiver due to error: %s", trServeErr)
-
-		return trServeErr
-	}
+	if s.cfg.Transceiver != nil {
+		trServe, trServeErr := s.cfg.Transceiver.Serve()
+
+		if trServeErr != nil {
+			s.log.Errorf("Failed to start Transceiver due to error: %s",
+				trServeErr)
+
+			return trServeErr
+		}
+
+		s.transceiver = balanced{balanced: trServe}
+	} else {
+		if s.cfg.TransceiverRegistry != nil {
+			regServeErr := s.cfg.TransceiverRegistry.Serve()
+
+			if regServeErr != nil {
+				s.log.Errorf("Failed to start Registry due to error: %s",
+					regServeErr)
+
+				return regServeErr
+			}
+		}
+
+		trServe, trServeErr := tclient.New(
+			0, s.log, s.dialer, s.codec, s.ticker, tclient.Config{
+				MaxConcurrent:        s.cfg.TransceiverMaxConnections,
+				RequestRetries:       s.cfg.TransceiverRequestRetries,
+				IdleTimeout:          s.cfg.TransceiverIdleTimeout,
+				InitialTimeout:       s.cfg.TransceiverInitialTimeout,
+				ConnectionPersistent: s.cfg.TransceiverConnectionPersistent,
+				ConnectionChannels:   s.cfg.TransceiverChannels,
+			}).Serve()
 
-	s.transceiver = trServe
+		if trServeErr != nil {
+			s.log.Errorf("Failed to start Transceiver due to error: %s",
+				trServeErr)
+
+			if s.cfg.TransceiverRegistry != nil {
+				s.cfg.TransceiverRegistry.Close()
+			}
+
+			return trServeErr
+		}
+
+		s.transceiver = single{requester: trServe}
+	}
 
 	// Init runners
+	minWorkers := pcommon.AutomaticalMinWorkerCount(
+		s.cfg.Mapping.TotalCapacity()*2, 128)
+
 	runner, runnerServeErr := worker.New(s.log, s.ticker, worker.Config{
-		MaxWorkers: s.cfg.Mapping.TotalCapacity() * 2,
-		MinWorkers: pcommon.AutomaticalMinWorkerCount(
-			s.cfg.Mapping.TotalCapacity()*2, 128),
+		MaxWorkers:        s.cfg.Mapping.TotalCapacity() * 2,
+		MinWorkers:        minWorkers,
 		MaxWorkerIdle:     s.cfg.TransceiverIdleTimeout * 2,
 		JobReceiveTimeout: s.cfg.TransceiverInitialTimeout,
+		MaxQueueLatency:   pcommon.WorkerMaxQueueLatency,
+		MemoryLimit:       s.cfg.MemoryLimit,
+		MaxQueued:         minWorkers,
 	}).Serve()
 
 	if runnerServeErr != nil {
@@ -139,7 +182,7 @@ func (s *mapper) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 
 	// Start all servers
 	shb := &common.SharedBuffer{
-		Buffer: make([]byte, 4096*trServe.Connections()),
+		Buffer: make([]byte, 4096*s.transceiver.Connections()),
 		Size:   4096}
 
 	s.servers = make([]network.Serving, len(s.cfg.Mapping))
@@ -162,6 +205,7 @@ func (s *mapper) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 				transceiver: s.transceiver,
 				timeout:     s.cfg.TransceiverIdleTimeout,
 				reqTimeout:  s.cfg.TransceiverInitialTimeout,
+				access:      s.cfg.AccessLog,
 			}, s.log.Context(strconv.FormatUint(
 				uint64(s.cfg.Mapping[mIdx].ID), 10)+" ("+
 				s.cfg.Mapping[mIdx].Protocol.String()+" "+
@@ -189,6 +233,8 @@ func (s *mapper) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 				transceiver: s.transceiver,
 				timeout:     s.cfg.TransceiverIdleTimeout,
 				reqTimeout:  s.cfg.TransceiverInitialTimeout,
+				datagram:    s.cfg.TransceiverDatagram,
+				access:      s.cfg.AccessLog,
 			}, s.log.Context(strconv.FormatUint(
 				uint64(s.cfg.Mapping[mIdx].ID), 10)+" ("+
 				s.cfg.Mapping[mIdx].Protocol.String()+" "+
@@ -229,6 +275,55 @@ func (s *mapper) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 	return nil
 }
 
+// Drain stops accepting new connections and waits for existing ones to
+// complete, up to the timeout
+func (s *mapper) Drain(timeout time.Duration) error {
+	var lastErr error
+
+	s.log.Infof("Draining")
+
+	deadline := time.Now().Add(timeout)
+
+	for sIdx := range s.servers {
+		if s.servers[sIdx] == nil {
+			continue
+		}
+
+		drainErr := s.servers[sIdx].Drain(time.Until(deadline))
+
+		if drainErr == nil {
+			continue
+		}
+
+		s.log.Warningf("Failed to drain server \"%s\" due to error: %s",
+			s.servers[sIdx].Listening(), drainErr)
+
+		lastErr = drainErr
+	}
+
+	if lastErr != nil {
+		return lastErr
+	}
+
+	s.log.Infof("Server is drained")
+
+	return nil
+}
+
+// Reopen reopens the access log
+func (s *mapper) Reopen() error {
+	reopenErr := s.cfg.AccessLog.Reopen()
+
+	if reopenErr != nil {
+		s.log.Warningf("Failed to reopen access log due to error: %s",
+			reopenErr)
+
+		return reopenErr
+	}
+
+	return nil
+}
+
 func (s *mapper) Unspawn() error {
 	s.log.Infof("Closing")
 
@@ -241,6 +336,11 @@ func (s *mapper) Unspawn() error {
 		}
 	}
 
+	// And the Registry which the transceiver takes connections from
+	if s.cfg.TransceiverRegistry != nil {
+		s.cfg.TransceiverRegistry.Close()
+	}
+
 	// Then, close all servers
 	for sIdx := range s.servers {
 		if s.servers[sIdx] == nil {
@@ -266,6 +366,13 @@ func (s *mapper) Unspawn() error {
 		s.ticker = nil
 	}
 
+	accessCloseErr := s.cfg.AccessLog.Close()
+
+	if accessCloseErr != nil {
+		s.log.Warningf("Failed to close access log due to error: %s",
+			accessCloseErr)
+	}
+
 	s.log.Infof("Server is closed")
 
 	s.unspawnNotifier <- struct{}{}
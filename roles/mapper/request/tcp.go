@@ -21,12 +21,14 @@
 package request
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/reinit/coward/common/fsm"
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/access"
 	"github.com/reinit/coward/roles/common/network"
 	"github.com/reinit/coward/roles/common/relay"
 	"github.com/reinit/coward/roles/common/transceiver"
@@ -48,20 +50,27 @@ func TCP(
 	runner worker.Runner,
 	timeout time.Duration,
 	shb *common.SharedBuffer,
-) transceiver.RequestBuilder {
+	session *access.Session,
+) transceiver.BalancedRequestBuilder {
 	return func(
+		cID transceiver.ClientID,
 		id transceiver.ConnectionID,
 		conn rw.ReadWriteDepleteDoner,
 		connCtl transceiver.ConnectionControl,
 		log logger.Logger,
 	) fsm.Machine {
+		session.ProxyClient(strconv.FormatUint(uint64(cID), 10))
+
 		return tcp{
 			log: log,
-			relay: relay.New(log, runner, conn, shb.Select(id), tcpRelay{
-				mapper:  mapper,
-				client:  client,
-				timeout: timeout,
-			}, make([]byte, 4096)),
+			relay: relay.NewMetered(
+				log, runner, conn, shb.Select(id), &tcpRelay{
+					mapper:  mapper,
+					client:  client,
+					timeout: timeout,
+					splice:  relay.Exclusive(conn),
+					spliced: false,
+				}, make([]byte, 4096), &session.Traffic),
 			cancel: client.Closed(),
 		}
 	}
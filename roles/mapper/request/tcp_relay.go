@@ -23,6 +23,7 @@ package request
 import (
 	"errors"
 	"io"
+	"net"
 	"time"
 
 	"github.com/reinit/coward/common/logger"
@@ -60,11 +61,18 @@ type tcpRelay struct {
 	mapper  proxycommon.MapID
 	client  network.Connection
 	timeout time.Duration
+	splice  bool
+	spliced bool
 }
 
-func (c tcpRelay) Initialize(l logger.Logger, server relay.Server) error {
-	_, wErr := rw.WriteFull(
-		server, []byte{request.TCPCommandMapping, byte(c.mapper)})
+func (c *tcpRelay) Initialize(l logger.Logger, server relay.Server) error {
+	cmd := byte(request.TCPCommandMapping)
+
+	if c.splice {
+		cmd += request.TCPCommandSpliced
+	}
+
+	_, wErr := rw.WriteFull(server, []byte{cmd, byte(c.mapper)})
 
 	if wErr != nil {
 		return wErr
@@ -80,6 +88,14 @@ func (c tcpRelay) Initialize(l logger.Logger, server relay.Server) error {
 		return crErr
 	}
 
+	// Keep the segment undone, so the server connection won't be read by
+	// the dispatcher before it's been taken over
+	if c.splice && command[0] == request.TCPRespondSpliced {
+		c.spliced = true
+
+		return nil
+	}
+
 	server.Done()
 
 	var initError error
@@ -116,11 +132,11 @@ func (c tcpRelay) Initialize(l logger.Logger, server relay.Server) error {
 	return initError
 }
 
-func (c tcpRelay) Abort(l logger.Logger, aborter relay.Aborter) error {
+func (c *tcpRelay) Abort(l logger.Logger, aborter relay.Aborter) error {
 	return aborter.Goodbye()
 }
 
-func (c tcpRelay) Client(
+func (c *tcpRelay) Client(
 	l logger.Logger, server relay.Server) (io.ReadWriteCloser, error) {
 	return &relayConn{
 		Connection:      c.client,
@@ -128,3 +144,11 @@ func (c tcpRelay) Client(
 		timeoutExpanded: false,
 	}, nil
 }
+
+func (c *tcpRelay) Spliced() bool {
+	return c.spliced
+}
+
+func (c *tcpRelay) Splice(l logger.Logger) (net.Conn, time.Duration, error) {
+	return c.client, c.timeout, nil
+}
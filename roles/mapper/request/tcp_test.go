@@ -0,0 +1,370 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/reinit/coward/common/fsm"
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/common/ticker"
+	"github.com/reinit/coward/common/timer"
+	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/access"
+	"github.com/reinit/coward/roles/common/codec/plain"
+	"github.com/reinit/coward/roles/common/command"
+	"github.com/reinit/coward/roles/common/network"
+	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
+	tcpdial "github.com/reinit/coward/roles/common/network/dialer/tcp"
+	"github.com/reinit/coward/roles/common/transceiver"
+	"github.com/reinit/coward/roles/common/transceiver/client"
+	"github.com/reinit/coward/roles/common/transceiver/server"
+	"github.com/reinit/coward/roles/mapper/common"
+	proxycommon "github.com/reinit/coward/roles/proxy/common"
+	"github.com/reinit/coward/roles/proxy/request"
+)
+
+type dummyTCPMeter struct{}
+
+func (d dummyTCPMeter) Connection() timer.Stopper {
+	return timer.New().Start()
+}
+
+func (d dummyTCPMeter) ConnectionFailure(e error) {}
+
+func (d dummyTCPMeter) Request() timer.Stopper {
+	return timer.New().Start()
+}
+
+func (d dummyTCPMeter) RequestFailure(e error) {}
+
+// testTCPEcho starts a TCP server which sends back everything it received
+func testTCPEcho(t *testing.T) (net.Listener, bool) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Error("Failed to listen echo server due to error:", listenErr)
+
+		return nil, false
+	}
+
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+
+			if acceptErr != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				io.Copy(conn, conn)
+
+				conn.(*net.TCPConn).CloseWrite()
+			}()
+		}
+	}()
+
+	return listener, true
+}
+
+// testTCPMapping sends data to an echo server through a Mapper TCP request
+// and a Proxy which serves a Transceiver of given Channels, and returns the
+// result of the Proxy Transceiver handling
+func testTCPMapping(
+	t *testing.T, channels uint8, proxyChannels uint8) (error, bool) {
+	log := logger.NewDitch()
+
+	tk, tkErr := ticker.New(300*time.Millisecond, 1024).Serve()
+
+	if tkErr != nil {
+		t.Error("Failed to start Ticker due to error:", tkErr)
+
+		return nil, false
+	}
+
+	defer tk.Close()
+
+	runner, runnerErr := worker.New(log, tk, worker.Config{
+		MaxWorkers:        16,
+		MinWorkers:        16,
+		MaxWorkerIdle:     5 * time.Minute,
+		JobReceiveTimeout: 300 * time.Millisecond,
+	}).Serve()
+
+	if runnerErr != nil {
+		t.Error("Failed to start Runner due to error:", runnerErr)
+
+		return nil, false
+	}
+
+	defer runner.Close()
+
+	echo, echoOK := testTCPEcho(t)
+
+	if !echoOK {
+		return nil, false
+	}
+
+	defer echo.Close()
+
+	mapping := proxycommon.Mapping{}
+	mapping[1] = &proxycommon.Mapped{
+		Protocol: network.TCP,
+		Host:     "127.0.0.1",
+		Port:     uint16(echo.Addr().(*net.TCPAddr).Port),
+		Egress:   nil,
+		Forward:  false,
+	}
+
+	proxyListener, proxyListenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if proxyListenErr != nil {
+		t.Error("Failed to listen Proxy due to error:", proxyListenErr)
+
+		return nil, false
+	}
+
+	defer proxyListener.Close()
+
+	handled := make(chan error, 1)
+
+	go func() {
+		conn, acceptErr := proxyListener.Accept()
+
+		if acceptErr != nil {
+			handled <- acceptErr
+
+			return
+		}
+
+		wrapped := tcpconn.Wrap(conn)
+
+		defer wrapped.Close()
+
+		tcpMapping := func(splice bool) request.TCPMapping {
+			return request.TCPMapping{
+				TCP: request.TCP{
+					Runner:            runner,
+					Buffer:            make([]byte, 4096),
+					DialTimeout:       3 * time.Second,
+					ConnectionTimeout: 3 * time.Second,
+					Cancel:            wrapped.Closed(),
+					NoLocalAccess:     false,
+					Splice:            splice,
+				},
+				Mapping: mapping,
+			}
+		}
+
+		handled <- server.New(plain.New, tk, server.Config{
+			InitialTimeout:       3 * time.Second,
+			IdleTimeout:          3 * time.Second,
+			ConnectionChannels:   proxyChannels,
+			ChannelDispatchDelay: 0,
+		}).Handle(log, wrapped, command.New(
+			tcpMapping(false), tcpMapping(true)))
+	}()
+
+	requester, serveErr := client.New(0, log, tcpdial.New(
+		"127.0.0.1", uint16(proxyListener.Addr().(*net.TCPAddr).Port),
+		3*time.Second, tcpconn.Wrap), plain.New, tk, client.Config{
+		MaxConcurrent:        1,
+		RequestRetries:       3,
+		InitialTimeout:       3 * time.Second,
+		IdleTimeout:          3 * time.Second,
+		ConnectionPersistent: false,
+		ConnectionChannels:   channels,
+	}).Serve()
+
+	if serveErr != nil {
+		t.Error("Failed to serve Transceiver client due to error:", serveErr)
+
+		return nil, false
+	}
+
+	defer requester.Close()
+
+	mapperListener, mapperListenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if mapperListenErr != nil {
+		t.Error("Failed to listen Mapper due to error:", mapperListenErr)
+
+		return nil, false
+	}
+
+	defer mapperListener.Close()
+
+	user, dialErr := net.Dial("tcp", mapperListener.Addr().String())
+
+	if dialErr != nil {
+		t.Error("Failed to dial Mapper due to error:", dialErr)
+
+		return nil, false
+	}
+
+	defer user.Close()
+
+	mapped, acceptErr := mapperListener.Accept()
+
+	if acceptErr != nil {
+		t.Error("Failed to accept Mapper client due to error:", acceptErr)
+
+		return nil, false
+	}
+
+	mappedConn := tcpconn.Wrap(mapped)
+	requested := make(chan error, 1)
+
+	go func() {
+		builder := TCP(1, mappedConn, runner, 3*time.Second,
+			&common.SharedBuffer{Buffer: make([]byte, 4096), Size: 4096},
+			access.NewSession(mapped.RemoteAddr().String(), "tcp"))
+
+		_, reqErr := requester.Request(log, func(
+			id transceiver.ConnectionID,
+			conn rw.ReadWriteDepleteDoner,
+			connCtl transceiver.ConnectionControl,
+			log logger.Logger,
+		) fsm.Machine {
+			return builder(0, id, conn, connCtl, log)
+		}, mappedConn.Closed(), dummyTCPMeter{})
+
+		mappedConn.Close()
+
+		requested <- reqErr
+	}()
+
+	user.SetDeadline(time.Now().Add(5 * time.Second))
+
+	sent := bytes.Repeat([]byte("Hello World!"), 1024*20)
+
+	_, wErr := user.Write(sent)
+
+	if wErr != nil {
+		t.Error("Failed to write data due to error:", wErr)
+
+		return nil, false
+	}
+
+	received := make([]byte, len(sent))
+
+	_, rErr := io.ReadFull(user, received)
+
+	if rErr != nil {
+		t.Error("Failed to read data due to error:", rErr)
+
+		return nil, false
+	}
+
+	if !bytes.Equal(received, sent) {
+		t.Error("Expecting the received data to be the same as sent")
+
+		return nil, false
+	}
+
+	user.(*net.TCPConn).CloseWrite()
+
+	_, rErr = user.Read(received)
+
+	if rErr != io.EOF {
+		t.Errorf("Expecting connection to be closed, got %s", rErr)
+
+		return nil, false
+	}
+
+	select {
+	case reqErr := <-requested:
+		if reqErr != nil {
+			t.Error("Request has failed due to error:", reqErr)
+
+			return nil, false
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Error("Request has not been completed")
+
+		return nil, false
+	}
+
+	select {
+	case handleErr := <-handled:
+		return handleErr, true
+
+	case <-time.After(5 * time.Second):
+		t.Error("Proxy has not completed it's handling")
+
+		return nil, false
+	}
+}
+
+func TestTCPSpliced(t *testing.T) {
+	handleErr, ok := testTCPMapping(t, 1, 1)
+
+	if !ok {
+		return
+	}
+
+	if handleErr != nil {
+		t.Error("Expecting the Proxy connection to be handed over for "+
+			"splicing, got", handleErr)
+
+		return
+	}
+}
+
+func TestTCPNotSplicedWhenShared(t *testing.T) {
+	handleErr, ok := testTCPMapping(t, 2, 2)
+
+	if !ok {
+		return
+	}
+
+	if handleErr == nil {
+		t.Error("Expecting the Proxy connection not to be handed over " +
+			"when it can be shared by multiple Channels")
+
+		return
+	}
+}
+
+func TestTCPNotSplicedWhenProxyShared(t *testing.T) {
+	// The Mapper asks for splicing as it's connection is exclusive, but
+	// the Proxy must refuse as it's side of the connection can be shared
+	handleErr, ok := testTCPMapping(t, 1, 2)
+
+	if !ok {
+		return
+	}
+
+	if handleErr == nil {
+		t.Error("Expecting the Proxy connection not to be handed over " +
+			"when it can be shared by multiple Channels of the Proxy")
+
+		return
+	}
+}
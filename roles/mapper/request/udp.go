@@ -21,17 +21,21 @@
 package request
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/reinit/coward/common/fsm"
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/access"
+	"github.com/reinit/coward/roles/common/datagram"
 	"github.com/reinit/coward/roles/common/network"
 	"github.com/reinit/coward/roles/common/relay"
 	"github.com/reinit/coward/roles/common/transceiver"
 	"github.com/reinit/coward/roles/mapper/common"
 	proxycommon "github.com/reinit/coward/roles/proxy/common"
+	"github.com/reinit/coward/roles/proxy/request"
 )
 
 type udp struct {
@@ -48,20 +52,35 @@ func UDP(
 	runner worker.Runner,
 	timeout time.Duration,
 	shb *common.SharedBuffer,
-) transceiver.RequestBuilder {
+	session *access.Session,
+	endpoint datagram.Endpoint,
+) transceiver.BalancedRequestBuilder {
 	return func(
+		cID transceiver.ClientID,
 		id transceiver.ConnectionID,
 		conn rw.ReadWriteDepleteDoner,
 		connCtl transceiver.ConnectionControl,
 		log logger.Logger,
 	) fsm.Machine {
+		session.ProxyClient(strconv.FormatUint(uint64(cID), 10))
+
+		var relayClient relay.Client = udpRelay{
+			mapper:  mapper,
+			client:  client,
+			timeout: timeout,
+		}
+
+		if endpoint.Enabled() {
+			relayClient = datagram.Negotiator(
+				relayClient, request.UDPCommandDatagram, endpoint,
+				runner, client.Closed())
+		}
+
 		return tcp{
 			log: log,
-			relay: relay.New(log, runner, conn, shb.Select(id), udpRelay{
-				mapper:  mapper,
-				client:  client,
-				timeout: timeout,
-			}, make([]byte, 4096)),
+			relay: relay.NewMetered(
+				log, runner, conn, shb.Select(id), relayClient,
+				make([]byte, 4096), &session.Traffic),
 			cancel: client.Closed(),
 		}
 	}
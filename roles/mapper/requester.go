@@ -0,0 +1,112 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package mapper
+
+import (
+	"github.com/reinit/coward/common/fsm"
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/transceiver"
+)
+
+// Consts
+const (
+	balancedDestination = transceiver.Destination("Mapper")
+)
+
+// requester sends requests to the COWARD Proxy
+type requester interface {
+	Request(
+		log logger.Logger,
+		req transceiver.BalancedRequestBuilder,
+		cancel <-chan struct{},
+		m transceiver.Meter) (bool, error)
+	Connections() uint32
+	Close() error
+}
+
+// single sends requests through a dedicated transceiver.Requester
+type single struct {
+	requester transceiver.Requester
+}
+
+// Request sends a request
+func (s single) Request(
+	log logger.Logger,
+	req transceiver.BalancedRequestBuilder,
+	cancel <-chan struct{},
+	m transceiver.Meter,
+) (bool, error) {
+	return s.requester.Request(log, func(
+		connID transceiver.ConnectionID,
+		conn rw.ReadWriteDepleteDoner,
+		connCtl transceiver.ConnectionControl,
+		log logger.Logger,
+	) fsm.Machine {
+		return req(s.requester.ID(), connID, conn, connCtl, log)
+	}, cancel, m)
+}
+
+// Connections returns the total connections of the Transceiver Client
+func (s single) Connections() uint32 {
+	return s.requester.Connections()
+}
+
+// Close releases the Transceiver Client
+func (s single) Close() error {
+	return s.requester.Close()
+}
+
+// balanced sends requests through a (shared) transceiver.Balanced
+type balanced struct {
+	balanced transceiver.Balanced
+}
+
+// Request sends a request
+func (b balanced) Request(
+	log logger.Logger,
+	req transceiver.BalancedRequestBuilder,
+	cancel <-chan struct{},
+	m transceiver.Meter,
+) (bool, error) {
+	reqErr := b.balanced.Request(log, balancedDestination, req, cancel)
+
+	return false, reqErr
+}
+
+// Connections returns the total connections of all Transceiver Clients
+func (b balanced) Connections() uint32 {
+	connections := uint32(0)
+
+	b.balanced.Clients(func(
+		id transceiver.ClientID,
+		req transceiver.Requester,
+	) {
+		connections += req.Connections()
+	})
+
+	return connections
+}
+
+// Close releases the Balanced
+func (b balanced) Close() error {
+	return b.balanced.Close()
+}
@@ -24,16 +24,24 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/print"
 	"github.com/reinit/coward/common/role"
+	"github.com/reinit/coward/common/ticker"
+	"github.com/reinit/coward/roles/common/access"
+	"github.com/reinit/coward/roles/common/datagram"
 	"github.com/reinit/coward/roles/common/network"
 	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
 	"github.com/reinit/coward/roles/common/network/dialer/tcp"
+	tcplisten "github.com/reinit/coward/roles/common/network/listener/tcp"
 	"github.com/reinit/coward/roles/common/transceiver"
+	tclient "github.com/reinit/coward/roles/common/transceiver/client"
+	"github.com/reinit/coward/roles/common/transceiver/clients"
+	"github.com/reinit/coward/roles/common/transceiver/reverse"
 	"github.com/reinit/coward/roles/proxy/common"
 )
 
@@ -118,24 +126,27 @@ type ConfigInput struct {
 	Channels       uint8           `json:"channels" cfg:"n,-channels:How many requests can be simultaneously opened on a single established connection.\r\n\r\nSet the value greater than 1 so a single connection can be use to transport multiple requests (Multiplexing).\r\n\r\nWARNING:\r\nThis value must matchs or smaller than the related setting on the COWARD Proxy server, otherwise the request will be come malformed and thus dropped."`
 	Timeout        uint16          `json:"timeout" cfg:"t,-timeout:The maximum idle time in second of a established proxy connection.\r\n\r\nIf the proxy connection consecutively idle during this period of time, then that connection will be considered as inactive and thus be disconnected.\r\n\r\nIt is recommended to set this value no greater than the related one on the COWARD Proxy server setting."`
 	RequestTimeout uint16          `json:"request_timeout" cfg:"rt,-request-timeout:The maximum wait time in second for the server to respond the Initial request of a client.\r\n\r\nIf the COWARD Proxy server has failed to respond the Initial request within this period of time, the connection will be considered broken and thus be closed.\r\n\r\nIt is recommended to set this value slightly greater than the \"--initial-timeout\" setting on the COWARD Proxy server."`
+	Share          bool            `json:"share" cfg:"sh,-share:Share connections to the COWARD Proxy server with other Roles running in the same process.\r\n\r\nConnections will only be shared between Roles which have exactly the same Proxy settings (Including the Codec settings)."`
 	Mapping        []ConfigMapping `json:"mapping" cfg:"m,-mapping:Enable and configure mapped remote destinations.\r\n\r\nThis will allow you to map the pre-defined destinations on the Proxy as local servers.\r\n\r\nAll access to these servers will be relayed to their corresponding remote destinations transparently through the COWARD Proxy server."`
 	Codec          string          `json:"codec" cfg:"e,-codec:Specify which Codec will be used to encode and decode data payload to and from a connection."`
-	CodecSetting   []string        `json:"codec_setting" cfg:"es,-codec-cfg:Configuration of the Codec as an array of string.\r\n\r\nThe actual configuration format of this setting is depend on the Codec of your choosing."`
+	CodecSetting   []string        `json:"codec_setting" secret:"true" cfg:"es,-codec-cfg:Configuration of the Codec as an array of string.\r\n\r\nThe actual configuration format of this setting is depend on the Codec of your choosing."`
+	AccessLog      string          `json:"access_log" cfg:"al,-access-log:Write a record for every finished mapped request to this file.\r\n\r\nEach record is a JSON line which contains the client address, the mapping ID as destination, the protocol, the ID of the chosen COWARD Proxy, the amount of bytes received from and sent to the client, the duration and the close reason of the request.\r\n\r\nSend SIGUSR1 to reopen the file after it has been moved away."`
+	MemoryLimit    uint32          `json:"memory_limit" cfg:"ml,-memory-limit:Soft limit of the memory usage in megabytes.\r\n\r\nOnce the memory usage of the process has exceeded this limit, no more worker will be created and new mapped requests which can't be picked up by an idle worker will be rejected.\r\n\r\nSet to 0 (default) to disable the limit."`
+	Datagram       bool            `json:"datagram" cfg:"du,-datagram:Carry the payload of UDP mappings through an encrypted UDP Association with the COWARD Proxy server instead of the proxy connection.\r\n\r\nThe COWARD Proxy server must enable the same option. If the Association is not available or unreachable, the proxy connection will be used."`
+	Reverse        bool            `json:"reverse" cfg:"rv,-reverse:Wait for the COWARD Proxy server to dial in instead of connecting to it, which is useful when the server can't be reached directly.\r\n\r\nWhen enabled, \"--host\" must be the IP address of a local network interface, and the registration port will be listened on it and the \"--port\". The server must specify this address as its \"--reverse\" setting, and only the servers which share the same Codec key can register.\r\n\r\nConnections to the server will not be shared with other Roles, and \"--datagram\" is not available in this mode."`
 }
 
-// GetDescription gets description
-func (c ConfigInput) GetDescription(fieldPath string) string {
-	result := ""
-
+// GetCandidates gets candidate values of a field
+func (c ConfigInput) GetCandidates(fieldPath string) []string {
 	switch fieldPath {
 	case "/Mapping/Interface":
 		ifAddrs, ifAddrsErr := net.InterfaceAddrs()
 
 		if ifAddrsErr != nil {
-			return ""
+			return nil
 		}
 
-		result = "Available network interfaces:\r\n- 0.0.0.0"
+		result := []string{"0.0.0.0"}
 
 		for idIdx := range ifAddrs {
 			ifIP, _, ifIPErr := net.ParseCIDR(ifAddrs[idIdx].String())
@@ -144,15 +155,16 @@ func (c ConfigInput) GetDescription(fieldPath string) string {
 				continue
 			}
 
-			result += "\r\n- " + ifIP.String()
+			result = append(result, ifIP.String())
 		}
 
+		return result
+
 	case "/Mapping/Protocol":
-		result = "Available protocols:\r\n- " +
-			strings.Join([]string{"tcp", "udp"}, "\r\n- ")
+		return []string{"tcp", "udp"}
 
 	case "/Codec":
-		result = "Available codecs:"
+		result := []string{}
 
 		for cIdx := range c.components {
 			codecBuilder, isCodecBuilder :=
@@ -162,13 +174,38 @@ func (c ConfigInput) GetDescription(fieldPath string) string {
 				continue
 			}
 
-			codecInfo := codecBuilder()
-
-			result += "\r\n- " + codecInfo.Name
+			result = append(result, codecBuilder().Name)
 		}
+
+		return result
 	}
 
-	return result
+	return nil
+}
+
+// GetDescription gets description
+func (c ConfigInput) GetDescription(fieldPath string) string {
+	candidates := c.GetCandidates(fieldPath)
+
+	if len(candidates) <= 0 {
+		return ""
+	}
+
+	switch fieldPath {
+	case "/Mapping/Interface":
+		return "Available network interfaces:\r\n- " +
+			strings.Join(candidates, "\r\n- ")
+
+	case "/Mapping/Protocol":
+		return "Available protocols:\r\n- " +
+			strings.Join(candidates, "\r\n- ")
+
+	case "/Codec":
+		return "Available codecs:\r\n- " +
+			strings.Join(candidates, "\r\n- ")
+	}
+
+	return ""
 }
 
 // VerifyPort Verify Port
@@ -276,6 +313,17 @@ func (c *ConfigInput) Verify() error {
 		return errors.New("Connections must be specified")
 	}
 
+	if c.Reverse {
+		if net.ParseIP(c.Host) == nil {
+			return errors.New(
+				"Host must be an IP address to listen on in Reverse mode")
+		}
+
+		if c.Datagram {
+			return errors.New("Datagram is not available in Reverse mode")
+		}
+	}
+
 	if c.RequestRetries <= 0 {
 		c.RequestRetries = 3
 	}
@@ -315,6 +363,21 @@ func (c *ConfigInput) Verify() error {
 	return nil
 }
 
+// shared returns the shared Transceiver registry from components
+func (c *ConfigInput) shared() *clients.Shared {
+	for cIdx := range c.components {
+		shared, isShared := c.components[cIdx].(*clients.Shared)
+
+		if !isShared {
+			continue
+		}
+
+		return shared
+	}
+
+	return nil
+}
+
 // Role register
 func Role() role.Registration {
 	return role.Registration{
@@ -332,9 +395,13 @@ func Role() role.Registration {
 				Channels:       0,
 				Timeout:        0,
 				RequestTimeout: 0,
+				Share:          false,
 				Mapping:        []ConfigMapping{},
 				Codec:          "",
 				CodecSetting:   nil,
+				AccessLog:      "",
+				Datagram:       false,
+				Reverse:        false,
 			}
 		},
 		Generater: func(
@@ -344,12 +411,63 @@ func Role() role.Registration {
 		) (role.Role, error) {
 			cfg := config.(*ConfigInput)
 
-			dialer := tcp.New(
-				cfg.Host,
-				cfg.Port,
-				time.Duration(cfg.RequestTimeout)*time.Second,
-				tcpconn.Wrap,
-			)
+			var dialer network.Dialer
+			var registry reverse.Registry
+
+			if cfg.Reverse {
+				registry = reverse.NewRegistry(tcplisten.New(
+					net.ParseIP(cfg.Host),
+					cfg.Port,
+					tcpconn.Wrap,
+				), cfg.selectedCodec.Build(
+					cfg.CodecSetting,
+				), cfg.Connections, time.Duration(
+					cfg.RequestTimeout)*time.Second, log)
+
+				dialer = registry
+			} else {
+				dialer = tcp.New(
+					cfg.Host,
+					cfg.Port,
+					time.Duration(cfg.RequestTimeout)*time.Second,
+					tcpconn.Wrap,
+				)
+			}
+
+			var balancer transceiver.Balancer
+
+			shared := cfg.shared()
+
+			if cfg.Share && shared != nil && registry == nil {
+				balancer = shared.Balancer([]clients.SharedProxy{{
+					Host:           cfg.Host,
+					Port:           cfg.Port,
+					Connections:    cfg.Connections,
+					RequestRetries: cfg.RequestRetries,
+					Timeout:        cfg.Timeout,
+					RequestTimeout: cfg.RequestTimeout,
+					Channels:       cfg.Channels,
+					Persistent:     cfg.Persistent,
+					Codec:          cfg.Codec,
+					CodecSetting:   cfg.CodecSetting,
+				}}, func(
+					tk ticker.Requester, l logger.Logger,
+				) []transceiver.Client {
+					return []transceiver.Client{tclient.New(
+						0, l, dialer,
+						cfg.selectedCodec.Build(cfg.CodecSetting),
+						tk, tclient.Config{
+							MaxConcurrent:  cfg.Connections,
+							RequestRetries: cfg.RequestRetries,
+							IdleTimeout: time.Duration(
+								cfg.Timeout) * time.Second,
+							InitialTimeout: time.Duration(
+								cfg.RequestTimeout) * time.Second,
+							ConnectionPersistent: cfg.Persistent,
+							ConnectionChannels:   cfg.Channels,
+						})}
+				}, 1, log)
+			}
 
 			mapps := make([]Mapped, len(cfg.Mapping))
 
@@ -363,6 +481,26 @@ func Role() role.Registration {
 				}
 			}
 
+			datagramEndpoint := datagram.Endpoint{
+				Address:   "",
+				Keepalive: 0,
+			}
+
+			if cfg.Datagram {
+				datagramEndpoint = datagram.Endpoint{
+					Address: net.JoinHostPort(
+						cfg.Host, strconv.FormatUint(uint64(cfg.Port), 10)),
+					Keepalive: datagram.Keepalive(
+						time.Duration(cfg.Timeout) * time.Second),
+				}
+			}
+
+			accessLog := access.NewDitch()
+
+			if cfg.AccessLog != "" {
+				accessLog = access.NewFile(cfg.AccessLog)
+			}
+
 			return New(
 				cfg.selectedCodec.Build(cfg.CodecSetting),
 				dialer,
@@ -376,7 +514,13 @@ func Role() role.Registration {
 						cfg.RequestTimeout) * time.Second,
 					TransceiverConnectionPersistent: cfg.Persistent,
 					TransceiverChannels:             cfg.Channels,
+					TransceiverDatagram:             datagramEndpoint,
+					TransceiverRegistry:             registry,
+					Transceiver:                     balancer,
 					Mapping:                         mapps,
+					AccessLog:                       accessLog,
+					MemoryLimit: uint64(
+						cfg.MemoryLimit) * 1024 * 1024,
 				}), nil
 		},
 	}
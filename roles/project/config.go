@@ -23,6 +23,7 @@ package project
 import (
 	"time"
 
+	"github.com/reinit/coward/roles/common/network/dialer/upstream"
 	"github.com/reinit/coward/roles/project/project"
 )
 
@@ -48,4 +49,6 @@ type Config struct {
 	TransceiverChannels             uint8
 	TransceiverConnectionPersistent bool
 	Endpoints                       Endpoints
+	MemoryLimit                     uint64
+	Upstream                        upstream.Upstream
 }
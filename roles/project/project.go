@@ -34,6 +34,7 @@ import (
 	udpconn "github.com/reinit/coward/roles/common/network/connection/udp"
 	"github.com/reinit/coward/roles/common/network/dialer/tcp"
 	"github.com/reinit/coward/roles/common/network/dialer/udp"
+	"github.com/reinit/coward/roles/common/network/dialer/upstream"
 	"github.com/reinit/coward/roles/common/transceiver"
 	tclient "github.com/reinit/coward/roles/common/transceiver/client"
 	"github.com/reinit/coward/roles/project/project"
@@ -84,6 +85,19 @@ func New(
 	}
 }
 
+// endpointDialer returns a Dialer which connects to the TCP Endpoint through
+// the upstream proxy if it's enabled, or directly otherwise
+func (s *projectile) endpointDialer(endpoint project.Endpoint) network.Dialer {
+	if s.cfg.Upstream.Enabled() {
+		return upstream.New(
+			s.cfg.Upstream, endpoint.Host, endpoint.Port,
+			endpoint.RequestTimeout, nil, tcpconn.Wrap)
+	}
+
+	return tcp.New(
+		endpoint.Host, endpoint.Port, endpoint.RequestTimeout, tcpconn.Wrap)
+}
+
 // Spawn initialize a new Projectile
 func (s *projectile) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 	s.unspawnNotifier = unspawnNotifier
@@ -102,12 +116,17 @@ func (s *projectile) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 	s.ticker = tticker
 
 	// Start Corunner
+	minWorkers := pcommon.AutomaticalMinWorkerCount(
+		s.cfg.Endpoints.TotalConnections()*2, 128)
+
 	runner, runnerServeErr := worker.New(s.logger, s.ticker, worker.Config{
-		MaxWorkers: s.cfg.Endpoints.TotalConnections() * 2,
-		MinWorkers: pcommon.AutomaticalMinWorkerCount(
-			s.cfg.Endpoints.TotalConnections()*2, 128),
+		MaxWorkers:        s.cfg.Endpoints.TotalConnections() * 2,
+		MinWorkers:        minWorkers,
 		MaxWorkerIdle:     s.cfg.TransceiverIdleTimeout * 2,
 		JobReceiveTimeout: s.cfg.TransceiverInitialTimeout,
+		MaxQueueLatency:   pcommon.WorkerMaxQueueLatency,
+		MemoryLimit:       s.cfg.MemoryLimit,
+		MaxQueued:         minWorkers,
 	}).Serve()
 
 	if runnerServeErr != nil {
@@ -147,9 +166,7 @@ func (s *projectile) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 		case network.TCP:
 			pRegisterations[epIdx] = project.Registeration{
 				Endpoint: s.cfg.Endpoints[epIdx],
-				Dialer: tcp.New(
-					s.cfg.Endpoints[epIdx].Host, s.cfg.Endpoints[epIdx].Port,
-					s.cfg.Endpoints[epIdx].RequestTimeout, tcpconn.Wrap),
+				Dialer:   s.endpointDialer(s.cfg.Endpoints[epIdx]),
 				MinWorkers: pcommon.AutomaticalMinWorkerCount(
 					s.cfg.Endpoints[epIdx].MaxConnections, 64),
 			}
@@ -24,6 +24,7 @@ import (
 	"net"
 	"time"
 
+	"github.com/reinit/coward/roles/common/access"
 	"github.com/reinit/coward/roles/common/network"
 	"github.com/reinit/coward/roles/projector/projection"
 )
@@ -49,6 +50,8 @@ type Config struct {
 	RequestRetries       uint8
 	ConnectionChannels   uint8
 	ChannelDispatchDelay time.Duration
+	AccessLog            access.Log
+	MemoryLimit          uint64
 }
 
 // GetAllServerRegisterations return projection registeration for all
@@ -25,6 +25,7 @@ import (
 
 	"github.com/reinit/coward/common/worker"
 	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/relay"
 )
 
 // Accessor is the accessing requesting client
@@ -33,6 +34,7 @@ type Accessor interface {
 	Result(e error, retriable bool, resetProccessor bool, wait chan struct{})
 	Runner() worker.Runner
 	Proccessor(p Proccessor)
+	Traffic() *relay.Traffic
 }
 
 // Proccessor represents a handler that will handle Accessor
@@ -55,6 +57,7 @@ type accessor struct {
 	proccessor chan Proccessor
 	result     chan accessorResult
 	runner     worker.Runner
+	traffic    *relay.Traffic
 }
 
 // Access returns the Connection of the Accessor
@@ -82,3 +85,8 @@ func (a accessor) Runner() worker.Runner {
 func (a accessor) Proccessor(p Proccessor) {
 	a.proccessor <- p
 }
+
+// Traffic returns the Traffic counter of the Accessor
+func (a accessor) Traffic() *relay.Traffic {
+	return a.traffic
+}
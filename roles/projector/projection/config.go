@@ -20,11 +20,16 @@
 
 package projection
 
-import "time"
+import (
+	"time"
+
+	"github.com/reinit/coward/roles/common/access"
+)
 
 // Config Projection configuration
 type Config struct {
 	MaxReceivers   uint32
 	RequestTimeout time.Duration
 	Projects       []Register
+	Access         access.Logger
 }
@@ -21,13 +21,17 @@
 package projection
 
 import (
+	"strconv"
+
 	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/roles/common/access"
 	"github.com/reinit/coward/roles/common/network"
 )
 
 // handler Projection handler
 type handler struct {
 	projection *projection
+	access     access.Logger
 }
 
 // client Projection client
@@ -35,6 +39,7 @@ type client struct {
 	projection *projection
 	c          network.Connection
 	l          logger.Logger
+	access     access.Logger
 }
 
 // New creates a new Projection client
@@ -46,10 +51,21 @@ func (d handler) New(
 		projection: d.projection,
 		c:          c,
 		l:          l,
+		access:     d.access,
 	}, nil
 }
 
 // Serve start serving projection
 func (d client) Serve() error {
-	return d.projection.Receive(d.c)
+	session := access.NewSession(
+		d.c.RemoteAddr().String(), d.c.LocalAddr().Network())
+
+	session.Destination(
+		"#" + strconv.FormatUint(uint64(d.projection.id), 10))
+
+	receiveErr := d.projection.Receive(d.c, &session.Traffic)
+
+	session.Finish(d.access, receiveErr)
+
+	return receiveErr
 }
@@ -27,6 +27,7 @@ import (
 
 	"github.com/reinit/coward/common/ticker"
 	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/relay"
 )
 
 // Errors
@@ -46,7 +47,7 @@ var (
 
 // Projection is the projection operator
 type Projection interface {
-	Receive(c network.Connection) error
+	Receive(c network.Connection, t *relay.Traffic) error
 	Receiver() Receiver
 }
 
@@ -175,7 +176,8 @@ func (p *projection) receive(
 }
 
 // Receive deliver a request to one receiver
-func (p *projection) Receive(c network.Connection) error {
+func (p *projection) Receive(
+	c network.Connection, t *relay.Traffic) error {
 	exp := time.Now().Add(p.requestTimeout)
 
 	runn := runner{
@@ -189,6 +191,7 @@ func (p *projection) Receive(c network.Connection) error {
 		proccessor: make(chan Proccessor),
 		result:     make(chan accessorResult, 1),
 		runner:     runn,
+		traffic:    t,
 	}
 
 	defer func() {
@@ -108,5 +108,6 @@ func (p *projections) Handler(id ID) (network.Handler, error) {
 
 	return handler{
 		projection: p.projections[id],
+		access:     p.cfg.Access,
 	}, nil
 }
@@ -75,3 +75,17 @@ func (r runner) Close() error {
 
 	return nil
 }
+
+// Stats returns the state of current runner. It runs the job in the local
+// routine, so there is always one and only one Worker
+func (r runner) Stats() worker.Stats {
+	return worker.Stats{
+		Workers:      1,
+		Busy:         0,
+		Idle:         0,
+		Queued:       0,
+		Rejected:     0,
+		QueueLatency: 0,
+		Memory:       0,
+	}
+}
@@ -100,6 +100,15 @@ func (s *projector) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 		return ErrNoServerToProject
 	}
 
+	accessOpenErr := s.cfg.AccessLog.Open()
+
+	if accessOpenErr != nil {
+		s.logger.Errorf("Failed to open access log due to error: %s",
+			accessOpenErr)
+
+		return accessOpenErr
+	}
+
 	tticker, tickerErr := ticker.New(tickDelay, 1024).Serve()
 
 	if tickerErr != nil {
@@ -111,12 +120,16 @@ func (s *projector) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 	// Start Corunner
 	startWorkers := s.cfg.GetTotalServerCapacity() +
 		((s.cfg.Capacity * uint32(s.cfg.ConnectionChannels)) * 2)
+	minWorkers := common.AutomaticalMinWorkerCount(startWorkers, 128)
 
 	runner, runnerServeErr := worker.New(s.logger, s.ticker, worker.Config{
 		MaxWorkers:        startWorkers,
-		MinWorkers:        common.AutomaticalMinWorkerCount(startWorkers, 128),
+		MinWorkers:        minWorkers,
 		MaxWorkerIdle:     s.cfg.IdleTimeout * 2,
 		JobReceiveTimeout: s.cfg.InitialTimeout,
+		MaxQueueLatency:   common.WorkerMaxQueueLatency,
+		MemoryLimit:       s.cfg.MemoryLimit,
+		MaxQueued:         minWorkers,
 	}).Serve()
 
 	if runnerServeErr != nil {
@@ -131,6 +144,7 @@ func (s *projector) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 			MaxReceivers:   s.cfg.Capacity,
 			RequestTimeout: s.cfg.InitialTimeout,
 			Projects:       s.cfg.GetAllServerRegisterations(),
+			Access:         s.cfg.AccessLog,
 		})
 
 	// Bootup project servers
@@ -245,6 +259,57 @@ func (s *projector) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 	return nil
 }
 
+// Drain stops accepting new connections on projections and waits for
+// existing ones to complete, up to the timeout. The registration server will
+// keep serving until Unspawn, so the ongoing projection requests can still
+// be relayed through registered Projects
+func (s *projector) Drain(timeout time.Duration) error {
+	var lastErr error
+
+	s.logger.Infof("Draining")
+
+	deadline := time.Now().Add(timeout)
+
+	for sIdx := range s.servers {
+		if s.servers[sIdx] == nil {
+			continue
+		}
+
+		drainErr := s.servers[sIdx].Drain(time.Until(deadline))
+
+		if drainErr == nil {
+			continue
+		}
+
+		s.logger.Warningf("Failed to drain projection %d (%s) due to "+
+			"error: %s", sIdx, s.servers[sIdx].Listening(), drainErr)
+
+		lastErr = drainErr
+	}
+
+	if lastErr != nil {
+		return lastErr
+	}
+
+	s.logger.Infof("Server is drained")
+
+	return nil
+}
+
+// Reopen reopens the access log
+func (s *projector) Reopen() error {
+	reopenErr := s.cfg.AccessLog.Reopen()
+
+	if reopenErr != nil {
+		s.logger.Warningf("Failed to reopen access log due to error: %s",
+			reopenErr)
+
+		return reopenErr
+	}
+
+	return nil
+}
+
 // // Unspawn closes current Projector
 func (s *projector) Unspawn() error {
 	s.logger.Infof("Closing")
@@ -295,6 +360,13 @@ func (s *projector) Unspawn() error {
 		s.ticker = nil
 	}
 
+	accessCloseErr := s.cfg.AccessLog.Close()
+
+	if accessCloseErr != nil {
+		s.logger.Warningf("Failed to close access log due to error: %s",
+			accessCloseErr)
+	}
+
 	s.logger.Infof("Server is closed")
 
 	s.unspawnNotifier <- struct{}{}
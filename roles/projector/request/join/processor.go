@@ -401,7 +401,7 @@ func (p *processor) wait(f fsm.FSM) error {
 
 // relayInit initialize the relay
 func (p *processor) relayInit(f fsm.FSM) error {
-	p.currentRelay = relay.New(
+	p.currentRelay = relay.NewMetered(
 		p.logger,
 		p.currentReceivedAccessor.Runner(),
 		p.rw,
@@ -411,7 +411,8 @@ func (p *processor) relayInit(f fsm.FSM) error {
 			reqTimeout: p.cfg.ClientReqTimeout,
 			timeout:    p.cfg.ClientTimeout,
 		},
-		make([]byte, 4096))
+		make([]byte, 4096),
+		p.currentReceivedAccessor.Traffic())
 
 	bootErr := p.currentRelay.Bootup(nil)
 
@@ -66,9 +66,10 @@ type dummyAccessorResult struct {
 }
 
 type dummyAccessor struct {
-	access network.Connection
-	result chan dummyAccessorResult
-	rr     worker.Runner
+	access  network.Connection
+	result  chan dummyAccessorResult
+	rr      worker.Runner
+	traffic *relay.Traffic
 }
 
 func (d dummyAccessor) Access() network.Connection {
@@ -87,15 +88,21 @@ func (d dummyAccessor) Runner() worker.Runner {
 
 func (d dummyAccessor) Proccessor(p projection.Proccessor) {}
 
+func (d dummyAccessor) Traffic() *relay.Traffic {
+	return d.traffic
+}
+
 type dummyProjection struct {
 	id         projection.ID
 	accessChan chan projection.Accessor
 }
 
-func (d *dummyProjection) Receive(c network.Connection) error {
+func (d *dummyProjection) Receive(
+	c network.Connection, t *relay.Traffic) error {
 	aa := dummyAccessor{
-		access: c,
-		result: make(chan dummyAccessorResult),
+		access:  c,
+		result:  make(chan dummyAccessorResult),
+		traffic: t,
 	}
 
 	d.accessChan <- aa
@@ -322,9 +329,10 @@ func TestProccessor(t *testing.T) {
 					},
 				}
 				acc := dummyAccessor{
-					access: accConn,
-					result: make(chan dummyAccessorResult),
-					rr:     rr,
+					access:  accConn,
+					result:  make(chan dummyAccessorResult),
+					rr:      rr,
+					traffic: &relay.Traffic{},
 				}
 
 				dp1.accessChan <- acc
@@ -30,6 +30,7 @@ import (
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/print"
 	"github.com/reinit/coward/common/role"
+	"github.com/reinit/coward/roles/common/access"
 	"github.com/reinit/coward/roles/common/network"
 	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
 	"github.com/reinit/coward/roles/common/network/listener/tcp"
@@ -130,13 +131,13 @@ type ConfigInput struct {
 	ChannelDispatchDelay uint16           `json:"channel_dispatch_delay" cfg:"cd,-channel-delay:A delay of time in millisecond in between Connection Channel data dispatch operations.\r\n\r\nThe main propose of this setting is to limit the CPU usage of the Connection Channel data dispatch. However, it can also in part be use to control the server's connection bandwidth (Higher the delay, lower the bandwidth and CPU usage)."`
 	Projects             []*ConfigProject `json:"projects" cfg:"s,-projects:Pre-defined Projection servers"`
 	Codec                string           `json:"codec" cfg:"e,-codec:Specify which Codec will be used to encode and decode data payload to and from a connection."`
-	CodecSetting         []string         `json:"codec_setting" cfg:"es,-codec-cfg:Configuration of the Codec as an array of string.\r\n\r\nThe actual configuration format of this setting is depend on the Codec of your choosing."`
+	CodecSetting         []string         `json:"codec_setting" secret:"true" cfg:"es,-codec-cfg:Configuration of the Codec as an array of string.\r\n\r\nThe actual configuration format of this setting is depend on the Codec of your choosing."`
+	AccessLog            string           `json:"access_log" cfg:"al,-access-log:Write a record for every finished Projection request to this file.\r\n\r\nEach record is a JSON line which contains the client address, the Projection ID as destination, the protocol, the amount of bytes received from and sent to the client, the duration and the close reason of the request.\r\n\r\nSend SIGUSR1 to reopen the file after it has been moved away."`
+	MemoryLimit          uint32           `json:"memory_limit" cfg:"ml,-memory-limit:Soft limit of the memory usage in megabytes.\r\n\r\nOnce the memory usage of the process has exceeded this limit, no more worker will be created and new Projection requests which can't be picked up by an idle worker will be rejected.\r\n\r\nSet to 0 (default) to disable the limit."`
 }
 
-// GetDescription get descriptions
-func (c ConfigInput) GetDescription(fieldPath string) string {
-	result := ""
-
+// GetCandidates gets candidate values of a field
+func (c ConfigInput) GetCandidates(fieldPath string) []string {
 	switch fieldPath {
 	case "/Interface":
 		fallthrough
@@ -144,10 +145,10 @@ func (c ConfigInput) GetDescription(fieldPath string) string {
 		ifAddrs, ifAddrsErr := net.InterfaceAddrs()
 
 		if ifAddrsErr != nil {
-			return ""
+			return nil
 		}
 
-		result = "Available network interfaces:\r\n- 0.0.0.0"
+		result := []string{"0.0.0.0"}
 
 		for idIdx := range ifAddrs {
 			ifIP, _, ifIPErr := net.ParseCIDR(ifAddrs[idIdx].String())
@@ -156,15 +157,16 @@ func (c ConfigInput) GetDescription(fieldPath string) string {
 				continue
 			}
 
-			result += "\r\n- " + ifIP.String()
+			result = append(result, ifIP.String())
 		}
 
+		return result
+
 	case "/Projects/Protocol":
-		result = "Available protocols:\r\n- " +
-			strings.Join([]string{"tcp", "udp"}, "\r\n- ")
+		return []string{"tcp", "udp"}
 
 	case "/Codec":
-		result = "Available codecs:"
+		result := []string{}
 
 		for cIdx := range c.components {
 			codecBuilder, isCodecBuilder :=
@@ -174,13 +176,40 @@ func (c ConfigInput) GetDescription(fieldPath string) string {
 				continue
 			}
 
-			codecInfo := codecBuilder()
-
-			result += "\r\n- " + codecInfo.Name
+			result = append(result, codecBuilder().Name)
 		}
+
+		return result
+	}
+
+	return nil
+}
+
+// GetDescription get descriptions
+func (c ConfigInput) GetDescription(fieldPath string) string {
+	candidates := c.GetCandidates(fieldPath)
+
+	if len(candidates) <= 0 {
+		return ""
+	}
+
+	switch fieldPath {
+	case "/Interface":
+		fallthrough
+	case "/Projects/Interface":
+		return "Available network interfaces:\r\n- " +
+			strings.Join(candidates, "\r\n- ")
+
+	case "/Projects/Protocol":
+		return "Available protocols:\r\n- " +
+			strings.Join(candidates, "\r\n- ")
+
+	case "/Codec":
+		return "Available codecs:\r\n- " +
+			strings.Join(candidates, "\r\n- ")
 	}
 
-	return result
+	return ""
 }
 
 // VerifyInterface Verify Interface
@@ -342,6 +371,7 @@ func Role() role.Registration {
 				Projects:             []*ConfigProject{},
 				Codec:                "",
 				CodecSetting:         nil,
+				AccessLog:            "",
 			}
 		},
 		Generater: func(
@@ -369,6 +399,12 @@ func Role() role.Registration {
 				}
 			}
 
+			accessLog := access.NewDitch()
+
+			if cfg.AccessLog != "" {
+				accessLog = access.NewFile(cfg.AccessLog)
+			}
+
 			return New(
 				listen,
 				cfg.selectedCodec.Build(cfg.CodecSetting),
@@ -383,6 +419,8 @@ func Role() role.Registration {
 					ConnectionChannels: cfg.Channels,
 					ChannelDispatchDelay: time.Duration(
 						cfg.ChannelDispatchDelay) * time.Millisecond,
+					AccessLog:   accessLog,
+					MemoryLimit: uint64(cfg.MemoryLimit) * 1024 * 1024,
 				}), nil
 		},
 	}
@@ -0,0 +1,37 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package common
+
+import "time"
+
+// Settings of the DNS cache
+const (
+	// DNSCacheMinTTL is the minimum time a resolved host will be cached,
+	// even when the DNS server says it should expire sooner
+	DNSCacheMinTTL = 10 * time.Second
+
+	// DNSCacheMaxTTL is the maximum time a resolved host will be cached
+	DNSCacheMaxTTL = 1 * time.Hour
+
+	// DNSCacheNegativeTTL is how long a host that doesn't exist will be
+	// remembered
+	DNSCacheNegativeTTL = 30 * time.Second
+)
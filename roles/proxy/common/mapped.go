@@ -25,6 +25,7 @@ import (
 	"math"
 
 	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/network/egress"
 )
 
 // Errors
@@ -41,11 +42,14 @@ const (
 	MaxMapID = math.MaxUint8
 )
 
-// Mapped Item
+// Mapped Item. If Egress is nil, the default Egress will be used. If
+// Forward is true, requests of it will be forwarded to the next hop Proxy
 type Mapped struct {
 	Protocol network.Protocol
 	Host     string
 	Port     uint16
+	Egress   egress.Egress
+	Forward  bool
 }
 
 // Mapping contains Mapped Items
@@ -20,6 +20,12 @@
 
 package common
 
+import "time"
+
+// WorkerMaxQueueLatency is the maximum time a Job should wait for a Worker.
+// Once Jobs are waiting longer, more Workers will be created at once
+const WorkerMaxQueueLatency = 100 * time.Millisecond
+
 // AutomaticalMinWorkerCount returns the recommended minWorker setting
 // according to maxWorker and rate
 func AutomaticalMinWorkerCount(maxWorkers uint32, rate uint32) uint32 {
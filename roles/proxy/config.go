@@ -21,9 +21,15 @@
 package proxy
 
 import (
+	"net"
 	"time"
 
+	"github.com/reinit/coward/common/ticker"
 	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/network/dialer/upstream"
+	"github.com/reinit/coward/roles/common/network/egress"
+	"github.com/reinit/coward/roles/common/network/resolve"
+	"github.com/reinit/coward/roles/common/transceiver"
 )
 
 // Mapped Mapping destinations
@@ -32,6 +38,15 @@ type Mapped struct {
 	Host     string
 	Port     uint16
 	Protocol network.Protocol
+	Egress   egress.Egress
+	Forward  bool
+}
+
+// Forward is the next hop Proxies that the requests can be forwarded to.
+// Requests will not be forwarded if the Balancer is nil
+type Forward struct {
+	Balancer func(ticker.Requester) transceiver.Balancer
+	Default  bool
 }
 
 // Config of the Proxy
@@ -42,4 +57,10 @@ type Config struct {
 	ConnectionChannels   uint8
 	ChannelDispatchDelay time.Duration
 	Mapping              []Mapped
+	MemoryLimit          uint64
+	Resolver             resolve.Resolver
+	Datagram             *net.UDPAddr
+	Egress               egress.Egress
+	Upstream             upstream.Upstream
+	Forward              Forward
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/worker"
 	"github.com/reinit/coward/roles/common/command"
+	"github.com/reinit/coward/roles/common/datagram"
 	"github.com/reinit/coward/roles/common/network"
 	"github.com/reinit/coward/roles/common/transceiver"
 	"github.com/reinit/coward/roles/proxy/common"
@@ -34,6 +35,8 @@ type handler struct {
 	transceiver transceiver.Server
 	runner      worker.Runner
 	mapping     common.Mapping
+	datagram    datagram.Server
+	forward     transceiver.Balanced
 	cfg         Config
 }
 
@@ -43,6 +46,8 @@ type client struct {
 	mapping     common.Mapping
 	transceiver transceiver.Server
 	runner      worker.Runner
+	datagram    datagram.Server
+	forward     transceiver.Balanced
 	cfg         Config
 }
 
@@ -56,6 +61,8 @@ func (d handler) New(
 		mapping:     d.mapping,
 		transceiver: d.transceiver,
 		runner:      d.runner,
+		datagram:    d.datagram,
+		forward:     d.forward,
 		cfg:         d.cfg,
 	}, nil
 }
@@ -63,64 +70,95 @@ func (d handler) New(
 func (d client) Serve() error {
 	buf := [4096]byte{}
 
+	udp := request.UDP{
+		Runner:    d.runner,
+		Buffer:    buf[:],
+		Cancel:    d.conn.Closed(),
+		LocalAddr: d.conn.LocalAddr(),
+		Resolver:  d.cfg.Resolver,
+		Egress:    d.cfg.Egress,
+	}
+
+	udpMapping := request.UDPMapping{
+		Runner:      d.runner,
+		Buffer:      buf[:],
+		Cancel:      d.conn.Closed(),
+		LocalAddr:   d.conn.LocalAddr(),
+		DialTimeout: d.cfg.InitialTimeout,
+		Mapping:     d.mapping,
+		Resolver:    d.cfg.Resolver,
+		Egress:      d.cfg.Egress,
+	}
+
+	tcp := func(noLocalAccess bool, splice bool) request.TCP {
+		return request.TCP{
+			Runner:            d.runner,
+			Buffer:            buf[:],
+			DialTimeout:       d.cfg.InitialTimeout,
+			ConnectionTimeout: d.cfg.IdleTimeout,
+			Cancel:            d.conn.Closed(),
+			NoLocalAccess:     noLocalAccess,
+			Resolver:          d.cfg.Resolver,
+			Egress:            d.cfg.Egress,
+			Upstream:          d.cfg.Upstream,
+			Splice:            splice,
+		}
+	}
+
+	forward := request.Forward{
+		Runner:      d.runner,
+		Cancel:      d.conn.Closed(),
+		Transceiver: d.forward,
+		IdleTimeout: d.cfg.IdleTimeout,
+		Default:     d.cfg.Forward.Default,
+		Mapping:     d.mapping,
+	}
+
+	forwarded := func(c command.Command) command.Command {
+		if d.forward == nil {
+			return c
+		}
+
+		return request.Forwarded{
+			Command: c,
+			Forward: forward,
+		}
+	}
+
+	// Payload of the forwarded requests can't be carried by the
+	// Association, so don't offer it
+	datagramServer := d.datagram
+
+	if d.forward != nil {
+		datagramServer = nil
+	}
+
 	return d.transceiver.Handle(
 		d.logger,
 		d.conn,
 		command.New(
-			request.TCPIPv4{
-				TCP: request.TCP{
-					Runner:            d.runner,
-					Buffer:            buf[:],
-					DialTimeout:       d.cfg.InitialTimeout,
-					ConnectionTimeout: d.cfg.IdleTimeout,
-					Cancel:            d.conn.Closed(),
-					NoLocalAccess:     true,
-				},
-			},
-			request.TCPIPv6{
-				TCP: request.TCP{
-					Runner:            d.runner,
-					Buffer:            buf[:],
-					DialTimeout:       d.cfg.InitialTimeout,
-					ConnectionTimeout: d.cfg.IdleTimeout,
-					Cancel:            d.conn.Closed(),
-					NoLocalAccess:     true,
-				},
-			},
-			request.TCPHost{
-				TCP: request.TCP{
-					Runner:            d.runner,
-					Buffer:            buf[:],
-					DialTimeout:       d.cfg.InitialTimeout,
-					ConnectionTimeout: d.cfg.IdleTimeout,
-					Cancel:            d.conn.Closed(),
-					NoLocalAccess:     true,
-				},
-			},
-			request.TCPMapping{
-				TCP: request.TCP{
-					Runner:            d.runner,
-					Buffer:            buf[:],
-					DialTimeout:       d.cfg.InitialTimeout,
-					ConnectionTimeout: d.cfg.IdleTimeout,
-					Cancel:            d.conn.Closed(),
-					NoLocalAccess:     false,
-				},
+			forwarded(request.TCPIPv4{TCP: tcp(true, false)}),
+			forwarded(request.TCPIPv6{TCP: tcp(true, false)}),
+			forwarded(request.TCPHost{TCP: tcp(true, false)}),
+			forwarded(request.TCPMapping{
+				TCP:     tcp(false, false),
 				Mapping: d.mapping,
-			},
-			request.UDP{
-				Runner:    d.runner,
-				Buffer:    buf[:],
-				Cancel:    d.conn.Closed(),
-				LocalAddr: d.conn.LocalAddr(),
-			},
-			request.UDPMapping{
-				Runner:      d.runner,
-				Buffer:      buf[:],
-				Cancel:      d.conn.Closed(),
-				LocalAddr:   d.conn.LocalAddr(),
-				DialTimeout: d.cfg.InitialTimeout,
-				Mapping:     d.mapping,
+			}),
+			forwarded(request.TCPIPv4{TCP: tcp(true, true)}),
+			forwarded(request.TCPIPv6{TCP: tcp(true, true)}),
+			forwarded(request.TCPHost{TCP: tcp(true, true)}),
+			forwarded(request.TCPMapping{
+				TCP:     tcp(false, true),
+				Mapping: d.mapping,
+			}),
+			forwarded(udp),
+			forwarded(udpMapping),
+			request.UDPDatagram{
+				UDP:        udp,
+				UDPMapping: udpMapping,
+				Server:     datagramServer,
+				Keepalive:  datagram.Keepalive(d.cfg.IdleTimeout),
+				Forward:    forward,
 			},
 		),
 	)
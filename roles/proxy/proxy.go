@@ -27,6 +27,7 @@ import (
 	"github.com/reinit/coward/common/role"
 	"github.com/reinit/coward/common/ticker"
 	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/datagram"
 	"github.com/reinit/coward/roles/common/network"
 	"github.com/reinit/coward/roles/common/network/server"
 	"github.com/reinit/coward/roles/common/transceiver"
@@ -48,6 +49,8 @@ type proxy struct {
 	serving         network.Serving
 	ticker          ticker.RequestCloser
 	runner          worker.Runner
+	datagram        datagram.Server
+	forward         transceiver.Balanced
 	unspawnNotifier role.UnspawnNotifier
 }
 
@@ -69,6 +72,8 @@ func New(
 		serving:         nil,
 		ticker:          nil,
 		runner:          nil,
+		datagram:        nil,
+		forward:         nil,
 		unspawnNotifier: nil,
 	}
 }
@@ -82,6 +87,8 @@ func (s *proxy) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 			Protocol: s.cfg.Mapping[mapIdx].Protocol,
 			Host:     s.cfg.Mapping[mapIdx].Host,
 			Port:     s.cfg.Mapping[mapIdx].Port,
+			Egress:   s.cfg.Mapping[mapIdx].Egress,
+			Forward:  s.cfg.Mapping[mapIdx].Forward,
 		}
 	}
 
@@ -94,13 +101,18 @@ func (s *proxy) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 
 	s.ticker = tticker
 
+	minWorkers := common.AutomaticalMinWorkerCount(
+		s.cfg.Capacity*uint32(s.cfg.ConnectionChannels)*2, 128)
+
 	runner, runnerServeErr := worker.New(s.logger, s.ticker, worker.Config{
 		MaxWorkers: (s.cfg.Capacity * uint32(
 			s.cfg.ConnectionChannels)) * 2,
-		MinWorkers: common.AutomaticalMinWorkerCount(
-			s.cfg.Capacity*uint32(s.cfg.ConnectionChannels)*2, 128),
+		MinWorkers:        minWorkers,
 		MaxWorkerIdle:     s.cfg.IdleTimeout * 2,
 		JobReceiveTimeout: s.cfg.InitialTimeout,
+		MaxQueueLatency:   common.WorkerMaxQueueLatency,
+		MemoryLimit:       s.cfg.MemoryLimit,
+		MaxQueued:         minWorkers,
 	}).Serve()
 
 	if runnerServeErr != nil {
@@ -109,6 +121,38 @@ func (s *proxy) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 
 	s.runner = runner
 
+	if s.cfg.Datagram != nil {
+		datagramServer, listenErr := datagram.Listen(
+			s.cfg.Datagram,
+			s.cfg.Capacity*uint32(s.cfg.ConnectionChannels),
+			s.logger)
+
+		if listenErr != nil {
+			s.logger.Errorf("Failed to listen datagram due to error: %s",
+				listenErr)
+
+			return listenErr
+		}
+
+		s.datagram = datagramServer
+
+		s.logger.Infof("Datagram is up, listening \"%s\"",
+			s.datagram.Listening())
+	}
+
+	if s.cfg.Forward.Balancer != nil {
+		forward, forwardErr := s.cfg.Forward.Balancer(s.ticker).Serve()
+
+		if forwardErr != nil {
+			s.logger.Errorf("Failed to start the next hop Proxy clients "+
+				"due to error: %s", forwardErr)
+
+			return forwardErr
+		}
+
+		s.forward = forward
+	}
+
 	server, serveErr := server.New(s.listener, handler{
 		transceiver: tserver.New(s.codec, nil, tserver.Config{
 			InitialTimeout:       s.cfg.InitialTimeout,
@@ -116,9 +160,11 @@ func (s *proxy) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 			ConnectionChannels:   s.cfg.ConnectionChannels,
 			ChannelDispatchDelay: s.cfg.ChannelDispatchDelay,
 		}),
-		runner:  s.runner,
-		mapping: s.mapping,
-		cfg:     s.cfg,
+		runner:   s.runner,
+		mapping:  s.mapping,
+		datagram: s.datagram,
+		forward:  s.forward,
+		cfg:      s.cfg,
 	}, s.logger, s.runner, server.Config{
 		AcceptErrorWait: 300 * time.Millisecond,
 		MaxConnections:  s.cfg.Capacity,
@@ -137,6 +183,28 @@ func (s *proxy) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 	return nil
 }
 
+// Drain stops accepting new connections and waits for existing ones to
+// complete, up to the timeout
+func (s *proxy) Drain(timeout time.Duration) error {
+	if s.serving == nil {
+		return nil
+	}
+
+	s.logger.Infof("Draining")
+
+	drainErr := s.serving.Drain(timeout)
+
+	if drainErr != nil {
+		s.logger.Warningf("Failed to drain server due to error: %s", drainErr)
+
+		return drainErr
+	}
+
+	s.logger.Infof("Server is drained")
+
+	return nil
+}
+
 func (s *proxy) Unspawn() error {
 	s.logger.Infof("Closing")
 
@@ -152,6 +220,32 @@ func (s *proxy) Unspawn() error {
 		s.serving = nil
 	}
 
+	if s.forward != nil {
+		forwardCloseErr := s.forward.Close()
+
+		if forwardCloseErr != nil {
+			s.logger.Errorf("Failed to close the next hop Proxy clients "+
+				"due to error: %s", forwardCloseErr)
+
+			return forwardCloseErr
+		}
+
+		s.forward = nil
+	}
+
+	if s.datagram != nil {
+		datagramCloseErr := s.datagram.Close()
+
+		if datagramCloseErr != nil {
+			s.logger.Errorf(
+				"Failed to close datagram due to error: %s", datagramCloseErr)
+
+			return datagramCloseErr
+		}
+
+		s.datagram = nil
+	}
+
 	if s.runner != nil {
 		runnerCloseErr := s.runner.Close()
 
@@ -28,4 +28,10 @@ const (
 	TCPCommandMapping   = 0x13
 	UDPCommandDelegate  = 0x14
 	UDPCommandTransport = 0x15
+	UDPCommandDatagram  = 0x16
 )
+
+// TCPCommandSpliced is added to the ID of a TCP request to ask the Proxy to
+// splice the Relay. Only the requester who exclusively owns a plain
+// Transceiver connection can ask for it
+const TCPCommandSpliced = 0x20
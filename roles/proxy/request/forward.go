@@ -0,0 +1,791 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/reinit/coward/common/fsm"
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/command"
+	"github.com/reinit/coward/roles/common/relay"
+	"github.com/reinit/coward/roles/common/transceiver"
+	"github.com/reinit/coward/roles/common/transceiver/connection"
+	"github.com/reinit/coward/roles/proxy/common"
+)
+
+// Errors
+var (
+	ErrForwardNextHopTimedout = errors.New(
+		"Next hop Proxy has timed out")
+
+	ErrForwardTooManyPending = errors.New(
+		"Too many data is waiting for the next hop Proxy")
+)
+
+// Consts
+const (
+	forwardDestination = transceiver.Destination("Forward")
+	forwardBufferSize  = 4096
+	forwardMaxPending  = 64 * 1024
+)
+
+// localRequest builds the request context which handles the request
+// locally
+type localRequest func(rw.ReadWriteDepleteDoner, logger.Logger) fsm.Machine
+
+// Forward forwards requests to the next hop Proxy. Requests will be handled
+// locally if the Transceiver is nil
+type Forward struct {
+	Runner      worker.Runner
+	Cancel      <-chan struct{}
+	Transceiver transceiver.Balanced
+	IdleTimeout time.Duration
+	Default     bool
+	Mapping     common.Mapping
+}
+
+// Forwarded wraps a Command so it's requests can be forwarded to the next
+// hop Proxy
+type Forwarded struct {
+	Command command.Command
+	Forward Forward
+}
+
+type forwarded struct {
+	logger    logger.Logger
+	command   command.Command
+	forward   Forward
+	rw        rw.ReadWriteDepleteDoner
+	request   fsm.FSM
+	completer command.Completer
+}
+
+// forwardState tracks the progress of a forwarded request by watching the
+// segments that been passed through
+type forwardState struct {
+	lock      sync.Mutex
+	command   command.ID
+	active    time.Time
+	responded bool
+	closeUp   bool
+	closeDown bool
+	goodbye   bool
+	ended     bool
+	aborted   bool
+}
+
+// forwarding passes segments of a request between the client and the next
+// hop Proxy. Segments received before the next hop request is opened will
+// be kept in pending, and be sent right after the request is opened.
+//
+// The wake will be closed once the request is ended or aborted by the
+// client, so the next hop request can stop waiting for the next hop Proxy
+type forwarding struct {
+	logger      logger.Logger
+	forward     Forward
+	rw          rw.ReadWriteDepleteDoner
+	head        []byte
+	buf         []byte
+	state       *forwardState
+	pending     [][]byte
+	pendingSize int
+	nextHop     rw.ReadWriteDepleteDoner
+	wake        chan struct{}
+	woken       bool
+}
+
+// forwardRequest is the request sent to the next hop Proxy
+type forwardRequest struct {
+	forwarding *forwarding
+	rw         rw.ReadWriteDepleteDoner
+	buf        []byte
+}
+
+// peeked replays the data which has been read for selecting before reading
+// the rest of the segment
+type peeked struct {
+	rw.ReadWriteDepleteDoner
+
+	head []byte
+}
+
+// forwardStopped returns whether or not the request will stop right after
+// the given respond
+func forwardStopped(id command.ID, respond byte) bool {
+	if respond == byte(relay.SignalError) {
+		return true
+	}
+
+	switch id {
+	case UDPCommandDelegate:
+		fallthrough
+	case UDPCommandTransport:
+		switch respond {
+		case UDPRespondGeneralError:
+			return true
+
+		case UDPRespondInvalidRequest:
+			return true
+
+		case UDPRespondMappingNotFound:
+			return true
+		}
+
+		return false
+	}
+
+	switch respond {
+	case TCPRespondGeneralError:
+		return true
+
+	case TCPRespondMappingNotFound:
+		return true
+
+	case TCPRespondBadRequest:
+		return true
+	}
+
+	return false
+}
+
+// readSegment reads the rest of current segment into the buf after the
+// first n bytes, the buf will be enlarged when necessary. If begin is true,
+// it will wait for a new segment
+func readSegment(
+	r rw.ReadWriteDepleteDoner,
+	buf []byte,
+	n int,
+	begin bool,
+) ([]byte, int, error) {
+	for begin || !r.Depleted() {
+		begin = false
+
+		if n >= len(buf) {
+			enlarged := make([]byte, len(buf)*2)
+
+			copy(enlarged, buf)
+
+			buf = enlarged
+		}
+
+		rLen, rErr := r.Read(buf[n:])
+
+		n += rLen
+
+		if rErr != nil {
+			return buf, n, rErr
+		}
+	}
+
+	return buf, n, nil
+}
+
+// request selects whether the request should be forwarded or be handled
+// by the local request context
+func (f Forward) request(
+	id command.ID,
+	r rw.ReadWriteDepleteDoner,
+	log logger.Logger,
+	local localRequest,
+) (fsm.Machine, error) {
+	if f.Transceiver == nil {
+		return local(r, log), nil
+	}
+
+	forward := f.Default
+	head := []byte{byte(id)}
+
+	switch id {
+	case TCPCommandMapping:
+		fallthrough
+	case UDPCommandTransport:
+		mapID := [1]byte{}
+
+		_, rErr := io.ReadFull(r, mapID[:])
+
+		if rErr != nil {
+			r.Done()
+
+			return nil, rErr
+		}
+
+		mapped, mappedErr := f.Mapping.Get(common.MapID(mapID[0]))
+
+		forward = mappedErr == nil && mapped.Forward
+
+		if !forward {
+			return local(&peeked{
+				ReadWriteDepleteDoner: r,
+				head:                  mapID[:],
+			}, log), nil
+		}
+
+		head = append(head, mapID[0])
+	}
+
+	if !forward {
+		return local(r, log), nil
+	}
+
+	return &forwarding{
+		logger:  log.Context("Forward"),
+		forward: f,
+		rw:      r,
+		head:    head,
+		buf:     make([]byte, forwardBufferSize),
+		state: &forwardState{
+			lock:      sync.Mutex{},
+			command:   id,
+			active:    time.Now(),
+			responded: false,
+			closeUp:   false,
+			closeDown: false,
+			goodbye:   false,
+			ended:     false,
+			aborted:   false,
+		},
+		pending:     nil,
+		pendingSize: 0,
+		nextHop:     nil,
+		wake:        make(chan struct{}),
+		woken:       false,
+	}, nil
+}
+
+// ID returns current Request ID
+func (c Forwarded) ID() command.ID {
+	return c.Command.ID()
+}
+
+// New creates a new request context
+func (c Forwarded) New(
+	rw rw.ReadWriteDepleteDoner, log logger.Logger) fsm.Machine {
+	return &forwarded{
+		logger:    log,
+		command:   c.Command,
+		forward:   c.Forward,
+		rw:        rw,
+		request:   nil,
+		completer: nil,
+	}
+}
+
+func (c *forwarded) Bootup() (fsm.State, error) {
+	// Forwarded request is carried by the next hop connection, so it can't
+	// be spliced. Forward it as an ordinary one, the requester will fall
+	// back after seeing the ordinary respond
+	m, mErr := c.forward.request(
+		tcpUnspliced(c.command.ID()), c.rw, c.logger, c.command.New)
+
+	if mErr != nil {
+		return nil, mErr
+	}
+
+	c.request = fsm.New(m)
+
+	bootupErr := c.request.Bootup()
+
+	if bootupErr != nil {
+		return nil, bootupErr
+	}
+
+	c.completer, _ = m.(command.Completer)
+
+	return c.tick, nil
+}
+
+func (c *forwarded) tick(f fsm.FSM) error {
+	tErr := c.request.Tick()
+
+	if tErr != nil {
+		return tErr
+	}
+
+	if !c.request.Running() {
+		return f.Shutdown()
+	}
+
+	return nil
+}
+
+func (c *forwarded) Shutdown() error {
+	if c.request == nil || !c.request.Running() {
+		return nil
+	}
+
+	return c.request.Shutdown()
+}
+
+func (c *forwarded) Completed() bool {
+	return c.completer != nil && c.completer.Completed()
+}
+
+// up watches a segment sent from the client to the next hop Proxy
+func (s *forwardState) up(seg []byte) {
+	s.active = time.Now()
+
+	if len(seg) <= 0 {
+		return
+	}
+
+	switch relay.Signal(seg[0]) {
+	case relay.SignalClosed:
+		fallthrough
+	case relay.SignalError:
+		s.ended = true
+
+	case relay.SignalClose:
+		s.closeUp = true
+		s.ended = s.ended || s.closeDown
+	}
+}
+
+// down watches a segment sent from the next hop Proxy to the client
+func (s *forwardState) down(seg []byte) {
+	s.active = time.Now()
+
+	if len(seg) <= 0 {
+		return
+	}
+
+	if !s.responded {
+		s.responded = true
+		s.ended = s.ended || forwardStopped(s.command, seg[0])
+
+		return
+	}
+
+	switch relay.Signal(seg[0]) {
+	case relay.SignalClosed:
+		fallthrough
+	case relay.SignalError:
+		s.ended = true
+
+	case relay.SignalClose:
+		s.closeDown = true
+		s.ended = s.ended || s.closeUp
+	}
+}
+
+func (f *forwarding) Bootup() (fsm.State, error) {
+	buf := make([]byte, forwardBufferSize)
+
+	copy(buf, f.head)
+
+	buf, n, rErr := readSegment(f.rw, buf, len(f.head), false)
+
+	f.rw.Done()
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	f.head = buf[:n]
+
+	_, runErr := f.forward.Runner.Run(f.logger, f.requesting, f.forward.Cancel)
+
+	if runErr != nil {
+		rw.WriteFull(f.rw, []byte{byte(relay.SignalError)})
+
+		return nil, runErr
+	}
+
+	f.logger.Debugf("Forwarding request to the next hop Proxy")
+
+	return f.tick, nil
+}
+
+// requesting sends the request to the next hop Proxy
+func (f *forwarding) requesting(l logger.Logger) error {
+	reqErr := f.forward.Transceiver.Request(
+		l, forwardDestination, f.build, f.forward.Cancel)
+
+	if reqErr == nil {
+		return nil
+	}
+
+	f.fail()
+
+	return reqErr
+}
+
+// build builds the request which will be sent to the next hop Proxy
+func (f *forwarding) build(
+	id transceiver.ClientID,
+	connID transceiver.ConnectionID,
+	conn rw.ReadWriteDepleteDoner,
+	connCtl transceiver.ConnectionControl,
+	log logger.Logger,
+) fsm.Machine {
+	return &forwardRequest{
+		forwarding: f,
+		rw:         conn,
+		buf:        make([]byte, forwardBufferSize),
+	}
+}
+
+// fail ends the request with an error if it's not already ended
+func (f *forwarding) fail() {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+
+	if f.state.ended {
+		return
+	}
+
+	f.state.ended = true
+
+	if f.state.aborted {
+		return
+	}
+
+	rw.WriteFull(f.rw, []byte{byte(relay.SignalError)})
+}
+
+// goodbye asks the next hop Proxy to close the request after the client
+// is gone. Must be called with the state locked
+func (f *forwarding) goodbye(nextHop rw.ReadWriteDepleteDoner) error {
+	if !f.state.responded || f.state.goodbye || f.state.closeUp {
+		return nil
+	}
+
+	f.state.goodbye = true
+
+	_, wErr := rw.WriteFull(nextHop, []byte{byte(relay.SignalCompleted)})
+
+	return wErr
+}
+
+// awake wakes the next hop request up. Must be called with the state
+// locked
+func (f *forwarding) awake() {
+	if f.woken {
+		return
+	}
+
+	f.woken = true
+
+	close(f.wake)
+}
+
+// down delivers a segment received from the next hop Proxy to the client
+func (f *forwarding) down(
+	nextHop rw.ReadWriteDepleteDoner, seg []byte) (bool, error) {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+
+	f.state.down(seg)
+
+	if !f.state.aborted {
+		_, wErr := rw.WriteFull(f.rw, seg)
+
+		if wErr == nil {
+			return f.state.ended, nil
+		}
+
+		f.state.aborted = true
+	}
+
+	if f.state.ended {
+		return true, nil
+	}
+
+	var wErr error
+
+	switch relay.Signal(seg[0]) {
+	case relay.SignalCompleted:
+		if !f.state.closeUp {
+			f.state.closeUp = true
+
+			_, wErr = rw.WriteFull(nextHop, []byte{byte(relay.SignalClose)})
+		}
+
+	case relay.SignalClose:
+		f.state.ended = true
+
+		_, wErr = rw.WriteFull(nextHop, []byte{byte(relay.SignalClosed)})
+
+	default:
+		wErr = f.goodbye(nextHop)
+	}
+
+	return f.state.ended, wErr
+}
+
+// idle checks the request when it's been woken by the client, or when the
+// next hop Proxy has nothing to send for a while
+func (f *forwarding) idle(nextHop rw.ReadWriteDepleteDoner) (bool, error) {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+
+	if f.state.ended {
+		return true, nil
+	}
+
+	if f.state.aborted {
+		gErr := f.goodbye(nextHop)
+
+		if gErr != nil {
+			return false, gErr
+		}
+	}
+
+	if f.forward.IdleTimeout <= 0 ||
+		time.Since(f.state.active) < f.forward.IdleTimeout {
+		return false, nil
+	}
+
+	f.state.ended = true
+
+	rw.WriteFull(nextHop, []byte{byte(relay.SignalError)})
+
+	if !f.state.aborted {
+		rw.WriteFull(f.rw, []byte{byte(relay.SignalError)})
+	}
+
+	return true, ErrForwardNextHopTimedout
+}
+
+// open sends the request head and the pending segments to the next hop
+// Proxy, and starts to deliver the following segments directly
+func (f *forwarding) open(nextHop rw.ReadWriteDepleteDoner) error {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+
+	_, wErr := rw.WriteFull(nextHop, f.head)
+
+	for pIdx := range f.pending {
+		if wErr != nil {
+			break
+		}
+
+		_, wErr = rw.WriteFull(nextHop, f.pending[pIdx])
+	}
+
+	f.pending = nil
+	f.pendingSize = 0
+
+	if wErr != nil {
+		return wErr
+	}
+
+	f.nextHop = nextHop
+
+	return nil
+}
+
+// up delivers a segment received from the client to the next hop Proxy,
+// or keeps it in pending when the next hop request is not yet opened
+func (f *forwarding) up(seg []byte) bool {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+
+	if f.state.ended {
+		return true
+	}
+
+	var wErr error
+
+	switch {
+	case f.nextHop != nil:
+		f.state.up(seg)
+
+		_, wErr = rw.WriteFull(f.nextHop, seg)
+
+	case f.pendingSize+len(seg) <= forwardMaxPending:
+		f.state.up(seg)
+
+		pending := make([]byte, len(seg))
+
+		copy(pending, seg)
+
+		f.pending = append(f.pending, pending)
+		f.pendingSize += len(pending)
+
+	default:
+		wErr = ErrForwardTooManyPending
+	}
+
+	if wErr == nil {
+		if f.state.ended {
+			f.awake()
+		}
+
+		return f.state.ended
+	}
+
+	f.logger.Debugf("Failed to deliver data to the next hop Proxy: %s", wErr)
+
+	f.state.ended = true
+
+	f.awake()
+
+	rw.WriteFull(f.rw, []byte{byte(relay.SignalError)})
+
+	return true
+}
+
+func (f *forwarding) tick(m fsm.FSM) error {
+	buf, n, rErr := readSegment(f.rw, f.buf, 0, true)
+
+	f.buf = buf
+
+	f.rw.Done()
+
+	if rErr != nil {
+		return rErr
+	}
+
+	if !f.up(buf[:n]) {
+		return nil
+	}
+
+	return m.Shutdown()
+}
+
+func (f *forwarding) Shutdown() error {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+
+	if !f.state.ended {
+		f.state.aborted = true
+	}
+
+	f.awake()
+
+	return nil
+}
+
+func (f *forwarding) Completed() bool {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+
+	return f.state.ended
+}
+
+func (r *forwardRequest) Bootup() (fsm.State, error) {
+	openErr := r.forwarding.open(r.rw)
+
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	// Stop waiting for the next hop Proxy once the client has ended or
+	// aborted the request, as it may never send anything after that
+	virtual, isVirtual := r.rw.(connection.Virtual)
+
+	if isVirtual {
+		virtual.Cancel(r.forwarding.wake)
+	}
+
+	return r.tick, nil
+}
+
+func (r *forwardRequest) tick(m fsm.FSM) error {
+	virtual, isVirtual := r.rw.(connection.Virtual)
+
+	if isVirtual && r.forwarding.forward.IdleTimeout > 0 {
+		virtual.Timeout(r.forwarding.forward.IdleTimeout)
+	}
+
+	buf, n, rErr := readSegment(r.rw, r.buf, 0, true)
+
+	r.buf = buf
+
+	r.rw.Done()
+
+	var ended bool
+
+	switch rErr {
+	case nil:
+		if n <= 0 {
+			return nil
+		}
+
+		ended, rErr = r.forwarding.down(r.rw, buf[:n])
+
+	case connection.ErrChannelVirtualConnectionCanceled:
+		// Only needs to be woken once, the rest will be driven by the
+		// next hop Proxy
+		virtual.Cancel(nil)
+
+		fallthrough
+
+	case connection.ErrChannelVirtualConnectionTimedout:
+		ended, rErr = r.forwarding.idle(r.rw)
+	}
+
+	if rErr != nil {
+		r.forwarding.fail()
+
+		return rErr
+	}
+
+	if !ended {
+		return nil
+	}
+
+	return m.Shutdown()
+}
+
+func (r *forwardRequest) Shutdown() error {
+	virtual, isVirtual := r.rw.(connection.Virtual)
+
+	if isVirtual {
+		virtual.Cancel(nil)
+	}
+
+	return nil
+}
+
+func (p *peeked) Read(b []byte) (int, error) {
+	if len(p.head) <= 0 {
+		return p.ReadWriteDepleteDoner.Read(b)
+	}
+
+	cLen := copy(b, p.head)
+
+	p.head = p.head[cLen:]
+
+	return cLen, nil
+}
+
+func (p *peeked) Depleted() bool {
+	return len(p.head) <= 0 && p.ReadWriteDepleteDoner.Depleted()
+}
+
+func (p *peeked) Deplete() error {
+	p.head = nil
+
+	return p.ReadWriteDepleteDoner.Deplete()
+}
+
+func (p *peeked) Done() error {
+	p.head = nil
+
+	return p.ReadWriteDepleteDoner.Done()
+}
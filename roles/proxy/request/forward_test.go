@@ -0,0 +1,787 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/reinit/coward/common/fsm"
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/common/ticker"
+	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/codec/plain"
+	"github.com/reinit/coward/roles/common/command"
+	"github.com/reinit/coward/roles/common/network"
+	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
+	tcpdial "github.com/reinit/coward/roles/common/network/dialer/tcp"
+	"github.com/reinit/coward/roles/common/relay"
+	"github.com/reinit/coward/roles/common/transceiver"
+	"github.com/reinit/coward/roles/common/transceiver/client"
+	"github.com/reinit/coward/roles/common/transceiver/clients"
+	"github.com/reinit/coward/roles/common/transceiver/server"
+	"github.com/reinit/coward/roles/proxy/common"
+)
+
+var (
+	errChainUnexpectedRespond = errors.New("Unexpected respond")
+)
+
+// chainRelay sends the request head and hands the user end to the relay
+type chainRelay struct {
+	head []byte
+	ok   byte
+	user io.ReadWriteCloser
+}
+
+// chainRequest is the request sent by the client of the chain
+type chainRequest struct {
+	relay  relay.Relay
+	cancel <-chan struct{}
+}
+
+// chainNextHop tells when the requests to the next hop Proxy are completed
+type chainNextHop struct {
+	transceiver.Balanced
+
+	completed chan struct{}
+}
+
+// chain is the client -> Proxy A -> Proxy B -> destination chain
+type chain struct {
+	tcpEcho  net.Listener
+	tcp6Echo net.Listener
+	udpEcho  *net.UDPConn
+	client   transceiver.Balanced
+	nextHop  chainNextHop
+	runner   worker.Runner
+	closers  []io.Closer
+}
+
+func TestForwardStopped(t *testing.T) {
+	if !forwardStopped(TCPCommandHost, TCPRespondBadRequest) {
+		t.Error("TCP request must be stopped by the Bad Request respond")
+
+		return
+	}
+
+	if forwardStopped(TCPCommandHost, TCPRespondUnreachable) {
+		t.Error("TCP request must not be stopped by the Unreachable respond")
+
+		return
+	}
+
+	if !forwardStopped(UDPCommandTransport, UDPRespondMappingNotFound) {
+		t.Error("UDP request must be stopped by the Mapping Not Found " +
+			"respond")
+
+		return
+	}
+
+	if forwardStopped(UDPCommandDelegate, UDPRespondFailedToListen) {
+		t.Error("UDP request must not be stopped by the Failed To Listen " +
+			"respond")
+
+		return
+	}
+
+	if !forwardStopped(UDPCommandDelegate, byte(relay.SignalError)) {
+		t.Error("Request must be stopped by the Error signal")
+
+		return
+	}
+}
+
+func TestForwardStateEnded(t *testing.T) {
+	s := forwardState{command: TCPCommandIPv4}
+
+	s.down([]byte{TCPRespondOK})
+	s.up([]byte{byte(relay.SignalData), 'A'})
+	s.down([]byte{byte(relay.SignalCompleted)})
+
+	if s.ended {
+		t.Error("Request must not be ended before it's been closed")
+
+		return
+	}
+
+	s.up([]byte{byte(relay.SignalClose)})
+
+	if s.ended {
+		t.Error("Request must not be ended before the Close signal has " +
+			"been confirmed")
+
+		return
+	}
+
+	s.down([]byte{byte(relay.SignalClosed)})
+
+	if !s.ended {
+		t.Error("Request must be ended after the Closed signal")
+
+		return
+	}
+
+	s = forwardState{command: TCPCommandIPv4}
+
+	s.down([]byte{TCPRespondOK})
+	s.up([]byte{byte(relay.SignalClose)})
+	s.down([]byte{byte(relay.SignalClose)})
+
+	if !s.ended {
+		t.Error("Request must be ended after both side has sent the Close " +
+			"signal")
+
+		return
+	}
+
+	s = forwardState{command: UDPCommandTransport}
+
+	s.down([]byte{UDPRespondMappingNotFound})
+
+	if !s.ended {
+		t.Error("Request must be ended after a stopping respond")
+
+		return
+	}
+}
+
+func (c chainNextHop) Request(
+	log logger.Logger,
+	destName transceiver.Destination,
+	req transceiver.BalancedRequestBuilder,
+	cancel <-chan struct{},
+) error {
+	reqErr := c.Balanced.Request(log, destName, req, cancel)
+
+	c.completed <- struct{}{}
+
+	return reqErr
+}
+
+func (c *chainRelay) Initialize(l logger.Logger, server relay.Server) error {
+	initErr := c.initialize(server)
+
+	if initErr != nil {
+		c.user.Close()
+	}
+
+	return initErr
+}
+
+func (c *chainRelay) initialize(server relay.Server) error {
+	_, wErr := rw.WriteFull(server, c.head)
+
+	if wErr != nil {
+		return wErr
+	}
+
+	respond := [1]byte{}
+
+	_, rErr := io.ReadFull(server, respond[:])
+
+	server.Done()
+
+	if rErr != nil {
+		return rErr
+	}
+
+	if respond[0] != c.ok {
+		return errChainUnexpectedRespond
+	}
+
+	return nil
+}
+
+func (c *chainRelay) Abort(l logger.Logger, aborter relay.Aborter) error {
+	return aborter.Goodbye()
+}
+
+func (c *chainRelay) Client(
+	l logger.Logger, server relay.Server) (io.ReadWriteCloser, error) {
+	return c.user, nil
+}
+
+func (c chainRequest) Bootup() (fsm.State, error) {
+	bootErr := c.relay.Bootup(c.cancel)
+
+	if bootErr != nil {
+		return nil, bootErr
+	}
+
+	return c.tick, nil
+}
+
+func (c chainRequest) tick(f fsm.FSM) error {
+	tErr := c.relay.Tick()
+
+	if tErr != nil {
+		return tErr
+	}
+
+	if !c.relay.Running() {
+		return f.Shutdown()
+	}
+
+	return nil
+}
+
+func (c chainRequest) Shutdown() error {
+	c.relay.Close()
+
+	return nil
+}
+
+// close closes everything in the chain in the reversed order of creation
+func (c *chain) close() {
+	for cIdx := len(c.closers) - 1; cIdx >= 0; cIdx-- {
+		c.closers[cIdx].Close()
+	}
+}
+
+// serveChainProxy serves a Proxy which handles the requests with the
+// commands
+func serveChainProxy(
+	t *testing.T,
+	c *chain,
+	tk ticker.Requester,
+	commands func(cancel <-chan struct{}) command.Commands,
+) (net.Listener, bool) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Error("Failed to listen Proxy due to error:", listenErr)
+
+		return nil, false
+	}
+
+	c.closers = append(c.closers, listener)
+
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+
+			if acceptErr != nil {
+				return
+			}
+
+			go func() {
+				wrapped := tcpconn.Wrap(conn)
+
+				defer wrapped.Close()
+
+				server.New(plain.New, tk, server.Config{
+					InitialTimeout:       3 * time.Second,
+					IdleTimeout:          3 * time.Second,
+					ConnectionChannels:   1,
+					ChannelDispatchDelay: 0,
+				}).Handle(
+					logger.NewDitch(), wrapped, commands(wrapped.Closed()))
+			}()
+		}
+	}()
+
+	return listener, true
+}
+
+// chainClient serves a Transceiver client which sends requests to the
+// Proxy on the listener
+func chainClient(
+	t *testing.T,
+	c *chain,
+	tk ticker.Requester,
+	listener net.Listener,
+) (transceiver.Balanced, bool) {
+	balanced, serveErr := clients.New([]transceiver.Client{
+		client.New(0, logger.NewDitch(), tcpdial.New(
+			"127.0.0.1", uint16(listener.Addr().(*net.TCPAddr).Port),
+			3*time.Second, tcpconn.Wrap), plain.New, tk, client.Config{
+			MaxConcurrent:        4,
+			RequestRetries:       3,
+			InitialTimeout:       3 * time.Second,
+			IdleTimeout:          3 * time.Second,
+			ConnectionPersistent: false,
+			ConnectionChannels:   1,
+		}),
+	}, 16).Serve()
+
+	if serveErr != nil {
+		t.Error("Failed to serve Transceiver client due to error:", serveErr)
+
+		return nil, false
+	}
+
+	c.closers = append(c.closers, balanced)
+
+	return balanced, true
+}
+
+// chainTCPEcho starts a TCP server which sends back everything it received
+func chainTCPEcho(
+	t *testing.T, c *chain, address string) (net.Listener, bool) {
+	listener, listenErr := net.Listen("tcp", address)
+
+	if listenErr != nil {
+		t.Error("Failed to listen echo server due to error:", listenErr)
+
+		return nil, false
+	}
+
+	c.closers = append(c.closers, listener)
+
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+
+			if acceptErr != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener, true
+}
+
+// newChain builds the chain. Proxy A denies local access and maps to
+// nowhere, so requests can only reach the destinations after they've been
+// forwarded to Proxy B
+func newChain(t *testing.T) (*chain, bool) {
+	c := &chain{closers: make([]io.Closer, 0, 16)}
+	log := logger.NewDitch()
+
+	tk, tkErr := ticker.New(300*time.Millisecond, 1024).Serve()
+
+	if tkErr != nil {
+		t.Error("Failed to start Ticker due to error:", tkErr)
+
+		return nil, false
+	}
+
+	c.closers = append(c.closers, tk)
+
+	runner, runnerErr := worker.New(log, tk, worker.Config{
+		MaxWorkers:        32,
+		MinWorkers:        32,
+		MaxWorkerIdle:     5 * time.Minute,
+		JobReceiveTimeout: 300 * time.Millisecond,
+	}).Serve()
+
+	if runnerErr != nil {
+		c.close()
+
+		t.Error("Failed to start Runner due to error:", runnerErr)
+
+		return nil, false
+	}
+
+	c.runner = runner
+	c.closers = append(c.closers, runner)
+
+	var echoOK bool
+
+	c.tcpEcho, echoOK = chainTCPEcho(t, c, "127.0.0.1:0")
+
+	if !echoOK {
+		c.close()
+
+		return nil, false
+	}
+
+	c.tcp6Echo, echoOK = chainTCPEcho(t, c, "[::1]:0")
+
+	if !echoOK {
+		c.close()
+
+		return nil, false
+	}
+
+	udpEcho, udpListenErr := net.ListenUDP("udp", &net.UDPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: 0,
+		Zone: "",
+	})
+
+	if udpListenErr != nil {
+		c.close()
+
+		t.Error("Failed to listen UDP echo server due to error:",
+			udpListenErr)
+
+		return nil, false
+	}
+
+	c.udpEcho = udpEcho
+	c.closers = append(c.closers, udpEcho)
+
+	go func() {
+		buf := make([]byte, 4096)
+
+		for {
+			rLen, rAddr, rErr := udpEcho.ReadFromUDP(buf)
+
+			if rErr != nil {
+				return
+			}
+
+			udpEcho.WriteToUDP(buf[:rLen], rAddr)
+		}
+	}()
+
+	resolver := &dummyResolver{
+		resolves: map[string][]net.IP{
+			"echo.test": {net.ParseIP("127.0.0.1")},
+		},
+	}
+
+	mapping := func(forward bool, tcpPort int, udpPort int) common.Mapping {
+		m := common.Mapping{}
+
+		m[1] = &common.Mapped{
+			Protocol: network.TCP,
+			Host:     "127.0.0.1",
+			Port:     uint16(tcpPort),
+			Egress:   nil,
+			Forward:  forward,
+		}
+
+		m[2] = &common.Mapped{
+			Protocol: network.UDP,
+			Host:     "echo.test",
+			Port:     uint16(udpPort),
+			Egress:   nil,
+			Forward:  forward,
+		}
+
+		return m
+	}
+
+	commands := func(
+		m common.Mapping,
+		noLocalAccess bool,
+		forward *Forward,
+	) func(cancel <-chan struct{}) command.Commands {
+		return func(cancel <-chan struct{}) command.Commands {
+			buf := make([]byte, 4096)
+
+			tcp := TCP{
+				Runner:            runner,
+				Buffer:            buf,
+				DialTimeout:       3 * time.Second,
+				ConnectionTimeout: 3 * time.Second,
+				Cancel:            cancel,
+				NoLocalAccess:     noLocalAccess,
+				Resolver:          resolver,
+			}
+
+			cmds := []command.Command{
+				TCPIPv4{TCP: tcp},
+				TCPIPv6{TCP: tcp},
+				TCPHost{TCP: tcp},
+				TCPMapping{TCP: tcp, Mapping: m},
+				UDPMapping{
+					Runner:      runner,
+					Buffer:      buf,
+					Cancel:      cancel,
+					LocalAddr:   &net.TCPAddr{IP: net.ParseIP("127.0.0.1")},
+					DialTimeout: 3 * time.Second,
+					Mapping:     m,
+					Resolver:    resolver,
+				},
+			}
+
+			if forward == nil {
+				return command.New(cmds...)
+			}
+
+			fwd := *forward
+			fwd.Cancel = cancel
+
+			for cIdx := range cmds {
+				cmds[cIdx] = Forwarded{Command: cmds[cIdx], Forward: fwd}
+			}
+
+			return command.New(cmds...)
+		}
+	}
+
+	proxyB, proxyBOK := serveChainProxy(t, c, tk, commands(mapping(false,
+		c.tcpEcho.Addr().(*net.TCPAddr).Port,
+		c.udpEcho.LocalAddr().(*net.UDPAddr).Port), false, nil))
+
+	if !proxyBOK {
+		c.close()
+
+		return nil, false
+	}
+
+	nextHop, nextHopOK := chainClient(t, c, tk, proxyB)
+
+	if !nextHopOK {
+		c.close()
+
+		return nil, false
+	}
+
+	c.nextHop = chainNextHop{
+		Balanced:  nextHop,
+		completed: make(chan struct{}, 16),
+	}
+
+	proxyA, proxyAOK := serveChainProxy(t, c, tk, commands(
+		mapping(true, 1, 1), true, &Forward{
+			Runner:      runner,
+			Cancel:      nil,
+			Transceiver: c.nextHop,
+			IdleTimeout: 3 * time.Second,
+			Default:     true,
+			Mapping:     mapping(true, 1, 1),
+		}))
+
+	if !proxyAOK {
+		c.close()
+
+		return nil, false
+	}
+
+	c.client, echoOK = chainClient(t, c, tk, proxyA)
+
+	if !echoOK {
+		c.close()
+
+		return nil, false
+	}
+
+	return c, true
+}
+
+// request sends the request head through the chain, and returns the user
+// end of the request
+func (c *chain) request(
+	t *testing.T, head []byte, ok byte) (net.Conn, chan error) {
+	user, relayed := net.Pipe()
+	requested := make(chan error, 1)
+
+	go func() {
+		requested <- c.client.Request(
+			logger.NewDitch(), "Chain", func(
+				cID transceiver.ClientID,
+				id transceiver.ConnectionID,
+				conn rw.ReadWriteDepleteDoner,
+				connCtl transceiver.ConnectionControl,
+				log logger.Logger,
+			) fsm.Machine {
+				return chainRequest{
+					relay: relay.New(log, c.runner, conn, make([]byte, 4096),
+						&chainRelay{head: head, ok: ok, user: relayed},
+						make([]byte, 4096)),
+					cancel: nil,
+				}
+			}, nil)
+	}()
+
+	return user, requested
+}
+
+// testForwardChain sends data through the chain and expects it to be echoed
+func testForwardChain(
+	t *testing.T, head func(c *chain) []byte, ok byte, udp bool) {
+	c, chainOK := newChain(t)
+
+	if !chainOK {
+		return
+	}
+
+	defer c.close()
+
+	user, requested := c.request(t, head(c), ok)
+
+	user.SetDeadline(time.Now().Add(10 * time.Second))
+
+	sent := bytes.Repeat([]byte("Hello World!"), 1024*8)
+
+	if udp {
+		sent = []byte("Hello World!")
+	}
+
+	written := make(chan error, 1)
+
+	go func() {
+		_, wErr := user.Write(sent)
+
+		written <- wErr
+	}()
+
+	received := make([]byte, len(sent))
+
+	_, rErr := io.ReadFull(user, received)
+
+	if rErr != nil {
+		t.Error("Failed to read data due to error:", rErr)
+
+		return
+	}
+
+	if wErr := <-written; wErr != nil {
+		t.Error("Failed to write data due to error:", wErr)
+
+		return
+	}
+
+	if !bytes.Equal(received, sent) {
+		t.Error("Expecting the received data to be the same as sent")
+
+		return
+	}
+
+	closed := time.Now()
+
+	user.Close()
+
+	select {
+	case reqErr := <-requested:
+		if reqErr != nil {
+			t.Error("Request has failed due to error:", reqErr)
+
+			return
+		}
+
+	case <-time.After(10 * time.Second):
+		t.Error("Request has not been completed")
+
+		return
+	}
+
+	// The request to the next hop Proxy must be completed in response to
+	// the closing, rather than waiting for the 3 seconds idle timeout
+	select {
+	case <-c.nextHop.completed:
+		if time.Since(closed) >= 1*time.Second {
+			t.Error("Next hop request has not been completed in time")
+
+			return
+		}
+
+	case <-time.After(10 * time.Second):
+		t.Error("Next hop request has not been completed")
+
+		return
+	}
+}
+
+// chainPort returns the port bytes of the listener
+func chainPort(l net.Listener) []byte {
+	port := l.Addr().(*net.TCPAddr).Port
+
+	return []byte{byte(port >> 8), byte(port)}
+}
+
+func TestForwardChainTCPIPv4(t *testing.T) {
+	testForwardChain(t, func(c *chain) []byte {
+		return append(append([]byte{TCPCommandIPv4, 127, 0, 0, 1},
+			chainPort(c.tcpEcho)...), 3)
+	}, TCPRespondOK, false)
+}
+
+func TestForwardChainTCPIPv6(t *testing.T) {
+	testForwardChain(t, func(c *chain) []byte {
+		return append(append(append([]byte{TCPCommandIPv6},
+			net.IPv6loopback...), chainPort(c.tcp6Echo)...), 3)
+	}, TCPRespondOK, false)
+}
+
+func TestForwardChainTCPHost(t *testing.T) {
+	testForwardChain(t, func(c *chain) []byte {
+		return append(append([]byte{TCPCommandHost, 9, 'e', 'c', 'h', 'o',
+			'.', 't', 'e', 's', 't'}, chainPort(c.tcpEcho)...), 3)
+	}, TCPRespondOK, false)
+}
+
+func TestForwardChainTCPMapping(t *testing.T) {
+	testForwardChain(t, func(c *chain) []byte {
+		return []byte{TCPCommandMapping, 1}
+	}, TCPRespondOK, false)
+}
+
+func TestForwardChainUDPMapping(t *testing.T) {
+	testForwardChain(t, func(c *chain) []byte {
+		return []byte{UDPCommandTransport, 2}
+	}, UDPRespondOK, true)
+}
+
+func TestForwardChainAborted(t *testing.T) {
+	c, chainOK := newChain(t)
+
+	if !chainOK {
+		return
+	}
+
+	defer c.close()
+
+	user, _ := c.request(t, append(append([]byte{TCPCommandIPv4, 127, 0, 0, 1},
+		chainPort(c.tcpEcho)...), 3), TCPRespondOK)
+
+	user.SetDeadline(time.Now().Add(10 * time.Second))
+
+	sent := []byte("Hello World!")
+
+	go user.Write(sent)
+
+	received := make([]byte, len(sent))
+
+	_, rErr := io.ReadFull(user, received)
+
+	if rErr != nil {
+		t.Error("Failed to read data due to error:", rErr)
+
+		return
+	}
+
+	// Drop the connection to Proxy A, so the forwarded request is aborted
+	// without anything been sent to the next hop Proxy
+	aborted := time.Now()
+
+	c.client.Close()
+
+	select {
+	case <-c.nextHop.completed:
+		if time.Since(aborted) >= 1*time.Second {
+			t.Error("Next hop request has not been completed in time")
+
+			return
+		}
+
+	case <-time.After(10 * time.Second):
+		t.Error("Next hop request has not been completed")
+
+		return
+	}
+}
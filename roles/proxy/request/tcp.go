@@ -29,6 +29,12 @@ import (
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/common/worker"
 	"github.com/reinit/coward/roles/common/command"
+	"github.com/reinit/coward/roles/common/network"
+	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
+	tcpdial "github.com/reinit/coward/roles/common/network/dialer/tcp"
+	"github.com/reinit/coward/roles/common/network/dialer/upstream"
+	"github.com/reinit/coward/roles/common/network/egress"
+	"github.com/reinit/coward/roles/common/network/resolve"
 	"github.com/reinit/coward/roles/common/relay"
 )
 
@@ -49,6 +55,7 @@ const (
 	TCPRespondAccessDeined    = 0x04
 	TCPRespondMappingNotFound = 0x05
 	TCPRespondBadRequest      = 0x06
+	TCPRespondSpliced         = 0x07
 )
 
 // TCP request
@@ -60,6 +67,10 @@ type TCP struct {
 	ConnectionTimeout time.Duration
 	Cancel            <-chan struct{}
 	NoLocalAccess     bool
+	Resolver          resolve.Resolver
+	Egress            egress.Egress
+	Upstream          upstream.Upstream
+	Splice            bool
 }
 
 type tcp struct {
@@ -70,6 +81,10 @@ type tcp struct {
 	runner            worker.Runner
 	cancel            <-chan struct{}
 	noLocalAccess     bool
+	resolver          resolve.Resolver
+	egress            egress.Egress
+	upstream          upstream.Upstream
+	splice            bool
 	rw                rw.ReadWriteDepleteDoner
 	relay             relay.Relay
 }
@@ -79,6 +94,27 @@ func (c TCP) ID() command.ID {
 	panic("`ID` must be overrided")
 }
 
+// id returns the ID of the request, or the ID of the spliced one when
+// Splice is enabled
+func (c TCP) id(base command.ID) command.ID {
+	if !c.Splice {
+		return base
+	}
+
+	return base + TCPCommandSpliced
+}
+
+// tcpUnspliced returns the ID of the TCP request which the given request
+// is spliced from
+func tcpUnspliced(id command.ID) command.ID {
+	if id < TCPCommandIPv4+TCPCommandSpliced ||
+		id > TCPCommandMapping+TCPCommandSpliced {
+		return id
+	}
+
+	return id - TCPCommandSpliced
+}
+
 // New creates a new request context
 func (c TCP) New(rw rw.ReadWriteDepleteDoner, log logger.Logger) fsm.Machine {
 	panic("`New` must be overrided")
@@ -89,6 +125,70 @@ func (c *tcp) Bootup() (fsm.State, error) {
 	panic("`Bootup` must be overrided")
 }
 
+// dialer returns a Dialer which dials the destination through the upstream
+// proxy if it's enabled, or directly otherwise
+func (c *tcp) dialer(
+	host string,
+	port uint16,
+	timeout time.Duration,
+	bound egress.Egress,
+) network.Dialer {
+	if c.upstream.Enabled() {
+		d := upstream.New(
+			c.upstream, host, port, timeout, bound, tcpconn.Wrap)
+
+		if !c.noLocalAccess {
+			return d
+		}
+
+		return localGuardedDialer{
+			dialer:   d,
+			host:     host,
+			resolver: c.resolver,
+		}
+	}
+
+	return tcpdial.NewBound(
+		host, port, timeout, c.resolver, bound, tcpconn.Wrap)
+}
+
+// relayTo creates and boots up the Relay which exchanges data between the
+// request and the destination
+func (c *tcp) relayTo(log logger.Logger, dial network.Dial) (fsm.State, error) {
+	client := &tcpRelay{
+		noLocalAccess:     c.noLocalAccess,
+		dialTimeout:       c.dialTimeout,
+		connectionTimeout: c.connectionTimeout,
+		dial:              dial,
+		splice:            c.splice && relay.Exclusive(c.rw),
+		spliced:           nil,
+		spliceErr:         nil,
+	}
+
+	c.relay = relay.New(
+		log, c.runner, c.rw, c.buf, client, make([]byte, 4096))
+
+	bootErr := c.relay.Bootup(c.cancel)
+
+	if bootErr != nil {
+		return nil, bootErr
+	}
+
+	if client.spliced == nil {
+		return c.tick, nil
+	}
+
+	// Spliced Relay will not be ticked by the dispatcher as it's connection
+	// has been taken over, so run it through right now
+	spliceErr := c.relay.Tick()
+
+	if spliceErr != nil {
+		return nil, spliceErr
+	}
+
+	return c.tick, nil
+}
+
 func (c *tcp) tick(f fsm.FSM) error {
 	tErr := c.relay.Tick()
 
@@ -24,15 +24,14 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"net"
+	"strconv"
 	"time"
 
 	"github.com/reinit/coward/common/fsm"
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/roles/common/command"
-	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
-	tcpdial "github.com/reinit/coward/roles/common/network/dialer/tcp"
-	"github.com/reinit/coward/roles/common/relay"
 )
 
 // Errors
@@ -52,7 +51,7 @@ type tcpHost struct {
 
 // ID returns current Request ID
 func (c TCPHost) ID() command.ID {
-	return TCPCommandHost
+	return c.id(TCPCommandHost)
 }
 
 // New creates a new context
@@ -66,6 +65,10 @@ func (c TCPHost) New(rw rw.ReadWriteDepleteDoner, l logger.Logger) fsm.Machine {
 			runner:            c.Runner,
 			cancel:            c.Cancel,
 			noLocalAccess:     c.NoLocalAccess,
+			resolver:          c.Resolver,
+			egress:            c.Egress,
+			upstream:          c.Upstream,
+			splice:            c.Splice,
 			rw:                rw,
 			relay:             nil,
 		},
@@ -112,19 +115,8 @@ func (c *tcpHost) Bootup() (fsm.State, error) {
 		timeout = c.dialTimeout
 	}
 
-	c.relay = relay.New(c.logger, c.runner, c.rw, c.buf, tcpRelay{
-		noLocalAccess:     c.noLocalAccess,
-		dialTimeout:       c.dialTimeout,
-		connectionTimeout: c.connectionTimeout,
-		dial: tcpdial.New(
-			string(host), port, timeout, tcpconn.Wrap).Dialer(),
-	}, make([]byte, 4096))
-
-	bootErr := c.relay.Bootup(c.cancel)
-
-	if bootErr != nil {
-		return nil, bootErr
-	}
-
-	return c.tick, nil
+	return c.relayTo(logger.WithField(
+		c.logger, logger.FieldDestination, net.JoinHostPort(
+			string(host), strconv.FormatUint(uint64(port), 10),
+		)), c.dialer(string(host), port, timeout, c.egress).Dialer())
 }
@@ -23,15 +23,13 @@ package request
 import (
 	"io"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/reinit/coward/common/fsm"
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/roles/common/command"
-	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
-	tcpdial "github.com/reinit/coward/roles/common/network/dialer/tcp"
-	"github.com/reinit/coward/roles/common/relay"
 )
 
 // TCPIPv4 IPv4 Connect request
@@ -45,7 +43,7 @@ type tcpIPv4 struct {
 
 // ID returns the request ID
 func (c TCPIPv4) ID() command.ID {
-	return TCPCommandIPv4
+	return c.id(TCPCommandIPv4)
 }
 
 // New creates a new context
@@ -59,6 +57,10 @@ func (c TCPIPv4) New(rw rw.ReadWriteDepleteDoner, l logger.Logger) fsm.Machine {
 			runner:            c.Runner,
 			cancel:            c.Cancel,
 			noLocalAccess:     c.NoLocalAccess,
+			resolver:          c.Resolver,
+			egress:            c.Egress,
+			upstream:          c.Upstream,
+			splice:            c.Splice,
 			rw:                rw,
 			relay:             nil,
 		},
@@ -95,19 +97,8 @@ func (c *tcpIPv4) Bootup() (fsm.State, error) {
 		timeout = c.dialTimeout
 	}
 
-	c.relay = relay.New(c.logger, c.runner, c.rw, c.buf, tcpRelay{
-		noLocalAccess:     c.noLocalAccess,
-		dialTimeout:       c.dialTimeout,
-		connectionTimeout: c.connectionTimeout,
-		dial: tcpdial.New(
-			ipv4.String(), port, timeout, tcpconn.Wrap).Dialer(),
-	}, make([]byte, 4096))
-
-	bootErr := c.relay.Bootup(c.cancel)
-
-	if bootErr != nil {
-		return nil, bootErr
-	}
-
-	return c.tick, nil
+	return c.relayTo(logger.WithField(
+		c.logger, logger.FieldDestination, net.JoinHostPort(
+			ipv4.String(), strconv.FormatUint(uint64(port), 10),
+		)), c.dialer(ipv4.String(), port, timeout, c.egress).Dialer())
 }
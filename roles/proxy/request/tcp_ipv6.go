@@ -23,15 +23,13 @@ package request
 import (
 	"io"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/reinit/coward/common/fsm"
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/roles/common/command"
-	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
-	tcpdial "github.com/reinit/coward/roles/common/network/dialer/tcp"
-	"github.com/reinit/coward/roles/common/relay"
 )
 
 // TCPIPv6 IPv6 Connent request
@@ -45,12 +43,12 @@ type tcpIPv6 struct {
 
 // ID returns current Request ID
 func (c TCPIPv6) ID() command.ID {
-	return TCPCommandIPv6
+	return c.id(TCPCommandIPv6)
 }
 
 // New creates a new request context
 func (c TCPIPv6) New(rw rw.ReadWriteDepleteDoner, l logger.Logger) fsm.Machine {
-	return &tcpIPv4{
+	return &tcpIPv6{
 		tcp: tcp{
 			logger:            l,
 			buf:               c.Buffer,
@@ -59,6 +57,10 @@ func (c TCPIPv6) New(rw rw.ReadWriteDepleteDoner, l logger.Logger) fsm.Machine {
 			runner:            c.Runner,
 			cancel:            c.Cancel,
 			noLocalAccess:     c.NoLocalAccess,
+			resolver:          c.Resolver,
+			egress:            c.Egress,
+			upstream:          c.Upstream,
+			splice:            c.Splice,
 			rw:                rw,
 			relay:             nil,
 		},
@@ -99,19 +101,8 @@ func (c *tcpIPv6) Bootup() (fsm.State, error) {
 		timeout = c.dialTimeout
 	}
 
-	c.relay = relay.New(c.logger, c.runner, c.rw, c.buf, tcpRelay{
-		noLocalAccess:     c.noLocalAccess,
-		dialTimeout:       c.dialTimeout,
-		connectionTimeout: c.connectionTimeout,
-		dial: tcpdial.New(
-			ipv6.String(), port, timeout, tcpconn.Wrap).Dialer(),
-	}, make([]byte, 4096))
-
-	bootErr := c.relay.Bootup(c.cancel)
-
-	if bootErr != nil {
-		return nil, bootErr
-	}
-
-	return c.tick, nil
+	return c.relayTo(logger.WithField(
+		c.logger, logger.FieldDestination, net.JoinHostPort(
+			ipv6.String(), strconv.FormatUint(uint64(port), 10),
+		)), c.dialer(ipv6.String(), port, timeout, c.egress).Dialer())
 }
@@ -0,0 +1,38 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"testing"
+
+	"github.com/reinit/coward/common/logger"
+)
+
+func TestTCPIPv6New(t *testing.T) {
+	_, isIPv6 := TCPIPv6{}.New(nil, logger.NewDitch()).(*tcpIPv6)
+
+	if !isIPv6 {
+		t.Error("TCPIPv6 must create an IPv6 request context, otherwise " +
+			"the IPv6 address will be read as an IPv4 one")
+
+		return
+	}
+}
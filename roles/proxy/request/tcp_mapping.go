@@ -29,9 +29,6 @@ import (
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/roles/common/command"
 	"github.com/reinit/coward/roles/common/network"
-	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
-	tcpdial "github.com/reinit/coward/roles/common/network/dialer/tcp"
-	"github.com/reinit/coward/roles/common/relay"
 	"github.com/reinit/coward/roles/proxy/common"
 )
 
@@ -56,7 +53,7 @@ type tcpMapping struct {
 
 // ID returns current Request ID
 func (c TCPMapping) ID() command.ID {
-	return TCPCommandMapping
+	return c.id(TCPCommandMapping)
 }
 
 // New creates a new request context
@@ -71,6 +68,10 @@ func (c TCPMapping) New(
 			runner:            c.Runner,
 			cancel:            c.Cancel,
 			noLocalAccess:     c.NoLocalAccess,
+			resolver:          c.Resolver,
+			egress:            c.Egress,
+			upstream:          c.Upstream,
+			splice:            c.Splice,
 			rw:                rw,
 			relay:             nil,
 		},
@@ -103,19 +104,12 @@ func (c *tcpMapping) Bootup() (fsm.State, error) {
 		return nil, ErrTCPMappingNotFound
 	}
 
-	c.relay = relay.New(c.logger, c.runner, c.rw, c.buf, tcpRelay{
-		noLocalAccess:     c.noLocalAccess,
-		dialTimeout:       c.dialTimeout,
-		connectionTimeout: c.connectionTimeout,
-		dial: tcpdial.New(
-			mapped.Host, mapped.Port, c.dialTimeout, tcpconn.Wrap).Dialer(),
-	}, make([]byte, 4096))
+	bound := c.egress
 
-	bootErr := c.relay.Bootup(c.cancel)
-
-	if bootErr != nil {
-		return nil, bootErr
+	if mapped.Egress != nil {
+		bound = mapped.Egress
 	}
 
-	return c.tick, nil
+	return c.relayTo(c.logger, c.dialer(
+		mapped.Host, mapped.Port, c.dialTimeout, bound).Dialer())
 }
@@ -28,6 +28,7 @@ import (
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/network/resolve"
 	"github.com/reinit/coward/roles/common/relay"
 )
 
@@ -36,20 +37,127 @@ type tcpRelay struct {
 	dialTimeout       time.Duration
 	connectionTimeout time.Duration
 	dial              network.Dial
+	splice            bool
+	spliced           network.Connection
+	spliceErr         error
 }
 
-func (c tcpRelay) Initialize(l logger.Logger, server relay.Server) error {
+// localGuardedDialer guards the Dialer of an upstream proxy, which resolves
+// the host remotely thus the IP address of the destination is unknown
+// after it's connected
+type localGuardedDialer struct {
+	dialer   network.Dialer
+	host     string
+	resolver resolve.Resolver
+}
+
+// localGuardedDial resolves the host locally, and refuses to dial when it's
+// a local address
+type localGuardedDial struct {
+	dial     network.Dial
+	host     string
+	resolver resolve.Resolver
+}
+
+// localAddress returns whether or not the IP is a local address
+func localAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast()
+}
+
+func (d localGuardedDialer) Dialer() network.Dial {
+	return localGuardedDial{
+		dial:     d.dialer.Dialer(),
+		host:     d.host,
+		resolver: d.resolver,
+	}
+}
+
+func (d localGuardedDial) resolve() ([]net.IP, error) {
+	ip := net.ParseIP(d.host)
+
+	if ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	if d.resolver != nil {
+		return d.resolver.Resolve(d.host)
+	}
+
+	return net.LookupIP(d.host)
+}
+
+func (d localGuardedDial) Dial() (network.Connection, error) {
+	resolved, resolveErr := d.resolve()
+
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+
+	for rIdx := range resolved {
+		if !localAddress(resolved[rIdx]) {
+			continue
+		}
+
+		return nil, ErrTCPLocalAccessDeined
+	}
+
+	return d.dial.Dial()
+}
+
+func (d localGuardedDial) String() string {
+	return d.dial.String()
+}
+
+func (c *tcpRelay) Initialize(l logger.Logger, server relay.Server) error {
+	if !c.splice {
+		return nil
+	}
+
+	// Connect right away so the Relay can be spliced once it's connected.
+	// If the connect has failed, the error will be returned by Client as
+	// usual
+	c.spliced, c.spliceErr = c.connect(server, TCPRespondSpliced)
+
 	return nil
 }
 
-func (c tcpRelay) Abort(l logger.Logger, aborter relay.Aborter) error {
+func (c *tcpRelay) Abort(l logger.Logger, aborter relay.Aborter) error {
 	return aborter.SendError()
 }
 
-func (c tcpRelay) Client(
+func (c *tcpRelay) Client(
 	l logger.Logger, server relay.Server) (io.ReadWriteCloser, error) {
+	if c.splice {
+		return nil, c.spliceErr
+	}
+
+	return c.connect(server, TCPRespondOK)
+}
+
+func (c *tcpRelay) Spliced() bool {
+	return c.spliced != nil
+}
+
+func (c *tcpRelay) Splice(l logger.Logger) (net.Conn, time.Duration, error) {
+	return c.spliced, c.connectionTimeout, nil
+}
+
+// connect connects to the destination, and sends the given respond to the
+// server once it's connected
+func (c *tcpRelay) connect(
+	server relay.Server, respond byte) (network.Connection, error) {
 	remoteConn, remoteDialErr := c.dial.Dial()
 
+	if remoteDialErr == ErrTCPLocalAccessDeined {
+		_, wErr := rw.WriteFull(server, []byte{TCPRespondAccessDeined})
+
+		if wErr != nil {
+			return nil, wErr
+		}
+
+		return nil, remoteDialErr
+	}
+
 	if remoteDialErr != nil {
 		_, wErr := rw.WriteFull(server, []byte{TCPRespondUnreachable})
 
@@ -62,10 +170,7 @@ func (c tcpRelay) Client(
 
 	remoteAddr := remoteConn.RemoteAddr().(*net.TCPAddr)
 
-	if c.noLocalAccess &&
-		(remoteAddr.IP.IsLoopback() ||
-			remoteAddr.IP.IsUnspecified() ||
-			remoteAddr.IP.IsMulticast()) {
+	if c.noLocalAccess && localAddress(remoteAddr.IP) {
 		remoteConn.Close()
 
 		_, wErr := rw.WriteFull(server, []byte{TCPRespondAccessDeined})
@@ -77,7 +182,7 @@ func (c tcpRelay) Client(
 		return nil, ErrTCPLocalAccessDeined
 	}
 
-	_, wErr := rw.WriteFull(server, []byte{TCPRespondOK})
+	_, wErr := rw.WriteFull(server, []byte{respond})
 
 	if wErr != nil {
 		remoteConn.Close()
@@ -0,0 +1,110 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/reinit/coward/roles/common/network"
+)
+
+var (
+	errDummyDialDialed = errors.New("Dialed")
+)
+
+type dummyDial struct {
+	dialed bool
+}
+
+func (d *dummyDial) Dial() (network.Connection, error) {
+	d.dialed = true
+
+	return nil, errDummyDialDialed
+}
+
+func (d *dummyDial) String() string {
+	return "Dummy"
+}
+
+func TestLocalGuardedDial(t *testing.T) {
+	resolver := &dummyResolver{
+		resolves: map[string][]net.IP{
+			"localhost":   {net.ParseIP("127.0.0.1")},
+			"example.com": {net.ParseIP("192.0.2.1")},
+			"mixed.com": {
+				net.ParseIP("192.0.2.1"), net.ParseIP("::1")},
+		},
+	}
+
+	tests := []struct {
+		Host   string
+		Denied bool
+	}{
+		{Host: "localhost", Denied: true},
+		{Host: "mixed.com", Denied: true},
+		{Host: "127.0.0.1", Denied: true},
+		{Host: "::", Denied: true},
+		{Host: "example.com", Denied: false},
+		{Host: "192.0.2.1", Denied: false},
+	}
+
+	for _, test := range tests {
+		dial := &dummyDial{dialed: false}
+
+		_, dialErr := localGuardedDial{
+			dial:     dial,
+			host:     test.Host,
+			resolver: resolver,
+		}.Dial()
+
+		if test.Denied {
+			if dialErr != ErrTCPLocalAccessDeined || dial.dialed {
+				t.Errorf("Expecting %s to be denied before dialing, "+
+					"got error %v", test.Host, dialErr)
+
+				return
+			}
+
+			continue
+		}
+
+		if dialErr != errDummyDialDialed || !dial.dialed {
+			t.Errorf("Expecting %s to be dialed, got error %v",
+				test.Host, dialErr)
+
+			return
+		}
+	}
+
+	_, dialErr := localGuardedDial{
+		dial:     &dummyDial{dialed: false},
+		host:     "unknown.com",
+		resolver: resolver,
+	}.Dial()
+
+	if dialErr != errdummyResolverFailed {
+		t.Errorf("Expecting error %s, got %v", errdummyResolverFailed, dialErr)
+
+		return
+	}
+}
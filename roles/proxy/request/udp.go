@@ -29,6 +29,8 @@ import (
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/common/worker"
 	"github.com/reinit/coward/roles/common/command"
+	"github.com/reinit/coward/roles/common/network/egress"
+	"github.com/reinit/coward/roles/common/network/resolve"
 	"github.com/reinit/coward/roles/common/relay"
 )
 
@@ -67,6 +69,8 @@ type UDP struct {
 	Buffer    []byte
 	Cancel    <-chan struct{}
 	LocalAddr net.Addr
+	Resolver  resolve.Resolver
+	Egress    egress.Egress
 }
 
 type udp struct {
@@ -84,14 +88,33 @@ func (c UDP) ID() command.ID {
 
 // New creates a new request context
 func (c UDP) New(rw rw.ReadWriteDepleteDoner, log logger.Logger) fsm.Machine {
+	return c.wrapped(rw, log, nil)
+}
+
+// wrapped creates a new request context which's relay.Client will be
+// wrapped by the given wrapper
+func (c UDP) wrapped(
+	rw rw.ReadWriteDepleteDoner,
+	log logger.Logger,
+	wrap relayWrapper,
+) fsm.Machine {
+	var client relay.Client = &udpRelay{
+		localAddr: c.LocalAddr,
+		listenIP:  nil,
+		resolver:  c.Resolver,
+		egress:    c.Egress,
+	}
+
+	if wrap != nil {
+		client = wrap(client)
+	}
+
 	return udp{
 		runner: c.Runner,
 		cancel: c.Cancel,
 		rw:     rw,
-		relay: relay.New(log, c.Runner, rw, c.Buffer, &udpRelay{
-			localAddr: c.LocalAddr,
-			listenIP:  nil,
-		}, make([]byte, 4096)),
+		relay: relay.New(
+			log, c.Runner, rw, c.Buffer, client, make([]byte, 4096)),
 	}
 }
 
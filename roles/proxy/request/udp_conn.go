@@ -39,6 +39,9 @@ var (
 
 	ErrUDPTransportLocalAccessDeined = errors.New(
 		"Local UDP access deined")
+
+	ErrUDPTransportClosed = errors.New(
+		"UDP connection is closed")
 )
 
 // UDPConn is the Conn of UDP
@@ -71,22 +74,23 @@ func (u *udpConn) Read(b []byte) (int, error) {
 			return 0, rErr
 		}
 
-		addressSendType := UDPSendHost
+		ipMark := resolve.IPMark{}
+		ipMark.Import(rAddr.IP)
 
-		knownHost, reverseErr := u.resolver.Reverse(rAddr.IP)
+		u.remoteLock.RLock()
+		_, found := u.remotes[ipMark]
+		u.remoteLock.RUnlock()
 
-		if reverseErr != nil {
-			ipMark := resolve.IPMark{}
-			ipMark.Import(rAddr.IP)
+		// Only accept datagrams from the remotes that we've sent to
+		if !found {
+			continue
+		}
 
-			u.remoteLock.RLock()
-			_, found := u.remotes[ipMark]
-			u.remoteLock.RUnlock()
+		addressSendType := UDPSendHost
 
-			if !found {
-				continue
-			}
+		knownHost, reverseErr := u.resolver.Reverse(rAddr.IP)
 
+		if reverseErr != nil {
 			if knownHost = string(rAddr.IP.To4()); knownHost != "" {
 				addressSendType = UDPSendIPv4
 			} else if knownHost = string(rAddr.IP.To16()); knownHost != "" {
@@ -413,3 +413,92 @@ func TestUDPConnWriteHost(t *testing.T) {
 		return
 	}
 }
+
+func TestUDPConnSharedResolver(t *testing.T) {
+	shared := &dummyResolver{resolves: map[string][]net.IP{
+		"a.test": []net.IP{net.ParseIP("128.0.0.1")},
+		"b.test": []net.IP{net.ParseIP("128.0.0.2")},
+	}}
+
+	newConn := func() (*udpConn, chan dummyUDPConnRead) {
+		read := make(chan dummyUDPConnRead, 2)
+
+		return &udpConn{
+			UDPConn: &dummyUDPConn{
+				Read:  read,
+				Write: make(chan dummyUDPConnWrite, 1),
+			},
+			resolver:   resolve.Scoped(shared, 16),
+			remotes:    make(map[resolve.IPMark]struct{}, 16),
+			maxRemotes: 16,
+			remoteLock: sync.RWMutex{},
+		}, read
+	}
+
+	connA, readA := newConn()
+	connB, readB := newConn()
+
+	_, wErr := connA.Write([]byte{
+		UDPSendHost, 6, 'a', '.', 't', 'e', 's', 't', 0, 53, 'A'})
+
+	if wErr != nil {
+		t.Error("Failed to write due to error:", wErr)
+
+		return
+	}
+
+	_, wErr = connB.Write([]byte{
+		UDPSendHost, 6, 'b', '.', 't', 'e', 's', 't', 0, 53, 'B'})
+
+	if wErr != nil {
+		t.Error("Failed to write due to error:", wErr)
+
+		return
+	}
+
+	for _, test := range []struct {
+		conn    *udpConn
+		read    chan dummyUDPConnRead
+		other   net.IP
+		own     net.IP
+		ownHost string
+	}{
+		{connA, readA, net.ParseIP("128.0.0.2"), net.ParseIP("128.0.0.1"),
+			"a.test"},
+		{connB, readB, net.ParseIP("128.0.0.1"), net.ParseIP("128.0.0.2"),
+			"b.test"},
+	} {
+		test.read <- dummyUDPConnRead{
+			Data:  []byte("OTHER"),
+			Addr:  &net.UDPAddr{IP: test.other, Port: 53, Zone: ""},
+			Error: nil,
+		}
+
+		test.read <- dummyUDPConnRead{
+			Data:  []byte("OWN"),
+			Addr:  &net.UDPAddr{IP: test.own, Port: 53, Zone: ""},
+			Error: nil,
+		}
+
+		buf := [4096]byte{}
+
+		rLen, rErr := test.conn.Read(buf[:])
+
+		if rErr != nil {
+			t.Error("Failed to read due to error:", rErr)
+
+			return
+		}
+
+		expected := append([]byte{UDPSendHost, byte(len(test.ownHost))},
+			test.ownHost...)
+		expected = append(expected, 0, 53, 'O', 'W', 'N')
+
+		if string(buf[:rLen]) != string(expected) {
+			t.Errorf("Expecting only the datagram from %s to be read as "+
+				"%d, got %d", test.ownHost, expected, buf[:rLen])
+
+			return
+		}
+	}
+}
@@ -0,0 +1,267 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/reinit/coward/common/fsm"
+	"github.com/reinit/coward/common/logger"
+	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/command"
+	"github.com/reinit/coward/roles/common/datagram"
+	"github.com/reinit/coward/roles/common/relay"
+)
+
+// Errors
+var (
+	ErrUDPDatagramInvalidRequest = errors.New(
+		"Invalid UDP Datagram request")
+)
+
+// relayWrapper wraps a relay.Client
+type relayWrapper func(relay.Client) relay.Client
+
+// UDPDatagram UDP Datagram request. It offers a datagram Association to
+// the client, and then handles the following UDP or UDP Mapping request.
+//
+// If the client has started the Association, payload of the following
+// request will be carried through it, otherwise the request will be
+// handled as usual, or be forwarded by the Forward
+type UDPDatagram struct {
+	UDP        UDP
+	UDPMapping UDPMapping
+	Server     datagram.Server
+	Keepalive  time.Duration
+	Forward    Forward
+}
+
+type udpDatagram struct {
+	logger     logger.Logger
+	udp        UDP
+	udpMapping UDPMapping
+	server     datagram.Server
+	keepalive  time.Duration
+	forward    Forward
+	rw         rw.ReadWriteDepleteDoner
+	assoc      datagram.Association
+	started    bool
+	request    fsm.FSM
+	completer  command.Completer
+}
+
+// ID returns current Request ID
+func (c UDPDatagram) ID() command.ID {
+	return UDPCommandDatagram
+}
+
+// New creates a new request context
+func (c UDPDatagram) New(
+	rw rw.ReadWriteDepleteDoner, log logger.Logger) fsm.Machine {
+	return &udpDatagram{
+		logger:     log,
+		udp:        c.UDP,
+		udpMapping: c.UDPMapping,
+		server:     c.Server,
+		keepalive:  c.Keepalive,
+		forward:    c.Forward,
+		rw:         rw,
+		assoc:      nil,
+		started:    false,
+		request:    nil,
+		completer:  nil,
+	}
+}
+
+func (u *udpDatagram) Bootup() (fsm.State, error) {
+	defer u.rw.Done()
+
+	var assocErr error
+
+	if u.server != nil {
+		assoc, secret, aErr := u.server.Associate()
+
+		if aErr == nil {
+			resp := [1 + datagram.SecretSize + 2]byte{}
+			keepaliveSecs := uint16(u.keepalive / time.Second)
+
+			resp[0] = datagram.RespondOK
+
+			secret.Encode(resp[1 : 1+datagram.SecretSize])
+
+			resp[1+datagram.SecretSize] = byte(keepaliveSecs >> 8)
+			resp[2+datagram.SecretSize] = byte(keepaliveSecs)
+
+			_, wErr := rw.WriteFull(u.rw, resp[:])
+
+			if wErr != nil {
+				assoc.Close()
+
+				return nil, wErr
+			}
+
+			u.assoc = assoc
+
+			return u.selecting, nil
+		}
+
+		assocErr = aErr
+	}
+
+	if assocErr != nil {
+		u.logger.Debugf("Datagram is unavailable: %s", assocErr)
+	}
+
+	_, wErr := rw.WriteFull(u.rw, []byte{datagram.RespondUnavailable})
+
+	if wErr != nil {
+		return nil, wErr
+	}
+
+	return u.selecting, nil
+}
+
+func (u *udpDatagram) selecting(f fsm.FSM) error {
+	req := [3]byte{}
+
+	_, rErr := io.ReadFull(u.rw, req[:1])
+
+	if rErr != nil {
+		u.rw.Done()
+
+		return rErr
+	}
+
+	switch req[0] {
+	case datagram.RequestStart:
+		_, rErr = io.ReadFull(u.rw, req[1:])
+
+		u.rw.Done()
+
+		if rErr != nil {
+			return rErr
+		}
+
+		if u.assoc == nil || u.started {
+			return ErrUDPDatagramInvalidRequest
+		}
+
+		keepalive := time.Duration(
+			uint16(req[1])<<8|uint16(req[2])) * time.Second
+
+		if keepalive > 0 && keepalive < u.keepalive {
+			u.keepalive = keepalive
+		}
+
+		u.started = true
+
+		return nil
+
+	case UDPCommandDelegate:
+		return u.handle(f, command.ID(req[0]), func(
+			r rw.ReadWriteDepleteDoner, l logger.Logger) fsm.Machine {
+			return u.udp.wrapped(r, l, u.wrapper())
+		})
+
+	case UDPCommandTransport:
+		return u.handle(f, command.ID(req[0]), func(
+			r rw.ReadWriteDepleteDoner, l logger.Logger) fsm.Machine {
+			return u.udpMapping.wrapped(r, l, u.wrapper())
+		})
+
+	default:
+		u.rw.Done()
+
+		return ErrUDPDatagramInvalidRequest
+	}
+}
+
+// wrapper returns the relayWrapper which carries the payload through the
+// Association, or nil when the Association is not been started
+func (u *udpDatagram) wrapper() relayWrapper {
+	if !u.started {
+		if u.assoc != nil {
+			u.assoc.Close()
+		}
+
+		return nil
+	}
+
+	return func(c relay.Client) relay.Client {
+		return datagram.Relay(
+			c, u.assoc, u.udp.Runner, u.keepalive, u.udp.Cancel)
+	}
+}
+
+func (u *udpDatagram) handle(
+	f fsm.FSM, id command.ID, local localRequest) error {
+	m, mErr := u.forward.request(id, u.rw, u.logger, local)
+
+	if mErr != nil {
+		return mErr
+	}
+
+	u.request = fsm.New(m)
+
+	bootupErr := u.request.Bootup()
+
+	if bootupErr != nil {
+		return bootupErr
+	}
+
+	u.completer, _ = m.(command.Completer)
+
+	f.Switch(u.tick)
+
+	return nil
+}
+
+func (u *udpDatagram) tick(f fsm.FSM) error {
+	tErr := u.request.Tick()
+
+	if tErr != nil {
+		return tErr
+	}
+
+	if !u.request.Running() {
+		return f.Shutdown()
+	}
+
+	return nil
+}
+
+func (u *udpDatagram) Shutdown() error {
+	if u.assoc != nil {
+		u.assoc.Close()
+	}
+
+	if u.request == nil || !u.request.Running() {
+		return nil
+	}
+
+	return u.request.Shutdown()
+}
+
+func (u *udpDatagram) Completed() bool {
+	return u.completer != nil && u.completer.Completed()
+}
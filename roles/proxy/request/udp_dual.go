@@ -0,0 +1,145 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"net"
+	"sync"
+)
+
+// Consts
+const (
+	udpDualMaxDatagramSize = 65535
+)
+
+// udpDualReceived is a datagram received by an udpDualConn
+type udpDualReceived struct {
+	data []byte
+	addr *net.UDPAddr
+	err  error
+}
+
+// udpDualConn is an UDPConn made of one socket for each IP version, so
+// datagrams can be sent from the source of the same IP version as their
+// destinations
+type udpDualConn struct {
+	ipv4      *net.UDPConn
+	ipv6      *net.UDPConn
+	received  chan udpDualReceived
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newUDPDualConn creates a new udpDualConn
+func newUDPDualConn(ipv4 *net.UDPConn, ipv6 *net.UDPConn) *udpDualConn {
+	d := &udpDualConn{
+		ipv4:      ipv4,
+		ipv6:      ipv6,
+		received:  make(chan udpDualReceived),
+		closed:    make(chan struct{}),
+		closeOnce: sync.Once{},
+	}
+
+	go d.receive(ipv4)
+	go d.receive(ipv6)
+
+	return d
+}
+
+// receive receives datagrams from the socket until it's closed
+func (d *udpDualConn) receive(conn *net.UDPConn) {
+	buf := make([]byte, udpDualMaxDatagramSize)
+
+	for {
+		rLen, rAddr, rErr := conn.ReadFromUDP(buf)
+
+		received := udpDualReceived{
+			data: nil,
+			addr: rAddr,
+			err:  rErr,
+		}
+
+		if rErr == nil {
+			received.data = make([]byte, rLen)
+
+			copy(received.data, buf[:rLen])
+		}
+
+		select {
+		case d.received <- received:
+		case <-d.closed:
+			return
+		}
+
+		if rErr != nil {
+			return
+		}
+	}
+}
+
+// ReadFromUDP reads a datagram from either socket
+func (d *udpDualConn) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	select {
+	case received := <-d.received:
+		if received.err == nil {
+			return copy(b, received.data), received.addr, nil
+		}
+
+		select {
+		case <-d.closed:
+			return 0, nil, ErrUDPTransportClosed
+
+		default:
+			return 0, nil, received.err
+		}
+
+	case <-d.closed:
+		return 0, nil, ErrUDPTransportClosed
+	}
+}
+
+// WriteToUDP writes a datagram through the socket of the same IP version
+// as the addr
+func (d *udpDualConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	if addr.IP.To4() != nil {
+		return d.ipv4.WriteToUDP(b, addr)
+	}
+
+	return d.ipv6.WriteToUDP(b, addr)
+}
+
+// Close closes both sockets
+func (d *udpDualConn) Close() error {
+	closeErr := ErrUDPTransportClosed
+
+	d.closeOnce.Do(func() {
+		close(d.closed)
+
+		closeErr = d.ipv4.Close()
+		ipv6CloseErr := d.ipv6.Close()
+
+		if closeErr == nil {
+			closeErr = ipv6CloseErr
+		}
+	})
+
+	return closeErr
+}
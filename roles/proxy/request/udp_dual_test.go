@@ -0,0 +1,139 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPDualConn(t *testing.T) {
+	ipv4, ipv4Err := net.ListenUDP("udp", &net.UDPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: 0,
+		Zone: "",
+	})
+
+	if ipv4Err != nil {
+		t.Error("Failed to listen IPv4:", ipv4Err)
+
+		return
+	}
+
+	ipv6, ipv6Err := net.ListenUDP("udp", &net.UDPAddr{
+		IP:   net.ParseIP("::1"),
+		Port: 0,
+		Zone: "",
+	})
+
+	if ipv6Err != nil {
+		ipv4.Close()
+
+		t.Skip("IPv6 is unavailable:", ipv6Err)
+
+		return
+	}
+
+	dual := newUDPDualConn(ipv4, ipv6)
+
+	defer dual.Close()
+
+	for _, local := range []*net.UDPAddr{
+		ipv4.LocalAddr().(*net.UDPAddr),
+		ipv6.LocalAddr().(*net.UDPAddr),
+	} {
+		remote, remoteErr := net.DialUDP("udp", nil, local)
+
+		if remoteErr != nil {
+			t.Error("Failed to dial:", remoteErr)
+
+			return
+		}
+
+		defer remote.Close()
+
+		_, wErr := remote.Write([]byte("Hello"))
+
+		if wErr != nil {
+			t.Error("Failed to write:", wErr)
+
+			return
+		}
+
+		buf := make([]byte, 16)
+
+		rLen, rAddr, rErr := dual.ReadFromUDP(buf)
+
+		if rErr != nil {
+			t.Error("Failed to read:", rErr)
+
+			return
+		}
+
+		if string(buf[:rLen]) != "Hello" {
+			t.Errorf("Expecting to read %q, got %q", "Hello", buf[:rLen])
+
+			return
+		}
+
+		if !rAddr.IP.Equal(remote.LocalAddr().(*net.UDPAddr).IP) {
+			t.Errorf("Expecting to read from %s, got %s",
+				remote.LocalAddr(), rAddr)
+
+			return
+		}
+
+		_, wErr = dual.WriteToUDP([]byte("World"), rAddr)
+
+		if wErr != nil {
+			t.Error("Failed to write back:", wErr)
+
+			return
+		}
+
+		remote.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+		rLen, rErr = remote.Read(buf)
+
+		if rErr != nil {
+			t.Error("Failed to read back:", rErr)
+
+			return
+		}
+
+		if string(buf[:rLen]) != "World" {
+			t.Errorf("Expecting to read back %q, got %q", "World", buf[:rLen])
+
+			return
+		}
+	}
+
+	dual.Close()
+
+	_, _, rErr := dual.ReadFromUDP(make([]byte, 16))
+
+	if rErr != ErrUDPTransportClosed {
+		t.Errorf("Expecting error %s, got %v", ErrUDPTransportClosed, rErr)
+
+		return
+	}
+}
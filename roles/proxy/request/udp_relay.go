@@ -28,6 +28,7 @@ import (
 
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/network/egress"
 	"github.com/reinit/coward/roles/common/network/resolve"
 	"github.com/reinit/coward/roles/common/relay"
 )
@@ -35,6 +36,8 @@ import (
 type udpRelay struct {
 	localAddr net.Addr
 	listenIP  net.IP
+	resolver  resolve.Resolver
+	egress    egress.Egress
 }
 
 func (u *udpRelay) Initialize(l logger.Logger, server relay.Server) error {
@@ -63,9 +66,56 @@ func (u *udpRelay) Abort(l logger.Logger, aborter relay.Aborter) error {
 	return aborter.SendError()
 }
 
+// listen listens for the datagrams. Destinations are unknown until the
+// client sends, so when the egress contains addresses of both IP versions,
+// one socket will be created for each of them
+func (u *udpRelay) listen() (UDPConn, error) {
+	if u.egress == nil {
+		return egress.Source{
+			IP:     nil,
+			Device: "",
+		}.ListenUDP()
+	}
+
+	ipv4Source, ipv4SourceErr := u.egress.Source(net.IPv4zero)
+	ipv6Source, ipv6SourceErr := u.egress.Source(net.IPv6zero)
+
+	switch {
+	case ipv4SourceErr != nil && ipv6SourceErr != nil:
+		return nil, ipv4SourceErr
+
+	case ipv6SourceErr != nil:
+		return ipv4Source.ListenUDP()
+
+	case ipv4SourceErr != nil:
+		return ipv6Source.ListenUDP()
+	}
+
+	// Without any address, both Sources are the same wildcard one
+	if ipv4Source.IP == nil && ipv6Source.IP == nil {
+		return ipv4Source.ListenUDP()
+	}
+
+	ipv4Listener, ipv4ListenErr := ipv4Source.ListenUDP()
+
+	if ipv4ListenErr != nil {
+		return nil, ipv4ListenErr
+	}
+
+	ipv6Listener, ipv6ListenErr := ipv6Source.ListenUDP()
+
+	if ipv6ListenErr != nil {
+		ipv4Listener.Close()
+
+		return nil, ipv6ListenErr
+	}
+
+	return newUDPDualConn(ipv4Listener, ipv6Listener), nil
+}
+
 func (u *udpRelay) Client(
 	l logger.Logger, server relay.Server) (io.ReadWriteCloser, error) {
-	listener, listenErr := net.ListenUDP("udp", nil)
+	listener, listenErr := u.listen()
 
 	if listenErr != nil {
 		rw.WriteFull(server, []byte{UDPRespondFailedToListen})
@@ -73,9 +123,19 @@ func (u *udpRelay) Client(
 		return nil, listenErr
 	}
 
+	var resolver resolve.Resolver
+
+	// The shared Resolver reverses addresses resolved by every client,
+	// so each association reverses only the ones resolved by itself
+	if u.resolver != nil {
+		resolver = resolve.Scoped(u.resolver, 16)
+	} else {
+		resolver = resolve.Cached(1*time.Hour, 10*time.Second, 16)
+	}
+
 	listenerConn := &udpConn{
 		UDPConn:    listener,
-		resolver:   resolve.Cached(1*time.Hour, 10*time.Second, 16),
+		resolver:   resolver,
 		remotes:    make(map[resolve.IPMark]struct{}, 16),
 		maxRemotes: 16,
 		remoteLock: sync.RWMutex{},
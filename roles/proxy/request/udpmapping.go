@@ -32,6 +32,8 @@ import (
 	"github.com/reinit/coward/common/worker"
 	"github.com/reinit/coward/roles/common/command"
 	"github.com/reinit/coward/roles/common/network"
+	"github.com/reinit/coward/roles/common/network/egress"
+	"github.com/reinit/coward/roles/common/network/resolve"
 	"github.com/reinit/coward/roles/common/relay"
 	"github.com/reinit/coward/roles/proxy/common"
 )
@@ -50,6 +52,8 @@ type UDPMapping struct {
 	LocalAddr   net.Addr
 	DialTimeout time.Duration
 	Mapping     common.Mapping
+	Resolver    resolve.Resolver
+	Egress      egress.Egress
 }
 
 type udpMapping struct {
@@ -58,10 +62,13 @@ type udpMapping struct {
 	buf         []byte
 	localAddr   net.Addr
 	dialTimeout time.Duration
+	resolver    resolve.Resolver
+	egress      egress.Egress
 	runner      worker.Runner
 	cancel      <-chan struct{}
 	rw          rw.ReadWriteDepleteDoner
 	relay       relay.Relay
+	wrap        relayWrapper
 }
 
 // ID returns current Request ID
@@ -72,16 +79,29 @@ func (c UDPMapping) ID() command.ID {
 // New creates a new request context
 func (c UDPMapping) New(
 	rw rw.ReadWriteDepleteDoner, log logger.Logger) fsm.Machine {
+	return c.wrapped(rw, log, nil)
+}
+
+// wrapped creates a new request context which's relay.Client will be
+// wrapped by the given wrapper
+func (c UDPMapping) wrapped(
+	rw rw.ReadWriteDepleteDoner,
+	log logger.Logger,
+	wrap relayWrapper,
+) fsm.Machine {
 	return &udpMapping{
 		logger:      log,
 		mapping:     c.Mapping,
 		buf:         c.Buffer,
 		localAddr:   c.LocalAddr,
 		dialTimeout: c.DialTimeout,
+		resolver:    c.Resolver,
+		egress:      c.Egress,
 		runner:      c.Runner,
 		cancel:      c.Cancel,
 		rw:          rw,
 		relay:       nil,
+		wrap:        wrap,
 	}
 }
 
@@ -110,12 +130,27 @@ func (u *udpMapping) Bootup() (fsm.State, error) {
 		return nil, ErrUDPMappingNotFound
 	}
 
-	u.relay = relay.New(u.logger, u.runner, u.rw, u.buf, &udpMappingRelay{
+	bound := u.egress
+
+	if mapped.Egress != nil {
+		bound = mapped.Egress
+	}
+
+	var client relay.Client = &udpMappingRelay{
 		localAddr:      u.localAddr,
 		resolveTimeout: u.dialTimeout,
+		resolver:       u.resolver,
+		egress:         bound,
 		mapped:         mapped,
 		listenIP:       nil,
-	}, make([]byte, 4096))
+	}
+
+	if u.wrap != nil {
+		client = u.wrap(client)
+	}
+
+	u.relay = relay.New(
+		u.logger, u.runner, u.rw, u.buf, client, make([]byte, 4096))
 
 	bootupErr := u.relay.Bootup(u.cancel)
 
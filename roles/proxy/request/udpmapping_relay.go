@@ -27,6 +27,7 @@ import (
 
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/network/egress"
 	"github.com/reinit/coward/roles/common/network/resolve"
 	"github.com/reinit/coward/roles/common/relay"
 	"github.com/reinit/coward/roles/proxy/common"
@@ -35,6 +36,8 @@ import (
 type udpMappingRelay struct {
 	localAddr      net.Addr
 	resolveTimeout time.Duration
+	resolver       resolve.Resolver
+	egress         egress.Egress
 	mapped         *common.Mapped
 	listenIP       net.IP
 }
@@ -68,7 +71,13 @@ func (u *udpMappingRelay) Abort(l logger.Logger, aborter relay.Aborter) error {
 
 func (u *udpMappingRelay) Client(
 	l logger.Logger, server relay.Server) (io.ReadWriteCloser, error) {
-	resolved, resolveErr := resolve.DNS(u.resolveTimeout).Resolve(u.mapped.Host)
+	resolver := u.resolver
+
+	if resolver == nil {
+		resolver = resolve.DNS(u.resolveTimeout)
+	}
+
+	resolved, resolveErr := resolver.Resolve(u.mapped.Host)
 
 	if resolveErr != nil {
 		rw.WriteFull(server, []byte{UDPRespondMappingHostUnresolved})
@@ -76,11 +85,24 @@ func (u *udpMappingRelay) Client(
 		return nil, resolveErr
 	}
 
-	listener, listenErr := net.DialUDP("udp", &net.UDPAddr{
-		IP:   u.listenIP,
-		Port: 0,
-		Zone: "",
-	}, &net.UDPAddr{
+	source := egress.Source{
+		IP:     u.listenIP,
+		Device: "",
+	}
+
+	if u.egress != nil {
+		var sourceErr error
+
+		source, sourceErr = u.egress.Source(resolved[0])
+
+		if sourceErr != nil {
+			rw.WriteFull(server, []byte{UDPRespondFailedToListen})
+
+			return nil, sourceErr
+		}
+	}
+
+	listener, listenErr := source.DialUDP(&net.UDPAddr{
 		IP:   resolved[0],
 		Port: int(u.mapped.Port),
 		Zone: "",
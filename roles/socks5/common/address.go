@@ -23,6 +23,8 @@ package common
 import (
 	"errors"
 	"io"
+	"net"
+	"strconv"
 )
 
 // Errors
@@ -113,3 +115,18 @@ func (a *Address) ReadFrom(reader io.Reader) (int64, error) {
 		return int64(rLen), ErrAddressInvalidSocks5AddressType
 	}
 }
+
+// String returns the host:port form of the Address
+func (a Address) String() string {
+	host := ""
+
+	switch a.AType {
+	case ATypeIPv4, ATypeIPv6:
+		host = net.IP(a.Address).String()
+
+	default:
+		host = string(a.Address)
+	}
+
+	return net.JoinHostPort(host, strconv.FormatUint(uint64(a.Port), 10))
+}
@@ -20,13 +20,20 @@
 
 package socks5
 
-import "time"
+import (
+	"time"
+
+	"github.com/reinit/coward/roles/common/access"
+	"github.com/reinit/coward/roles/common/datagram"
+)
 
 // Config Socks5 configuration
 type Config struct {
-	Capacity              uint32
-	NegotiationTimeout    time.Duration
-	ConnectionTimeout     time.Duration
-	MaxDestinationRecords int
-	Authenticator         Authenticator
+	Capacity           uint32
+	NegotiationTimeout time.Duration
+	ConnectionTimeout  time.Duration
+	Authenticator      Authenticator
+	AccessLog          access.Log
+	MemoryLimit        uint64
+	Datagram           []datagram.Endpoint
 }
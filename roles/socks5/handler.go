@@ -27,6 +27,8 @@ import (
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/access"
+	"github.com/reinit/coward/roles/common/datagram"
 	"github.com/reinit/coward/roles/common/network"
 	"github.com/reinit/coward/roles/common/transceiver"
 	"github.com/reinit/coward/roles/socks5/common"
@@ -41,6 +43,8 @@ type handler struct {
 	negoTimeout   time.Duration
 	timeout       time.Duration
 	authenticator Authenticator
+	access        access.Logger
+	datagrams     []datagram.Endpoint
 }
 
 type client struct {
@@ -53,6 +57,8 @@ type client struct {
 	shb           *common.SharedBuffers
 	authenticator Authenticator
 	runner        worker.Runner
+	access        access.Logger
+	datagrams     []datagram.Endpoint
 }
 
 func (d handler) New(
@@ -69,14 +75,20 @@ func (d handler) New(
 		shb:           d.shb,
 		authenticator: d.authenticator,
 		runner:        d.runner,
+		access:        d.access,
+		datagrams:     d.datagrams,
 	}, nil
 }
 
 func (d client) Serve() error {
 	var reqErr error
 
+	session := access.NewSession(d.conn.RemoteAddr().String(), "tcp")
+
 	d.logger.Infof("Serving")
 	defer func() {
+		session.Finish(d.access, reqErr)
+
 		if reqErr == nil {
 			d.logger.Infof("Request completed")
 
@@ -93,9 +105,11 @@ func (d client) Serve() error {
 		runner:                 d.runner,
 		shb:                    d.shb,
 		authenticator:          d.authenticator,
+		session:                session,
 		selectedCMD:            0,
 		selectedAddress:        common.Address{},
 		selectedRequestBuilder: nil,
+		datagrams:              d.datagrams,
 	}
 	negoFSM := fsm.New(nego)
 
@@ -27,6 +27,8 @@ import (
 	"github.com/reinit/coward/common/worker"
 	"github.com/reinit/coward/common/fsm"
 	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/access"
+	"github.com/reinit/coward/roles/common/datagram"
 	"github.com/reinit/coward/roles/common/network"
 	"github.com/reinit/coward/roles/common/transceiver"
 	"github.com/reinit/coward/roles/socks5/common"
@@ -88,9 +90,11 @@ type negotiator struct {
 	runner                 worker.Runner
 	shb                    *common.SharedBuffers
 	authenticator          Authenticator
+	session                *access.Session
 	selectedCMD            cmd
 	selectedAddress        common.Address
 	selectedRequestBuilder transceiver.RequestBuilder
+	datagrams              []datagram.Endpoint
 }
 
 func (n *negotiator) Bootup() (fsm.State, error) {
@@ -162,6 +166,8 @@ func (n *negotiator) Build() (
 	transceiver.BalancedRequestBuilder,
 	error,
 ) {
+	n.session.Destination(n.selectedAddress.String())
+
 	switch n.selectedCMD {
 	case cmdConnect:
 		return "Connect:" + transceiver.Destination(
@@ -171,9 +177,13 @@ func (n *negotiator) Build() (
 				n.selectedAddress,
 				n.runner,
 				n.shb,
-				n.cfg.NegotiationTimeout), nil
+				n.session,
+				n.cfg.NegotiationTimeout,
+				n.cfg.ConnectionTimeout), nil
 
 	case cmdUDP:
+		n.session.Protocol("udp")
+
 		return "UDP:" + transceiver.Destination(
 				n.selectedAddress.Address,
 			), request.UDP(
@@ -181,7 +191,9 @@ func (n *negotiator) Build() (
 				n.selectedAddress,
 				n.runner,
 				n.shb,
-				n.cfg.NegotiationTimeout), nil
+				n.session,
+				n.cfg.NegotiationTimeout,
+				n.datagrams), nil
 
 	default:
 		// +----+-----+-------+------+----------+----------+
@@ -246,6 +258,8 @@ func (n *negotiator) auth(f fsm.FSM) error {
 			return aErr
 		}
 
+		n.session.User(string(userName))
+
 		_, wErr := rw.WriteFull(n.conn, []byte{0x05, 0x00})
 
 		if wErr != nil {
@@ -271,6 +285,8 @@ func (n *negotiator) auth(f fsm.FSM) error {
 		return aErr
 	}
 
+	n.session.User(string(userName))
+
 	_, wErr := rw.WriteFull(n.conn, []byte{0x05, 0x00})
 
 	if wErr != nil {
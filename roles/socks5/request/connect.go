@@ -22,12 +22,14 @@ package request
 
 import (
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/reinit/coward/common/fsm"
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/access"
 	"github.com/reinit/coward/roles/common/network"
 	"github.com/reinit/coward/roles/common/relay"
 	"github.com/reinit/coward/roles/common/transceiver"
@@ -73,7 +75,9 @@ func Connect(
 	addr common.Address,
 	runner worker.Runner,
 	shb *common.SharedBuffers,
+	session *access.Session,
 	requestTimeout time.Duration,
+	idleTimeout time.Duration,
 ) transceiver.BalancedRequestBuilder {
 	return func(
 		cID transceiver.ClientID,
@@ -82,14 +86,21 @@ func Connect(
 		connCtl transceiver.ConnectionControl,
 		log logger.Logger,
 	) fsm.Machine {
+		session.ProxyClient(strconv.FormatUint(uint64(cID), 10))
+
+		log = logger.WithField(log, logger.FieldDestination, addr.String())
+
 		return connect{
 			log: log,
-			relay: relay.New(
-				log, runner, conn, shb.For(cID).Select(id), connectRelay{
+			relay: relay.NewMetered(
+				log, runner, conn, shb.For(cID).Select(id), &connectRelay{
 					client:         client,
 					addr:           addr,
 					requestTimeout: requestTimeout,
-				}, make([]byte, 4096)),
+					idleTimeout:    idleTimeout,
+					splice:         relay.Exclusive(conn),
+					spliced:        false,
+				}, make([]byte, 4096), &session.Traffic),
 			cancel: client.Closed(),
 		}
 	}
@@ -24,10 +24,12 @@ import (
 	"errors"
 	"io"
 	"math"
+	"net"
 	"time"
 
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
+	"github.com/reinit/coward/roles/common/network"
 	"github.com/reinit/coward/roles/common/relay"
 	"github.com/reinit/coward/roles/proxy/request"
 	"github.com/reinit/coward/roles/socks5/common"
@@ -40,14 +42,23 @@ var (
 )
 
 type connectRelay struct {
-	client         io.ReadWriteCloser
+	client         network.Connection
 	addr           common.Address
 	requestTimeout time.Duration
+	idleTimeout    time.Duration
+	splice         bool
+	spliced        bool
 }
 
-func (c connectRelay) Initialize(l logger.Logger, server relay.Server) error {
+func (c *connectRelay) Initialize(l logger.Logger, server relay.Server) error {
 	var wErr error
 
+	spliced := byte(0)
+
+	if c.splice {
+		spliced = request.TCPCommandSpliced
+	}
+
 	// Initialize the Channel to Connect command
 	// +-----+------+------+------------+
 	// | CMD | Addr | Port | ReqTimeout |
@@ -73,7 +84,7 @@ func (c connectRelay) Initialize(l logger.Logger, server relay.Server) error {
 	switch c.addr.AType {
 	case common.ATypeIPv4:
 		_, wErr = rw.WriteFull(server, []byte{
-			request.TCPCommandIPv4,
+			request.TCPCommandIPv4 + spliced,
 			c.addr.Address[0], c.addr.Address[1],
 			c.addr.Address[2], c.addr.Address[3],
 			portTimeoutBytes[0], portTimeoutBytes[1], portTimeoutBytes[2],
@@ -81,7 +92,7 @@ func (c connectRelay) Initialize(l logger.Logger, server relay.Server) error {
 
 	case common.ATypeIPv6:
 		_, wErr = rw.WriteFull(server, []byte{
-			request.TCPCommandIPv6,
+			request.TCPCommandIPv6 + spliced,
 			c.addr.Address[0], c.addr.Address[1],
 			c.addr.Address[2], c.addr.Address[3],
 			c.addr.Address[4], c.addr.Address[5],
@@ -104,7 +115,7 @@ func (c connectRelay) Initialize(l logger.Logger, server relay.Server) error {
 
 		copy(hostData[2:2+addrLen], c.addr.Address)
 
-		hostData[0] = request.TCPCommandHost
+		hostData[0] = request.TCPCommandHost + spliced
 		hostData[1] = byte(addrLen)
 		hostData[addrLen+2] = portTimeoutBytes[0]
 		hostData[addrLen+3] = portTimeoutBytes[1]
@@ -136,6 +147,14 @@ func (c connectRelay) Initialize(l logger.Logger, server relay.Server) error {
 		return crErr
 	}
 
+	// Keep the segment undone, so the server connection won't be read by
+	// the dispatcher before it's been taken over
+	if c.splice && command[0] == request.TCPRespondSpliced {
+		c.spliced = true
+
+		return nil
+	}
+
 	server.Done()
 
 	var connectError error
@@ -172,12 +191,39 @@ func (c connectRelay) Initialize(l logger.Logger, server relay.Server) error {
 	return connectError
 }
 
-func (c connectRelay) Abort(l logger.Logger, aborter relay.Aborter) error {
+func (c *connectRelay) Abort(l logger.Logger, aborter relay.Aborter) error {
 	return aborter.Goodbye()
 }
 
-func (c connectRelay) Client(
+func (c *connectRelay) Client(
 	l logger.Logger, server relay.Server) (io.ReadWriteCloser, error) {
+	rErr := c.ready()
+
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	return c.client, nil
+}
+
+func (c *connectRelay) Spliced() bool {
+	return c.spliced
+}
+
+func (c *connectRelay) Splice(l logger.Logger) (net.Conn, time.Duration, error) {
+	rErr := c.ready()
+
+	if rErr != nil {
+		c.client.Close()
+
+		return nil, 0, rErr
+	}
+
+	return c.client, c.idleTimeout, nil
+}
+
+// ready tells the client that the connection is ready
+func (c *connectRelay) ready() error {
 	// Tell client that we're ready
 	//
 	// +----+-----+-------+------+----------+----------+
@@ -189,9 +235,5 @@ func (c connectRelay) Client(
 	_, wErr := rw.WriteFull(c.client, []byte{
 		0x05, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
 
-	if wErr != nil {
-		return nil, wErr
-	}
-
-	return c.client, nil
+	return wErr
 }
@@ -21,15 +21,19 @@
 package request
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/reinit/coward/common/fsm"
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/common/worker"
+	"github.com/reinit/coward/roles/common/access"
+	"github.com/reinit/coward/roles/common/datagram"
 	"github.com/reinit/coward/roles/common/network"
 	"github.com/reinit/coward/roles/common/relay"
 	"github.com/reinit/coward/roles/common/transceiver"
+	"github.com/reinit/coward/roles/proxy/request"
 	"github.com/reinit/coward/roles/socks5/common"
 )
 
@@ -46,7 +50,9 @@ func UDP(
 	addr common.Address,
 	runner worker.Runner,
 	shb *common.SharedBuffers,
+	session *access.Session,
 	requestTimeout time.Duration,
+	datagrams []datagram.Endpoint,
 ) transceiver.BalancedRequestBuilder {
 	return func(
 		cID transceiver.ClientID,
@@ -55,18 +61,29 @@ func UDP(
 		connCtl transceiver.ConnectionControl,
 		log logger.Logger,
 	) fsm.Machine {
+		session.ProxyClient(strconv.FormatUint(uint64(cID), 10))
+
+		var relayClient relay.Client = &udpRelay{
+			client:         client,
+			addr:           addr,
+			requestTimeout: requestTimeout,
+			runner:         runner,
+			cancel:         client.Closed(),
+			udpConn:        nil,
+			comfirmData:    nil,
+		}
+
+		if int(cID) < len(datagrams) && datagrams[cID].Enabled() {
+			relayClient = datagram.Negotiator(
+				relayClient, request.UDPCommandDatagram, datagrams[cID],
+				runner, client.Closed())
+		}
+
 		return udp{
 			log: log,
-			relay: relay.New(
-				log, runner, conn, shb.For(cID).Select(id), &udpRelay{
-					client:         client,
-					addr:           addr,
-					requestTimeout: requestTimeout,
-					runner:         runner,
-					cancel:         client.Closed(),
-					udpConn:        nil,
-					comfirmData:    nil,
-				}, make([]byte, 4096)),
+			relay: relay.NewMetered(
+				log, runner, conn, shb.For(cID).Select(id), relayClient,
+				make([]byte, 4096), &session.Traffic),
 			cancel: client.Closed(),
 		}
 	}
@@ -25,6 +25,7 @@ import (
 	"math"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/reinit/coward/common/rw"
 	"github.com/reinit/coward/roles/common/network"
@@ -57,6 +58,7 @@ type udpConn struct {
 	accConnCloseResult chan error
 	allowedIP          net.IP
 	client             *net.UDPAddr
+	fragments          udpFragments
 	closeLock          sync.Mutex
 }
 
@@ -88,11 +90,6 @@ func (c *udpConn) Read(b []byte) (int, error) {
 		// But remember this pre-condition. Once it changed, following
 		// code may not working and cause panic.
 
-		// We don't support FRAG.
-		if b[2] != 0x00 {
-			continue
-		}
-
 		targetAddr := common.Address{}
 
 		addrReadLen, addrReadErr := targetAddr.ReadFrom(
@@ -102,6 +99,27 @@ func (c *udpConn) Read(b []byte) (int, error) {
 			return 0, addrReadErr
 		}
 
+		if b[2] == 0x00 {
+			c.fragments.reset()
+		} else {
+			addrEnd := 3 + int(addrReadLen)
+
+			if addrEnd >= rLen {
+				continue
+			}
+
+			// All fragments of the sequence has the same address, so the
+			// targetAddr we just read is still valid for the reassembled one
+			reassembled, completed := c.fragments.add(
+				time.Now(), b[2], b[3:addrEnd], b[addrEnd:rLen], len(b)-3)
+
+			if !completed {
+				continue
+			}
+
+			rLen = 3 + copy(b[3:], reassembled)
+		}
+
 		switch targetAddr.AType {
 		case common.ATypeIPv4:
 			if rLen <= 10 || addrReadLen != 7 {
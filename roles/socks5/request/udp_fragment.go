@@ -0,0 +1,123 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"bytes"
+	"time"
+)
+
+const (
+	// udpFragmentEnd is the FRAG flag marks the last fragment of a sequence
+	udpFragmentEnd = 0x80
+
+	// udpFragmentPositionMask is the mask to get the fragment position
+	// from the FRAG field
+	udpFragmentPositionMask = 0x7F
+
+	// udpFragmentReassemblyTimeout is the REASSEMBLY TIMER. RFC 1928
+	// requires it to be no less than 5 seconds
+	udpFragmentReassemblyTimeout = 5 * time.Second
+)
+
+// udpFragments is the reassembly queue of a SOCKS5 UDP association.
+//
+// Following the rules of RFC 1928, the queue is abandoned when the
+// reassembly timer expired, or when a fragment carries a position that is
+// not higher than the highest position received in current sequence.
+// Because fragments can only be accepted in ascending order, they're
+// appended to one buffer which never grows larger than the maxLen given
+// to add, so the memory used by each association is bounded
+type udpFragments struct {
+	buf        []byte
+	addressLen int
+	position   byte
+	started    time.Time
+}
+
+// reset abandons the fragments in the queue
+func (f *udpFragments) reset() {
+	f.buf = f.buf[:0]
+	f.addressLen = 0
+	f.position = 0
+}
+
+// add puts a fragment to the queue. The address is the ATYP, DST.ADDR and
+// DST.PORT fields of the fragment, and the data is it's payload.
+//
+// Once the last fragment of the sequence has been received, the address and
+// the reassembled payload will be returned together in one slice. The slice
+// is only valid until the next call of add
+func (f *udpFragments) add(
+	now time.Time,
+	frag byte,
+	address []byte,
+	data []byte,
+	maxLen int,
+) ([]byte, bool) {
+	position := frag & udpFragmentPositionMask
+
+	if f.position > 0 && now.Sub(f.started) > udpFragmentReassemblyTimeout {
+		f.reset()
+	}
+
+	if position <= f.position {
+		f.reset()
+	}
+
+	// Either the sequence is not started from 1, or some fragment between
+	// is missing. Drop them all as the sequence can never be completed
+	if position != f.position+1 {
+		f.reset()
+
+		return nil, false
+	}
+
+	if f.position == 0 {
+		if len(address)+len(data) > maxLen {
+			return nil, false
+		}
+
+		if cap(f.buf) < maxLen {
+			f.buf = make([]byte, 0, maxLen)
+		}
+
+		f.buf = append(f.buf[:0], address...)
+		f.addressLen = len(address)
+		f.started = now
+	} else if !bytes.Equal(f.buf[:f.addressLen], address) ||
+		len(f.buf)+len(data) > maxLen {
+		f.reset()
+
+		return nil, false
+	}
+
+	f.buf = append(f.buf, data...)
+	f.position = position
+
+	if frag&udpFragmentEnd == 0 {
+		return nil, false
+	}
+
+	f.position = 0
+
+	return f.buf, true
+}
@@ -0,0 +1,176 @@
+//  Crypto-Obscured Forwarder
+//
+//  Copyright (C) 2018 Rui NI <ranqus@gmail.com>
+//
+//  This file is part of Crypto-Obscured Forwarder.
+//
+//  Crypto-Obscured Forwarder is free software: you can redistribute it
+//  and/or modify it under the terms of the GNU General Public License
+//  as published by the Free Software Foundation, either version 3 of
+//  the License, or (at your option) any later version.
+//
+//  Crypto-Obscured Forwarder is distributed in the hope that it will be
+//  useful, but WITHOUT ANY WARRANTY; without even the implied warranty
+//  of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU General Public License for more details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with Crypto-Obscured Forwarder. If not, see
+//  <http://www.gnu.org/licenses/>.
+
+package request
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/reinit/coward/roles/proxy/request"
+	"github.com/reinit/coward/roles/socks5/common"
+)
+
+func TestUDPConnReadFragmentsInOrder(t *testing.T) {
+	rChan := make(chan dummyUDPConnRead, 3)
+	addr := &net.UDPAddr{
+		IP:   net.ParseIP("127.0.0.2"),
+		Port: 198,
+		Zone: "",
+	}
+
+	rChan <- dummyUDPConnRead{
+		Data: []byte{0, 0, 1, byte(common.ATypeIPv4), 127, 0, 0, 1, 0, 198,
+			'H', 'E'},
+		Addr:  addr,
+		Error: nil,
+	}
+
+	rChan <- dummyUDPConnRead{
+		Data: []byte{0, 0, 2, byte(common.ATypeIPv4), 127, 0, 0, 1, 0, 198,
+			'L', 'L'},
+		Addr:  addr,
+		Error: nil,
+	}
+
+	rChan <- dummyUDPConnRead{
+		Data: []byte{0, 0, 3 | udpFragmentEnd, byte(common.ATypeIPv4),
+			127, 0, 0, 1, 0, 198, 'O', '!'},
+		Addr:  addr,
+		Error: nil,
+	}
+
+	readData, readErr := testUDPConnRead(t, rChan, net.ParseIP("127.0.0.2"))
+
+	if readErr != nil {
+		t.Error("Failed to read data due to error:", readErr)
+
+		return
+	}
+
+	if !bytes.Equal(readData, []byte{byte(request.UDPSendIPv4), 127, 0, 0, 1,
+		0, 198, 72, 69, 76, 76, 79, 33}) {
+		t.Errorf("Failed to reassemble fragments. Expecting %d, got %d",
+			[]byte{byte(request.UDPSendIPv4), 127, 0, 0, 1, 0, 198,
+				72, 69, 76, 76, 79, 33}, readData)
+
+		return
+	}
+}
+
+func TestUDPFragmentsOutOfOrder(t *testing.T) {
+	f := udpFragments{}
+	now := time.Now()
+	addr := []byte{byte(common.ATypeIPv4), 127, 0, 0, 1, 0, 198}
+
+	// Position 2 arrives before 1, the sequence is broken
+	for _, frag := range []byte{2, 1, 3 | udpFragmentEnd} {
+		_, completed := f.add(now, frag, addr, []byte{'A'}, 4093)
+
+		if completed {
+			t.Errorf("Fragment %d must not complete a broken sequence", frag)
+
+			return
+		}
+	}
+
+	// Position lower than the highest received will start a new sequence
+	for _, frag := range []byte{1, 2, 1, 2} {
+		_, completed := f.add(now, frag, addr, []byte{frag}, 4093)
+
+		if completed {
+			t.Errorf("Fragment %d must not complete the sequence", frag)
+
+			return
+		}
+	}
+
+	result, completed := f.add(now, 3|udpFragmentEnd, addr, []byte{3}, 4093)
+
+	if !completed {
+		t.Error("Expecting the restarted sequence to be completed")
+
+		return
+	}
+
+	if !bytes.Equal(result, append(addr, 1, 2, 3)) {
+		t.Errorf("Expecting %d to be reassembled, got %d",
+			append(addr, 1, 2, 3), result)
+
+		return
+	}
+}
+
+func TestUDPFragmentsExpired(t *testing.T) {
+	f := udpFragments{}
+	now := time.Now()
+	addr := []byte{byte(common.ATypeIPv4), 127, 0, 0, 1, 0, 198}
+
+	f.add(now, 1, addr, []byte{1}, 4093)
+	f.add(now.Add(time.Second), 2, addr, []byte{2}, 4093)
+
+	_, completed := f.add(
+		now.Add(udpFragmentReassemblyTimeout+time.Second),
+		3|udpFragmentEnd, addr, []byte{3}, 4093)
+
+	if completed {
+		t.Error("Expired sequence must not be completed")
+
+		return
+	}
+
+	later := now.Add(udpFragmentReassemblyTimeout * 2)
+
+	f.add(later, 1, addr, []byte{1}, 4093)
+
+	result, completed := f.add(later, 2|udpFragmentEnd, addr, []byte{2}, 4093)
+
+	if !completed || !bytes.Equal(result, append(addr, 1, 2)) {
+		t.Errorf("Expecting %d to be reassembled after expiration, got %d",
+			append(addr, 1, 2), result)
+
+		return
+	}
+}
+
+func TestUDPFragmentsBounded(t *testing.T) {
+	f := udpFragments{}
+	now := time.Now()
+	addr := []byte{byte(common.ATypeIPv4), 127, 0, 0, 1, 0, 198}
+
+	f.add(now, 1, addr, make([]byte, 8), 16)
+
+	_, completed := f.add(now, 2|udpFragmentEnd, addr, make([]byte, 8), 16)
+
+	if completed {
+		t.Error("Sequence larger than the limit must be abandoned")
+
+		return
+	}
+
+	if len(f.buf) != 0 || cap(f.buf) != 16 {
+		t.Errorf("Expecting an empty queue with 16 bytes of capacity, "+
+			"got %d bytes with %d capacity", len(f.buf), cap(f.buf))
+
+		return
+	}
+}
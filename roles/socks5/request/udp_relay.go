@@ -135,6 +135,7 @@ func (u *udpRelay) Initialize(l logger.Logger, server relay.Server) error {
 		accConn:            u.client,
 		accConnCloseResult: runnerCloseResult,
 		client:             nil,
+		fragments:          udpFragments{},
 		closeLock:          sync.Mutex{},
 	}
 
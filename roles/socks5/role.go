@@ -23,17 +23,29 @@ package socks5
 import (
 	"errors"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/reinit/coward/common/logger"
 	"github.com/reinit/coward/common/print"
 	"github.com/reinit/coward/common/role"
 	"github.com/reinit/coward/common/ticker"
+	"github.com/reinit/coward/roles/common/access"
+	"github.com/reinit/coward/roles/common/datagram"
+	"github.com/reinit/coward/roles/common/network"
 	tcpconn "github.com/reinit/coward/roles/common/network/connection/tcp"
 	"github.com/reinit/coward/roles/common/network/dialer/tcp"
 	tcplisten "github.com/reinit/coward/roles/common/network/listener/tcp"
 	"github.com/reinit/coward/roles/common/transceiver"
 	tclient "github.com/reinit/coward/roles/common/transceiver/client"
+	"github.com/reinit/coward/roles/common/transceiver/clients"
+	"github.com/reinit/coward/roles/common/transceiver/reverse"
+)
+
+// Consts
+const (
+	maxDestinationRecords = 8192
 )
 
 // ConfigProxy Proxy configurations
@@ -49,7 +61,9 @@ type ConfigProxy struct {
 	Channels       uint8    `json:"channels" cfg:"n,-channels:How many requests can be simultaneously opened on a single established connection.\r\n\r\nSet the value greater than 1 so a single connection can be use to transport multiple requests (Multiplexing).\r\n\r\nWARNING:\r\nThis value must matchs or smaller than the related setting on the COWARD Proxy server, otherwise the request will be come malformed and thus dropped."`
 	Persistent     bool     `json:"persist" cfg:"k,-persist:Whether or not to keep the connection to the COWARD Proxy active after all requests on the connection is completed."`
 	Codec          string   `json:"codec" cfg:"e,-codec:Specify which Codec will be used to encode and decode data payload to and from a connection."`
-	CodecSetting   []string `json:"codec_setting" cfg:"es,-codec-cfg:Configuration of the Codec as an array of string.\r\n\r\nThe actual configuration format of this setting is depend on the Codec of your choosing."`
+	CodecSetting   []string `json:"codec_setting" secret:"true" cfg:"es,-codec-cfg:Configuration of the Codec as an array of string.\r\n\r\nThe actual configuration format of this setting is depend on the Codec of your choosing."`
+	Datagram       bool     `json:"datagram" cfg:"du,-datagram:Carry the payload of UDP requests through an encrypted UDP Association with the COWARD Proxy server instead of the proxy connection.\r\n\r\nThe COWARD Proxy server must enable the same option. If the Association is not available or unreachable, the proxy connection will be used."`
+	Reverse        bool     `json:"reverse" cfg:"rv,-reverse:Wait for the COWARD Proxy server to dial in instead of connecting to it, which is useful when the server can't be reached directly.\r\n\r\nWhen enabled, \"--host\" must be the IP address of a local network interface, and the registration port will be listened on it and the \"--port\". The server must specify this address as its \"--reverse\" setting, and only the servers which share the same Codec key can register.\r\n\r\nConnections of this server will not be shared with other Roles, and \"--datagram\" is not available in this mode."`
 }
 
 // Init inits the configuration
@@ -162,6 +176,17 @@ func (c *ConfigProxy) Verify() error {
 		return errors.New("Connections must be defined")
 	}
 
+	if c.Reverse {
+		if net.ParseIP(c.Host) == nil {
+			return errors.New(
+				"Host must be an IP address to listen on in Reverse mode")
+		}
+
+		if c.Datagram {
+			return errors.New("Datagram is not available in Reverse mode")
+		}
+	}
+
 	if c.RequestRetries <= 0 {
 		c.RequestRetries = 3
 	}
@@ -200,7 +225,7 @@ func (c *ConfigProxy) Verify() error {
 // ConfigAccount Socks5 accounts
 type ConfigAccount struct {
 	Username string `json:"username" cfg:"u,-user:Login name of the Socks5 account."`
-	Password string `json:"password" cfg:"p,-pass:Password of the Socks5 account."`
+	Password string `json:"password" secret:"true" cfg:"p,-pass:Password of the Socks5 account."`
 }
 
 // VerifyUsername Verify Username
@@ -236,30 +261,33 @@ func (c *ConfigAccount) Verify() error {
 
 // ConfigInput Configuration
 type ConfigInput struct {
-	components        []interface{}
-	selectedInterface net.IP
-	Proxies           []ConfigProxy   `json:"proxies" cfg:"r,-proxies:Specify a set of remote COWARD Proxy servers.\r\n\r\nRequest will be dispatched to one of these proxies automatically."`
-	Interface         string          `json:"interface" cfg:"i,-interface:Specify a local network interface to serve the Socks5 server."`
-	Port              uint16          `json:"port" cfg:"p,-port:Specify a port to serve the Socks5 server"`
-	Timeout           uint16          `json:"timeout" cfg:"t,-timeout:The maximum idle time in second of a Socks5 client connection.\r\n\r\nIf server consecutively receives no data from a connection during this period of time, then that connection will be considered as inactive and thus be disconnected."`
-	InitialTimeout    uint16          `json:"initial_timeout" cfg:"it,-initial-timeout:The maximum wait time in second for Socks5 clients to finish Handshake.\r\n\r\nA well balanced value is required: You need to give clients plenty of time to finish the Initial request (Otherwise they may never be able to connect), and also be able defending against malicious accesses (By time them out) at same time."`
-	Capacity          uint32          `json:"Capacity" cfg:"c,-capacity:The maximum connections this Socks5 server can accept.\r\n\r\nWhen amount of connections reached this limitation, new incoming connection will be dropped."`
-	Account           []ConfigAccount `json:"account" cfg:"a,-accounts:Accounts of the Socks5 server.\r\n\r\nOnce defined, the Socks5 server will require user authentication before relaying the request."`
+	components            []interface{}
+	selectedInterface     net.IP
+	selectedProxyProtocol []*net.IPNet
+	Proxies               []ConfigProxy   `json:"proxies" cfg:"r,-proxies:Specify a set of remote COWARD Proxy servers.\r\n\r\nRequest will be dispatched to one of these proxies automatically."`
+	Interface             string          `json:"interface" cfg:"i,-interface:Specify a local network interface to serve the Socks5 server."`
+	Port                  uint16          `json:"port" cfg:"p,-port:Specify a port to serve the Socks5 server"`
+	Timeout               uint16          `json:"timeout" cfg:"t,-timeout:The maximum idle time in second of a Socks5 client connection.\r\n\r\nIf server consecutively receives no data from a connection during this period of time, then that connection will be considered as inactive and thus be disconnected."`
+	InitialTimeout        uint16          `json:"initial_timeout" cfg:"it,-initial-timeout:The maximum wait time in second for Socks5 clients to finish Handshake.\r\n\r\nA well balanced value is required: You need to give clients plenty of time to finish the Initial request (Otherwise they may never be able to connect), and also be able defending against malicious accesses (By time them out) at same time."`
+	Capacity              uint32          `json:"Capacity" cfg:"c,-capacity:The maximum connections this Socks5 server can accept.\r\n\r\nWhen amount of connections reached this limitation, new incoming connection will be dropped."`
+	Share                 bool            `json:"share" cfg:"sh,-share:Share connections to the COWARD Proxy servers with other Roles running in the same process.\r\n\r\nConnections will only be shared between Roles which have exactly the same Proxy settings (Including the Codec settings)."`
+	Account               []ConfigAccount `json:"account" cfg:"a,-accounts:Accounts of the Socks5 server.\r\n\r\nOnce defined, the Socks5 server will require user authentication before relaying the request."`
+	ProxyProtocol         []string        `json:"proxy_protocol" cfg:"pp,-proxy-protocol:Accept PROXY protocol (v1 and v2) headers from these trusted sources, specified as a list of CIDRs or IP addresses.\r\n\r\nThis is useful when the server is running behind a load balancer such as HAProxy or a cloud load balancer: Connections from the trusted sources must start with a valid PROXY protocol header, and the address carried by it will be used as the client address.\r\n\r\nConnections from other sources will be accepted as usual."`
+	AccessLog             string          `json:"access_log" cfg:"al,-access-log:Write a record for every finished Socks5 request to this file.\r\n\r\nEach record is a JSON line which contains the client address, the authenticated user, the destination, the protocol, the ID of the chosen COWARD Proxy, the amount of bytes received from and sent to the client, the duration and the close reason of the request.\r\n\r\nSend SIGUSR1 to reopen the file after it has been moved away."`
+	MemoryLimit           uint32          `json:"memory_limit" cfg:"ml,-memory-limit:Soft limit of the memory usage in megabytes.\r\n\r\nOnce the memory usage of the process has exceeded this limit, no more worker will be created and new Socks5 requests which can't be picked up by an idle worker will be rejected.\r\n\r\nSet to 0 (default) to disable the limit."`
 }
 
-// GetDescription gets description
-func (c ConfigInput) GetDescription(fieldPath string) string {
-	result := ""
-
+// GetCandidates gets candidate values of a field
+func (c ConfigInput) GetCandidates(fieldPath string) []string {
 	switch fieldPath {
 	case "/Interface":
 		ifAddrs, ifAddrsErr := net.InterfaceAddrs()
 
 		if ifAddrsErr != nil {
-			return ""
+			return nil
 		}
 
-		result = "Available network interfaces:\r\n- 0.0.0.0"
+		result := []string{"0.0.0.0"}
 
 		for idIdx := range ifAddrs {
 			ifIP, _, ifIPErr := net.ParseCIDR(ifAddrs[idIdx].String())
@@ -268,11 +296,13 @@ func (c ConfigInput) GetDescription(fieldPath string) string {
 				continue
 			}
 
-			result += "\r\n- " + ifIP.String()
+			result = append(result, ifIP.String())
 		}
 
+		return result
+
 	case "/Proxies/Codec":
-		result = "Available codecs:"
+		result := []string{}
 
 		for cIdx := range c.components {
 			codecBuilder, isCodecBuilder :=
@@ -282,13 +312,34 @@ func (c ConfigInput) GetDescription(fieldPath string) string {
 				continue
 			}
 
-			codecInfo := codecBuilder()
-
-			result += "\r\n- " + codecInfo.Name
+			result = append(result, codecBuilder().Name)
 		}
+
+		return result
 	}
 
-	return result
+	return nil
+}
+
+// GetDescription gets description
+func (c ConfigInput) GetDescription(fieldPath string) string {
+	candidates := c.GetCandidates(fieldPath)
+
+	if len(candidates) <= 0 {
+		return ""
+	}
+
+	switch fieldPath {
+	case "/Interface":
+		return "Available network interfaces:\r\n- " +
+			strings.Join(candidates, "\r\n- ")
+
+	case "/Proxies/Codec":
+		return "Available codecs:\r\n- " +
+			strings.Join(candidates, "\r\n- ")
+	}
+
+	return ""
 }
 
 // VerifyInterface Verify Interface
@@ -346,6 +397,19 @@ func (c *ConfigInput) VerifyCapacity() error {
 	return nil
 }
 
+// VerifyProxyProtocol Verify ProxyProtocol
+func (c *ConfigInput) VerifyProxyProtocol() error {
+	trusted, parseErr := tcplisten.ParseTrusted(c.ProxyProtocol)
+
+	if parseErr != nil {
+		return errors.New("Invalid trusted source: " + parseErr.Error())
+	}
+
+	c.selectedProxyProtocol = trusted
+
+	return nil
+}
+
 // Verify Verifies
 func (c *ConfigInput) Verify() error {
 	if len(c.Proxies) <= 0 {
@@ -372,6 +436,23 @@ func (c *ConfigInput) Verify() error {
 		return errors.New("Capacity must be specified")
 	}
 
+	// Values of a string slice will not be verified by field, so verify
+	// them here
+	return c.VerifyProxyProtocol()
+}
+
+// shared returns the shared Transceiver registry from components
+func (c *ConfigInput) shared() *clients.Shared {
+	for cIdx := range c.components {
+		shared, isShared := c.components[cIdx].(*clients.Shared)
+
+		if !isShared {
+			continue
+		}
+
+		return shared
+	}
+
 	return nil
 }
 
@@ -391,6 +472,9 @@ func Role() role.Registration {
 				Timeout:           0,
 				InitialTimeout:    0,
 				Capacity:          0,
+				Share:             false,
+				ProxyProtocol:     nil,
+				AccessLog:         "",
 			}
 		},
 		Generater: func(
@@ -407,33 +491,103 @@ func Role() role.Registration {
 				return nil, tTickerErr
 			}
 
-			listen := tcplisten.New(
-				cfg.selectedInterface,
-				cfg.Port,
-				tcpconn.Wrap)
+			var listen network.Listener
+
+			if len(cfg.selectedProxyProtocol) > 0 {
+				listen = tcplisten.NewProxyProtocol(
+					cfg.selectedInterface,
+					cfg.Port,
+					cfg.selectedProxyProtocol,
+					time.Duration(cfg.InitialTimeout)*time.Second,
+					tcpconn.Wrap)
+			} else {
+				listen = tcplisten.New(
+					cfg.selectedInterface,
+					cfg.Port,
+					tcpconn.Wrap)
+			}
+
+			dialers := make([]network.Dialer, len(cfg.Proxies))
+			registries := []reverse.Registry{}
 
-			clients := make([]transceiver.Client, len(cfg.Proxies))
+			for pIdx := range cfg.Proxies {
+				if !cfg.Proxies[pIdx].Reverse {
+					dialers[pIdx] = tcp.New(
+						cfg.Proxies[pIdx].Host,
+						cfg.Proxies[pIdx].Port,
+						time.Duration(
+							cfg.Proxies[pIdx].RequestTimeout)*time.Second,
+						tcpconn.Wrap)
 
-			for cIdx := range cfg.Proxies {
-				clentID := transceiver.ClientID(cIdx)
+					continue
+				}
 
-				clients[cIdx] = tclient.New(clentID, log, tcp.New(
-					cfg.Proxies[cIdx].Host,
-					cfg.Proxies[cIdx].Port,
-					time.Duration(cfg.Proxies[cIdx].RequestTimeout)*time.Second,
+				registry := reverse.NewRegistry(tcplisten.New(
+					net.ParseIP(cfg.Proxies[pIdx].Host),
+					cfg.Proxies[pIdx].Port,
 					tcpconn.Wrap,
-				), cfg.Proxies[cIdx].selectedCodec.Build(
-					cfg.Proxies[cIdx].CodecSetting,
-				), tTicker, tclient.Config{
-					MaxConcurrent:  cfg.Proxies[cIdx].Connections,
-					RequestRetries: cfg.Proxies[cIdx].RequestRetries,
-					InitialTimeout: time.Duration(
-						cfg.Proxies[cIdx].RequestTimeout) * time.Second,
-					IdleTimeout: time.Duration(
-						cfg.Proxies[cIdx].Timeout) * time.Second,
-					ConnectionPersistent: cfg.Proxies[cIdx].Persistent,
-					ConnectionChannels:   cfg.Proxies[cIdx].Channels,
-				})
+				), cfg.Proxies[pIdx].selectedCodec.Build(
+					cfg.Proxies[pIdx].CodecSetting,
+				), cfg.Proxies[pIdx].Connections, time.Duration(
+					cfg.Proxies[pIdx].RequestTimeout)*time.Second, log)
+
+				dialers[pIdx] = registry
+				registries = append(registries, registry)
+			}
+
+			buildClients := func(
+				tk ticker.Requester, l logger.Logger) []transceiver.Client {
+				clients := make([]transceiver.Client, len(cfg.Proxies))
+
+				for cIdx := range cfg.Proxies {
+					clentID := transceiver.ClientID(cIdx)
+
+					clients[cIdx] = tclient.New(clentID, l, dialers[cIdx],
+						cfg.Proxies[cIdx].selectedCodec.Build(
+							cfg.Proxies[cIdx].CodecSetting,
+						), tk, tclient.Config{
+							MaxConcurrent:  cfg.Proxies[cIdx].Connections,
+							RequestRetries: cfg.Proxies[cIdx].RequestRetries,
+							InitialTimeout: time.Duration(
+								cfg.Proxies[cIdx].RequestTimeout) * time.Second,
+							IdleTimeout: time.Duration(
+								cfg.Proxies[cIdx].Timeout) * time.Second,
+							ConnectionPersistent: cfg.Proxies[cIdx].Persistent,
+							ConnectionChannels:   cfg.Proxies[cIdx].Channels,
+						})
+				}
+
+				return clients
+			}
+
+			var balancer transceiver.Balancer
+
+			shared := cfg.shared()
+
+			if cfg.Share && shared != nil && len(registries) <= 0 {
+				sharedProxies := make(
+					[]clients.SharedProxy, len(cfg.Proxies))
+
+				for pIdx := range cfg.Proxies {
+					sharedProxies[pIdx] = clients.SharedProxy{
+						Host:           cfg.Proxies[pIdx].Host,
+						Port:           cfg.Proxies[pIdx].Port,
+						Connections:    cfg.Proxies[pIdx].Connections,
+						RequestRetries: cfg.Proxies[pIdx].RequestRetries,
+						Timeout:        cfg.Proxies[pIdx].Timeout,
+						RequestTimeout: cfg.Proxies[pIdx].RequestTimeout,
+						Channels:       cfg.Proxies[pIdx].Channels,
+						Persistent:     cfg.Proxies[pIdx].Persistent,
+						Codec:          cfg.Proxies[pIdx].Codec,
+						CodecSetting:   cfg.Proxies[pIdx].CodecSetting,
+					}
+				}
+
+				balancer = shared.Balancer(
+					sharedProxies, buildClients, maxDestinationRecords, log)
+			} else {
+				balancer = reverse.Balancer(clients.New(
+					buildClients(tTicker, log), maxDestinationRecords), registries)
 			}
 
 			var accountVerifer Authenticator
@@ -461,14 +615,38 @@ func Role() role.Registration {
 				}
 			}
 
-			return New(tTicker, clients, listen, log, Config{
+			accessLog := access.NewDitch()
+
+			if cfg.AccessLog != "" {
+				accessLog = access.NewFile(cfg.AccessLog)
+			}
+
+			datagrams := make([]datagram.Endpoint, len(cfg.Proxies))
+
+			for pIdx := range cfg.Proxies {
+				if !cfg.Proxies[pIdx].Datagram {
+					continue
+				}
+
+				datagrams[pIdx] = datagram.Endpoint{
+					Address: net.JoinHostPort(cfg.Proxies[pIdx].Host,
+						strconv.FormatUint(
+							uint64(cfg.Proxies[pIdx].Port), 10)),
+					Keepalive: datagram.Keepalive(time.Duration(
+						cfg.Proxies[pIdx].Timeout) * time.Second),
+				}
+			}
+
+			return New(tTicker, balancer, listen, log, Config{
 				Capacity: cfg.Capacity,
 				NegotiationTimeout: time.Duration(
 					cfg.InitialTimeout) * time.Second,
 				ConnectionTimeout: time.Duration(
 					cfg.Timeout) * time.Second,
-				MaxDestinationRecords: 8192,
-				Authenticator:         accountVerifer,
+				Authenticator: accountVerifer,
+				AccessLog:     accessLog,
+				MemoryLimit:   uint64(cfg.MemoryLimit) * 1024 * 1024,
+				Datagram:      datagrams,
 			}), nil
 		},
 	}
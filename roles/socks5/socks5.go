@@ -30,7 +30,6 @@ import (
 	"github.com/reinit/coward/roles/common/network"
 	"github.com/reinit/coward/roles/common/network/server"
 	"github.com/reinit/coward/roles/common/transceiver"
-	"github.com/reinit/coward/roles/common/transceiver/clients"
 	pcommon "github.com/reinit/coward/roles/proxy/common"
 	"github.com/reinit/coward/roles/socks5/common"
 )
@@ -53,13 +52,13 @@ type socks5 struct {
 // New creates a new Socks5 server
 func New(
 	ticker ticker.RequestCloser,
-	cs []transceiver.Client,
+	balancer transceiver.Balancer,
 	listener network.Listener,
 	log logger.Logger,
 	cfg Config,
 ) role.Role {
 	return &socks5{
-		clients:         clients.New(cs, cfg.MaxDestinationRecords),
+		clients:         balancer,
 		listener:        listener,
 		log:             log.Context("Socks5"),
 		cfg:             cfg,
@@ -74,6 +73,16 @@ func New(
 func (s *socks5) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 	s.unspawnNotifier = unspawnNotifier
 
+	// Open access log
+	accessOpenErr := s.cfg.AccessLog.Open()
+
+	if accessOpenErr != nil {
+		s.log.Errorf("Failed to open access log due to error: %s",
+			accessOpenErr)
+
+		return accessOpenErr
+	}
+
 	// Open transceiver client first
 	trServes, trServeErr := s.clients.Serve()
 
@@ -87,12 +96,16 @@ func (s *socks5) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 	s.transceiver = trServes
 
 	// Start Corunner
+	minWorkers := pcommon.AutomaticalMinWorkerCount(s.cfg.Capacity*2, 128)
+
 	runner, runnerServeErr := worker.New(s.log, s.ticker, worker.Config{
-		MaxWorkers: s.cfg.Capacity * 2,
-		MinWorkers: pcommon.AutomaticalMinWorkerCount(
-			s.cfg.Capacity*2, 128),
+		MaxWorkers:        s.cfg.Capacity * 2,
+		MinWorkers:        minWorkers,
 		MaxWorkerIdle:     s.cfg.ConnectionTimeout * 2,
 		JobReceiveTimeout: s.cfg.NegotiationTimeout,
+		MaxQueueLatency:   pcommon.WorkerMaxQueueLatency,
+		MemoryLimit:       s.cfg.MemoryLimit,
+		MaxQueued:         minWorkers,
 	}).Serve()
 
 	if runnerServeErr != nil {
@@ -124,6 +137,8 @@ func (s *socks5) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 		negoTimeout:   s.cfg.NegotiationTimeout,
 		timeout:       s.cfg.ConnectionTimeout,
 		authenticator: s.cfg.Authenticator,
+		access:        s.cfg.AccessLog,
+		datagrams:     s.cfg.Datagram,
 	}, s.log, s.runner, server.Config{
 		AcceptErrorWait: 100 * time.Millisecond,
 		MaxConnections:  s.cfg.Capacity,
@@ -142,6 +157,52 @@ func (s *socks5) Spawn(unspawnNotifier role.UnspawnNotifier) error {
 	return nil
 }
 
+// Drain stops accepting new connections and waits for existing ones to
+// complete, up to the timeout
+func (s *socks5) Drain(timeout time.Duration) error {
+	if s.serverServing == nil {
+		return nil
+	}
+
+	s.log.Infof("Draining")
+
+	drainErr := s.serverServing.Drain(timeout)
+
+	if drainErr != nil {
+		s.log.Warningf("Failed to drain server due to error: %s", drainErr)
+
+		return drainErr
+	}
+
+	s.log.Infof("Server is drained")
+
+	return nil
+}
+
+// Reopen reopens the access log
+func (s *socks5) Reopen() error {
+	reopenErr := s.cfg.AccessLog.Reopen()
+
+	if reopenErr != nil {
+		s.log.Warningf("Failed to reopen access log due to error: %s",
+			reopenErr)
+
+		return reopenErr
+	}
+
+	return nil
+}
+
+// Release closes the ticker when the Socks5 server will never be spawned
+func (s *socks5) Release() error {
+	if s.ticker != nil {
+		s.ticker.Close()
+		s.ticker = nil
+	}
+
+	return nil
+}
+
 func (s *socks5) Unspawn() error {
 	s.log.Infof("Closing")
 
@@ -193,6 +254,13 @@ func (s *socks5) Unspawn() error {
 		s.ticker = nil
 	}
 
+	accessCloseErr := s.cfg.AccessLog.Close()
+
+	if accessCloseErr != nil {
+		s.log.Warningf("Failed to close access log due to error: %s",
+			accessCloseErr)
+	}
+
 	s.log.Infof("Server is closed")
 
 	s.unspawnNotifier <- struct{}{}